@@ -0,0 +1,46 @@
+// Command breachindex builds the breached password corpus checked when users pick a password.
+//
+// It reads a Have I Been Pwned "ordered by hash" SHA-1 dump, or any list of HASH:COUNT lines sorted by hash,
+// and writes one range file per hash prefix into the output directory:
+//
+//	go run ./cmd/breachindex -in pwned-passwords-sha1-ordered-by-hash-v8.txt -out data/breached
+//
+// Then point BREACHED_PASSWORDS_DIR at the output directory.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/joangavelan/contacts-app/pkg/breached"
+)
+
+func main() {
+	in := flag.String("in", "-", "sorted HASH:COUNT file to read, or - for stdin")
+	out := flag.String("out", "", "directory to write the range files to")
+	flag.Parse()
+
+	if *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("Failed to open input: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	hashes, err := breached.Build(r, *out)
+	if err != nil {
+		log.Fatalf("Failed to build corpus: %v", err)
+	}
+
+	log.Printf("Indexed %d hashes into %s", hashes, *out)
+}
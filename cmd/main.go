@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
+	"github.com/joangavelan/contacts-app/config"
 	api "github.com/joangavelan/contacts-app/handlers/api"
 	pages "github.com/joangavelan/contacts-app/handlers/pages"
+	"github.com/joangavelan/contacts-app/internal/assets"
 	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/csrf"
 	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/flash"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/idempotency"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/internal/render"
+	"github.com/joangavelan/contacts-app/internal/sso"
+	"github.com/joangavelan/contacts-app/internal/webhook"
+	"github.com/joangavelan/contacts-app/pkg/breached"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
 )
 
 func main() {
@@ -21,21 +34,139 @@ func main() {
 	}
 	defer db.Close()
 
+	// Initialize mailer
+	mailer.Default, err = mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Open the breached password corpus, if configured
+	if config.BreachedPasswordsDir != "" {
+		auth.BreachedPasswords, err = breached.Open(config.BreachedPasswordsDir)
+		if err != nil {
+			log.Fatalf("Failed to open breached password corpus: %v", err)
+		}
+	}
+
+	// Promote the admins listed in the environment
+	for _, email := range config.AdminEmails {
+		promoted, err := database.PromoteAdmin(db, email)
+		if err != nil {
+			log.Fatalf("Failed to promote admin: %v", err)
+		}
+		if !promoted {
+			log.Printf("Admin %s has not registered or verified their email address yet", email)
+		}
+	}
+
+	// Read templates and assets from disk in development, so changes show up without a rebuild
+	if config.DevMode {
+		if err := render.ReadTemplatesFrom("web/templates"); err != nil {
+			log.Fatalf("Failed to parse templates: %v", err)
+		}
+		if assets.Default, err = assets.Dir("web/static"); err != nil {
+			log.Fatalf("Failed to load assets: %v", err)
+		}
+	}
+
+	// Register the identity providers users can sign in with
+	sso.Configure(config.OIDCProviders)
+
+	// Send queued webhook deliveries in the background
+	go webhook.NewDispatcher(db).Run(context.Background())
+
 	// Serve static files
-	fs := http.FileServer(http.Dir("web/static"))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	mux.Handle("GET "+assets.Prefix, assets.Default)
 
 	// Routes
 	// group - pages
 	mux.HandleFunc("GET /{$}", pages.Home)
 	mux.HandleFunc("GET /auth/login", auth.AuthPagesMiddleware(http.HandlerFunc(pages.Login)))
 	mux.HandleFunc("GET /auth/register", auth.AuthPagesMiddleware(http.HandlerFunc(pages.Register)))
-	mux.HandleFunc("GET /contacts", auth.Middleware(http.HandlerFunc(pages.Contacts)))
+	mux.HandleFunc("GET /auth/forgot-password", auth.AuthPagesMiddleware(http.HandlerFunc(pages.ForgotPassword)))
+	mux.HandleFunc("GET /auth/reset-password", pages.ResetPassword)
+	mux.HandleFunc("GET /auth/verify-email", pages.VerifyEmail)
+	mux.HandleFunc("GET /auth/verify-email/notice", auth.Middleware(http.HandlerFunc(pages.VerifyEmailNotice)))
+	mux.HandleFunc("GET /auth/magic-link", auth.AuthPagesMiddleware(http.HandlerFunc(pages.MagicLink)))
+	mux.HandleFunc("GET /auth/magic-link/sign-in", api.MagicLinkSignIn)
+	mux.HandleFunc("GET /auth/oidc/{provider}", auth.AuthPagesMiddleware(http.HandlerFunc(api.OIDCLogin)))
+	mux.HandleFunc("GET /auth/oidc/{provider}/callback", api.OIDCCallback)
+	mux.HandleFunc("GET /auth/2fa", auth.AuthPagesMiddleware(http.HandlerFunc(pages.TwoFactorChallenge)))
+	mux.HandleFunc("GET /contacts", auth.Middleware(auth.VerifiedMiddleware(http.HandlerFunc(api.ListContacts))))
+	mux.HandleFunc("GET /contacts/{id}", auth.Middleware(auth.VerifiedMiddleware(http.HandlerFunc(api.GetContact))))
+	mux.HandleFunc("GET /books", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(pages.AddressBooks)))))
+	mux.HandleFunc("GET /books/{id}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(pages.AddressBook)))))
+	mux.HandleFunc("GET /org", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(pages.Organization)))))
+	mux.HandleFunc("GET /orgs/new", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(pages.NewOrganization)))))
+	mux.HandleFunc("GET /settings", auth.Middleware(auth.RequireSession(http.HandlerFunc(pages.AccountSettings))))
+	mux.HandleFunc("GET /settings/security", auth.Middleware(auth.RequireSession(http.HandlerFunc(pages.SecuritySettings))))
+	mux.HandleFunc("GET /settings/security/sessions", auth.Middleware(auth.RequireSession(http.HandlerFunc(pages.SessionList))))
+	mux.HandleFunc("GET /settings/security/history", auth.Middleware(auth.RequireSession(http.HandlerFunc(pages.LoginHistory))))
+	mux.HandleFunc("GET /settings/2fa", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(pages.TwoFactorSettings)))))
+	mux.HandleFunc("GET /settings/tokens", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(pages.APITokens)))))
+	mux.HandleFunc("GET /settings/webhooks", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(pages.Webhooks)))))
+	mux.HandleFunc("GET /settings/webhooks/{id}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(pages.Webhook)))))
+	mux.HandleFunc("GET /admin", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(pages.AdminConsole)))))
+	mux.HandleFunc("GET /admin/users", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(pages.AdminUserList)))))
+	mux.HandleFunc("GET /admin/audit", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(pages.AuditTrail)))))
+	mux.HandleFunc("GET /admin/audit.csv", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(pages.ExportAuditTrail)))))
 	// group - api routes
 	mux.HandleFunc("POST /api/register", api.Register)
 	mux.HandleFunc("POST /api/login", api.Login)
 	mux.HandleFunc("POST /api/logout", api.Logout)
+	mux.HandleFunc("POST /api/forgot-password", api.ForgotPassword)
+	mux.HandleFunc("POST /api/reset-password", api.ResetPassword)
+	mux.HandleFunc("POST /api/magic-link", api.RequestMagicLink)
+	mux.HandleFunc("POST /api/verify-email/resend", auth.Middleware(auth.RequireSession(http.HandlerFunc(api.ResendVerification))))
+	mux.HandleFunc("POST /api/2fa", api.TwoFactorChallenge)
+	mux.HandleFunc("POST /api/2fa/enable", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.EnableTwoFactor)))))
+	mux.HandleFunc("POST /api/2fa/recovery-codes", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.RegenerateRecoveryCodes)))))
+	mux.HandleFunc("POST /api/2fa/disable", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.DisableTwoFactor)))))
+	mux.HandleFunc("POST /api/settings/username", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.UpdateUsername)))))
+	mux.HandleFunc("POST /api/settings/email", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.ChangeEmail)))))
+	mux.HandleFunc("POST /api/settings/password", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.ChangePassword)))))
+	mux.HandleFunc("POST /api/settings/delete", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.DeleteAccount)))))
+	mux.HandleFunc("DELETE /api/sessions/{id}", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.RevokeSession)))))
+	mux.HandleFunc("POST /api/tokens", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(auth.VerifiedMiddleware(http.HandlerFunc(api.CreateAPIToken))))))
+	mux.HandleFunc("DELETE /api/tokens/{id}", auth.Middleware(auth.RequireSession(http.HandlerFunc(api.RevokeAPIToken))))
+	mux.HandleFunc("POST /api/webhooks", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(auth.VerifiedMiddleware(http.HandlerFunc(api.CreateWebhook))))))
+	mux.HandleFunc("DELETE /api/webhooks/{id}", auth.Middleware(auth.RequireSession(http.HandlerFunc(api.DeleteWebhook))))
+	mux.HandleFunc("POST /api/webhooks/{id}/deliveries/{deliveryId}/redeliver", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(api.RedeliverWebhook)))))
+	mux.HandleFunc("POST /api/books", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(api.CreateAddressBook))))))
+	mux.HandleFunc("DELETE /api/books/{id}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(api.DeleteAddressBook))))))
+	mux.HandleFunc("POST /api/books/{id}/invitations", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(api.InviteToAddressBook))))))
+	mux.HandleFunc("DELETE /api/books/{id}/invitations/{invitationId}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(api.RevokeBookInvitation))))))
+	mux.HandleFunc("POST /api/books/{id}/members/{userId}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(api.UpdateBookMember))))))
+	mux.HandleFunc("DELETE /api/books/{id}/members/{userId}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(api.RemoveBookMember))))))
+	mux.HandleFunc("POST /api/book-invitations/{id}/accept", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(api.AcceptBookInvitation))))))
+	mux.HandleFunc("DELETE /api/book-invitations/{id}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(api.DeclineBookInvitation))))))
+	mux.HandleFunc("POST /api/orgs", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(api.CreateOrganization)))))
+	mux.HandleFunc("POST /api/orgs/switch", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(api.SwitchOrganization)))))
+	mux.HandleFunc("POST /api/org", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(api.UpdateOrganization)))))
+	mux.HandleFunc("POST /api/org/members", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(api.AddOrgMember)))))
+	mux.HandleFunc("POST /api/org/members/{userId}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(api.UpdateOrgMember)))))
+	mux.HandleFunc("DELETE /api/org/members/{userId}", auth.Middleware(auth.RequireSession(auth.VerifiedMiddleware(http.HandlerFunc(api.RemoveOrgMember)))))
+	mux.HandleFunc("POST /api/admin/users/{id}/disable", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(api.DisableUser)))))
+	mux.HandleFunc("POST /api/admin/users/{id}/enable", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(api.EnableUser)))))
+	mux.HandleFunc("POST /api/admin/users/{id}/reset-password", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(api.ForcePasswordReset)))))
+	mux.HandleFunc("POST /api/admin/users/{id}/role", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(api.UpdateUserRole)))))
+	mux.HandleFunc("POST /api/admin/users/{id}/impersonate", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(api.ImpersonateUser)))))
+	mux.HandleFunc("POST /api/admin/invitations", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(api.CreateInvitation)))))
+	mux.HandleFunc("DELETE /api/admin/invitations/{id}", auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, http.HandlerFunc(api.RevokeInvitation)))))
+	mux.HandleFunc("POST /api/admin/impersonation/stop", auth.Middleware(auth.RequireSession(http.HandlerFunc(api.StopImpersonation))))
+	mux.HandleFunc("GET /api/events", auth.Middleware(auth.RequireSession(http.HandlerFunc(api.Events))))
+	// group - json api routes
+	mux.HandleFunc("GET /api/v1/openapi.json", api.OpenAPI)
+	mux.HandleFunc("GET /api/v1/me", auth.Middleware(http.HandlerFunc(api.Me)))
+	mux.HandleFunc("GET /api/v1/books", auth.Middleware(auth.RequireScope(auth.ScopeContactsRead, http.HandlerFunc(api.ListAddressBooks))))
+	mux.HandleFunc("GET /api/v1/contacts", auth.Middleware(auth.VerifiedMiddleware(auth.RequireScope(auth.ScopeContactsRead, http.HandlerFunc(api.ListContacts)))))
+	mux.HandleFunc("POST /api/v1/contacts", auth.Middleware(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, auth.RequireScope(auth.ScopeContactsWrite, idempotency.Middleware(http.HandlerFunc(api.CreateContact)))))))
+	mux.HandleFunc("POST /api/v1/contacts/bulk", auth.Middleware(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, auth.RequireScope(auth.ScopeContactsWrite, idempotency.Middleware(http.HandlerFunc(api.BulkContacts)))))))
+	mux.HandleFunc("GET /api/v1/contacts/{id}", auth.Middleware(auth.VerifiedMiddleware(auth.RequireScope(auth.ScopeContactsRead, http.HandlerFunc(api.GetContact)))))
+	mux.HandleFunc("PATCH /api/v1/contacts/{id}", auth.Middleware(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, auth.RequireScope(auth.ScopeContactsWrite, http.HandlerFunc(api.UpdateContact))))))
+	mux.HandleFunc("DELETE /api/v1/contacts/{id}", auth.Middleware(auth.VerifiedMiddleware(auth.RequireRole(models.RoleUser, auth.RequireScope(auth.ScopeContactsWrite, http.HandlerFunc(api.DeleteContact))))))
 
-	// Initialize server
-	log.Fatal(http.ListenAndServe(":3000", mux))
+	// Initialize server, answering form posts made without JavaScript with redirects and full pages,
+	// and routing forms that delete or update to the route of their method
+	log.Fatal(http.ListenAndServe(":3000", csrf.Middleware(flash.Middleware(htmx.Fallback(pages.Fragment, htmx.MethodOverride(mux))))))
 }
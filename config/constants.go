@@ -3,6 +3,55 @@ package config
 import "time"
 
 const (
-	JWTExpiration    = 1 * time.Hour
-	CookieExpiration = 1 * time.Hour
+	JWTExpiration           = 1 * time.Hour
+	CookieExpiration        = 1 * time.Hour
+	PasswordResetExpiration = 30 * time.Minute
+	// Email verification links
+	EmailVerificationExpiration = 24 * time.Hour
+	VerificationResendCooldown  = 1 * time.Minute
+	// Passwordless sign-in links
+	MagicLinkExpiration = 15 * time.Minute
+	MagicLinkCooldown   = 1 * time.Minute
+	// How precisely the last activity of sessions is recorded, and how long login attempts are kept
+	SessionLastSeenResolution = 1 * time.Minute
+	LoginHistoryRetention     = 90 * 24 * time.Hour
+	// Time allowed to enter the second factor after a correct password
+	TwoFactorChallengeExpiration = 5 * time.Minute
+	// Failed logins per account before backoff delays start, and before the account is locked out
+	LoginFreeAttempts    = 3
+	LoginLockoutAttempts = 10
+	LoginLockoutDuration = 15 * time.Minute
+	// Failed logins per client IP, which may be shared by many users behind a NAT
+	LoginIPFreeAttempts    = 20
+	LoginIPLockoutAttempts = 100
+	LoginIPLockoutDuration = 1 * time.Hour
+	LoginBackoffBase       = 1 * time.Second
+	LoginBackoffMax        = 1 * time.Minute
+	LoginFailureWindow     = 1 * time.Hour
+	// Minimum strength score (0-4) of new passwords
+	MinPasswordScore = 3
+	// How precisely the last use of API tokens is recorded
+	APITokenLastUsedResolution = 1 * time.Minute
+	// Time allowed to sign in at an identity provider and come back
+	OIDCStateExpiration = 10 * time.Minute
+	// How long responses to requests with an Idempotency-Key are kept for retries
+	IdempotencyKeyExpiration = 24 * time.Hour
+	// Invitations to register can be used at most this many times
+	InvitationMaxUses = 1000
+	// How long invitations to join an address book can be accepted
+	BookInvitationExpiration = 14 * 24 * time.Hour
+	// How long an admin can stay logged in as another user
+	ImpersonationExpiration = 1 * time.Hour
+	// Webhook deliveries: how often the queue is checked, how long receivers have to respond,
+	// how often failed deliveries are retried with exponential backoff, and how long the delivery log is kept
+	WebhookPollInterval      = 5 * time.Second
+	WebhookTimeout           = 10 * time.Second
+	WebhookMaxAttempts       = 8
+	WebhookBackoffBase       = 30 * time.Second
+	WebhookBackoffMax        = 1 * time.Hour
+	WebhookDeliveryRetention = 30 * 24 * time.Hour
+	// Live updates: how often idle event streams are kept alive, and how long a stream lasts
+	// before the browser reconnects, which checks the session again
+	LiveHeartbeatInterval = 30 * time.Second
+	LiveStreamDuration    = 10 * time.Minute
 )
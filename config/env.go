@@ -0,0 +1,110 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AppURL is the public base URL of the application, used to build links sent by email.
+// It is read from the environment rather than the request Host header so links can't be poisoned.
+var AppURL = strings.TrimRight(getEnv("APP_URL", "http://localhost:3000"), "/")
+
+// TrustProxy makes the app take the client IP from the X-Forwarded-For header set by a reverse proxy.
+// It must only be enabled when the app is not reachable without going through the proxy.
+var TrustProxy = getEnv("TRUST_PROXY", "false") == "true"
+
+// DevMode reads templates and static assets from disk rather than the binary, loading them again whenever
+// they change, so edits show up on the next request. It is meant for development, from the root of the repository.
+var DevMode = getEnv("DEV_MODE", "false") == "true"
+
+// BreachedPasswordsDir is the directory of the breached password corpus new passwords are checked against.
+// The check is skipped when it's empty. See pkg/breached for the expected layout.
+var BreachedPasswordsDir = getEnv("BREACHED_PASSWORDS_DIR", "")
+
+// Registration modes.
+const (
+	RegistrationOpen       = "open"
+	RegistrationInviteOnly = "invite_only"
+	RegistrationClosed     = "closed"
+)
+
+// RegistrationMode controls who can create an account: anyone, only people invited by an admin, or nobody.
+// It is read from REGISTRATION_MODE and applies to signing in with an identity provider for the first time too.
+var RegistrationMode = loadRegistrationMode()
+
+// AdminEmails are promoted to admins at startup, once they registered and verified the address.
+// They are listed in ADMIN_EMAILS, separated by commas. Further admins can be appointed from the admin console.
+var AdminEmails = splitList(getEnv("ADMIN_EMAILS", ""))
+
+// Argon2id parameters for new password hashes. Existing hashes are upgraded on the next successful login
+// whenever these change. Memory is in KiB, between 8 MiB and 4 GiB, which leaves argon2id the 8 KiB per lane it
+// needs at the highest parallelism.
+var (
+	Argon2Memory      = uint32(getEnvIntInRange("ARGON2_MEMORY", 64*1024, 8*1024, 4*1024*1024))
+	Argon2Iterations  = uint32(getEnvIntInRange("ARGON2_ITERATIONS", 3, 1, 100))
+	Argon2Parallelism = uint8(getEnvIntInRange("ARGON2_PARALLELISM", 2, 1, 255))
+)
+
+// Argon2MemoryLimit caps the memory in KiB that passwords being hashed or verified may take at once, between
+// 8 MiB and 64 GiB. Every computation takes Argon2Memory, so Argon2Concurrency of them run in parallel and the
+// others, e.g. of a burst of login attempts, wait for their turn rather than exhausting the server's memory.
+// At least one runs at a time, even when the limit is below Argon2Memory.
+var (
+	Argon2MemoryLimit = uint32(getEnvIntInRange("ARGON2_MEMORY_LIMIT", 512*1024, 8*1024, 64*1024*1024))
+	Argon2Concurrency = max(1, int(Argon2MemoryLimit/Argon2Memory))
+)
+
+// getEnv returns the value of the environment variable or fallback when it is unset.
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt returns the integer value of the environment variable or fallback when it is unset.
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Fatalf("Invalid value for %s: %q", key, value)
+	}
+	return n
+}
+
+// getEnvIntInRange is like getEnvInt but refuses to start when the value is outside [min, max].
+func getEnvIntInRange(key string, fallback, min, max int) int {
+	n := getEnvInt(key, fallback)
+	if n < min || n > max {
+		log.Fatalf("Invalid value for %s: %d is not between %d and %d", key, n, min, max)
+	}
+	return n
+}
+
+// splitList splits a comma-separated list, ignoring blank entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func loadRegistrationMode() string {
+	mode := getEnv("REGISTRATION_MODE", RegistrationOpen)
+	switch mode {
+	case RegistrationOpen, RegistrationInviteOnly, RegistrationClosed:
+		return mode
+	}
+
+	log.Fatalf("Invalid value for REGISTRATION_MODE: %q", mode)
+	return ""
+}
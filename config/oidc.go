@@ -0,0 +1,79 @@
+package config
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// OIDCProvider configures an identity provider users can sign in with.
+type OIDCProvider struct {
+	// Name identifies the provider in URLs, like /auth/oidc/google.
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// RedirectURL is where the provider sends users back after they sign in.
+func (p OIDCProvider) RedirectURL() string {
+	return AppURL + "/auth/oidc/" + p.Name + "/callback"
+}
+
+// OIDCProviders are offered on the login page, in order. They are listed in OIDC_PROVIDERS, e.g. "google,github",
+// and each is configured with OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and, except for Google and GitHub,
+// OIDC_<NAME>_ISSUER. OIDC_<NAME>_DISPLAY_NAME and OIDC_<NAME>_SCOPES are optional.
+var OIDCProviders = loadOIDCProviders()
+
+var oidcProviderNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Well-known providers that only need client credentials.
+var oidcProviderDefaults = map[string]OIDCProvider{
+	"google": {DisplayName: "Google", Issuer: "https://accounts.google.com"},
+	"github": {DisplayName: "GitHub", Scopes: []string{"read:user", "user:email"}},
+}
+
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !oidcProviderNamePattern.MatchString(name) {
+			log.Fatalf("Invalid OIDC provider name %q: use lowercase letters, digits and dashes", name)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		defaults := oidcProviderDefaults[name]
+
+		provider := OIDCProvider{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", defaults.DisplayName),
+			Issuer:       getEnv(prefix+"ISSUER", defaults.Issuer),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       defaults.Scopes,
+		}
+		if scopes := getEnv(prefix+"SCOPES", ""); scopes != "" {
+			provider.Scopes = strings.Fields(scopes)
+		}
+		if provider.DisplayName == "" {
+			provider.DisplayName = strings.ToUpper(name[:1]) + name[1:]
+		}
+
+		if provider.ClientID == "" || provider.ClientSecret == "" {
+			log.Fatalf("Missing %sCLIENT_ID or %sCLIENT_SECRET", prefix, prefix)
+		}
+		if provider.Issuer == "" && name != "github" {
+			log.Fatalf("Missing %sISSUER", prefix)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers
+}
@@ -7,3 +7,5 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.22
 	golang.org/x/crypto v0.24.0
 )
+
+require golang.org/x/sys v0.21.0 // indirect
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// UpdateUsername changes the logged in user's username.
+// The session is reissued since the username is one of its claims.
+func UpdateUsername(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	usernameForm := models.UsernameForm{}
+	usernameForm.Values.Username = strings.TrimSpace(r.FormValue("username"))
+
+	if !auth.IsValidUsername(usernameForm.Values.Username) {
+		usernameForm.Errors.Username = fmt.Sprintf("Username must be between %d and %d characters long", auth.MinUsernameLength, auth.MaxUsernameLength)
+	}
+
+	if usernameForm.HasErrors() {
+		renderAccountForm(w, r, "username-form", usernameForm)
+		return
+	}
+
+	if err := database.UpdateUsername(database.DB, user.Id, usernameForm.Values.Username); err != nil {
+		log.Printf("Error updating username: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	user.Username = usernameForm.Values.Username
+
+	if err := refreshSession(w, r, user); err != nil {
+		log.Printf("Error refreshing session: %v", err)
+		http.Error(w, "Error generating JWT", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("Username updated").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	renderAccountForm(w, r, "username-form", usernameForm)
+}
+
+// ChangeEmail changes the logged in user's email address after confirming their password.
+// The new address must be verified before the user can use the app again.
+func ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if !allowPasswordConfirmation(w, r, user) {
+		return
+	}
+
+	changeEmailForm := models.ChangeEmailForm{}
+	changeEmailForm.Values.Email = strings.TrimSpace(r.FormValue("email"))
+	password := strings.TrimSpace(r.FormValue("password"))
+
+	if !auth.IsValidEmail(changeEmailForm.Values.Email) {
+		changeEmailForm.Errors.Email = "Invalid email address"
+	} else if changeEmailForm.Values.Email == user.Email {
+		changeEmailForm.Errors.Email = "This is already your email address"
+	}
+
+	if !confirmPassword(r, user, password) {
+		changeEmailForm.Errors.Password = "Incorrect password"
+	}
+
+	if !changeEmailForm.HasErrors() {
+		exists, err := database.EmailExists(database.DB, changeEmailForm.Values.Email)
+		if err != nil {
+			log.Printf("Error checking email existence: %v", err)
+			http.Error(w, "Error checking email existence", http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			changeEmailForm.Errors.Email = "Email address already registered"
+		}
+	}
+
+	if changeEmailForm.HasErrors() {
+		renderAccountForm(w, r, "email-form", changeEmailForm)
+		return
+	}
+
+	if err := auth.ChangeEmail(database.DB, r, mailer.Default, user, changeEmailForm.Values.Email); err != nil {
+		log.Printf("Error changing email: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The session carries the email address as a claim.
+	if err := refreshSession(w, r, user); err != nil {
+		log.Printf("Error refreshing session: %v", err)
+		http.Error(w, "Error generating JWT", http.StatusInternalServerError)
+		return
+	}
+
+	htmx.Redirect(w, r, "/auth/verify-email/notice")
+}
+
+// ChangePassword changes the logged in user's password after confirming their current one.
+// Every other session of the user is ended.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if !allowPasswordConfirmation(w, r, user) {
+		return
+	}
+
+	changePasswordForm := models.ChangePasswordForm{}
+	currentPassword := strings.TrimSpace(r.FormValue("current_password"))
+	newPassword := strings.TrimSpace(r.FormValue("new_password"))
+
+	if !confirmPassword(r, user, currentPassword) {
+		changePasswordForm.Errors.CurrentPassword = "Incorrect password"
+	}
+
+	changePasswordForm.Errors.NewPassword = auth.CheckNewPassword(newPassword, user.Username, user.Email)
+
+	// Passwords are never rendered back into the form.
+	if changePasswordForm.HasErrors() {
+		renderAccountForm(w, r, "password-form", changePasswordForm)
+		return
+	}
+
+	if err := auth.ChangePassword(database.DB, user, newPassword); err != nil {
+		log.Printf("Error changing password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := auth.RecordUserAudit(database.DB, r, user, models.AuditPasswordChanged, nil); err != nil {
+		log.Printf("Error recording audit event: %v", err)
+	}
+
+	// Keep this session, which was issued before the change.
+	if err := refreshSession(w, r, user); err != nil {
+		log.Printf("Error refreshing session: %v", err)
+		http.Error(w, "Error generating JWT", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("Password changed, your other sessions were signed out").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	renderAccountForm(w, r, "password-form", models.ChangePasswordForm{})
+}
+
+// DeleteAccount deletes the logged in user and all of their contacts after confirming their password.
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if !allowPasswordConfirmation(w, r, user) {
+		return
+	}
+
+	deleteAccountForm := models.DeleteAccountForm{}
+	if !confirmPassword(r, user, strings.TrimSpace(r.FormValue("password"))) {
+		deleteAccountForm.Errors.Password = "Incorrect password"
+		renderAccountForm(w, r, "delete-form", deleteAccountForm)
+		return
+	}
+
+	audit, err := auth.NewUserAuditEvent(r, user, models.AuditAccountDeleted, map[string]any{"email": user.Email})
+	if err != nil {
+		log.Printf("Error describing audit event: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.DeleteUser(database.DB, user.Id, audit)
+	if errors.Is(err, database.ErrSoleBookOwner) {
+		if err := toast.Error("Make someone else an owner of your shared address books, or delete them, first").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		renderAccountForm(w, r, "delete-form", models.DeleteAccountForm{})
+		return
+	}
+	if errors.Is(err, database.ErrLastOrgAdmin) {
+		if err := toast.Error("Make someone else an admin of your organizations first").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		renderAccountForm(w, r, "delete-form", models.DeleteAccountForm{})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	endSession(w)
+	htmx.Redirect(w, r, "/")
+}
+
+// allowPasswordConfirmation rejects the request if the user made too many wrong guesses,
+// whether while logging in or while confirming a change.
+func allowPasswordConfirmation(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	if retryAfter := auth.LoginRetryAfter(user.Email, auth.ClientIP(r)); retryAfter > 0 {
+		tooManyLoginAttempts(w, retryAfter)
+		return false
+	}
+	return true
+}
+
+// confirmPassword checks the password the user entered to confirm a sensitive change.
+// Wrong guesses count as failed logins, so a hijacked session can't be used to guess the password.
+func confirmPassword(r *http.Request, user *models.User, password string) bool {
+	if auth.CheckPasswordHash(password, user.Password) == nil {
+		return true
+	}
+
+	auth.RecordLoginFailure(user.Email, auth.ClientIP(r))
+	return false
+}
+
+func renderAccountForm(w http.ResponseWriter, r *http.Request, name string, form any) {
+	tmpl := parsePartial(r, "pages/settings/account/"+name+".html")
+	if err := tmpl.Execute(w, form); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,541 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+const maxBookNameLength = 50
+
+// invitationToastDuration is how long invitees are shown the toast about a new invitation, which they can dismiss.
+const invitationToastDuration = 8 * time.Second
+
+// bookMemberRow is a member as listed on the page of an address book.
+type bookMemberRow struct {
+	models.AddressBookMember
+	// Manage is set when the user viewing the book owns it and the member isn't themselves,
+	// who leave the book instead of changing their own permission.
+	Manage bool
+	// Fixed is set for the address book of an organization, which members only leave along with the organization.
+	Fixed bool
+}
+
+// bookInvitationFormView is the invitation form along with the book it invites to.
+type bookInvitationFormView struct {
+	BookId int64
+	Form   models.BookInvitationForm
+}
+
+// ListAddressBooks returns the address books of the workspace the user is a member of, its default book first.
+func ListAddressBooks(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		jsonapi.WriteError(w, http.StatusInternalServerError, "internal_error", "Could not retrieve user information")
+		return
+	}
+
+	books, err := database.ForTenant(database.DB, user.OrgId).ListAddressBooks(user.Id)
+	if err != nil {
+		log.Printf("Error listing address books: %v", err)
+		jsonapi.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return
+	}
+	if books == nil {
+		books = []models.AddressBook{}
+	}
+
+	jsonapi.Write(w, http.StatusOK, struct {
+		Data []models.AddressBook `json:"data"`
+	}{books})
+}
+
+// CreateAddressBook creates a shared address book owned by the user and takes them to it.
+// Organizations may leave creating books to their admins.
+func CreateAddressBook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	if user.OrgId != 0 {
+		org, err := database.GetUserOrganization(database.DB, user.Id, user.OrgId)
+		if err != nil {
+			log.Printf("Error retrieving organization: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if org == nil || !org.CanCreateBooks() {
+			if err := toast.Error("Only admins can create address books in this organization").WriteToHeader(w); err != nil {
+				log.Printf("Error writing toast event: %v", err)
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	addressBookForm := models.AddressBookForm{}
+	addressBookForm.Values.Name = strings.TrimSpace(r.FormValue("name"))
+
+	if addressBookForm.Values.Name == "" {
+		addressBookForm.Errors.Name = "Name is required"
+	} else if utf8.RuneCountInString(addressBookForm.Values.Name) > maxBookNameLength {
+		addressBookForm.Errors.Name = fmt.Sprintf("Name must be at most %d characters", maxBookNameLength)
+	}
+
+	if addressBookForm.HasErrors() {
+		tmpl := parsePartial(r, "pages/books/book-form.html")
+		if err := tmpl.Execute(w, addressBookForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	book := &models.AddressBook{Name: addressBookForm.Values.Name, CreatedAt: time.Now().UTC()}
+	if err := database.ForTenant(database.DB, user.OrgId).CreateAddressBook(user.Id, book); err != nil {
+		log.Printf("Error creating address book: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	htmx.Redirect(w, r, fmt.Sprintf("/books/%d", book.Id))
+}
+
+// DeleteAddressBook deletes a shared address book along with its contacts. Only owners can delete a book,
+// and the address book of an organization goes only with the organization.
+func DeleteAddressBook(w http.ResponseWriter, r *http.Request) {
+	user, book, ok := ownedBookFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if book.Default {
+		http.Error(w, "The address book of an organization can't be deleted", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := database.ForTenant(database.DB, user.OrgId).DeleteAddressBook(book.Id)
+	if err != nil {
+		log.Printf("Error deleting address book: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Address book not found", http.StatusNotFound)
+		return
+	}
+
+	htmx.Redirect(w, r, "/books")
+}
+
+// InviteToAddressBook invites an email address to join a shared address book.
+// The books of an organization can only be shared with its members.
+func InviteToAddressBook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	user, book, ok := ownedBookFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	invitationForm := models.BookInvitationForm{}
+	invitationForm.Values.Email = strings.TrimSpace(r.FormValue("email"))
+	invitationForm.Values.Permission = r.FormValue("permission")
+
+	if !auth.IsValidEmail(invitationForm.Values.Email) {
+		invitationForm.Errors.Email = "Invalid email address"
+	}
+	if !models.IsValidBookPermission(invitationForm.Values.Permission) {
+		invitationForm.Errors.Permission = "Invalid permission"
+	}
+
+	tenant := database.ForTenant(database.DB, user.OrgId)
+
+	if !invitationForm.HasErrors() && user.OrgId != 0 {
+		orgMember, err := database.OrgMemberEmailExists(database.DB, user.OrgId, invitationForm.Values.Email)
+		if err != nil {
+			log.Printf("Error checking organization members: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !orgMember {
+			invitationForm.Errors.Email = "Not a member of this organization"
+		}
+	}
+
+	if !invitationForm.HasErrors() {
+		member, err := tenant.BookMemberEmailExists(book.Id, invitationForm.Values.Email)
+		if err != nil {
+			log.Printf("Error checking address book members: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if member {
+			invitationForm.Errors.Email = "Already a member of this address book"
+		}
+	}
+
+	var invitation *models.AddressBookInvitation
+	if !invitationForm.HasErrors() {
+		var err error
+		invitation, err = auth.InviteToAddressBook(tenant, mailer.Default, user, book, invitationForm.Values.Email, invitationForm.Values.Permission)
+		if errors.Is(err, database.ErrBookInvitationExists) {
+			invitationForm.Errors.Email = "Already invited, revoke the pending invitation to send a new one"
+		} else if errors.Is(err, database.ErrAddressBookNotFound) {
+			http.Error(w, "Address book not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Error creating address book invitation: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Render form with errors and submitted values if validation fails.
+	if invitationForm.HasErrors() {
+		tmpl := parsePartial(r, "pages/books/invitation-form.html")
+		if err := tmpl.Execute(w, bookInvitationFormView{BookId: book.Id, Form: invitationForm}); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := toast.Success("Invitation sent to " + invitation.Email).WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+
+	// Let the invitee know right away if they have the app open.
+	invitee, err := database.GetUserByEmail(database.DB, invitation.Email)
+	if err != nil {
+		log.Printf("Error retrieving invitee: %v", err)
+	} else if invitee != nil {
+		notifyUser(invitee.Id, toast.Info(user.Username+" invited you to "+book.Name).
+			WithTitle("Address book invitation").WithDuration(invitationToastDuration).AsDismissible())
+	}
+
+	// Render a blank form, and add the invitation to the list out of band.
+	tmpl := parsePartial(r,
+		"pages/books/invitation-sent.html",
+		"pages/books/invitation-form.html",
+		"pages/books/invitation-row.html",
+	)
+	data := struct {
+		InvitationForm bookInvitationFormView
+		Invitation     *models.AddressBookInvitation
+	}{
+		InvitationForm: bookInvitationFormView{
+			BookId: book.Id,
+			Form:   models.BookInvitationForm{Values: models.BookInvitationFormFields{Permission: invitation.Permission}},
+		},
+		Invitation: invitation,
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// RevokeBookInvitation withdraws a pending invitation to join an address book.
+func RevokeBookInvitation(w http.ResponseWriter, r *http.Request) {
+	user, book, ok := ownedBookFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("invitationId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := database.ForTenant(database.DB, user.OrgId).RevokeBookInvitation(book.Id, id)
+	if err != nil {
+		log.Printf("Error revoking address book invitation: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	if err := toast.Success("Invitation revoked").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// UpdateBookMember changes the permission of another member of an address book.
+// A book always keeps at least one owner.
+func UpdateBookMember(w http.ResponseWriter, r *http.Request) {
+	user, book, member, ok := bookMemberFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if member.UserId == user.Id {
+		if err := toast.Error("Ask another owner to change your permission").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Members can't change their own permission", http.StatusForbidden)
+		return
+	}
+
+	permission := r.FormValue("permission")
+	if !models.IsValidBookPermission(permission) {
+		http.Error(w, "Invalid permission", http.StatusBadRequest)
+		return
+	}
+
+	_, err := database.ForTenant(database.DB, user.OrgId).UpdateBookMember(book.Id, member.UserId, permission)
+	if errors.Is(err, database.ErrLastBookOwner) {
+		// Render the row unchanged, so the permission shown is the one the member still has.
+		if err := toast.Error("An address book needs at least one owner").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		renderBookMemberRow(w, r, book, member)
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating address book member: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	member.Permission = permission
+	if err := toast.Success(member.Username + " is now " + strings.ToLower(member.PermissionLabel())).WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	renderBookMemberRow(w, r, book, member)
+}
+
+// RemoveBookMember takes a member's access to an address book away. Owners can remove other members,
+// and any member can remove themselves to leave the book, unless they are its last owner.
+// Everyone in an organization keeps access to its address book until they leave the organization.
+func RemoveBookMember(w http.ResponseWriter, r *http.Request) {
+	user, book, member, ok := bookMemberFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if book.Default {
+		if err := toast.Error("Members of the organization can't be removed from its address book").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	removed, err := database.ForTenant(database.DB, user.OrgId).RemoveBookMember(book.Id, member.UserId)
+	if errors.Is(err, database.ErrLastBookOwner) {
+		if err := toast.Error("Make someone else an owner before leaving, or delete the address book").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Address book needs an owner", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error removing address book member: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	if member.UserId == user.Id {
+		htmx.Redirect(w, r, "/books")
+		return
+	}
+
+	if err := toast.Success(member.Username + " was removed").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// AcceptBookInvitation makes the user a member of the address book an invitation to their email address is for.
+func AcceptBookInvitation(w http.ResponseWriter, r *http.Request) {
+	user, id, ok := bookInvitationFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	invitation, err := database.ForTenant(database.DB, user.OrgId).AcceptBookInvitation(id, user.Id, user.Email, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error accepting address book invitation: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if invitation == nil {
+		if err := toast.Error("This invitation has expired or was revoked").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	htmx.Redirect(w, r, fmt.Sprintf("/books/%d", invitation.BookId))
+}
+
+// DeclineBookInvitation deletes an invitation sent to the user's email address.
+func DeclineBookInvitation(w http.ResponseWriter, r *http.Request) {
+	user, id, ok := bookInvitationFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	declined, err := database.ForTenant(database.DB, user.OrgId).DeclineBookInvitation(id, user.Email)
+	if err != nil {
+		log.Printf("Error declining address book invitation: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !declined {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	if err := toast.Success("Invitation declined").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// bookFromPath returns the user making the request and the address book in the path,
+// which must belong to the workspace they work in and which they must be a member of.
+func bookFromPath(w http.ResponseWriter, r *http.Request) (*models.UserContext, *models.AddressBook, bool) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid address book ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	book, err := database.ForTenant(database.DB, user.OrgId).GetAddressBook(user.Id, id)
+	if err != nil {
+		log.Printf("Error retrieving address book: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if book == nil {
+		http.Error(w, "Address book not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return user, book, true
+}
+
+// ownedBookFromPath is like bookFromPath, but also requires the user to own the book, and the book to be shared.
+func ownedBookFromPath(w http.ResponseWriter, r *http.Request) (*models.UserContext, *models.AddressBook, bool) {
+	user, book, ok := bookFromPath(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if book.Personal {
+		http.Error(w, "Personal address books can't be shared", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	if !book.IsOwner() {
+		if err := toast.Error("Only owners can manage this address book").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return user, book, true
+}
+
+// bookMemberFromPath returns the user making the request, the address book and the member in the path.
+// Owners can act on other members, and everyone on themselves.
+func bookMemberFromPath(w http.ResponseWriter, r *http.Request) (*models.UserContext, *models.AddressBook, *models.AddressBookMember, bool) {
+	user, book, ok := bookFromPath(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	userId, err := strconv.ParseInt(r.PathValue("userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+
+	if userId != user.Id && !book.IsOwner() {
+		if err := toast.Error("Only owners can manage this address book").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, nil, nil, false
+	}
+
+	member, err := database.ForTenant(database.DB, user.OrgId).GetBookMember(book.Id, userId)
+	if err != nil {
+		log.Printf("Error retrieving address book member: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+	if member == nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+
+	return user, book, member, true
+}
+
+// bookInvitationFromPath returns the user making the request and the ID of the address book invitation in the path.
+func bookInvitationFromPath(w http.ResponseWriter, r *http.Request) (*models.UserContext, int64, bool) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	return user, id, true
+}
+
+// renderBookMemberRow answers htmx with the row of a member, and plain requests by going back to the book.
+func renderBookMemberRow(w http.ResponseWriter, r *http.Request, book *models.AddressBook, member *models.AddressBookMember) {
+	if htmx.Back(w, r) {
+		return
+	}
+	tmpl := parsePartial(r, "pages/books/member-row.html")
+	if err := tmpl.Execute(w, bookMemberRow{AddressBookMember: *member, Manage: true, Fixed: book.Default}); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// adminTokenCookieName holds the admin's own session while they are logged in as another user.
+const adminTokenCookieName = "admin_token"
+
+type adminUserRow struct {
+	models.User
+	// Self is set for the admin viewing the console, who can't act on their own account from it.
+	Self bool
+}
+
+// DisableUser keeps a user from logging in and logs them out everywhere.
+func DisableUser(w http.ResponseWriter, r *http.Request) {
+	admin, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	audit, ok := adminAudit(w, r, admin, models.AuditUserDisabled, target, nil)
+	if !ok {
+		return
+	}
+
+	if _, err := database.DisableUser(database.DB, target.Id, time.Now().UTC().Truncate(time.Second), audit); err != nil {
+		log.Printf("Error disabling user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	adminActionDone(w, r, target.Id, target.Username+" was disabled")
+}
+
+// EnableUser lets a disabled user log in again.
+func EnableUser(w http.ResponseWriter, r *http.Request) {
+	admin, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	audit, ok := adminAudit(w, r, admin, models.AuditUserEnabled, target, nil)
+	if !ok {
+		return
+	}
+
+	if _, err := database.EnableUser(database.DB, target.Id, audit); err != nil {
+		log.Printf("Error enabling user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	adminActionDone(w, r, target.Id, target.Username+" was enabled")
+}
+
+// ForcePasswordReset makes a user choose a new password through a link sent to their email address.
+func ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	admin, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := auth.ForcePasswordReset(database.DB, r, mailer.Default, admin, target); err != nil {
+		log.Printf("Error forcing password reset: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	adminActionDone(w, r, target.Id, target.Username+" must now reset their password")
+}
+
+// UpdateUserRole gives a user another role. Their sessions carry the old role and are ended.
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	admin, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if !models.IsValidRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	audit, ok := adminAudit(w, r, admin, models.AuditUserRoleChanged, target, map[string]any{"from": target.Role, "to": role})
+	if !ok {
+		return
+	}
+
+	if _, err := database.UpdateUserRole(database.DB, target.Id, role, audit); err != nil {
+		log.Printf("Error updating role: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	adminActionDone(w, r, target.Id, target.Username+" is now "+models.RoleLabel(role))
+}
+
+// ImpersonateUser logs the admin in as another user, keeping their own session to return to.
+func ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	admin, target, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	adminToken, err := r.Cookie("token")
+	if err != nil {
+		http.Error(w, "Session cookie required", http.StatusBadRequest)
+		return
+	}
+
+	tokenString, err := auth.StartImpersonation(database.DB, r, admin, target)
+	if errors.Is(err, auth.ErrCannotImpersonate) {
+		if err := toast.Error("Admins and disabled users can't be impersonated").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "User can't be impersonated", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting impersonation: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminTokenCookieName,
+		Value:    adminToken.Value,
+		Path:     "/",
+		Expires:  time.Now().Add(config.ImpersonationExpiration),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	setSessionCookie(w, tokenString)
+
+	htmx.Redirect(w, r, "/contacts")
+}
+
+// StopImpersonation ends the admin's session as another user and brings them back to the admin console.
+func StopImpersonation(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	if !userCtx.IsImpersonated() {
+		http.Error(w, "Not impersonating a user", http.StatusBadRequest)
+		return
+	}
+
+	if err := auth.StopImpersonation(database.DB, r, userCtx); err != nil {
+		log.Printf("Error stopping impersonation: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The admin's own session may have expired in the meantime, in which case they log in again.
+	cookie, err := r.Cookie(adminTokenCookieName)
+	clearAdminToken(w)
+	if err != nil {
+		endSession(w)
+		htmx.Redirect(w, r, "/auth/login")
+		return
+	}
+
+	setSessionCookie(w, cookie.Value)
+	htmx.Redirect(w, r, "/admin")
+}
+
+// adminTarget returns the admin making the request and the user they act on.
+// Admins can't act on their own account from the console, so they can't lock themselves out.
+func adminTarget(w http.ResponseWriter, r *http.Request) (*models.UserContext, *models.User, bool) {
+	admin, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	if id == admin.Id {
+		if err := toast.Error("Use your account settings to change your own account").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Admins can't act on their own account", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	target, err := database.GetUserById(database.DB, id)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if target == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return admin, target, true
+}
+
+// adminAudit describes an admin action for the database to record along with the change.
+func adminAudit(w http.ResponseWriter, r *http.Request, admin *models.UserContext, action string, target *models.User, detail map[string]any) (*models.AuditEvent, bool) {
+	audit, err := auth.NewAuditEvent(r, admin, action, target, detail)
+	if err != nil {
+		log.Printf("Error describing audit event: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return audit, true
+}
+
+// adminActionDone confirms an action and renders the updated row of the user it was taken on,
+// or goes back to the console for plain requests.
+func adminActionDone(w http.ResponseWriter, r *http.Request, userId int64, message string) {
+	user, err := database.GetUserById(database.DB, userId)
+	if err != nil || user == nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success(message).WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	auditTrailChanged(w)
+	if htmx.Back(w, r) {
+		return
+	}
+
+	tmpl := parsePartial(r, "pages/admin/user-row.html")
+	if err := tmpl.Execute(w, adminUserRow{User: *user}); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// clearAdminToken expires the cookie holding the admin's own session.
+func clearAdminToken(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminTokenCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// auditTrailChanged reloads the audit trail of the admin console, which listens for the event,
+// once the response has settled.
+func auditTrailChanged(w http.ResponseWriter) {
+	if err := toast.Collect(w).TriggerAt(toast.AfterSettle, "auditTrailChanged", nil); err != nil {
+		log.Printf("Error writing trigger event: %v", err)
+	}
+}
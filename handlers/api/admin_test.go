@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// loginAs starts a password session for the user and returns its cookie.
+func loginAs(t *testing.T, userId int64) *http.Cookie {
+	t.Helper()
+
+	user, _ := database.GetUserById(database.DB, userId)
+	token, err := auth.StartSession(database.DB, httptest.NewRequest("POST", "/api/login", nil), user, models.LoginMethodPassword)
+	if err != nil {
+		t.Fatalf("expected no error starting a session, got %v", err)
+	}
+
+	return &http.Cookie{Name: "token", Value: token}
+}
+
+func cookieValue(rec *httptest.ResponseRecorder, name string) string {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func adminTestMux() *http.ServeMux {
+	admin := func(h http.HandlerFunc) http.HandlerFunc {
+		return auth.Middleware(auth.RequireSession(auth.RequireRole(models.RoleAdmin, h)))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/me", auth.Middleware(http.HandlerFunc(Me)))
+	mux.HandleFunc("DELETE /api/v1/contacts/{id}", auth.Middleware(auth.RequireRole(models.RoleUser, http.HandlerFunc(DeleteContact))))
+	mux.HandleFunc("POST /api/settings/delete", auth.Middleware(auth.RequireSession(auth.DenyImpersonation(http.HandlerFunc(DeleteAccount)))))
+	mux.HandleFunc("POST /api/admin/users/{id}/disable", admin(DisableUser))
+	mux.HandleFunc("POST /api/admin/users/{id}/enable", admin(EnableUser))
+	mux.HandleFunc("POST /api/admin/users/{id}/reset-password", admin(ForcePasswordReset))
+	mux.HandleFunc("POST /api/admin/users/{id}/role", admin(UpdateUserRole))
+	mux.HandleFunc("POST /api/admin/users/{id}/impersonate", admin(ImpersonateUser))
+	mux.HandleFunc("POST /api/admin/impersonation/stop", auth.Middleware(http.HandlerFunc(StopImpersonation)))
+	return mux
+}
+
+func serve(mux *http.ServeMux, method, path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	r.Header.Set("HX-Request", "true")
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRoles(t *testing.T) {
+	db := openTestDB(t)
+	mux := adminTestMux()
+
+	viewerId, _ := database.CreateUser(db, "auditor", "auditor@example.com", "password-hash")
+	database.UpdateUserRole(db, viewerId, models.RoleReadOnly, nil)
+	viewer := loginAs(t, viewerId)
+
+	t.Run("read-only users can't write", func(t *testing.T) {
+		if rec := serve(mux, "GET", "/api/v1/me", viewer); rec.Code != http.StatusOK {
+			t.Errorf("expected read-only users to read, got %d", rec.Code)
+		}
+		if rec := serve(mux, "DELETE", "/api/v1/contacts/1", viewer); rec.Code != http.StatusForbidden {
+			t.Errorf("expected read-only users not to write, got %d", rec.Code)
+		}
+	})
+
+	t.Run("role changes end sessions", func(t *testing.T) {
+		database.UpdateUserRole(db, viewerId, models.RoleUser, nil)
+		if rec := serve(mux, "GET", "/api/v1/me", viewer); rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected a session carrying the old role to be rejected, got %d", rec.Code)
+		}
+	})
+
+	t.Run("disabled users are logged out", func(t *testing.T) {
+		session := loginAs(t, viewerId)
+		database.DisableUser(db, viewerId, time.Now().UTC(), nil)
+
+		if rec := serve(mux, "GET", "/api/v1/me", session); rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected the session of a disabled user to be rejected, got %d", rec.Code)
+		}
+
+		user, _ := database.GetUserById(db, viewerId)
+		_, err := auth.StartSession(db, httptest.NewRequest("POST", "/api/login", nil), user, models.LoginMethodPassword)
+		if !errors.Is(err, auth.ErrAccountDisabled) {
+			t.Errorf("expected ErrAccountDisabled, got %v", err)
+		}
+	})
+}
+
+func TestImpersonation(t *testing.T) {
+	db := openTestDB(t)
+	mux := adminTestMux()
+
+	adminId, _ := database.CreateUser(db, "support", "support@example.com", "password-hash")
+	database.UpdateUserRole(db, adminId, models.RoleAdmin, nil)
+	customerId, _ := database.CreateUser(db, "customer", "customer@example.com", "password-hash")
+	admin := loginAs(t, adminId)
+	customer := loginAs(t, customerId)
+
+	if rec := serve(mux, "POST", "/api/admin/users/"+strconv.FormatInt(adminId, 10)+"/impersonate", customer); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected users not to impersonate, got %d", rec.Code)
+	}
+
+	rec := serve(mux, "POST", "/api/admin/users/"+strconv.FormatInt(customerId, 10)+"/impersonate", admin)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("HX-Redirect") != "/contacts" {
+		t.Fatalf("expected to be logged in as the customer, got %d: %s", rec.Code, rec.Body)
+	}
+	if cookieValue(rec, adminTokenCookieName) != admin.Value {
+		t.Errorf("expected the admin's session to be kept")
+	}
+	impersonation := &http.Cookie{Name: "token", Value: cookieValue(rec, "token")}
+
+	if rec := serve(mux, "GET", "/api/v1/me", impersonation); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"username":"customer"`) {
+		t.Errorf("expected to act as the customer, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(mux, "POST", "/api/settings/delete", impersonation); rec.Code != http.StatusForbidden {
+		t.Errorf("expected the account not to be deletable while impersonating, got %d", rec.Code)
+	}
+	if rec := serve(mux, "POST", "/api/admin/users/"+strconv.FormatInt(adminId, 10)+"/impersonate", impersonation); rec.Code != http.StatusForbidden {
+		t.Errorf("expected admins to lose their role while impersonating, got %d", rec.Code)
+	}
+
+	rec = serve(mux, "POST", "/api/admin/impersonation/stop", impersonation, &http.Cookie{Name: adminTokenCookieName, Value: admin.Value})
+	if rec.Header().Get("HX-Redirect") != "/admin" || cookieValue(rec, "token") != admin.Value {
+		t.Fatalf("expected to be back in the admin's session, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(mux, "GET", "/api/v1/me", impersonation); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the impersonation session to be ended, got %d", rec.Code)
+	}
+	if rec := serve(mux, "GET", "/api/v1/me", customer); rec.Code != http.StatusOK {
+		t.Errorf("expected the customer's own sessions to be kept, got %d", rec.Code)
+	}
+
+	// Newest first, after the logins of the test's sessions.
+	events, _ := database.ListAuditEvents(db, models.AuditFilter{}, 10)
+	if len(events) < 2 || events[0].Action != models.AuditImpersonationStopped || events[1].Action != models.AuditImpersonationStarted ||
+		events[0].ActorName != "support" || events[0].TargetName != "customer" {
+		t.Errorf("expected both ends of the impersonation in the audit trail, got %+v", events)
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+const maxAPITokenNameLength = 50
+
+// apiTokenExpirations maps the expiration options of the form to token lifetimes. Zero means the token never expires.
+var apiTokenExpirations = map[string]time.Duration{
+	"7":     7 * 24 * time.Hour,
+	"30":    30 * 24 * time.Hour,
+	"90":    90 * 24 * time.Hour,
+	"365":   365 * 24 * time.Hour,
+	"never": 0,
+}
+
+// CreateAPIToken issues a personal access token and shows it to the user once.
+func CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	apiTokenForm := models.APITokenForm{}
+	apiTokenForm.Values.Name = strings.TrimSpace(r.FormValue("name"))
+	apiTokenForm.Values.Scopes = r.Form["scopes"]
+	apiTokenForm.Values.ExpiresIn = r.FormValue("expires_in")
+
+	if apiTokenForm.Values.Name == "" || len(apiTokenForm.Values.Name) > maxAPITokenNameLength {
+		apiTokenForm.Errors.Name = "Name must be between 1 and " + strconv.Itoa(maxAPITokenNameLength) + " characters long"
+	}
+
+	if len(apiTokenForm.Values.Scopes) == 0 {
+		apiTokenForm.Errors.Scopes = "Select at least one scope"
+	}
+
+	expiresIn, ok := apiTokenExpirations[apiTokenForm.Values.ExpiresIn]
+	if !ok {
+		apiTokenForm.Errors.ExpiresIn = "Invalid expiration"
+	}
+
+	// Render form with errors and submitted values if validation fails.
+	if apiTokenForm.HasErrors() {
+		renderAPITokenForm(w, r, apiTokenForm)
+		return
+	}
+
+	token, apiToken, err := auth.CreateAPIToken(database.DB, userCtx.Id, apiTokenForm.Values.Name, apiTokenForm.Values.Scopes, expiresIn)
+	if err == auth.ErrUnknownScope {
+		apiTokenForm.Errors.Scopes = "Invalid scope"
+		renderAPITokenForm(w, r, apiTokenForm)
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating api token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("Token created").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+
+	// Show the token in place of the form, and add it to the list out of band.
+	tmpl := parsePartial(r,
+		"pages/settings/tokens/created.html",
+		"pages/settings/tokens/row.html",
+	)
+	data := struct {
+		Token    string
+		APIToken *models.APIToken
+	}{token, apiToken}
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// RevokeAPIToken deletes one of the user's tokens, which stops working immediately.
+func RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := database.DeleteAPIToken(database.DB, userCtx.Id, id)
+	if err != nil {
+		log.Printf("Error revoking api token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	if err := toast.Success("Token revoked").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func renderAPITokenForm(w http.ResponseWriter, r *http.Request, form models.APITokenForm) {
+	tmpl := parsePartial(r, "pages/settings/tokens/form.html")
+	if err := tmpl.Execute(w, form); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+	"github.com/joangavelan/contacts-app/pkg/totp"
+)
+
+func postForm(mux *http.ServeMux, path string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+	r.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestAuditedAccountChanges(t *testing.T) {
+	db := openTestDB(t)
+	defaultMailer := mailer.Default
+	mailer.Default = &mailer.MemoryMailer{}
+	defer func() { mailer.Default = defaultMailer }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/orgs", auth.Middleware(http.HandlerFunc(CreateOrganization)))
+	mux.HandleFunc("POST /api/settings/email", auth.Middleware(http.HandlerFunc(ChangeEmail)))
+	mux.HandleFunc("POST /api/2fa/disable", auth.Middleware(http.HandlerFunc(DisableTwoFactor)))
+	mux.HandleFunc("POST /api/settings/delete", auth.Middleware(http.HandlerFunc(DeleteAccount)))
+
+	password := "violet-harbor-lantern-87"
+	hash, _ := auth.HashPassword(password)
+	userId, _ := database.CreateUser(db, "adalovelace", "ada@example.com", hash)
+	database.UpdateUserRole(db, userId, models.RoleAdmin, nil)
+	user, _ := database.GetUserById(db, userId)
+	auth.BeginTOTPEnrollment(db, user)
+	user, _ = database.GetUserById(db, userId)
+	code, _ := totp.CodeAt(user.TOTPSecret, time.Now())
+	recoveryCodes, err := auth.ConfirmTOTPEnrollment(db, user, code)
+	if err != nil {
+		t.Fatalf("error enabling two-factor authentication: %v", err)
+	}
+
+	// Each change is followed by the event recording it, newest first.
+	steps := []struct {
+		path   string
+		form   url.Values
+		action string
+	}{
+		{"/api/orgs", url.Values{"name": {"Analytical Engines"}}, models.AuditOrgCreated},
+		{"/api/settings/email", url.Values{"email": {"ada@engines.example"}, "password": {password}}, models.AuditEmailChanged},
+		{"/api/2fa/disable", url.Values{"code": {recoveryCodes[0]}}, models.AuditTwoFactorDisabled},
+		{"/api/settings/delete", url.Values{"password": {password}}, models.AuditAccountDeleted},
+	}
+	for _, step := range steps {
+		rec := postForm(mux, step.path, step.form, loginAs(t, userId))
+		if rec.Code >= 400 {
+			t.Fatalf("%s: expected the change to succeed, got %d: %s", step.path, rec.Code, rec.Body)
+		}
+
+		events, _ := database.ListAuditEvents(db, models.AuditFilter{}, 1)
+		if len(events) != 1 || events[0].Action != step.action || events[0].ActorId != userId {
+			t.Errorf("%s: expected %s in the audit trail, got %+v", step.path, step.action, events)
+		}
+	}
+
+	if _, err := database.VerifyAuditChain(db); err != nil {
+		t.Errorf("expected the audit trail to stay chained, got %v", err)
+	}
+}
+
+func TestAuditedAdminActions(t *testing.T) {
+	db := openTestDB(t)
+	mux := adminTestMux()
+	defaultMailer := mailer.Default
+	mailer.Default = &mailer.MemoryMailer{}
+	defer func() { mailer.Default = defaultMailer }()
+
+	adminId, _ := database.CreateUser(db, "support", "support@example.com", "password-hash")
+	database.UpdateUserRole(db, adminId, models.RoleAdmin, nil)
+	customerId, _ := database.CreateUser(db, "customer", "customer@example.com", "password-hash")
+	admin := loginAs(t, adminId)
+	users := "/api/admin/users/" + strconv.FormatInt(customerId, 10)
+
+	// Repeated actions only record the change they made.
+	steps := []struct {
+		path   string
+		form   url.Values
+		action string
+	}{
+		{users + "/disable", nil, models.AuditUserDisabled},
+		{users + "/disable", nil, ""},
+		{users + "/enable", nil, models.AuditUserEnabled},
+		{users + "/enable", nil, ""},
+		{users + "/reset-password", nil, models.AuditUserPasswordReset},
+		{users + "/role", url.Values{"role": {models.RoleReadOnly}}, models.AuditUserRoleChanged},
+		{users + "/role", url.Values{"role": {models.RoleReadOnly}}, ""},
+	}
+	var expected []string
+	for _, step := range steps {
+		if rec := postForm(mux, step.path, step.form, admin); rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected the action to succeed, got %d: %s", step.path, rec.Code, rec.Body)
+		}
+		if step.action != "" {
+			expected = append([]string{step.action}, expected...)
+		}
+	}
+
+	events, _ := database.ListAuditEvents(db, models.AuditFilter{}, 20)
+	var actions []string
+	for _, event := range events {
+		if event.TargetId == customerId {
+			actions = append(actions, event.Action)
+		}
+	}
+	if strings.Join(actions, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v in the audit trail, got %v", expected, actions)
+	}
+}
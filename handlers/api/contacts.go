@@ -0,0 +1,542 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/internal/render"
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+const (
+	contactsPath         = "/api/v1/contacts"
+	defaultContactsLimit = 20
+	maxContactsLimit     = 100
+	maxBulkOperations    = 100
+	maxContactNameLength = 50
+	maxPhoneNumberLength = 20
+)
+
+var phoneNumberPattern = regexp.MustCompile(`^[0-9+()\-. ]*$`)
+
+// contactList is the model of ListContacts, rendered as the contacts page, its rows or JSON.
+type contactList struct {
+	Data []models.Contact `json:"data"`
+	Meta struct {
+		Total  int `json:"total"`
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	} `json:"meta"`
+	// BookId is the address book the list is narrowed down to, which the page's links keep.
+	BookId int `json:"-"`
+}
+
+// First and Last are the positions of the first and last contacts of the list, counting from 1.
+func (l contactList) First() int { return l.Meta.Offset + 1 }
+func (l contactList) Last() int  { return l.Meta.Offset + len(l.Data) }
+
+func (l contactList) HasPrevious() bool   { return l.Meta.Offset > 0 }
+func (l contactList) HasNext() bool       { return l.Last() < l.Meta.Total }
+func (l contactList) PreviousOffset() int { return max(l.Meta.Offset-l.Meta.Limit, 0) }
+func (l contactList) NextOffset() int     { return l.Meta.Offset + l.Meta.Limit }
+
+// contactDocument is the model of the endpoints about a single contact.
+type contactDocument struct {
+	Data *models.Contact `json:"data"`
+}
+
+var (
+	contactListView = render.View{
+		Page: []string{
+			"layouts/base.html",
+			"pages/contacts/contacts.html",
+			"pages/contacts/row.html",
+		},
+		Fragment: []string{
+			"pages/contacts/contacts.html",
+			"pages/contacts/row.html",
+		},
+		Block: "contact-section",
+	}
+	contactView = render.View{
+		Page: []string{
+			"layouts/base.html",
+			"pages/contacts/contact.html",
+			"pages/contacts/row.html",
+		},
+		Fragment: []string{"pages/contacts/row.html"},
+		Block:    "contact",
+		Redirect: "/contacts",
+	}
+	deletedContactView = render.View{Redirect: "/contacts"}
+)
+
+// ListContacts returns a page of the contacts in the user's address books, ordered by name.
+// The bookId parameter narrows the list down to one book.
+func ListContacts(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Could not retrieve user information")
+		return
+	}
+
+	var errs []jsonapi.Error
+	limit := queryInt(r, "limit", defaultContactsLimit, 1, maxContactsLimit, &errs)
+	offset := queryInt(r, "offset", 0, 0, -1, &errs)
+	bookId := queryInt(r, "bookId", 0, 1, -1, &errs)
+	if len(errs) > 0 {
+		render.Errors(w, r, http.StatusBadRequest, errs...)
+		return
+	}
+
+	contacts, total, err := database.ForTenant(database.DB, user.OrgId).ListContacts(user.Id, int64(bookId), limit, offset)
+	if err != nil {
+		log.Printf("Error listing contacts: %v", err)
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return
+	}
+
+	list := contactList{Data: contacts, BookId: bookId}
+	if list.Data == nil {
+		list.Data = []models.Contact{}
+	}
+	list.Meta.Total, list.Meta.Limit, list.Meta.Offset = total, limit, offset
+
+	render.Render(w, r, http.StatusOK, contactListView, list)
+}
+
+// GetContact returns a contact from one of the user's address books. Scripts get a 304 if their copy is current,
+// while pages aren't cached since they also depend on who is looking.
+func GetContact(w http.ResponseWriter, r *http.Request) {
+	_, contact, ok := contactFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if render.Negotiate(r) == render.JSON {
+		etag := contactETag(contact)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	writeContact(w, r, http.StatusOK, contact)
+}
+
+// CreateContact adds a contact to one of the user's address books, their personal one unless another is chosen.
+func CreateContact(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Could not retrieve user information")
+		return
+	}
+
+	var patch models.ContactPatch
+	if !readContactPatch(w, r, &patch) {
+		return
+	}
+
+	if errs := validateContactPatch(patch, true, ""); len(errs) > 0 {
+		render.Errors(w, r, http.StatusUnprocessableEntity, errs...)
+		return
+	}
+
+	contact := &models.Contact{UserId: user.Id}
+	patch.Apply(contact)
+
+	err := database.ForTenant(database.DB, user.OrgId).CreateContact(contact)
+	if errors.Is(err, database.ErrAddressBookReadOnly) {
+		render.Errors(w, r, http.StatusForbidden, bookReadOnlyError("/bookId"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating contact: %v", err)
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return
+	}
+
+	publishContactChanges(user, contactChange{Contact: contact, To: contact.BookId})
+	notifyBookMembers(user, contact.BookId, toast.Info(user.Username+" added "+contactName(contact)))
+	render.Toast(w, r, toast.Success("Added "+contactName(contact)))
+
+	w.Header().Set("Location", contactsPath+"/"+strconv.FormatInt(contact.Id, 10))
+	w.Header().Set("ETag", contactETag(contact))
+	writeContact(w, r, http.StatusCreated, contact)
+}
+
+// UpdateContact changes the fields present in the request body.
+// With an If-Match header, the update only happens if the contact wasn't changed since the client read it.
+func UpdateContact(w http.ResponseWriter, r *http.Request) {
+	var patch models.ContactPatch
+	if !readContactPatch(w, r, &patch) {
+		return
+	}
+
+	if errs := validateContactPatch(patch, false, ""); len(errs) > 0 {
+		render.Errors(w, r, http.StatusUnprocessableEntity, errs...)
+		return
+	}
+
+	user, contact, ok := contactFromPath(w, r)
+	if !ok || !checkIfMatch(w, r, contact) {
+		return
+	}
+
+	// Only a move can be refused once the contact itself can be changed.
+	pointer := ""
+	if contact.CanEdit() {
+		pointer = "/bookId"
+	}
+	from := contact.BookId
+	patch.Apply(contact)
+
+	err := database.ForTenant(database.DB, user.OrgId).UpdateContact(user.Id, contact)
+	if errors.Is(err, database.ErrAddressBookReadOnly) {
+		render.Errors(w, r, http.StatusForbidden, bookReadOnlyError(pointer))
+		return
+	}
+	if errors.Is(err, database.ErrContactVersionMismatch) {
+		preconditionFailed(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating contact: %v", err)
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return
+	}
+
+	publishContactChanges(user, contactChange{Contact: contact, From: from, To: contact.BookId})
+	notifyBookMembers(user, contact.BookId, toast.Info(user.Username+" updated "+contactName(contact)))
+	render.Toast(w, r, toast.Success("Updated "+contactName(contact)))
+
+	w.Header().Set("ETag", contactETag(contact))
+	writeContact(w, r, http.StatusOK, contact)
+}
+
+// DeleteContact removes a contact from one of the user's address books, honoring If-Match like UpdateContact.
+func DeleteContact(w http.ResponseWriter, r *http.Request) {
+	user, contact, ok := contactFromPath(w, r)
+	if !ok || !checkIfMatch(w, r, contact) {
+		return
+	}
+
+	err := database.ForTenant(database.DB, user.OrgId).DeleteContact(user.Id, contact)
+	if errors.Is(err, database.ErrAddressBookReadOnly) {
+		render.Errors(w, r, http.StatusForbidden, bookReadOnlyError(""))
+		return
+	}
+	if errors.Is(err, database.ErrContactVersionMismatch) {
+		preconditionFailed(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting contact: %v", err)
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return
+	}
+
+	publishContactChanges(user, contactChange{Contact: contact, From: contact.BookId})
+	notifyBookMembers(user, contact.BookId, toast.Info(user.Username+" deleted "+contactName(contact)))
+	render.Toast(w, r, toast.Success("Deleted "+contactName(contact)))
+
+	render.Render(w, r, http.StatusNoContent, deletedContactView, nil)
+}
+
+// BulkContacts applies a list of create, update and delete operations atomically:
+// if any of them fails, none are applied.
+func BulkContacts(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Could not retrieve user information")
+		return
+	}
+
+	var request struct {
+		Operations []models.ContactOperation `json:"operations"`
+	}
+	if !jsonapi.Read(w, r, &request) {
+		return
+	}
+
+	if errs := validateContactOperations(request.Operations); len(errs) > 0 {
+		render.Errors(w, r, http.StatusUnprocessableEntity, errs...)
+		return
+	}
+
+	results, err := database.ForTenant(database.DB, user.OrgId).ApplyContactOperations(user.Id, request.Operations)
+
+	var opErr *database.ContactOperationError
+	if errors.As(err, &opErr) && opErr.Err == database.ErrContactNotFound {
+		render.Errors(w, r, http.StatusNotFound, pointerError(http.StatusNotFound, "not_found", operationPointer(opErr.Index, "id"), "Contact does not exist"))
+		return
+	}
+	if errors.As(err, &opErr) && opErr.Err == database.ErrAddressBookReadOnly {
+		pointer := operationPointer(opErr.Index, "id")
+		if request.Operations[opErr.Index].Data.BookId != nil {
+			pointer = operationPointer(opErr.Index, "data/bookId")
+		}
+		render.Errors(w, r, http.StatusForbidden, bookReadOnlyError(pointer))
+		return
+	}
+	if errors.As(err, &opErr) && opErr.Err == database.ErrContactVersionMismatch {
+		render.Errors(w, r, http.StatusPreconditionFailed, pointerError(http.StatusPreconditionFailed, "precondition_failed", operationPointer(opErr.Index, "version"), "Contact was changed since it was read"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error applying contact operations: %v", err)
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return
+	}
+
+	// Bulk changes update the contact lists, without a toast for every contact.
+	changes := make([]contactChange, 0, len(results))
+	var deleted []int64
+	for _, result := range results {
+		switch result.Op {
+		case models.ContactOpCreate:
+			changes = append(changes, contactChange{Contact: result.Contact, To: result.Contact.BookId})
+		case models.ContactOpUpdate:
+			changes = append(changes, contactChange{Contact: result.Contact, From: result.PreviousBookId, To: result.Contact.BookId})
+		case models.ContactOpDelete:
+			changes = append(changes, contactChange{Contact: &models.Contact{Id: result.Id}, From: result.PreviousBookId})
+			deleted = append(deleted, result.Id)
+		}
+	}
+	publishContactChanges(user, changes...)
+
+	if len(deleted) > 0 {
+		detail := map[string]any{"count": len(deleted), "contactIds": deleted, "orgId": user.OrgId}
+		if err := auth.RecordAudit(database.DB, r, user, models.AuditContactsBulkDeleted, nil, detail); err != nil {
+			log.Printf("Error recording audit event: %v", err)
+		}
+	}
+
+	render.Render(w, r, http.StatusOK, render.View{}, struct {
+		Data []models.ContactOperationResult `json:"data"`
+	}{results})
+}
+
+func validateContactOperations(ops []models.ContactOperation) []jsonapi.Error {
+	if len(ops) == 0 || len(ops) > maxBulkOperations {
+		detail := fmt.Sprintf("Send between 1 and %d operations", maxBulkOperations)
+		return []jsonapi.Error{pointerError(http.StatusUnprocessableEntity, "invalid_field", "/operations", detail)}
+	}
+
+	var errs []jsonapi.Error
+	for i, op := range ops {
+		switch op.Op {
+		case models.ContactOpCreate:
+			errs = append(errs, validateContactPatch(op.Data, true, operationPointer(i, "data"))...)
+		case models.ContactOpUpdate, models.ContactOpDelete:
+			if op.Id <= 0 {
+				errs = append(errs, pointerError(http.StatusUnprocessableEntity, "invalid_field", operationPointer(i, "id"), "Contact ID is required"))
+			}
+			if op.Op == models.ContactOpUpdate {
+				errs = append(errs, validateContactPatch(op.Data, false, operationPointer(i, "data"))...)
+			}
+		default:
+			errs = append(errs, pointerError(http.StatusUnprocessableEntity, "invalid_field", operationPointer(i, "op"), "Operation must be create, update or delete"))
+		}
+	}
+
+	return errs
+}
+
+// validateContactPatch checks the fields present in the patch, and that new contacts have every required field.
+// Errors point to the fields below prefix in the request body.
+func validateContactPatch(patch models.ContactPatch, create bool, prefix string) []jsonapi.Error {
+	var errs []jsonapi.Error
+	invalid := func(field, detail string) {
+		errs = append(errs, pointerError(http.StatusUnprocessableEntity, "invalid_field", prefix+"/"+field, detail))
+	}
+
+	if patch.BookId != nil && *patch.BookId <= 0 {
+		invalid("bookId", "Must be the ID of an address book")
+	}
+
+	names := []struct {
+		field string
+		value *string
+	}{{"firstName", patch.FirstName}, {"lastName", patch.LastName}}
+
+	for _, name := range names {
+		if name.value == nil && !create {
+			continue
+		}
+		if name.value == nil || strings.TrimSpace(*name.value) == "" || len(*name.value) > maxContactNameLength {
+			invalid(name.field, fmt.Sprintf("Must be between 1 and %d characters long", maxContactNameLength))
+		}
+	}
+
+	if patch.Email != nil && *patch.Email != "" && !auth.IsValidEmail(*patch.Email) {
+		invalid("email", "Must be a valid email address")
+	}
+
+	if patch.PhoneNumber != nil && (len(*patch.PhoneNumber) > maxPhoneNumberLength || !phoneNumberPattern.MatchString(*patch.PhoneNumber)) {
+		invalid("phoneNumber", fmt.Sprintf("Must be at most %d digits, spaces and + ( ) - . characters", maxPhoneNumberLength))
+	}
+
+	return errs
+}
+
+// contactFromPath loads the contact named in the URL along with the requesting user,
+// writing a 404 if none of the user's address books has such a contact.
+func contactFromPath(w http.ResponseWriter, r *http.Request) (*models.UserContext, *models.Contact, bool) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Could not retrieve user information")
+		return nil, nil, false
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		render.Error(w, r, http.StatusNotFound, "not_found", "Contact "+r.PathValue("id")+" does not exist")
+		return nil, nil, false
+	}
+
+	contact, err := database.ForTenant(database.DB, user.OrgId).GetContact(user.Id, id)
+	if err != nil {
+		log.Printf("Error retrieving contact: %v", err)
+		render.Error(w, r, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return nil, nil, false
+	}
+	if contact == nil {
+		render.Error(w, r, http.StatusNotFound, "not_found", "Contact "+r.PathValue("id")+" does not exist")
+		return nil, nil, false
+	}
+
+	return user, contact, true
+}
+
+// bookReadOnlyError reports that the user may only view the address book,
+// pointing to the field of the request body that chose the book, if any.
+func bookReadOnlyError(pointer string) jsonapi.Error {
+	err := jsonapi.NewError(http.StatusForbidden, "address_book_read_only", "You can't change contacts in this address book")
+	if pointer != "" {
+		err.Source = &jsonapi.ErrorSource{Pointer: pointer}
+	}
+	return err
+}
+
+// checkIfMatch writes a 412 and returns false if the request has an If-Match header
+// that doesn't match the contact's current version.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, contact *models.Contact) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || etagMatches(ifMatch, contactETag(contact)) {
+		return true
+	}
+
+	w.Header().Set("ETag", contactETag(contact))
+	preconditionFailed(w, r)
+	return false
+}
+
+func preconditionFailed(w http.ResponseWriter, r *http.Request) {
+	err := jsonapi.NewError(http.StatusPreconditionFailed, "precondition_failed", "Contact was changed since it was read, fetch it again and retry")
+	err.Source = &jsonapi.ErrorSource{Header: "If-Match"}
+	render.Errors(w, r, http.StatusPreconditionFailed, err)
+}
+
+func contactETag(contact *models.Contact) string {
+	return `"` + strconv.FormatInt(contact.Version, 10) + `"`
+}
+
+// etagMatches reports whether a comma separated If-Match or If-None-Match header lists etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func contactName(contact *models.Contact) string {
+	return contact.FirstName + " " + contact.LastName
+}
+
+func writeContact(w http.ResponseWriter, r *http.Request, status int, contact *models.Contact) {
+	render.Render(w, r, status, contactView, contactDocument{contact})
+}
+
+// readContactPatch decodes the request body, a JSON document from scripts or a form posted from the UI,
+// where only the fields present are set. If the body can't be decoded it writes an error response and returns false.
+func readContactPatch(w http.ResponseWriter, r *http.Request, patch *models.ContactPatch) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isForm := mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+	if !isForm || render.Negotiate(r) == render.JSON {
+		return jsonapi.Read(w, r, patch)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		render.Error(w, r, http.StatusBadRequest, "invalid_form", "Unable to parse form")
+		return false
+	}
+
+	fields := map[string]**string{
+		"firstName":   &patch.FirstName,
+		"lastName":    &patch.LastName,
+		"email":       &patch.Email,
+		"phoneNumber": &patch.PhoneNumber,
+	}
+	for name, field := range fields {
+		if values, ok := r.PostForm[name]; ok {
+			value := strings.TrimSpace(values[0])
+			*field = &value
+		}
+	}
+
+	// Books that can't be parsed are left for validation to reject.
+	if values, ok := r.PostForm["bookId"]; ok && values[0] != "" {
+		bookId, _ := strconv.ParseInt(values[0], 10, 64)
+		patch.BookId = &bookId
+	}
+
+	return true
+}
+
+// queryInt parses an integer query parameter, returning def if it's missing. A negative max means no upper bound.
+// Invalid values are reported by appending to errs.
+func queryInt(r *http.Request, name string, def, min, max int, errs *[]jsonapi.Error) int {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || (max >= 0 && n > max) {
+		detail := fmt.Sprintf("%s must be an integer of at least %d", name, min)
+		if max >= 0 {
+			detail = fmt.Sprintf("%s must be an integer between %d and %d", name, min, max)
+		}
+		err := jsonapi.NewError(http.StatusBadRequest, "invalid_parameter", detail)
+		err.Source = &jsonapi.ErrorSource{Parameter: name}
+		*errs = append(*errs, err)
+		return def
+	}
+
+	return n
+}
+
+func pointerError(status int, code, pointer, detail string) jsonapi.Error {
+	err := jsonapi.NewError(status, code, detail)
+	err.Source = &jsonapi.ErrorSource{Pointer: pointer}
+	return err
+}
+
+func operationPointer(index int, field string) string {
+	return fmt.Sprintf("/operations/%d/%s", index, field)
+}
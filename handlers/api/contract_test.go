@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/idempotency"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// contract checks responses against the OpenAPI document.
+type contract struct {
+	t         *testing.T
+	spec      map[string]any
+	mux       *http.ServeMux
+	exercised map[string]bool
+}
+
+func newContract(t *testing.T) *contract {
+	t.Helper()
+
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("error parsing openapi.json: %v", err)
+	}
+
+	// Same routes as cmd/main.go.
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/openapi.json", OpenAPI)
+	mux.HandleFunc("GET /api/v1/me", auth.Middleware(http.HandlerFunc(Me)))
+	mux.HandleFunc("GET /api/v1/books", auth.Middleware(auth.RequireScope(auth.ScopeContactsRead, http.HandlerFunc(ListAddressBooks))))
+	mux.HandleFunc("GET /api/v1/contacts", auth.Middleware(auth.VerifiedMiddleware(auth.RequireScope(auth.ScopeContactsRead, http.HandlerFunc(ListContacts)))))
+	mux.HandleFunc("POST /api/v1/contacts", auth.Middleware(auth.VerifiedMiddleware(auth.RequireScope(auth.ScopeContactsWrite, idempotency.Middleware(http.HandlerFunc(CreateContact))))))
+	mux.HandleFunc("POST /api/v1/contacts/bulk", auth.Middleware(auth.VerifiedMiddleware(auth.RequireScope(auth.ScopeContactsWrite, idempotency.Middleware(http.HandlerFunc(BulkContacts))))))
+	mux.HandleFunc("GET /api/v1/contacts/{id}", auth.Middleware(auth.VerifiedMiddleware(auth.RequireScope(auth.ScopeContactsRead, http.HandlerFunc(GetContact)))))
+	mux.HandleFunc("PATCH /api/v1/contacts/{id}", auth.Middleware(auth.VerifiedMiddleware(auth.RequireScope(auth.ScopeContactsWrite, http.HandlerFunc(UpdateContact)))))
+	mux.HandleFunc("DELETE /api/v1/contacts/{id}", auth.Middleware(auth.VerifiedMiddleware(auth.RequireScope(auth.ScopeContactsWrite, http.HandlerFunc(DeleteContact)))))
+
+	return &contract{t: t, spec: spec, mux: mux, exercised: map[string]bool{}}
+}
+
+// call makes a request and fails the test if the response doesn't match the document.
+func (c *contract) call(method, path, token, body string, headers ...string) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	c.mux.ServeHTTP(rec, r)
+	c.check(method, path, rec)
+
+	return rec
+}
+
+func (c *contract) check(method, path string, rec *httptest.ResponseRecorder) {
+	c.t.Helper()
+
+	operation, ok := c.operation(method, strings.TrimPrefix(strings.SplitN(path, "?", 2)[0], "/api/v1"))
+	if !ok {
+		c.t.Errorf("%s %s is not documented", method, path)
+		return
+	}
+	c.exercised[operation["operationId"].(string)] = true
+
+	responses := operation["responses"].(map[string]any)
+	response, ok := responses[strconv.Itoa(rec.Code)].(map[string]any)
+	if !ok {
+		c.t.Errorf("%s %s: status %d is not documented: %s", method, path, rec.Code, rec.Body)
+		return
+	}
+	response = c.resolve(response)
+
+	headers, _ := response["headers"].(map[string]any)
+	for name := range headers {
+		if rec.Header().Get(name) == "" {
+			c.t.Errorf("%s %s: documented header %s is missing", method, path, name)
+		}
+	}
+
+	content, _ := response["content"].(map[string]any)
+	if content == nil {
+		if rec.Body.Len() > 0 {
+			c.t.Errorf("%s %s: expected no body for status %d, got %s", method, path, rec.Code, rec.Body)
+		}
+		return
+	}
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		c.t.Errorf("%s %s: expected application/json, got %q", method, path, contentType)
+		return
+	}
+
+	var body any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		c.t.Errorf("%s %s: invalid JSON body: %v", method, path, err)
+		return
+	}
+
+	schema := content["application/json"].(map[string]any)["schema"].(map[string]any)
+	for _, problem := range c.validate(schema, body, "") {
+		c.t.Errorf("%s %s (%d): %s", method, path, rec.Code, problem)
+	}
+}
+
+// operation finds the documented operation for the request, preferring literal path segments over parameters.
+func (c *contract) operation(method, path string) (map[string]any, bool) {
+	var best map[string]any
+	bestParams := math.MaxInt
+
+	for template, item := range c.spec["paths"].(map[string]any) {
+		params, ok := matchPath(template, path)
+		if !ok || params >= bestParams {
+			continue
+		}
+		if operation, ok := item.(map[string]any)[strings.ToLower(method)].(map[string]any); ok {
+			best, bestParams = operation, params
+		}
+	}
+
+	return best, best != nil
+}
+
+// matchPath reports whether path matches the template and how many parameters it used.
+func matchPath(template, path string) (int, bool) {
+	want, got := strings.Split(template, "/"), strings.Split(path, "/")
+	if len(want) != len(got) {
+		return 0, false
+	}
+
+	params := 0
+	for i := range want {
+		if strings.HasPrefix(want[i], "{") {
+			params++
+		} else if want[i] != got[i] {
+			return 0, false
+		}
+	}
+	return params, true
+}
+
+func (c *contract) resolve(node map[string]any) map[string]any {
+	ref, ok := node["$ref"].(string)
+	if !ok {
+		return node
+	}
+
+	var target any = c.spec
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		target = target.(map[string]any)[part]
+	}
+	return c.resolve(target.(map[string]any))
+}
+
+// validate checks value against the subset of JSON Schema used by the document.
+func (c *contract) validate(schema map[string]any, value any, at string) []string {
+	schema = c.resolve(schema)
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("%s: %s", "#"+at, fmt.Sprintf(format, args...)))
+	}
+
+	if typ, ok := schema["type"].(string); ok && !hasType(value, typ) {
+		fail("expected %s, got %T", typ, value)
+		return problems
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		fail("%v is not one of %v", value, enum)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range asStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for name, propertyValue := range v {
+			property, ok := properties[name].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					fail("unexpected property %q", name)
+				}
+				continue
+			}
+			problems = append(problems, c.validate(property, propertyValue, at+"/"+name)...)
+		}
+	case []any:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			fail("expected at least %v items, got %d", min, len(v))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			fail("expected at most %v items, got %d", max, len(v))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				problems = append(problems, c.validate(items, item, at+"/"+strconv.Itoa(i))...)
+			}
+		}
+	case string:
+		if min, ok := schema["minLength"].(float64); ok && float64(len(v)) < min {
+			fail("expected at least %v characters", min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && float64(len(v)) > max {
+			fail("expected at most %v characters", max)
+		}
+	}
+
+	return problems
+}
+
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	}
+	return false
+}
+
+func asStrings(value any) []string {
+	var strs []string
+	values, _ := value.([]any)
+	for _, v := range values {
+		strs = append(strs, v.(string))
+	}
+	return strs
+}
+
+// openTestDB opens an in-memory database with the current schema and makes it the global database.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	// Every connection to :memory: is a separate database.
+	db.SetMaxOpenConns(1)
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = nil
+		db.Close()
+	})
+
+	return db
+}
+
+func createTestToken(t *testing.T, db *sql.DB, userId int64, scopes ...string) string {
+	t.Helper()
+
+	token, _, err := auth.CreateAPIToken(db, userId, "test", scopes, 0)
+	if err != nil {
+		t.Fatalf("error creating api token: %v", err)
+	}
+	return token
+}
+
+func TestContactsAPIContract(t *testing.T) {
+	db := openTestDB(t)
+	c := newContract(t)
+
+	userId, err := database.CreateUser(db, "testuser", "testuser@example.com", "hash")
+	if err != nil {
+		t.Fatalf("error creating user: %v", err)
+	}
+	otherId, err := database.CreateUser(db, "otheruser", "otheruser@example.com", "hash")
+	if err != nil {
+		t.Fatalf("error creating user: %v", err)
+	}
+
+	token := createTestToken(t, db, userId, auth.ScopeContactsRead, auth.ScopeContactsWrite)
+	readOnly := createTestToken(t, db, userId, auth.ScopeContactsRead)
+	other := createTestToken(t, db, otherId, auth.ScopeContactsRead, auth.ScopeContactsWrite)
+
+	expect := func(rec *httptest.ResponseRecorder, status int) {
+		t.Helper()
+		if rec.Code != status {
+			t.Fatalf("expected status %d, got %d: %s", status, rec.Code, rec.Body)
+		}
+	}
+
+	expect(c.call("GET", "/api/v1/openapi.json", "", ""), http.StatusOK)
+	expect(c.call("GET", "/api/v1/me", readOnly, ""), http.StatusOK)
+	expect(c.call("GET", "/api/v1/contacts", "", ""), http.StatusUnauthorized)
+	expect(c.call("GET", "/api/v1/contacts", "cat_invalid", ""), http.StatusUnauthorized)
+	expect(c.call("POST", "/api/v1/contacts", readOnly, `{"firstName": "Ada", "lastName": "Lovelace"}`), http.StatusForbidden)
+
+	// Unverified accounts can't use the API until they verify their email address.
+	expect(c.call("GET", "/api/v1/contacts", token, ""), http.StatusForbidden)
+	database.MarkEmailVerified(db, userId, "testuser@example.com", time.Now().UTC())
+	database.MarkEmailVerified(db, otherId, "otheruser@example.com", time.Now().UTC())
+
+	// Create
+	rec := c.call("POST", "/api/v1/contacts", token, `{"firstName": "Ada", "lastName": "Lovelace", "email": "ada@example.com"}`, "Idempotency-Key", "create-ada")
+	expect(rec, http.StatusCreated)
+	var created struct {
+		Data struct {
+			Id      int64 `json:"id"`
+			Version int64 `json:"version"`
+		} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	contactPath := "/api/v1/contacts/" + strconv.FormatInt(created.Data.Id, 10)
+	if rec.Header().Get("Location") != contactPath {
+		t.Errorf("expected Location %s, got %s", contactPath, rec.Header().Get("Location"))
+	}
+
+	// A retry with the same key replays the response instead of creating a duplicate.
+	replayed := c.call("POST", "/api/v1/contacts", token, `{"firstName": "Ada", "lastName": "Lovelace", "email": "ada@example.com"}`, "Idempotency-Key", "create-ada")
+	expect(replayed, http.StatusCreated)
+	if replayed.Body.String() != rec.Body.String() || replayed.Header().Get(idempotency.ReplayedHeaderName) != "true" {
+		t.Errorf("expected the original response to be replayed, got %s", replayed.Body)
+	}
+	expect(c.call("POST", "/api/v1/contacts", token, `{"firstName": "Grace", "lastName": "Hopper"}`, "Idempotency-Key", "create-ada"), http.StatusUnprocessableEntity)
+
+	expect(c.call("POST", "/api/v1/contacts", token, `{"firstName": ""}`), http.StatusUnprocessableEntity)
+	expect(c.call("POST", "/api/v1/contacts", token, `{"firstName": "Ada", "lastName": "Lovelace", "nickname": "Ada"}`), http.StatusBadRequest)
+	unsupported := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/contacts", strings.NewReader("firstName=Ada"))
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.mux.ServeHTTP(unsupported, r)
+	c.check("POST", "/api/v1/contacts", unsupported)
+	expect(unsupported, http.StatusUnsupportedMediaType)
+
+	// Read
+	rec = c.call("GET", contactPath, readOnly, "")
+	expect(rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	expect(c.call("GET", contactPath, readOnly, "", "If-None-Match", etag), http.StatusNotModified)
+	expect(c.call("GET", contactPath, other, ""), http.StatusNotFound)
+	expect(c.call("GET", "/api/v1/contacts/abc", token, ""), http.StatusNotFound)
+	expect(c.call("GET", "/api/v1/contacts?limit=1", readOnly, ""), http.StatusOK)
+	expect(c.call("GET", "/api/v1/contacts?limit=0&offset=-1", readOnly, ""), http.StatusBadRequest)
+
+	// Update with optimistic concurrency
+	rec = c.call("PATCH", contactPath, token, `{"phoneNumber": "+44 20 7946 0000"}`, "If-Match", etag)
+	expect(rec, http.StatusOK)
+	if rec.Header().Get("ETag") == etag {
+		t.Errorf("expected the ETag to change after an update")
+	}
+	expect(c.call("PATCH", contactPath, token, `{"firstName": "Augusta"}`, "If-Match", etag), http.StatusPreconditionFailed)
+	expect(c.call("PATCH", contactPath, token, `{"email": "not an email"}`), http.StatusUnprocessableEntity)
+	expect(c.call("PATCH", contactPath, other, `{"firstName": "Augusta"}`), http.StatusNotFound)
+
+	// Bulk operations are atomic.
+	bulk := fmt.Sprintf(`{"operations": [
+		{"op": "create", "data": {"firstName": "Grace", "lastName": "Hopper"}},
+		{"op": "update", "id": %d, "data": {"firstName": "Augusta"}},
+		{"op": "delete", "id": 9999}
+	]}`, created.Data.Id)
+	rec = c.call("POST", "/api/v1/contacts/bulk", token, bulk)
+	expect(rec, http.StatusNotFound)
+	if !strings.Contains(rec.Body.String(), `"pointer":"/operations/2/id"`) {
+		t.Errorf("expected the error to point to the failed operation, got %s", rec.Body)
+	}
+	expect(c.call("GET", "/api/v1/contacts", token, ""), http.StatusOK)
+	if contacts, _, _ := database.ForTenant(db, 0).ListContacts(userId, 0, 10, 0); len(contacts) != 1 || contacts[0].FirstName != "Ada" {
+		t.Errorf("expected the failed bulk request to change nothing, got %+v", contacts)
+	}
+
+	bulk = fmt.Sprintf(`{"operations": [
+		{"op": "create", "data": {"firstName": "Grace", "lastName": "Hopper"}},
+		{"op": "update", "id": %d, "version": 2, "data": {"firstName": "Augusta"}}
+	]}`, created.Data.Id)
+	expect(c.call("POST", "/api/v1/contacts/bulk", token, bulk), http.StatusOK)
+	expect(c.call("POST", "/api/v1/contacts/bulk", token, bulk), http.StatusPreconditionFailed)
+	expect(c.call("POST", "/api/v1/contacts/bulk", token, `{"operations": [{"op": "merge"}]}`), http.StatusUnprocessableEntity)
+
+	// Shared address books let their members read contacts, and editors change them.
+	book := &models.AddressBook{Name: "Family", CreatedAt: time.Now().UTC()}
+	if err := database.ForTenant(db, 0).CreateAddressBook(userId, book); err != nil {
+		t.Fatalf("error creating address book: %v", err)
+	}
+	invitation := &models.AddressBookInvitation{
+		BookId: book.Id, Email: "otheruser@example.com", Permission: models.BookViewer, InvitedBy: userId,
+		ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now().UTC(),
+	}
+	if err := database.ForTenant(db, 0).CreateBookInvitation(invitation); err != nil {
+		t.Fatalf("error creating address book invitation: %v", err)
+	}
+	if _, err := database.ForTenant(db, 0).AcceptBookInvitation(invitation.Id, otherId, "otheruser@example.com", time.Now().UTC()); err != nil {
+		t.Fatalf("error accepting address book invitation: %v", err)
+	}
+	expect(c.call("GET", "/api/v1/books", other, ""), http.StatusOK)
+
+	rec = c.call("POST", "/api/v1/contacts", token, fmt.Sprintf(`{"bookId": %d, "firstName": "Alan", "lastName": "Turing"}`, book.Id))
+	expect(rec, http.StatusCreated)
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	sharedPath := "/api/v1/contacts/" + strconv.FormatInt(created.Data.Id, 10)
+	expect(c.call("GET", sharedPath, other, ""), http.StatusOK)
+	expect(c.call("GET", fmt.Sprintf("/api/v1/contacts?bookId=%d", book.Id), other, ""), http.StatusOK)
+	rec = c.call("PATCH", sharedPath, other, `{"firstName": "Alan Mathison"}`)
+	expect(rec, http.StatusForbidden)
+	if !strings.Contains(rec.Body.String(), `"code":"address_book_read_only"`) {
+		t.Errorf("expected a read-only address book error, got %s", rec.Body)
+	}
+	expect(c.call("POST", "/api/v1/contacts", other, fmt.Sprintf(`{"bookId": %d, "firstName": "Joan", "lastName": "Clarke"}`, book.Id)), http.StatusForbidden)
+
+	if _, err := database.ForTenant(db, 0).UpdateBookMember(book.Id, otherId, models.BookEditor); err != nil {
+		t.Fatalf("error updating address book member: %v", err)
+	}
+	expect(c.call("PATCH", sharedPath, other, `{"firstName": "Alan Mathison"}`), http.StatusOK)
+	// Contacts move between books their editors can edit.
+	expect(c.call("PATCH", contactPath, token, fmt.Sprintf(`{"bookId": %d}`, book.Id)), http.StatusOK)
+	if _, err := database.ForTenant(db, 0).UpdateBookMember(book.Id, otherId, models.BookViewer); err != nil {
+		t.Fatalf("error updating address book member: %v", err)
+	}
+	expect(c.call("DELETE", sharedPath, other, ""), http.StatusForbidden)
+
+	// Organizations are separate workspaces, picked with a header.
+	org := &models.Organization{Name: "Acme", CreatedAt: time.Now().UTC()}
+	if err := database.CreateOrganization(db, userId, org, nil); err != nil {
+		t.Fatalf("error creating organization: %v", err)
+	}
+	orgId := strconv.FormatInt(org.Id, 10)
+	expect(c.call("GET", "/api/v1/books", token, "", "X-Organization-Id", orgId), http.StatusOK)
+	rec = c.call("POST", "/api/v1/contacts", token, `{"firstName": "Hedy", "lastName": "Lamarr"}`, "X-Organization-Id", orgId)
+	expect(rec, http.StatusCreated)
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	orgPath := "/api/v1/contacts/" + strconv.FormatInt(created.Data.Id, 10)
+	expect(c.call("GET", orgPath, token, "", "X-Organization-Id", orgId), http.StatusOK)
+	expect(c.call("GET", orgPath, token, ""), http.StatusNotFound)
+	rec = c.call("GET", fmt.Sprintf("/api/v1/contacts?bookId=%d", book.Id), token, "", "X-Organization-Id", orgId)
+	expect(rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), `"data":[]`) {
+		t.Errorf("expected the books of another workspace to be out of reach, got %s", rec.Body)
+	}
+	expect(c.call("GET", "/api/v1/contacts", other, "", "X-Organization-Id", orgId), http.StatusForbidden)
+
+	// Delete
+	expect(c.call("DELETE", contactPath, token, "", "If-Match", etag), http.StatusPreconditionFailed)
+	expect(c.call("DELETE", contactPath, token, ""), http.StatusNoContent)
+	expect(c.call("DELETE", contactPath, token, ""), http.StatusNotFound)
+
+	for _, item := range c.spec["paths"].(map[string]any) {
+		for _, operation := range item.(map[string]any) {
+			if operation, ok := operation.(map[string]any); ok {
+				if id := operation["operationId"].(string); !c.exercised[id] {
+					t.Errorf("operation %s is documented but not exercised", id)
+				}
+			}
+		}
+	}
+}
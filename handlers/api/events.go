@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/live"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// Events streams the live updates of the user's workspace as Server-Sent Events. The stream ends after
+// LiveStreamDuration, and browsers reconnect to it, so a session that was revoked stops receiving updates.
+func Events(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	sub := live.Default.Subscribe(user.Id, user.OrgId)
+	defer live.Default.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Error flushing event stream: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(config.LiveHeartbeatInterval)
+	defer heartbeat.Stop()
+	end := time.After(config.LiveStreamDuration)
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-end:
+			return
+		case <-heartbeat.C:
+			err = live.WriteHeartbeat(w)
+		case event := <-sub.Events():
+			err = live.Write(w, event)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// contactChange is a change to a contact, which moved it from one address book to another.
+// From is 0 for contacts that were created, and To is 0 for contacts that were deleted.
+type contactChange struct {
+	Contact  *models.Contact
+	From, To int64
+}
+
+// publishContactChanges updates the contact lists open in the workspace of the user who made the changes.
+// Members of the book a contact is in get its row added or updated, and members of the book it left
+// who can't see it anymore get its row removed.
+func publishContactChanges(user *models.UserContext, changes ...contactChange) {
+	if !live.Default.Listening(user.OrgId) {
+		return
+	}
+
+	tmpl := parseFragment(
+		"pages/contacts/live.html",
+		"pages/contacts/row.html",
+	)
+	tenant := database.ForTenant(database.DB, user.OrgId)
+
+	members := make(map[int64]map[int64]bool)
+	bookMembers := func(bookId int64) (map[int64]bool, error) {
+		if bookId == 0 || members[bookId] != nil {
+			return members[bookId], nil
+		}
+		list, err := tenant.ListBookMembers(bookId)
+		if err != nil {
+			return nil, err
+		}
+		members[bookId] = make(map[int64]bool, len(list))
+		for _, member := range list {
+			members[bookId][member.UserId] = true
+		}
+		return members[bookId], nil
+	}
+
+	for _, change := range changes {
+		before, err := bookMembers(change.From)
+		if err != nil {
+			log.Printf("Error publishing contact change: %v", err)
+			continue
+		}
+		after, err := bookMembers(change.To)
+		if err != nil {
+			log.Printf("Error publishing contact change: %v", err)
+			continue
+		}
+
+		var added, updated, removed []int64
+		for id := range after {
+			if before[id] {
+				updated = append(updated, id)
+			} else {
+				added = append(added, id)
+			}
+		}
+		for id := range before {
+			if !after[id] {
+				removed = append(removed, id)
+			}
+		}
+
+		publish := func(userIds []int64, name string) {
+			if len(userIds) == 0 {
+				return
+			}
+			var buf bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&buf, name, change.Contact); err != nil {
+				log.Printf("Error rendering contact change: %v", err)
+				return
+			}
+			live.Default.Publish(user.OrgId, userIds, live.Event{Name: live.ContactEvent, Data: strings.TrimSpace(buf.String())})
+		}
+		publish(added, "contact-created")
+		publish(updated, "contact-updated")
+		publish(removed, "contact-deleted")
+	}
+}
+
+// notifyBookMembers pushes a toast to the tabs open in the user's workspace of the other members of the book.
+func notifyBookMembers(user *models.UserContext, bookId int64, t toast.Toast) {
+	if !live.Default.Listening(user.OrgId) {
+		return
+	}
+
+	list, err := database.ForTenant(database.DB, user.OrgId).ListBookMembers(bookId)
+	if err != nil {
+		log.Printf("Error notifying address book members: %v", err)
+		return
+	}
+
+	var userIds []int64
+	for _, member := range list {
+		if member.UserId != user.Id {
+			userIds = append(userIds, member.UserId)
+		}
+	}
+	if len(userIds) == 0 {
+		return
+	}
+
+	event, err := toastEvent(t)
+	if err != nil {
+		log.Printf("Error rendering toast: %v", err)
+		return
+	}
+	live.Default.Publish(user.OrgId, userIds, event)
+}
+
+// notifyUser pushes a toast to every open tab of the user.
+func notifyUser(userId int64, t toast.Toast) {
+	event, err := toastEvent(t)
+	if err != nil {
+		log.Printf("Error rendering toast: %v", err)
+		return
+	}
+	live.Default.Notify(userId, event)
+}
+
+// toastEvent renders a toast for the toast container to append.
+func toastEvent(t toast.Toast) (live.Event, error) {
+	tmpl := parseFragment("commons/toast.html")
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "toast", t); err != nil {
+		return live.Event{}, err
+	}
+
+	return live.Event{Name: live.ToastEvent, Data: strings.TrimSpace(buf.String())}, nil
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// ForgotPassword emails a password reset link to the given address.
+// It responds the same way whether or not the address is registered so accounts can't be enumerated.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse and Validate Form Data
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	forgotPasswordForm := models.ForgotPasswordForm{}
+	forgotPasswordForm.Values.Email = strings.TrimSpace(r.FormValue("email"))
+
+	if !auth.IsValidEmail(forgotPasswordForm.Values.Email) {
+		forgotPasswordForm.Errors.Email = "Invalid email address"
+	}
+
+	tmpl := parsePartial(r, "pages/forgot-password/form.html")
+
+	// Render form with errors and submitted values if validation fails.
+	if forgotPasswordForm.HasErrors() {
+		if err := tmpl.Execute(w, forgotPasswordForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Send the reset link only if the user exists.
+	user, err := database.GetUserByEmail(database.DB, forgotPasswordForm.Values.Email)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user != nil {
+		if err := auth.SendPasswordResetEmail(database.DB, mailer.Default, user); err != nil {
+			log.Printf("Error sending password reset email: %v", err)
+		}
+	}
+
+	if err := toast.Success("If that email is registered, a reset link is on its way").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+
+	// Render a blank form.
+	if err := tmpl.Execute(w, models.ForgotPasswordForm{}); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// invitationExpirations maps the expiration options of the form to invitation lifetimes. Zero means it never expires.
+var invitationExpirations = map[string]time.Duration{
+	"1":     24 * time.Hour,
+	"7":     7 * 24 * time.Hour,
+	"30":    30 * 24 * time.Hour,
+	"never": 0,
+}
+
+// CreateInvitation issues an invitation to register and shows its link to the admin.
+// Invitations bound to an email address are also sent there.
+func CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	admin, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	invitationForm := models.InvitationForm{}
+	invitationForm.Values.Email = strings.TrimSpace(r.FormValue("email"))
+	invitationForm.Values.MaxUses = strings.TrimSpace(r.FormValue("max_uses"))
+	invitationForm.Values.ExpiresIn = r.FormValue("expires_in")
+
+	if invitationForm.Values.Email != "" && !auth.IsValidEmail(invitationForm.Values.Email) {
+		invitationForm.Errors.Email = "Invalid email address"
+	}
+
+	maxUses, err := strconv.Atoi(invitationForm.Values.MaxUses)
+	if err != nil || maxUses < 1 || maxUses > config.InvitationMaxUses {
+		invitationForm.Errors.MaxUses = fmt.Sprintf("Enter a number between 1 and %d", config.InvitationMaxUses)
+	}
+
+	expiresIn, ok := invitationExpirations[invitationForm.Values.ExpiresIn]
+	if !ok {
+		invitationForm.Errors.ExpiresIn = "Invalid expiration"
+	}
+
+	// Render form with errors and submitted values if validation fails.
+	if invitationForm.HasErrors() {
+		tmpl := parsePartial(r, "pages/admin/invitation-form.html")
+		if err := tmpl.Execute(w, invitationForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	link, invitation, err := auth.CreateInvitation(database.DB, r, mailer.Default, admin, invitationForm.Values.Email, maxUses, expiresIn)
+	if err != nil {
+		log.Printf("Error creating invitation: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Invitation created"
+	if invitation.Email != "" {
+		message = "Invitation sent to " + invitation.Email
+	}
+	if err := toast.Success(message).WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	auditTrailChanged(w)
+
+	// Show the link in place of the form, and add the invitation to the list out of band.
+	tmpl := parsePartial(r,
+		"pages/admin/invitation-created.html",
+		"pages/admin/invitation-row.html",
+	)
+	data := struct {
+		Link       string
+		Invitation *models.Invitation
+	}{link, invitation}
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// RevokeInvitation keeps an invitation from being used to register again.
+func RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	admin, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	audit, err := auth.NewAuditEvent(r, admin, models.AuditInvitationRevoked, nil, map[string]any{"invitationId": id})
+	if err != nil {
+		log.Printf("Error describing audit event: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	revoked, err := database.RevokeInvitation(database.DB, id, time.Now().UTC(), audit)
+	if err != nil {
+		log.Printf("Error revoking invitation: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	if err := toast.Success("Invitation revoked").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	auditTrailChanged(w)
+	w.WriteHeader(http.StatusOK)
+}
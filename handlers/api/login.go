@@ -1,16 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"html/template"
 	"log"
+	"math"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/joangavelan/contacts-app/config"
 	"github.com/joangavelan/contacts-app/internal/auth"
 	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
 	"github.com/joangavelan/contacts-app/internal/models"
 	"github.com/joangavelan/contacts-app/pkg/toast"
 )
@@ -38,57 +39,97 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 	// Render form with errors and submitted values if validation fails.
 	if LoginForm.HasErrors() {
-		tmpl := template.Must(template.ParseFiles("web/templates/pages/login/form.html"))
+		tmpl := parsePartial(r, "pages/login/form.html")
 		if err := tmpl.Execute(w, LoginForm); err != nil {
 			http.Error(w, "Unable to render template", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// Retrieve user from database.
-	user, err := database.GetUserByEmail(database.DB, LoginForm.Values.Email)
-	if err != nil {
-		log.Printf("Error retrieving user: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	ip := auth.ClientIP(r)
+	if retryAfter := auth.LoginRetryAfter(LoginForm.Values.Email, ip); retryAfter > 0 {
+		tooManyLoginAttempts(w, retryAfter)
 		return
 	}
-	if user == nil {
-		if err := toast.Error("User not found").WriteToHeader(w); err != nil {
+
+	// Verify credentials.
+	user, err := auth.Authenticate(database.DB, LoginForm.Values.Email, LoginForm.Values.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		auth.RecordLoginFailure(LoginForm.Values.Email, ip)
+		recordFailedLogin(r, LoginForm.Values.Email)
+		if err := toast.Error("Invalid email or password").WriteToHeader(w); err != nil {
 			log.Printf("Error writing toast event: %v", err)
 		}
-		http.Error(w, "User not found", http.StatusUnauthorized)
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, auth.ErrAccountDisabled) {
+		accountDisabled(w)
+		return
+	}
+	if err != nil {
+		log.Printf("Error authenticating user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Verify password.
-	if err := auth.CheckPasswordHash(LoginForm.Values.Password, user.Password); err != nil {
-		if err := toast.Error("Incorrect Password").WriteToHeader(w); err != nil {
-			log.Printf("Error writing toast event: %v", err)
-		}
-		http.Error(w, "Incorrect Password", http.StatusUnauthorized)
+	// Ask for the second factor before issuing the session.
+	if user.TwoFactorEnabled() {
+		setTwoFactorChallenge(w, user, models.LoginMethodPassword)
+		htmx.Redirect(w, r, "/auth/2fa")
 		return
 	}
 
-	// Generate JWT.
-	tokenString, err := auth.GenerateJWT(user.Id, user.Username, user.Email)
-	if err != nil {
-		log.Printf("Error generating JWT: %v", err)
+	auth.RecordLoginSuccess(user.Email)
+
+	// Generate JWT and set it in a cookie.
+	if err := startSession(w, r, user, models.LoginMethodPassword); err != nil {
+		log.Printf("Error starting session: %v", err)
 		http.Error(w, "Error generating JWT", http.StatusInternalServerError)
 		return
 	}
 
-	// Set JWT in a cookie.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    tokenString,
-		Path:     "/",
-		Expires:  time.Now().Add(config.CookieExpiration),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-	})
-
 	// Redirect to contacts page.
-	w.Header().Set("HX-Redirect", "/contacts")
-	w.WriteHeader(http.StatusSeeOther)
+	htmx.Redirect(w, r, "/contacts")
+}
+
+// recordFailedLogin adds a failed password attempt to the login history of the account with the email, if there is one,
+// and to the audit trail.
+func recordFailedLogin(r *http.Request, email string) {
+	user, err := database.GetUserByEmail(database.DB, email)
+	if err == nil && user != nil {
+		err = auth.RecordLoginEvent(database.DB, r, user, models.LoginMethodPassword, false)
+	} else if err == nil {
+		err = auth.RecordUnknownLogin(database.DB, r, email, models.LoginMethodPassword)
+	}
+	if err != nil {
+		log.Printf("Error recording login event: %v", err)
+	}
+}
+
+// tooManyLoginAttempts responds to a login attempt blocked by brute-force protection.
+func tooManyLoginAttempts(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+
+	wait := fmt.Sprintf("%d seconds", seconds)
+	if seconds == 1 {
+		wait = "1 second"
+	} else if seconds > 60 {
+		wait = fmt.Sprintf("%d minutes", int(math.Ceil(retryAfter.Minutes())))
+	}
+	message := "Too many login attempts, please try again in " + wait
+
+	if err := toast.Warning(message).WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.Header().Set("Retry-After", fmt.Sprint(seconds))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
+
+// accountDisabled tells a user who passed authentication that an admin disabled their account.
+func accountDisabled(w http.ResponseWriter) {
+	if err := toast.Error("This account has been disabled").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	http.Error(w, "Account disabled", http.StatusForbidden)
 }
@@ -1,27 +1,24 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
-	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
 )
 
 // Logout handles the logout process.
 func Logout(w http.ResponseWriter, r *http.Request) {
-	// Create an expired cookie to clear the JWT cookie
-	expiredCookie := http.Cookie{
-		Name:     "token",
-		Value:    "",
-		Expires:  time.Unix(0, 0),
-		HttpOnly: true,
-		Secure:   true,
-		Path:     "/",
-		SameSite: http.SameSiteLaxMode,
+	if err := auth.RecordLogout(database.DB, r); err != nil {
+		log.Printf("Error recording audit event: %v", err)
 	}
 
-	// Set the expired cookie in the response
-	http.SetCookie(w, &expiredCookie)
+	// Clear the JWT cookie, and the admin's own session if they were impersonating someone
+	endSession(w)
+	clearAdminToken(w)
 
 	// Redirect to login page
-	w.Header().Set("HX-Redirect", "/auth/login")
-	w.WriteHeader(http.StatusSeeOther)
+	htmx.Redirect(w, r, "/auth/login")
 }
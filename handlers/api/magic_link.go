@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+const (
+	magicLinkCookieName = "magic_link"
+	// magicLinkNonceMaxLength bounds nonces taken from the cookie, which are generated by auth.GenerateToken.
+	magicLinkNonceMaxLength = 64
+)
+
+// RequestMagicLink emails a sign-in link to the given address, bound to the requesting browser by a nonce cookie.
+// It responds the same way whether or not the address is registered so accounts can't be enumerated.
+func RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	// Parse and Validate Form Data
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	magicLinkForm := models.MagicLinkForm{}
+	magicLinkForm.Values.Email = strings.TrimSpace(r.FormValue("email"))
+
+	if !auth.IsValidEmail(magicLinkForm.Values.Email) {
+		magicLinkForm.Errors.Email = "Invalid email address"
+	}
+
+	tmpl := parsePartial(r, "pages/magic-link/form.html")
+
+	// Render form with errors and submitted values if validation fails.
+	if magicLinkForm.HasErrors() {
+		if err := tmpl.Execute(w, magicLinkForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Links asked for earlier from this browser keep working, so the nonce is reused while it lasts.
+	nonce := ""
+	if cookie, err := r.Cookie(magicLinkCookieName); err == nil && len(cookie.Value) <= magicLinkNonceMaxLength {
+		nonce = cookie.Value
+	}
+	if nonce == "" {
+		var err error
+		if nonce, _, err = auth.GenerateToken(); err != nil {
+			log.Printf("Error generating magic link nonce: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkCookieName,
+		Value:    nonce,
+		Path:     "/",
+		Expires:  time.Now().Add(config.MagicLinkExpiration),
+		HttpOnly: true,
+		Secure:   true,
+		// Lax, so the cookie is sent when the link is followed from an email.
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Send the link only if the user exists.
+	user, err := database.GetUserByEmail(database.DB, magicLinkForm.Values.Email)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user != nil {
+		err := auth.SendMagicLink(database.DB, mailer.Default, user, nonce)
+		if err != nil && !errors.Is(err, auth.ErrMagicLinkCooldown) {
+			log.Printf("Error sending magic link: %v", err)
+		}
+	}
+
+	if err := toast.Success("If that email is registered, a sign-in link is on its way").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+
+	// Render a blank form.
+	if err := tmpl.Execute(w, models.MagicLinkForm{}); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// MagicLinkSignIn signs the user in when they follow a magic link from their email.
+func MagicLinkSignIn(w http.ResponseWriter, r *http.Request) {
+	nonce := ""
+	if cookie, err := r.Cookie(magicLinkCookieName); err == nil {
+		nonce = cookie.Value
+	}
+
+	user, err := auth.RedeemMagicLink(database.DB, r.URL.Query().Get("token"), nonce)
+	if errors.Is(err, auth.ErrInvalidMagicLink) {
+		redirectToLogin(w, r, "magic_link_invalid")
+		return
+	}
+	if errors.Is(err, auth.ErrMagicLinkOtherBrowser) {
+		redirectToLogin(w, r, "magic_link_other_browser")
+		return
+	}
+	if err != nil {
+		log.Printf("Error redeeming magic link: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	clearMagicLinkNonce(w)
+
+	if user.IsDisabled() {
+		redirectToLogin(w, r, "account_disabled")
+		return
+	}
+
+	// Two-factor authentication applies to every way of signing in.
+	if user.TwoFactorEnabled() {
+		setTwoFactorChallenge(w, user, models.LoginMethodMagicLink)
+		http.Redirect(w, r, "/auth/2fa", http.StatusSeeOther)
+		return
+	}
+
+	if err := startSession(w, r, user, models.LoginMethodMagicLink); err != nil {
+		log.Printf("Error starting session: %v", err)
+		http.Error(w, "Error generating JWT", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/contacts", http.StatusSeeOther)
+}
+
+func clearMagicLinkNonce(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
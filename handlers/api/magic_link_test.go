@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+// sendMagicLink emails the user a link bound to nonce and returns the path it points to.
+func sendMagicLink(t *testing.T, email, nonce string) string {
+	t.Helper()
+
+	user, err := database.GetUserByEmail(database.DB, email)
+	if err != nil || user == nil {
+		t.Fatalf("expected user %s to exist, got %v", email, err)
+	}
+
+	m := &mailer.MemoryMailer{}
+	if err := auth.SendMagicLink(database.DB, m, user, nonce); err != nil {
+		t.Fatalf("expected no error sending the magic link, got %v", err)
+	}
+
+	match := regexp.MustCompile(`/auth/magic-link/sign-in\?token=\S+`).FindString(m.Messages()[0].Body)
+	if match == "" {
+		t.Fatalf("expected email to contain a sign-in link, got %q", m.Messages()[0].Body)
+	}
+
+	return match
+}
+
+// followMagicLink opens the link in a browser holding nonce, if any.
+func followMagicLink(link, nonce string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", link, nil)
+	if nonce != "" {
+		r.AddCookie(&http.Cookie{Name: magicLinkCookieName, Value: nonce})
+	}
+
+	rec := httptest.NewRecorder()
+	MagicLinkSignIn(rec, r)
+	return rec
+}
+
+func TestMagicLinkSignIn(t *testing.T) {
+	db := openTestDB(t)
+
+	userId, _ := database.CreateUser(db, "forgetful", "forgetful@example.com", "password-hash")
+	db.Exec("UPDATE users SET verifiedAt = CURRENT_TIMESTAMP WHERE id = ?", userId)
+
+	t.Run("signs in once in the requesting browser", func(t *testing.T) {
+		link := sendMagicLink(t, "forgetful@example.com", "nonce")
+
+		// A forwarded link doesn't work elsewhere, and isn't used up by trying.
+		rec := followMagicLink(link, "")
+		expectRedirect(t, rec, "/auth/login?error=magic_link_other_browser")
+		rec = followMagicLink(link, "other-nonce")
+		expectRedirect(t, rec, "/auth/login?error=magic_link_other_browser")
+		if hasCookie(rec, "token") {
+			t.Errorf("expected no session to be started")
+		}
+
+		rec = followMagicLink(link, "nonce")
+		expectRedirect(t, rec, "/contacts")
+		if !hasCookie(rec, "token") {
+			t.Errorf("expected a session to be started")
+		}
+		if user, _ := database.GetUserById(db, userId); user.Password != "password-hash" {
+			t.Errorf("expected the password of a verified user to be kept")
+		}
+
+		rec = followMagicLink(link, "nonce")
+		expectRedirect(t, rec, "/auth/login?error=magic_link_invalid")
+	})
+
+	t.Run("rejects tampered links", func(t *testing.T) {
+		db.Exec("UPDATE magic_links SET createdAt = '2000-01-01 00:00:00'")
+		link := sendMagicLink(t, "forgetful@example.com", "nonce")
+
+		rec := followMagicLink(link+"x", "nonce")
+		expectRedirect(t, rec, "/auth/login?error=magic_link_invalid")
+	})
+
+	t.Run("reclaims unverified accounts", func(t *testing.T) {
+		squatterId, _ := database.CreateUser(db, "squatter", "victim@example.com", "attacker-hash")
+		link := sendMagicLink(t, "victim@example.com", "nonce")
+
+		rec := followMagicLink(link, "nonce")
+		expectRedirect(t, rec, "/contacts")
+
+		user, _ := database.GetUserById(db, squatterId)
+		if user.Password == "attacker-hash" || !user.IsVerified() {
+			t.Errorf("expected the account to be reclaimed, got verified=%v", user.IsVerified())
+		}
+	})
+
+	t.Run("asks for the second factor", func(t *testing.T) {
+		db.Exec("UPDATE magic_links SET createdAt = '2000-01-01 00:00:00'")
+		db.Exec("UPDATE users SET totpSecret = 'secret', totpEnabledAt = CURRENT_TIMESTAMP WHERE id = ?", userId)
+		link := sendMagicLink(t, "forgetful@example.com", "nonce")
+
+		rec := followMagicLink(link, "nonce")
+		expectRedirect(t, rec, "/auth/2fa")
+		if hasCookie(rec, "token") || !hasCookie(rec, "two_factor") {
+			t.Errorf("expected a two-factor challenge instead of a session")
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+)
+
+// Me returns the authenticated user and the scopes available to the request.
+func Me(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		jsonapi.WriteError(w, http.StatusInternalServerError, "internal_error", "Could not retrieve user information")
+		return
+	}
+
+	// Browser sessions can do everything the user can.
+	scopes := user.Scopes
+	if user.APITokenId == 0 {
+		scopes = auth.Scopes
+	}
+
+	jsonapi.Write(w, http.StatusOK, struct {
+		Id       int64    `json:"id"`
+		Username string   `json:"username"`
+		Email    string   `json:"email"`
+		Scopes   []string `json:"scopes"`
+	}{user.Id, user.Username, user.Email, scopes})
+}
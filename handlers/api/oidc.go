@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/internal/sso"
+	"github.com/joangavelan/contacts-app/pkg/oidc"
+)
+
+const (
+	oidcStateCookieName = "oidc_state"
+	oidcStatePurpose    = "oidc-state"
+	oidcCookiePath      = "/auth/oidc/"
+)
+
+// OIDCLogin sends the user to the identity provider to sign in.
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := sso.Lookup(r.PathValue("provider"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The state ties the callback to this browser, the nonce ties the ID token to this login,
+	// and the PKCE verifier ties the code exchange to this login.
+	var values [3]string
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			log.Printf("Error generating oidc state: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("Error starting sign in with %s: %v", provider.Name(), err)
+		redirectToLogin(w, r, "sso_unavailable")
+		return
+	}
+
+	expiresAt := time.Now().Add(config.OIDCStateExpiration)
+	payload := strings.Join([]string{provider.Name(), state, nonce, verifier}, " ")
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    auth.SignToken(oidcStatePurpose, payload, expiresAt),
+		Path:     oidcCookiePath,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		// Lax, so the cookie is sent when the provider redirects back.
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback finishes signing in when the identity provider redirects back.
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	// The state can only be used once.
+	clearOIDCState(w)
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		redirectToLogin(w, r, "sso_cancelled")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil {
+		redirectToLogin(w, r, "sso_failed")
+		return
+	}
+	payload, err := auth.VerifySignedToken(oidcStatePurpose, cookie.Value)
+	if err != nil {
+		redirectToLogin(w, r, "sso_failed")
+		return
+	}
+
+	fields := strings.Split(payload, " ")
+	if len(fields) != 4 || fields[0] != r.PathValue("provider") ||
+		subtle.ConstantTimeCompare([]byte(fields[1]), []byte(query.Get("state"))) != 1 {
+		redirectToLogin(w, r, "sso_failed")
+		return
+	}
+	nonce, verifier := fields[2], fields[3]
+
+	provider, ok := sso.Lookup(fields[0])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	identity, err := provider.Identify(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("Error signing in with %s: %v", provider.Name(), err)
+		redirectToLogin(w, r, "sso_failed")
+		return
+	}
+
+	user, err := sso.SignIn(database.DB, identity, time.Now().UTC())
+	if errors.Is(err, sso.ErrEmailNotVerified) {
+		redirectToLogin(w, r, "sso_email_unverified")
+		return
+	}
+	if errors.Is(err, sso.ErrRegistrationClosed) {
+		redirectToLogin(w, r, "sso_registration_closed")
+		return
+	}
+	if err != nil {
+		log.Printf("Error signing in with %s: %v", provider.Name(), err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if user.IsDisabled() {
+		redirectToLogin(w, r, "account_disabled")
+		return
+	}
+
+	// Two-factor authentication applies to every way of signing in.
+	if user.TwoFactorEnabled() {
+		setTwoFactorChallenge(w, user, models.LoginMethodOIDC)
+		http.Redirect(w, r, "/auth/2fa", http.StatusSeeOther)
+		return
+	}
+
+	if err := startSession(w, r, user, models.LoginMethodOIDC); err != nil {
+		log.Printf("Error starting session: %v", err)
+		http.Error(w, "Error generating JWT", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/contacts", http.StatusSeeOther)
+}
+
+// redirectToLogin sends the user back to the login page, which explains what went wrong.
+func redirectToLogin(w http.ResponseWriter, r *http.Request, errorCode string) {
+	http.Redirect(w, r, "/auth/login?error="+url.QueryEscape(errorCode), http.StatusSeeOther)
+}
+
+func clearOIDCState(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    "",
+		Path:     oidcCookiePath,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/sso"
+	"github.com/joangavelan/contacts-app/pkg/oidc/oidctest"
+)
+
+// oidcLogin signs in with the mock provider like a browser would and returns the final response.
+// tamper may change the callback URL before it's requested.
+func oidcLogin(t *testing.T, tamper func(callback *url.URL)) *httptest.ResponseRecorder {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /auth/oidc/{provider}", OIDCLogin)
+	mux.HandleFunc("GET /auth/oidc/{provider}/callback", OIDCCallback)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/auth/oidc/mock", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected a redirect to the provider, got %d: %s", rec.Code, rec.Body)
+	}
+	stateCookies := rec.Result().Cookies()
+
+	// The provider approves the sign in and redirects back to the app.
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("error calling the provider: %v", err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Path != "/auth/oidc/mock/callback" {
+		t.Fatalf("expected a redirect to the callback, got %q", resp.Header.Get("Location"))
+	}
+	if tamper != nil {
+		tamper(callback)
+	}
+
+	r := httptest.NewRequest("GET", callback.RequestURI(), nil)
+	for _, cookie := range stateCookies {
+		r.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+
+	return rec
+}
+
+func expectRedirect(t *testing.T, rec *httptest.ResponseRecorder, location string) {
+	t.Helper()
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != location {
+		t.Fatalf("expected a redirect to %s, got %d to %q", location, rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func hasCookie(rec *httptest.ResponseRecorder, name string) bool {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOIDCLogin(t *testing.T) {
+	db := openTestDB(t)
+
+	server := oidctest.NewServer("contacts-app", "secret")
+	defer server.Close()
+	sso.Register(sso.NewOIDCProvider(config.OIDCProvider{
+		Name:         "mock",
+		DisplayName:  "Mock",
+		Issuer:       server.URL,
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+	}))
+
+	t.Run("creates an account on first sign in", func(t *testing.T) {
+		server.SetUser(oidctest.User{Subject: "new", Email: "new@example.com", EmailVerified: true, PreferredUsername: "newbie"})
+
+		rec := oidcLogin(t, nil)
+		expectRedirect(t, rec, "/contacts")
+		if !hasCookie(rec, "token") {
+			t.Errorf("expected a session to be started")
+		}
+
+		user, _ := database.GetUserByEmail(db, "new@example.com")
+		if user == nil || !user.IsVerified() || user.Username != "newbie" {
+			t.Fatalf("expected a verified user to be created, got %+v", user)
+		}
+
+		// Signing in again uses the same account.
+		expectRedirect(t, oidcLogin(t, nil), "/contacts")
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", "new@example.com").Scan(&count)
+		if count != 1 {
+			t.Errorf("expected a single user, got %d", count)
+		}
+	})
+
+	t.Run("links an existing verified account", func(t *testing.T) {
+		userId, _ := database.CreateUser(db, "existing", "existing@example.com", "password-hash")
+		db.Exec("UPDATE users SET verifiedAt = CURRENT_TIMESTAMP WHERE id = ?", userId)
+		server.SetUser(oidctest.User{Subject: "existing", Email: "existing@example.com", EmailVerified: true})
+
+		expectRedirect(t, oidcLogin(t, nil), "/contacts")
+
+		identity, _ := database.GetUserIdentity(db, "mock", "existing")
+		if identity == nil || identity.UserId != userId {
+			t.Fatalf("expected the identity to be linked to user %d, got %+v", userId, identity)
+		}
+		if user, _ := database.GetUserById(db, userId); user.Password != "password-hash" {
+			t.Errorf("expected the password of a verified account to be kept")
+		}
+	})
+
+	t.Run("reclaims an existing unverified account", func(t *testing.T) {
+		userId, _ := database.CreateUser(db, "squatter", "victim@example.com", "attacker-hash")
+		server.SetUser(oidctest.User{Subject: "victim", Email: "victim@example.com", EmailVerified: true})
+
+		expectRedirect(t, oidcLogin(t, nil), "/contacts")
+
+		user, _ := database.GetUserById(db, userId)
+		if user.Password == "attacker-hash" || !user.IsVerified() || user.SessionsValidAfter.IsZero() {
+			t.Errorf("expected the account to be reclaimed, got %+v", user)
+		}
+	})
+
+	t.Run("rejects unverified emails", func(t *testing.T) {
+		server.SetUser(oidctest.User{Subject: "unverified", Email: "existing@example.com", EmailVerified: false})
+
+		rec := oidcLogin(t, nil)
+		expectRedirect(t, rec, "/auth/login?error=sso_email_unverified")
+		if identity, _ := database.GetUserIdentity(db, "mock", "unverified"); identity != nil {
+			t.Errorf("expected no identity to be linked")
+		}
+	})
+
+	t.Run("rejects a forged state", func(t *testing.T) {
+		server.SetUser(oidctest.User{Subject: "new", Email: "new@example.com", EmailVerified: true})
+
+		rec := oidcLogin(t, func(callback *url.URL) {
+			query := callback.Query()
+			query.Set("state", "forged")
+			callback.RawQuery = query.Encode()
+		})
+		expectRedirect(t, rec, "/auth/login?error=sso_failed")
+		if hasCookie(rec, "token") {
+			t.Errorf("expected no session to be started")
+		}
+	})
+
+	t.Run("asks for the second factor", func(t *testing.T) {
+		db.Exec("UPDATE users SET totpSecret = 'secret', totpEnabledAt = CURRENT_TIMESTAMP WHERE email = ?", "new@example.com")
+		server.SetUser(oidctest.User{Subject: "new", Email: "new@example.com", EmailVerified: true})
+
+		rec := oidcLogin(t, nil)
+		expectRedirect(t, rec, "/auth/2fa")
+		if hasCookie(rec, "token") || !hasCookie(rec, "two_factor") {
+			t.Errorf("expected a two-factor challenge instead of a session")
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+)
+
+// openAPISpec describes the /api/v1 routes. It is maintained by hand; the contract test checks it against the handlers.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPI serves the OpenAPI document of the JSON API.
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", jsonapi.ContentType)
+	w.Write(openAPISpec)
+}
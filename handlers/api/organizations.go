@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+const maxOrgNameLength = 50
+
+// orgMemberRow is a member as listed on the settings page of an organization.
+type orgMemberRow struct {
+	models.OrgMembership
+	// Manage is set when the user viewing the page is an admin and the member isn't themselves,
+	// who leave the organization instead of changing their own role.
+	Manage bool
+}
+
+// CreateOrganization creates an organization administered by the user and switches to it.
+func CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	organizationForm := models.OrganizationForm{}
+	organizationForm.Values.Name = strings.TrimSpace(r.FormValue("name"))
+	organizationForm.Errors.Name = validateOrgName(organizationForm.Values.Name)
+
+	if organizationForm.HasErrors() {
+		tmpl := parsePartial(r, "pages/orgs/new-form.html")
+		if err := tmpl.Execute(w, organizationForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	audit, err := auth.NewAuditEvent(r, user, models.AuditOrgCreated, nil, map[string]any{"name": organizationForm.Values.Name})
+	if err != nil {
+		log.Printf("Error describing audit event: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	org := &models.Organization{Name: organizationForm.Values.Name, CreatedAt: time.Now().UTC()}
+	if err := database.CreateOrganization(database.DB, user.Id, org, audit); err != nil {
+		log.Printf("Error creating organization: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	setOrgCookie(w, org.Id)
+	htmx.Redirect(w, r, "/org")
+}
+
+// SwitchOrganization changes the workspace the user works in, either an organization they are a member of
+// or their personal workspace, and reloads the page.
+func SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.FormValue("org"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	if id != 0 {
+		org, err := database.GetUserOrganization(database.DB, user.Id, id)
+		if err != nil {
+			log.Printf("Error retrieving organization: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if org == nil {
+			http.Error(w, "Organization not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	setOrgCookie(w, id)
+	htmx.Refresh(w, r)
+}
+
+// UpdateOrganization saves the name and settings of the organization the user works in.
+func UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	_, org, ok := adminOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	settingsForm := models.OrgSettingsForm{}
+	settingsForm.Values.Name = strings.TrimSpace(r.FormValue("name"))
+	settingsForm.Values.MembersCanCreateBooks = r.FormValue("members_can_create_books")
+	settingsForm.Values.DefaultPermission = r.FormValue("default_permission")
+
+	settingsForm.Errors.Name = validateOrgName(settingsForm.Values.Name)
+	if !models.IsValidBookPermission(settingsForm.Values.DefaultPermission) {
+		settingsForm.Errors.DefaultPermission = "Invalid permission"
+	}
+
+	if !settingsForm.HasErrors() {
+		org.Name = settingsForm.Values.Name
+		org.MembersCanCreateBooks = settingsForm.Values.MembersCanCreateBooks != ""
+		org.DefaultPermission = settingsForm.Values.DefaultPermission
+
+		if err := database.UpdateOrganization(database.DB, org); err != nil {
+			log.Printf("Error updating organization: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := toast.Success("Organization settings saved").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+	}
+
+	tmpl := parsePartial(r, "pages/orgs/settings-form.html")
+	if err := tmpl.Execute(w, settingsForm); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// AddOrgMember adds an existing user to the organization the user works in, by email address.
+func AddOrgMember(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	_, org, ok := adminOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	memberForm := models.OrgMemberForm{}
+	memberForm.Values.Email = strings.TrimSpace(r.FormValue("email"))
+	memberForm.Values.Role = r.FormValue("role")
+
+	if !auth.IsValidEmail(memberForm.Values.Email) {
+		memberForm.Errors.Email = "Invalid email address"
+	}
+	if !models.IsValidOrgRole(memberForm.Values.Role) {
+		memberForm.Errors.Role = "Invalid role"
+	}
+
+	var member *models.OrgMembership
+	if !memberForm.HasErrors() {
+		user, err := database.GetUserByEmail(database.DB, memberForm.Values.Email)
+		if err != nil {
+			log.Printf("Error retrieving user: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if user == nil || !user.IsVerified() {
+			memberForm.Errors.Email = "No account with this email address"
+		} else {
+			added, err := database.AddOrgMember(database.DB, org, user.Id, memberForm.Values.Role, time.Now().UTC())
+			if err != nil {
+				log.Printf("Error adding organization member: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !added {
+				memberForm.Errors.Email = "Already a member of this organization"
+			} else {
+				member, err = database.GetOrgMember(database.DB, org.Id, user.Id)
+				if err != nil {
+					log.Printf("Error retrieving organization member: %v", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+	}
+
+	// Render form with errors and submitted values if validation fails.
+	if memberForm.HasErrors() {
+		tmpl := parsePartial(r, "pages/orgs/member-form.html")
+		if err := tmpl.Execute(w, memberForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := toast.Success(member.Username + " was added").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+
+	// Render a blank form, and add the member to the list out of band.
+	tmpl := parsePartial(r,
+		"pages/orgs/member-added.html",
+		"pages/orgs/member-form.html",
+		"pages/orgs/member-row.html",
+	)
+	data := struct {
+		Form   models.OrgMemberForm
+		Member orgMemberRow
+	}{
+		Form:   models.OrgMemberForm{Values: models.OrgMemberFormFields{Role: models.OrgMember}},
+		Member: orgMemberRow{OrgMembership: *member, Manage: true},
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// UpdateOrgMember changes the role of another member of the organization. It always keeps at least one admin.
+func UpdateOrgMember(w http.ResponseWriter, r *http.Request) {
+	user, org, ok := adminOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	member, ok := orgMemberFromPath(w, r, org)
+	if !ok {
+		return
+	}
+
+	if member.UserId == user.Id {
+		if err := toast.Error("Ask another admin to change your role").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Members can't change their own role", http.StatusForbidden)
+		return
+	}
+
+	role := r.FormValue("role")
+	if !models.IsValidOrgRole(role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := database.UpdateOrgMember(database.DB, org, member.UserId, role)
+	if errors.Is(err, database.ErrLastOrgAdmin) {
+		// Render the row unchanged, so the role shown is the one the member still has.
+		if err := toast.Error("An organization needs at least one admin").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		renderOrgMemberRow(w, r, member)
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating organization member: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	member.Role = role
+	if err := toast.Success(member.Username + " is now " + strings.ToLower(member.RoleLabel())).WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	renderOrgMemberRow(w, r, member)
+}
+
+// RemoveOrgMember takes a member out of the organization, along with their access to its address books.
+// Admins can remove other members, and any member can remove themselves to leave the organization,
+// unless they are its last admin.
+func RemoveOrgMember(w http.ResponseWriter, r *http.Request) {
+	user, org, ok := currentOrganization(w, r)
+	if !ok {
+		return
+	}
+
+	member, ok := orgMemberFromPath(w, r, org)
+	if !ok {
+		return
+	}
+
+	if member.UserId != user.Id && !org.IsAdmin() {
+		forbidOrgChange(w)
+		return
+	}
+
+	removed, err := database.RemoveOrgMember(database.DB, org.Id, member.UserId)
+	if errors.Is(err, database.ErrLastOrgAdmin) {
+		if err := toast.Error("Make someone else an admin before leaving").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Organization needs an admin", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, database.ErrSoleBookOwner) {
+		if err := toast.Error(fmt.Sprintf("%s is the only owner of address books shared with others", member.Username)).WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Address book needs an owner", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error removing organization member: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	if member.UserId == user.Id {
+		setOrgCookie(w, 0)
+		htmx.Redirect(w, r, "/contacts")
+		return
+	}
+
+	if err := toast.Success(member.Username + " was removed").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// currentOrganization returns the user making the request and the organization they work in.
+func currentOrganization(w http.ResponseWriter, r *http.Request) (*models.UserContext, *models.Organization, bool) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	if user.OrgId == 0 {
+		http.Error(w, "Not working in an organization", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	org, err := database.GetUserOrganization(database.DB, user.Id, user.OrgId)
+	if err != nil {
+		log.Printf("Error retrieving organization: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if org == nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return user, org, true
+}
+
+// adminOrganization is like currentOrganization, but also requires the user to be an admin of the organization.
+func adminOrganization(w http.ResponseWriter, r *http.Request) (*models.UserContext, *models.Organization, bool) {
+	user, org, ok := currentOrganization(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !org.IsAdmin() {
+		forbidOrgChange(w)
+		return nil, nil, false
+	}
+
+	return user, org, true
+}
+
+// orgMemberFromPath returns the member of the organization in the path.
+func orgMemberFromPath(w http.ResponseWriter, r *http.Request, org *models.Organization) (*models.OrgMembership, bool) {
+	userId, err := strconv.ParseInt(r.PathValue("userId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	member, err := database.GetOrgMember(database.DB, org.Id, userId)
+	if err != nil {
+		log.Printf("Error retrieving organization member: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if member == nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return member, true
+}
+
+func forbidOrgChange(w http.ResponseWriter) {
+	if err := toast.Error("Only admins can manage this organization").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	http.Error(w, "Forbidden", http.StatusForbidden)
+}
+
+func validateOrgName(name string) string {
+	if name == "" {
+		return "Name is required"
+	}
+	if utf8.RuneCountInString(name) > maxOrgNameLength {
+		return fmt.Sprintf("Name must be at most %d characters", maxOrgNameLength)
+	}
+	return ""
+}
+
+// setOrgCookie remembers the workspace picked in the browser, where zero is the personal workspace.
+func setOrgCookie(w http.ResponseWriter, orgId int64) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.OrgCookieName,
+		Value:    strconv.FormatInt(orgId, 10),
+		Path:     "/",
+		Expires:  time.Now().Add(config.CookieExpiration),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// renderOrgMemberRow answers htmx with the row of a member, and plain requests by going back to the organization.
+func renderOrgMemberRow(w http.ResponseWriter, r *http.Request, member *models.OrgMembership) {
+	if htmx.Back(w, r) {
+		return
+	}
+	tmpl := parsePartial(r, "pages/orgs/member-row.html")
+	if err := tmpl.Execute(w, orgMemberRow{OrgMembership: *member, Manage: true}); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"strings"
@@ -11,13 +11,24 @@ import (
 	"github.com/joangavelan/contacts-app/config"
 	"github.com/joangavelan/contacts-app/internal/auth"
 	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
 	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
 	"github.com/joangavelan/contacts-app/pkg/toast"
 )
 
 // Register handles user registration by validating inputs,
 // storing user data, generating a JWT, and setting the token in a cookie.
+// While registration is invite-only, the invitation is validated and used up along the way.
 func Register(w http.ResponseWriter, r *http.Request) {
+	if config.RegistrationMode == config.RegistrationClosed {
+		if err := toast.Error("Registration is closed").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Registration is closed", http.StatusForbidden)
+		return
+	}
+
 	// Parse and Validate Form Inputs
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Unable to parse form", http.StatusBadRequest)
@@ -28,6 +39,7 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	registerForm.Values.Username = strings.TrimSpace(r.FormValue("username"))
 	registerForm.Values.Email = strings.TrimSpace(r.FormValue("email"))
 	registerForm.Values.Password = strings.TrimSpace(r.FormValue("password"))
+	registerForm.Values.Invite = r.FormValue("invite")
 
 	if !auth.IsValidUsername(registerForm.Values.Username) {
 		registerForm.Errors.Username = fmt.Sprintf("Username must be between %d and %d characters long", auth.MinUsernameLength, auth.MaxUsernameLength)
@@ -37,13 +49,29 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		registerForm.Errors.Email = "Invalid email address"
 	}
 
-	if !auth.IsValidPassword(registerForm.Values.Password) {
-		registerForm.Errors.Password = fmt.Sprintf("Password must be between %d and %d characters long", auth.MinPasswordLength, auth.MaxPasswordLength)
+	registerForm.Errors.Password = auth.CheckNewPassword(registerForm.Values.Password, registerForm.Values.Username, registerForm.Values.Email)
+
+	var invitation *models.Invitation
+	if config.RegistrationMode == config.RegistrationInviteOnly {
+		var err error
+		invitation, err = auth.CheckInvitation(database.DB, registerForm.Values.Invite, registerForm.Values.Email)
+		switch {
+		case errors.Is(err, auth.ErrInvalidInvitation):
+			registerForm.Errors.Invite = "This invitation is invalid, expired or used up"
+		case errors.Is(err, auth.ErrInvitationEmailMismatch):
+			registerForm.Errors.Email = "This invitation is for another email address"
+		case err != nil:
+			log.Printf("Error checking invitation: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		default:
+			registerForm.EmailBound = invitation.Email != ""
+		}
 	}
 
 	// Render form with errors and submitted values if validation fails.
 	if registerForm.HasErrors() {
-		tmpl := template.Must(template.ParseFiles("web/templates/pages/register/form.html"))
+		tmpl := parsePartial(r, "pages/register/form.html")
 		if err := tmpl.Execute(w, registerForm); err != nil {
 			http.Error(w, "Unable to render template", http.StatusInternalServerError)
 		}
@@ -73,32 +101,41 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId, err := database.CreateUser(database.DB, registerForm.Values.Username, registerForm.Values.Email, hashedPassword)
+	var userId int64
+	if invitation != nil {
+		userId, err = database.CreateUserWithInvitation(
+			database.DB, registerForm.Values.Username, registerForm.Values.Email, hashedPassword, invitation.Id, time.Now().UTC(),
+		)
+	} else {
+		userId, err = database.CreateUser(database.DB, registerForm.Values.Username, registerForm.Values.Email, hashedPassword)
+	}
+	if errors.Is(err, database.ErrInvitationUsedUp) {
+		registerForm.Errors.Invite = "This invitation was used up in the meantime"
+		tmpl := parsePartial(r, "pages/register/form.html")
+		if err := tmpl.Execute(w, registerForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
 	if err != nil {
 		log.Printf("Error creating user: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	// Send the email verification link. The user can request another one if delivery fails.
+	user := &models.User{Id: userId, Username: registerForm.Values.Username, Email: registerForm.Values.Email, Role: models.RoleUser}
+	if err := auth.SendVerificationEmail(database.DB, mailer.Default, user); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+
 	// JWT creation and delivery.
-	tokenString, err := auth.GenerateJWT(userId, registerForm.Values.Username, registerForm.Values.Email)
-	if err != nil {
-		log.Printf("Error generating JWT: %v", err)
+	if err := startSession(w, r, user, models.LoginMethodPassword); err != nil {
+		log.Printf("Error starting session: %v", err)
 		http.Error(w, "Error generating JWT", http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    tokenString,
-		Path:     "/",
-		Expires:  time.Now().Add(config.CookieExpiration),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-	})
-
 	// Redirect to contacts page.
-	w.Header().Set("HX-Redirect", "/contacts")
-	w.WriteHeader(http.StatusSeeOther)
+	htmx.Redirect(w, r, "/contacts")
 }
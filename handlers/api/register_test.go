@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+func setRegistrationMode(t *testing.T, mode string) {
+	t.Helper()
+
+	previous := config.RegistrationMode
+	config.RegistrationMode = mode
+	t.Cleanup(func() { config.RegistrationMode = previous })
+}
+
+func register(username, email, invite string) *httptest.ResponseRecorder {
+	form := url.Values{
+		"username": {username},
+		"email":    {email},
+		"password": {"violet-harbor-lantern-87"},
+		"invite":   {invite},
+	}
+	r := httptest.NewRequest("POST", "/api/register", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+
+	rec := httptest.NewRecorder()
+	Register(rec, r)
+	return rec
+}
+
+func TestRegisterClosed(t *testing.T) {
+	openTestDB(t)
+	setRegistrationMode(t, config.RegistrationClosed)
+
+	if rec := register("newcomer", "newcomer@example.com", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("expected registration to be refused, got %d", rec.Code)
+	}
+}
+
+func TestRegisterWithInvitation(t *testing.T) {
+	db := openTestDB(t)
+	setRegistrationMode(t, config.RegistrationInviteOnly)
+
+	admin := &models.UserContext{Id: 1, Username: "support"}
+	m := &mailer.MemoryMailer{}
+
+	link, _, err := auth.CreateInvitation(db, httptest.NewRequest("POST", "/", nil), m, admin, "newcomer@example.com", 1, 0)
+	if err != nil {
+		t.Fatalf("expected no error creating the invitation, got %v", err)
+	}
+	if messages := m.Messages(); len(messages) != 1 || !strings.Contains(messages[0].Body, link) {
+		t.Fatalf("expected the invitation to be emailed, got %+v", messages)
+	}
+	parsed, _ := url.Parse(link)
+	token := parsed.Query().Get("invite")
+
+	if _, err := auth.CheckInvitation(db, token, "someone-else@example.com"); !errors.Is(err, auth.ErrInvitationEmailMismatch) {
+		t.Errorf("expected the invitation to be bound to its email address, got %v", err)
+	}
+
+	rec := register("newcomer", "Newcomer@example.com", token)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("HX-Redirect") != "/contacts" {
+		t.Fatalf("expected to register with the invitation, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if _, err := auth.CheckInvitation(db, token, ""); !errors.Is(err, auth.ErrInvalidInvitation) {
+		t.Errorf("expected the invitation to be used up, got %v", err)
+	}
+	if user, _ := database.GetUserByEmail(db, "Newcomer@example.com"); user == nil || user.Role != models.RoleUser {
+		t.Errorf("expected the user to be created, got %+v", user)
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/render"
+)
+
+// parsePartial returns the templates of a fragment swapped into the page, making the request's CSRF token
+// available to the forms in it, which send it back as a field when they are posted without htmx.
+func parsePartial(r *http.Request, files ...string) *template.Template {
+	return render.PartialTemplate(r, files...)
+}
+
+// parseFragment returns the templates of a fragment that doesn't depend on the request, like a row
+// rendered for the live event stream.
+func parseFragment(files ...string) *template.Template {
+	return render.PartialTemplate(nil, files...)
+}
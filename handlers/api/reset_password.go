@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// ResetPassword redeems a password reset token and sets the user's new password.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse and Validate Form Data
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	resetPasswordForm := models.ResetPasswordForm{}
+	resetPasswordForm.Values.Token = r.FormValue("token")
+	resetPasswordForm.Values.Password = strings.TrimSpace(r.FormValue("password"))
+	resetPasswordForm.Values.ConfirmPassword = strings.TrimSpace(r.FormValue("confirm_password"))
+
+	if resetPasswordForm.Values.Token == "" {
+		resetPasswordForm.Errors.Token = "Missing reset token"
+	}
+
+	resetPasswordForm.Errors.Password = auth.CheckNewPassword(resetPasswordForm.Values.Password)
+
+	if resetPasswordForm.Values.ConfirmPassword != resetPasswordForm.Values.Password {
+		resetPasswordForm.Errors.ConfirmPassword = "Passwords do not match"
+	}
+
+	// Render form with errors and submitted values if validation fails.
+	if resetPasswordForm.HasErrors() {
+		tmpl := parsePartial(r, "pages/reset-password/form.html")
+		if err := tmpl.Execute(w, resetPasswordForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user, err := auth.ResetPassword(database.DB, resetPasswordForm.Values.Token, resetPasswordForm.Values.Password)
+	if errors.Is(err, auth.ErrInvalidResetToken) {
+		if err := toast.Error("This reset link is invalid or has expired").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error resetting password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if user != nil {
+		if err := auth.RecordUserAudit(database.DB, r, user, models.AuditPasswordResetUsed, nil); err != nil {
+			log.Printf("Error recording audit event: %v", err)
+		}
+	}
+
+	// Redirect to login page.
+	htmx.Redirect(w, r, "/auth/login")
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+const twoFactorCookieName = "two_factor"
+
+// startSession records a login with method and sets the cookie of the new session.
+func startSession(w http.ResponseWriter, r *http.Request, user *models.User, method string) error {
+	tokenString, err := auth.StartSession(database.DB, r, user, method)
+	if err != nil {
+		return fmt.Errorf("error starting session: %w", err)
+	}
+
+	setSessionCookie(w, tokenString)
+	return nil
+}
+
+// refreshSession reissues the cookie of the current session, e.g. after the username or email it carries changed.
+func refreshSession(w http.ResponseWriter, r *http.Request, user *models.User) error {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		return errors.New("no user in request context")
+	}
+
+	tokenString, err := auth.ReissueSession(database.DB, user, userCtx.SessionId)
+	if err != nil {
+		return fmt.Errorf("error reissuing session: %w", err)
+	}
+
+	setSessionCookie(w, tokenString)
+	return nil
+}
+
+func setSessionCookie(w http.ResponseWriter, tokenString string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    tokenString,
+		Path:     "/",
+		Expires:  time.Now().Add(config.CookieExpiration),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// endSession expires the session cookie.
+func endSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// setTwoFactorChallenge remembers that the user passed the first step of logging in with method
+// and must now provide their second factor.
+func setTwoFactorChallenge(w http.ResponseWriter, user *models.User, method string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookieName,
+		Value:    auth.TwoFactorChallengeToken(user.Id, method),
+		Path:     "/",
+		Expires:  time.Now().Add(config.TwoFactorChallengeExpiration),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearTwoFactorChallenge expires the two-factor challenge cookie.
+func clearTwoFactorChallenge(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// RevokeSession logs the user out of one of their sessions.
+// Revoking the current session logs them out of this browser too.
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := database.RevokeSession(database.DB, userCtx.Id, id, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error revoking session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if id == userCtx.SessionId {
+		endSession(w)
+		htmx.Redirect(w, r, "/auth/login")
+		return
+	}
+
+	if err := toast.Success("Session revoked").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func TestRevokeSession(t *testing.T) {
+	db := openTestDB(t)
+
+	userId, _ := database.CreateUser(db, "traveller", "traveller@example.com", "password-hash")
+	user, _ := database.GetUserById(db, userId)
+
+	login := func(userAgent string) (*http.Cookie, int64) {
+		r := httptest.NewRequest("POST", "/api/login", nil)
+		r.Header.Set("User-Agent", userAgent)
+		token, err := auth.StartSession(db, r, user, models.LoginMethodPassword)
+		if err != nil {
+			t.Fatalf("expected no error starting a session, got %v", err)
+		}
+		claims, _ := auth.ValidateJWT(token)
+		return &http.Cookie{Name: "token", Value: token}, claims.Sid
+	}
+	laptop, _ := login("Mozilla/5.0 (X11; Linux x86_64) Firefox/131.0")
+	phone, phoneId := login("Mozilla/5.0 (iPhone; CPU iPhone OS 17_6 like Mac OS X) Safari/604.1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/me", auth.Middleware(http.HandlerFunc(Me)))
+	mux.HandleFunc("DELETE /api/sessions/{id}", auth.Middleware(auth.RequireSession(http.HandlerFunc(RevokeSession))))
+
+	call := func(cookie *http.Cookie, method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := call(phone, "GET", "/api/v1/me"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the phone to be logged in, got %d", rec.Code)
+	}
+
+	// The laptop logs the phone out.
+	if rec := call(laptop, "DELETE", "/api/sessions/"+strconv.FormatInt(phoneId, 10)); rec.Code != http.StatusOK {
+		t.Fatalf("expected the session to be revoked, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := call(phone, "GET", "/api/v1/me"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the revoked session to be rejected, got %d", rec.Code)
+	}
+	if rec := call(laptop, "GET", "/api/v1/me"); rec.Code != http.StatusOK {
+		t.Errorf("expected other sessions to stay logged in, got %d", rec.Code)
+	}
+
+	// Another user's sessions can't be revoked.
+	otherId, _ := database.CreateUser(db, "stranger", "stranger@example.com", "password-hash")
+	other, _ := database.GetUserById(db, otherId)
+	token, _ := auth.StartSession(db, httptest.NewRequest("POST", "/api/login", nil), other, models.LoginMethodPassword)
+	claims, _ := auth.ValidateJWT(token)
+	if rec := call(laptop, "DELETE", "/api/sessions/"+strconv.FormatInt(claims.Sid, 10)); rec.Code != http.StatusNotFound {
+		t.Errorf("expected another user's session not to be found, got %d", rec.Code)
+	}
+
+	events, _ := database.ListLoginEvents(db, userId, time.Now().Add(-time.Hour), 10)
+	if len(events) != 2 || events[0].Device() != "Safari on iOS" || !events[0].Success {
+		t.Errorf("expected both logins in the history, newest first, got %+v", events)
+	}
+}
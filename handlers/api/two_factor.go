@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// TwoFactorChallenge completes a login by verifying the second factor of a user who already entered their password.
+func TwoFactorChallenge(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(twoFactorCookieName)
+	if err != nil {
+		htmx.Redirect(w, r, "/auth/login")
+		return
+	}
+
+	userId, method, err := auth.ParseTwoFactorChallengeToken(cookie.Value)
+	if err != nil {
+		clearTwoFactorChallenge(w)
+		if err := toast.Error("Your login attempt expired, please log in again").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		htmx.Redirect(w, r, "/auth/login")
+		return
+	}
+
+	user, err := database.GetUserById(database.DB, userId)
+	if err != nil || user == nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	ip := auth.ClientIP(r)
+	if retryAfter := auth.LoginRetryAfter(user.Email, ip); retryAfter > 0 {
+		tooManyLoginAttempts(w, retryAfter)
+		return
+	}
+
+	twoFactorForm := models.TwoFactorForm{}
+	twoFactorForm.Values.Code = strings.TrimSpace(r.FormValue("code"))
+
+	if twoFactorForm.Values.Code == "" {
+		twoFactorForm.Errors.Code = "Enter a code from your authenticator app or a recovery code"
+	} else if err := auth.VerifySecondFactor(database.DB, user, twoFactorForm.Values.Code); errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		auth.RecordLoginFailure(user.Email, ip)
+		if err := auth.RecordLoginEvent(database.DB, r, user, method, false); err != nil {
+			log.Printf("Error recording login event: %v", err)
+		}
+		twoFactorForm.Errors.Code = "Invalid code"
+	} else if err != nil {
+		log.Printf("Error verifying second factor: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Render form with errors if verification fails.
+	if twoFactorForm.HasErrors() {
+		twoFactorForm.Values.Code = ""
+		tmpl := parsePartial(r, "pages/two-factor/form.html")
+		if err := tmpl.Execute(w, twoFactorForm); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	auth.RecordLoginSuccess(user.Email)
+	clearTwoFactorChallenge(w)
+	err = startSession(w, r, user, method)
+	if errors.Is(err, auth.ErrAccountDisabled) {
+		accountDisabled(w)
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting session: %v", err)
+		http.Error(w, "Error generating JWT", http.StatusInternalServerError)
+		return
+	}
+
+	// Redirect to contacts page.
+	htmx.Redirect(w, r, "/contacts")
+}
+
+// EnableTwoFactor confirms a pending two-factor enrollment and shows the recovery codes.
+func EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	form := models.TwoFactorActionForm{Action: "/api/2fa/enable", Submit: "Enable"}
+
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	form.Values.Code = strings.TrimSpace(r.FormValue("code"))
+	codes, err := auth.ConfirmTOTPEnrollment(database.DB, user, form.Values.Code)
+	if errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		form.Errors.Code = "Invalid code, make sure your device's clock is correct"
+		renderCodeForm(w, r, form)
+		return
+	}
+	if errors.Is(err, auth.ErrTwoFactorNotPending) {
+		if err := toast.Error("Two-factor authentication was changed in the meantime, please reload the page").WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, "Two-factor enrollment is not pending", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error enabling two-factor authentication: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("Two-factor authentication enabled").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	renderRecoveryCodes(w, codes)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes and shows the new ones.
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	form := models.TwoFactorActionForm{Action: "/api/2fa/recovery-codes", Submit: "Generate new codes"}
+
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	form.Values.Code = strings.TrimSpace(r.FormValue("code"))
+	codes, err := auth.RegenerateRecoveryCodes(database.DB, user, form.Values.Code)
+	if errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		form.Errors.Code = "Invalid code"
+		renderCodeForm(w, r, form)
+		return
+	}
+	if err != nil {
+		log.Printf("Error regenerating recovery codes: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("New recovery codes generated").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	renderRecoveryCodes(w, codes)
+}
+
+// DisableTwoFactor turns two-factor authentication off.
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	form := models.TwoFactorActionForm{Action: "/api/2fa/disable", Submit: "Disable"}
+
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	form.Values.Code = strings.TrimSpace(r.FormValue("code"))
+	err := auth.DisableTwoFactor(database.DB, r, user, form.Values.Code)
+	if errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		form.Errors.Code = "Invalid code"
+		renderCodeForm(w, r, form)
+		return
+	}
+	if err != nil {
+		log.Printf("Error disabling two-factor authentication: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("Two-factor authentication disabled").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	htmx.Refresh(w, r)
+}
+
+// currentUser loads the logged in user from the database, writing an error response if that fails.
+func currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	user, err := database.GetUserById(database.DB, userCtx.Id)
+	if err != nil || user == nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func renderCodeForm(w http.ResponseWriter, r *http.Request, form models.TwoFactorActionForm) {
+	form.Values.Code = ""
+	tmpl := parsePartial(r, "pages/settings/two-factor/code-form.html")
+	if err := tmpl.Execute(w, form); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// renderRecoveryCodes replaces the whole two-factor section with the freshly generated recovery codes.
+func renderRecoveryCodes(w http.ResponseWriter, codes []string) {
+	w.Header().Set("HX-Retarget", "#two-factor")
+	w.Header().Set("HX-Reswap", "innerHTML")
+
+	tmpl := parseFragment("pages/settings/two-factor/recovery-codes.html")
+	if err := tmpl.Execute(w, codes); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// ResendVerification sends a new email verification link to the logged in user,
+// at most once per cooldown period.
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.IsVerified() {
+		htmx.Redirect(w, r, "/contacts")
+		return
+	}
+
+	remaining, err := auth.ResendVerificationEmail(database.DB, mailer.Default, user)
+	if errors.Is(err, auth.ErrVerificationCooldown) {
+		message := fmt.Sprintf("Please wait %d seconds before requesting another email", int(math.Ceil(remaining.Seconds())))
+		if err := toast.Warning(message).WriteToHeader(w); err != nil {
+			log.Printf("Error writing toast event: %v", err)
+		}
+		http.Error(w, message, http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		log.Printf("Error sending verification email: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("Verification email sent").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
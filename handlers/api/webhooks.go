@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/internal/webhook"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+const maxWebhookURLLength = 2000
+
+// CreateWebhook registers a webhook and shows its signing secret to the user once.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	webhookForm := models.WebhookForm{}
+	webhookForm.Values.URL = strings.TrimSpace(r.FormValue("url"))
+	webhookForm.Values.Events = r.Form["events"]
+
+	if webhookForm.Values.URL == "" || len(webhookForm.Values.URL) > maxWebhookURLLength {
+		webhookForm.Errors.URL = "URL must be between 1 and " + strconv.Itoa(maxWebhookURLLength) + " characters long"
+	}
+
+	if len(webhookForm.Values.Events) == 0 {
+		webhookForm.Errors.Events = "Select at least one event"
+	}
+
+	// Render form with errors and submitted values if validation fails.
+	if webhookForm.HasErrors() {
+		renderWebhookForm(w, r, webhookForm)
+		return
+	}
+
+	created, err := webhook.Register(database.DB, userCtx.Id, webhookForm.Values.URL, webhookForm.Values.Events)
+	if errors.Is(err, webhook.ErrInvalidURL) {
+		webhookForm.Errors.URL = "Enter an http or https URL"
+		renderWebhookForm(w, r, webhookForm)
+		return
+	}
+	if errors.Is(err, webhook.ErrPrivateAddress) {
+		webhookForm.Errors.URL = "Enter the URL of a public server"
+		renderWebhookForm(w, r, webhookForm)
+		return
+	}
+	if errors.Is(err, webhook.ErrUnknownEvent) {
+		webhookForm.Errors.Events = "Invalid event"
+		renderWebhookForm(w, r, webhookForm)
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating webhook: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("Webhook added").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+
+	// Show the secret in place of the form, and add the webhook to the list out of band.
+	tmpl := parsePartial(r,
+		"pages/settings/webhooks/created.html",
+		"pages/settings/webhooks/row.html",
+	)
+	if err := tmpl.Execute(w, created); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// DeleteWebhook deletes one of the user's webhooks along with its delivery log. Pending deliveries aren't sent.
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := database.DeleteWebhook(database.DB, userCtx.Id, id)
+	if err != nil {
+		log.Printf("Error deleting webhook: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	if err := toast.Success("Webhook deleted").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RedeliverWebhook queues the payload of a past delivery of one of the user's webhooks again,
+// and renders the new delivery for the top of the log.
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	webhookId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	deliveryId, err := strconv.ParseInt(r.PathValue("deliveryId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := database.GetWebhook(database.DB, userCtx.Id, webhookId)
+	if err != nil {
+		log.Printf("Error retrieving webhook: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if hook == nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	delivery, err := database.GetWebhookDelivery(database.DB, hook.Id, deliveryId)
+	if err != nil {
+		log.Printf("Error retrieving webhook delivery: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if delivery == nil {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+
+	redelivery, err := database.RedeliverWebhookDelivery(database.DB, delivery, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error redelivering webhook: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := toast.Success("Redelivery queued").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	if htmx.Back(w, r) {
+		return
+	}
+
+	tmpl := parsePartial(r, "pages/settings/webhooks/delivery-row.html")
+	if err := tmpl.Execute(w, redelivery); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+func renderWebhookForm(w http.ResponseWriter, r *http.Request, form models.WebhookForm) {
+	tmpl := parsePartial(r, "pages/settings/webhooks/form.html")
+	if err := tmpl.Execute(w, form); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// AccountSettings lets users change their username, email address and password, or delete their account.
+func AccountSettings(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/settings/account/account.html",
+		"pages/settings/account/username-form.html",
+		"pages/settings/account/email-form.html",
+		"pages/settings/account/password-form.html",
+		"pages/settings/account/delete-form.html",
+	)
+
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := database.GetUserById(database.DB, userCtx.Id)
+	if err != nil || user == nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Email        string
+		Verified     bool
+		UsernameForm models.UsernameForm
+		EmailForm    models.ChangeEmailForm
+		PasswordForm models.ChangePasswordForm
+		DeleteForm   models.DeleteAccountForm
+	}{
+		Email:    user.Email,
+		Verified: user.IsVerified(),
+	}
+	data.UsernameForm.Values.Username = user.Username
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
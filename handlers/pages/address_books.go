@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+type bookMemberRow struct {
+	models.AddressBookMember
+	// Manage is set when the user viewing the book owns it and the member isn't themselves,
+	// who leave the book instead of changing their own permission.
+	Manage bool
+	// Fixed is set for the address book of an organization, which members only leave along with the organization.
+	Fixed bool
+}
+
+type bookInvitationFormView struct {
+	BookId int64
+	Form   models.BookInvitationForm
+}
+
+// AddressBooks lists the user's address books in the workspace they work in and the invitations
+// to its books sent to their email address, and lets them create shared books.
+func AddressBooks(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/books/books.html",
+		"pages/books/book-form.html",
+	)
+
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	tenant := database.ForTenant(database.DB, user.OrgId)
+
+	books, err := tenant.ListAddressBooks(user.Id)
+	if err != nil {
+		log.Printf("Error listing address books: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invitations, err := tenant.ListInvitationsForEmail(user.Email, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error listing address book invitations: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	canCreate := true
+	if user.OrgId != 0 {
+		org, err := database.GetUserOrganization(database.DB, user.Id, user.OrgId)
+		if err != nil {
+			log.Printf("Error retrieving organization: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		canCreate = org != nil && org.CanCreateBooks()
+	}
+
+	data := struct {
+		Books       []models.AddressBook
+		Invitations []models.AddressBookInvitation
+		Form        models.AddressBookForm
+		CanCreate   bool
+	}{Books: books, Invitations: invitations, CanCreate: canCreate}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// AddressBook shows who an address book is shared with. Owners also manage its members and invitations there.
+func AddressBook(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/books/book.html",
+		"pages/books/member-row.html",
+		"pages/books/invitation-form.html",
+		"pages/books/invitation-row.html",
+	)
+
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	tenant := database.ForTenant(database.DB, user.OrgId)
+
+	book, err := tenant.GetAddressBook(user.Id, id)
+	if err != nil {
+		log.Printf("Error retrieving address book: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if book == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	members, err := tenant.ListBookMembers(book.Id)
+	if err != nil {
+		log.Printf("Error listing address book members: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]bookMemberRow, len(members))
+	for i, member := range members {
+		rows[i] = bookMemberRow{AddressBookMember: member, Manage: book.IsOwner() && member.UserId != user.Id, Fixed: book.Default}
+	}
+
+	// Only owners see who else was invited.
+	var invitations []models.AddressBookInvitation
+	if book.IsOwner() && !book.Personal {
+		invitations, err = tenant.ListBookInvitations(book.Id, time.Now().UTC())
+		if err != nil {
+			log.Printf("Error listing address book invitations: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	data := struct {
+		Book           *models.AddressBook
+		UserId         int64
+		Members        []bookMemberRow
+		Invitations    []models.AddressBookInvitation
+		InvitationForm bookInvitationFormView
+	}{
+		Book:        book,
+		UserId:      user.Id,
+		Members:     rows,
+		Invitations: invitations,
+		InvitationForm: bookInvitationFormView{
+			BookId: book.Id,
+			Form:   models.BookInvitationForm{Values: models.BookInvitationFormFields{Permission: models.BookEditor}},
+		},
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+const (
+	// adminUserListLimit caps how many users a search in the admin console shows.
+	adminUserListLimit = 50
+	// auditTrailLimit caps how many recent events of the audit trail are shown.
+	auditTrailLimit = 50
+)
+
+type adminUserRow struct {
+	models.User
+	// Self is set for the admin viewing the console, who can't act on their own account from it.
+	Self bool
+}
+
+// AdminConsole lets admins find users and manage their accounts, and invite people while registration is invite-only.
+// The user list and audit trail are loaded as partials.
+func AdminConsole(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/admin/admin.html",
+		"pages/admin/invitation-form.html",
+		"pages/admin/invitation-row.html",
+	)
+
+	invitations, err := database.ListUsableInvitations(database.DB, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error listing invitations: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		RegistrationMode string
+		InvitationForm   models.InvitationForm
+		Invitations      []models.Invitation
+	}{
+		RegistrationMode: config.RegistrationMode,
+		Invitations:      invitations,
+	}
+	data.InvitationForm.Values.MaxUses = "1"
+	data.InvitationForm.Values.ExpiresIn = "7"
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// AdminUserList renders the users whose username or email contains the search query.
+func AdminUserList(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	users, err := database.ListUsers(database.DB, strings.TrimSpace(r.URL.Query().Get("search")), adminUserListLimit)
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]adminUserRow, len(users))
+	for i, user := range users {
+		rows[i] = adminUserRow{User: user, Self: user.Id == userCtx.Id}
+	}
+
+	tmpl := parsePartial(r,
+		"pages/admin/users.html",
+		"pages/admin/user-row.html",
+	)
+	if err := tmpl.Execute(w, rows); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// AuditTrail renders the most recent events of the audit trail matching the filters in the query,
+// along with whether the trail is still intact.
+func AuditTrail(w http.ResponseWriter, r *http.Request) {
+	filter := auditFilter(r)
+	events, err := database.ListAuditEvents(database.DB, filter, auditTrailLimit)
+	if err != nil {
+		log.Printf("Error listing audit events: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	brokenAt, err := database.VerifyAuditChain(database.DB)
+	if err != nil {
+		log.Printf("Error verifying audit trail: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Events []models.AuditEvent
+		// BrokenAt is the ID of the first event that was tampered with, if any.
+		BrokenAt int64
+	}{events, brokenAt}
+
+	tmpl := parsePartial(r, "pages/admin/audit.html")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// ExportAuditTrail downloads every event of the audit trail matching the filters in the query as CSV, oldest first.
+// Hashes are included so the export can be checked against the database later. Exporting is itself audited.
+func ExportAuditTrail(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	filter := auditFilter(r)
+	events, err := database.ListAuditEvents(database.DB, filter, -1)
+	if err != nil {
+		log.Printf("Error listing audit events: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slices.Reverse(events)
+
+	detail := map[string]any{"count": len(events)}
+	for key, value := range map[string]string{"actor": filter.Actor, "from": r.URL.Query().Get("from"), "to": r.URL.Query().Get("to")} {
+		if value != "" {
+			detail[key] = value
+		}
+	}
+	if err := auth.RecordAudit(database.DB, r, userCtx, models.AuditTrailExported, nil, detail); err != nil {
+		log.Printf("Error recording audit event: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	filename := "audit-trail-" + time.Now().UTC().Format("2006-01-02") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "time", "actor_id", "actor", "action", "target_id", "target", "ip", "detail", "hash"})
+	for _, event := range events {
+		out.Write([]string{
+			strconv.FormatInt(event.Id, 10),
+			event.CreatedAt.UTC().Format(time.RFC3339Nano),
+			strconv.FormatInt(event.ActorId, 10),
+			event.ActorName,
+			event.Action,
+			strconv.FormatInt(event.TargetId, 10),
+			event.TargetName,
+			event.IP,
+			event.Detail,
+			event.Hash,
+		})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("Error writing audit trail export: %v", err)
+	}
+}
+
+// auditFilter reads the audit trail filters from the query. Dates are days in UTC, both included,
+// and are ignored when malformed.
+func auditFilter(r *http.Request) models.AuditFilter {
+	query := r.URL.Query()
+	filter := models.AuditFilter{Actor: strings.TrimSpace(query.Get("actor"))}
+
+	if from, err := time.Parse(time.DateOnly, query.Get("from")); err == nil {
+		filter.From = from
+	}
+	if to, err := time.Parse(time.DateOnly, query.Get("to")); err == nil {
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	return filter
+}
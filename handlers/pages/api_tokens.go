@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// APITokens lists the user's personal access tokens and lets them create new ones.
+func APITokens(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/settings/tokens/tokens.html",
+		"pages/settings/tokens/form.html",
+		"pages/settings/tokens/row.html",
+	)
+
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := database.ListAPITokens(database.DB, user.Id)
+	if err != nil {
+		log.Printf("Error listing api tokens: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	form := models.APITokenForm{}
+	form.Values.Scopes = []string{auth.ScopeContactsRead}
+	form.Values.ExpiresIn = "30"
+
+	data := struct {
+		Form   models.APITokenForm
+		Tokens []models.APIToken
+	}{form, tokens}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
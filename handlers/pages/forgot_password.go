@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"layouts/auth.html",
+		"pages/forgot-password/forgot-password.html",
+		"pages/forgot-password/form.html",
+	)
+
+	if err := tmpl.Execute(w, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
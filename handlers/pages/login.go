@@ -1,21 +1,48 @@
 package handlers
 
 import (
-	"html/template"
 	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/sso"
 )
 
+// loginErrors explain why signing in with an identity provider or a magic link failed,
+// by the error code it redirected with.
+var loginErrors = map[string]string{
+	"sso_cancelled":            "Sign in was cancelled",
+	"sso_failed":               "Sign in failed, please try again",
+	"sso_unavailable":          "The sign in provider is unavailable, please try again later",
+	"sso_email_unverified":     "Your email address is not verified with that provider",
+	"sso_registration_closed":  "There is no account with that email address, and registration is not open",
+	"magic_link_invalid":       "That sign-in link is invalid, expired or already used",
+	"magic_link_other_browser": "Open the sign-in link in the same browser you asked for it from",
+	"account_disabled":         "This account has been disabled",
+}
+
+type loginProvider struct {
+	Name        string
+	DisplayName string
+}
+
 func Login(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.ParseFiles(
-		"web/templates/layouts/base.html",
-		"web/templates/layouts/auth.html",
-		"web/templates/commons/header.html",
-		"web/templates/commons/footer.html",
-		"web/templates/pages/login/login.html",
-		"web/templates/pages/login/form.html",
-	))
-
-	if err := tmpl.Execute(w, nil); err != nil {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"layouts/auth.html",
+		"pages/login/login.html",
+		"pages/login/form.html",
+	)
+
+	var providers []loginProvider
+	for _, p := range sso.Providers() {
+		providers = append(providers, loginProvider{Name: p.Name(), DisplayName: p.DisplayName()})
+	}
+
+	data := struct {
+		Providers []loginProvider
+		Error     string
+	}{providers, loginErrors[r.URL.Query().Get("error")]}
+
+	if err := tmpl.Execute(w, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+type orgMemberRow struct {
+	models.OrgMembership
+	// Manage is set when the user viewing the page is an admin and the member isn't themselves,
+	// who leave the organization instead of changing their own role.
+	Manage bool
+}
+
+// NewOrganization lets admins of the app create an organization.
+func NewOrganization(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/orgs/new.html",
+		"pages/orgs/new-form.html",
+	)
+
+	if err := tmpl.Execute(w, models.OrganizationForm{}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Organization shows the members of the organization the user works in.
+// Its admins also change its settings and manage its members there.
+func Organization(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/orgs/org.html",
+		"pages/orgs/settings-form.html",
+		"pages/orgs/member-form.html",
+		"pages/orgs/member-row.html",
+	)
+
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	if user.OrgId == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	org, err := database.GetUserOrganization(database.DB, user.Id, user.OrgId)
+	if err != nil {
+		log.Printf("Error retrieving organization: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if org == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	members, err := database.ListOrgMembers(database.DB, org.Id)
+	if err != nil {
+		log.Printf("Error listing organization members: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]orgMemberRow, len(members))
+	for i, member := range members {
+		rows[i] = orgMemberRow{OrgMembership: member, Manage: org.IsAdmin() && member.UserId != user.Id}
+	}
+
+	settingsForm := models.OrgSettingsForm{Values: models.OrgSettingsFormFields{
+		Name:              org.Name,
+		DefaultPermission: org.DefaultPermission,
+	}}
+	if org.MembersCanCreateBooks {
+		settingsForm.Values.MembersCanCreateBooks = "on"
+	}
+
+	data := struct {
+		Org          *models.Organization
+		UserId       int64
+		Members      []orgMemberRow
+		SettingsForm models.OrgSettingsForm
+		MemberForm   models.OrgMemberForm
+	}{
+		Org:          org,
+		UserId:       user.Id,
+		Members:      rows,
+		SettingsForm: settingsForm,
+		MemberForm:   models.OrgMemberForm{Values: models.OrgMemberFormFields{Role: models.OrgMember}},
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
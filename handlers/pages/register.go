@@ -1,21 +1,51 @@
 package handlers
 
 import (
-	"html/template"
+	"errors"
+	"log"
 	"net/http"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
 )
 
 func Register(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.ParseFiles(
-		"web/templates/layouts/base.html",
-		"web/templates/layouts/auth.html",
-		"web/templates/commons/header.html",
-		"web/templates/commons/footer.html",
-		"web/templates/pages/register/register.html",
-		"web/templates/pages/register/form.html",
-	))
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"layouts/auth.html",
+		"pages/register/register.html",
+		"pages/register/form.html",
+	)
+
+	data := struct {
+		Form        models.RegisterForm
+		Closed      bool
+		InviteError string
+	}{Closed: config.RegistrationMode == config.RegistrationClosed}
+
+	// While registration is invite-only, the form is only shown to people following an invitation link.
+	if config.RegistrationMode == config.RegistrationInviteOnly {
+		token := r.URL.Query().Get("invite")
+		invitation, err := auth.CheckInvitation(database.DB, token, "")
+		switch {
+		case errors.Is(err, auth.ErrInvalidInvitation) && token == "":
+			data.InviteError = "Registration is by invitation only. Ask an administrator for an invitation link."
+		case errors.Is(err, auth.ErrInvalidInvitation):
+			data.InviteError = "This invitation is invalid, expired or used up. Ask an administrator for a new one."
+		case err != nil:
+			log.Printf("Error checking invitation: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		default:
+			data.Form.Values.Invite = token
+			data.Form.Values.Email = invitation.Email
+			data.Form.EmailBound = invitation.Email != ""
+		}
+	}
 
-	if err := tmpl.Execute(w, nil); err != nil {
+	if err := tmpl.Execute(w, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/render"
+)
+
+// parsePage returns the templates of a full page, starting with its layout, along with the partials,
+// and makes the request's CSRF token, logged in user and flashes available to base.html.
+func parsePage(r *http.Request, files ...string) *template.Template {
+	return render.PageTemplate(r, files...)
+}
+
+// parsePartial returns the templates of a fragment swapped into the page, with the functions of the request.
+func parsePartial(r *http.Request, files ...string) *template.Template {
+	return render.PartialTemplate(r, files...)
+}
+
+// Fragment renders the fragment a handler answered a form posted without htmx with as a full page,
+// so the form can be corrected and posted again.
+func Fragment(w http.ResponseWriter, r *http.Request, status int, fragment template.HTML) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"layouts/fragment.html",
+	)
+
+	data := struct {
+		Fragment template.HTML
+		Back     string
+	}{fragment, htmx.BackURL(r)}
+
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering fragment: %v", err)
+	}
+}
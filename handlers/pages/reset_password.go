@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"layouts/auth.html",
+		"pages/reset-password/reset-password.html",
+		"pages/reset-password/form.html",
+	)
+
+	resetPasswordForm := models.ResetPasswordForm{}
+	resetPasswordForm.Values.Token = r.URL.Query().Get("token")
+
+	if err := tmpl.Execute(w, resetPasswordForm); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// loginHistoryLimit caps how many login attempts are shown.
+const loginHistoryLimit = 100
+
+type sessionView struct {
+	models.Session
+	// Current is set for the session the page is viewed with.
+	Current bool
+}
+
+// SecuritySettings shows where the user is logged in and their recent login attempts.
+// Both lists are loaded as partials.
+func SecuritySettings(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/settings/security/security.html",
+	)
+
+	if err := tmpl.Execute(w, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SessionList renders the user's active sessions, each of which can be revoked.
+func SessionList(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := database.GetUserById(database.DB, userCtx.Id)
+	if err != nil || user == nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	sessions, err := database.ListSessions(database.DB, user.Id)
+	if err != nil {
+		log.Printf("Error listing sessions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	var views []sessionView
+	for _, session := range sessions {
+		if session.IsActive(now, user.SessionsValidAfter) {
+			views = append(views, sessionView{Session: session, Current: session.Id == userCtx.SessionId})
+		}
+	}
+
+	tmpl := parsePartial(r, "pages/settings/security/sessions.html")
+	if err := tmpl.Execute(w, views); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
+// LoginHistory renders the user's login attempts within the retention period, failed ones included.
+func LoginHistory(w http.ResponseWriter, r *http.Request) {
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	since := time.Now().UTC().Add(-config.LoginHistoryRetention)
+	events, err := database.ListLoginEvents(database.DB, userCtx.Id, since, loginHistoryLimit)
+	if err != nil {
+		log.Printf("Error listing login events: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Events []models.LoginEvent
+		Days   int
+	}{events, int(config.LoginHistoryRetention.Hours() / 24)}
+
+	tmpl := parsePartial(r, "pages/settings/security/history.html")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/qrcode"
+)
+
+// TwoFactorChallenge asks users who entered a correct password for their second factor.
+func TwoFactorChallenge(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("two_factor")
+	if err != nil {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+	if _, _, err := auth.ParseTwoFactorChallengeToken(cookie.Value); err != nil {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"layouts/auth.html",
+		"pages/two-factor/two-factor.html",
+		"pages/two-factor/form.html",
+	)
+
+	if err := tmpl.Execute(w, models.TwoFactorForm{}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// TwoFactorSettings lets users enroll an authenticator app, or manage two-factor authentication once enabled.
+func TwoFactorSettings(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/settings/two-factor/two-factor.html",
+		"pages/settings/two-factor/code-form.html",
+	)
+
+	userCtx, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := database.GetUserById(database.DB, userCtx.Id)
+	if err != nil || user == nil {
+		log.Printf("Error retrieving user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Enabled           bool
+		Secret            string
+		QRCode            template.HTML
+		RecoveryCodesLeft int
+		EnableForm        models.TwoFactorActionForm
+		RegenerateForm    models.TwoFactorActionForm
+		DisableForm       models.TwoFactorActionForm
+	}{
+		Enabled:        user.TwoFactorEnabled(),
+		EnableForm:     models.TwoFactorActionForm{Action: "/api/2fa/enable", Submit: "Enable"},
+		RegenerateForm: models.TwoFactorActionForm{Action: "/api/2fa/recovery-codes", Submit: "Generate new codes"},
+		DisableForm:    models.TwoFactorActionForm{Action: "/api/2fa/disable", Submit: "Disable"},
+	}
+
+	if data.Enabled {
+		data.RecoveryCodesLeft, err = database.CountRecoveryCodes(database.DB, user.Id)
+		if err != nil {
+			log.Printf("Error counting recovery codes: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		secret, uri, err := auth.BeginTOTPEnrollment(database.DB, user)
+		if err != nil {
+			log.Printf("Error starting two-factor enrollment: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		code, err := qrcode.EncodeString(uri, qrcode.Medium)
+		if err != nil {
+			log.Printf("Error encoding QR code: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data.Secret = secret
+		// The SVG is generated by us from escaped module coordinates only.
+		data.QRCode = template.HTML(code.SVG())
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
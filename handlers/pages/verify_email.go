@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+)
+
+// VerifyEmail redeems the verification link sent by email and shows the outcome.
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"layouts/auth.html",
+		"pages/verify-email/verify-email.html",
+	)
+
+	data := struct {
+		Verified bool
+	}{}
+
+	_, err := auth.VerifyEmail(database.DB, r.URL.Query().Get("token"))
+	switch {
+	case err == nil:
+		data.Verified = true
+	case errors.Is(err, auth.ErrInvalidVerificationToken):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		log.Printf("Error verifying email: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// VerifyEmailNotice tells unverified users to check their inbox and lets them resend the link.
+func VerifyEmailNotice(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"layouts/auth.html",
+		"pages/verify-email/notice.html",
+	)
+
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Verified {
+		http.Redirect(w, r, "/contacts", http.StatusSeeOther)
+		return
+	}
+
+	if err := tmpl.Execute(w, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// webhookDeliveryLogLimit caps how many recent deliveries of a webhook are shown.
+const webhookDeliveryLogLimit = 50
+
+// Webhooks lists the user's webhooks and lets them register new ones.
+func Webhooks(w http.ResponseWriter, r *http.Request) {
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/settings/webhooks/webhooks.html",
+		"pages/settings/webhooks/form.html",
+		"pages/settings/webhooks/row.html",
+	)
+
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	webhooks, err := database.ListWebhooks(database.DB, user.Id)
+	if err != nil {
+		log.Printf("Error listing webhooks: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	form := models.WebhookForm{}
+	form.Values.Events = models.WebhookEvents
+
+	data := struct {
+		Form     models.WebhookForm
+		Webhooks []models.Webhook
+	}{form, webhooks}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Webhook shows the log of recent deliveries of one of the user's webhooks, which can be sent again from there.
+func Webhook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.GetUser(r.Context())
+	if !ok {
+		http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := database.GetWebhook(database.DB, user.Id, id)
+	if err != nil {
+		log.Printf("Error retrieving webhook: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if webhook == nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	deliveries, err := database.ListWebhookDeliveries(database.DB, webhook.Id, webhookDeliveryLogLimit)
+	if err != nil {
+		log.Printf("Error listing webhook deliveries: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl := parsePage(r,
+		"layouts/base.html",
+		"pages/settings/webhooks/webhook.html",
+		"pages/settings/webhooks/delivery-row.html",
+	)
+	data := struct {
+		Webhook    *models.Webhook
+		Deliveries []models.WebhookDelivery
+	}{webhook, deliveries}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,297 @@
+// Package assets serves the static files embedded in the binary.
+//
+// Templates link to assets through the asset function, which adds a hash of their content to the file name,
+// like /static/js/toast.3b1f0c9a2e4d.js. Those URLs change along with the content, so browsers may cache them
+// for good. Plain URLs keep working for links that can't be fingerprinted, but are revalidated with their ETag.
+//
+// Responses are compressed with brotli when `npm run compress` in web/ built a variant of the asset, and with
+// gzip otherwise, from a built variant or compressed when the assets are loaded. Variants are named after the
+// hash of the content they were compressed from, like js/toast.js.3b1f0c9a2e4d.br, so stale ones are ignored.
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joangavelan/contacts-app/web"
+)
+
+// Prefix is the path assets are served under.
+const Prefix = "/static/"
+
+// hashLength is how many hex digits of the content hash go into a fingerprinted file name.
+const hashLength = 12
+
+// Encodings of the precompressed variants, in order of preference, and the extension of their files.
+var encodings = []struct{ name, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+type asset struct {
+	name     string
+	hash     string
+	variants map[string][]byte
+}
+
+// Server serves the assets of a file system.
+type Server struct {
+	fsys   fs.FS
+	reload bool
+
+	mu            sync.Mutex
+	assets        map[string]*asset
+	fingerprinted map[string]*asset
+	version       string
+}
+
+// Default serves the assets of the app, from the binary unless main reads them from disk.
+var Default = mustLoad(New(web.Static))
+
+func mustLoad(s *Server, err error) *Server {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// New loads the assets of fsys.
+func New(fsys fs.FS) (*Server, error) {
+	s := &Server{fsys: fsys}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Dir loads the assets in dir, and loads them again whenever one of them is added, changed or removed.
+func Dir(dir string) (*Server, error) {
+	s := &Server{fsys: os.DirFS(dir), reload: true}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path returns the fingerprinted URL of the asset of Default, which templates call as asset.
+func Path(name string) (string, error) {
+	return Default.Path(name)
+}
+
+// Path returns the fingerprinted URL of the asset.
+func (s *Server) Path(name string) (string, error) {
+	a, err := s.lookup(func() *asset { return s.assets[name] })
+	if err != nil {
+		return "", err
+	}
+	if a == nil {
+		return "", fmt.Errorf("asset %s not found", name)
+	}
+	return Prefix + fingerprint(a.name, a.hash), nil
+}
+
+// ServeHTTP serves the asset the request names, with its fingerprinted name or its plain one.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, Prefix)
+
+	immutable := true
+	a, err := s.lookup(func() *asset {
+		if a, ok := s.fingerprinted[name]; ok {
+			return a
+		}
+		immutable = false
+		return s.assets[name]
+	})
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if a == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), a.variants)
+	etag := a.hash
+	if encoding != "" {
+		etag += "-" + encoding
+		w.Header().Set("Content-Encoding", encoding)
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.Header().Set("ETag", `"`+etag+`"`)
+	if immutable {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if contentType := mime.TypeByExtension(path.Ext(a.name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	// ServeContent answers If-None-Match with a 304, and HEAD and range requests.
+	http.ServeContent(w, r, a.name, time.Time{}, bytes.NewReader(a.variants[encoding]))
+}
+
+// lookup finds an asset, loading the assets again first if they changed on disk.
+// Assets embedded in the binary never change, so they are found without locking.
+func (s *Server) lookup(find func() *asset) (*asset, error) {
+	if !s.reload {
+		return find(), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, err := s.stat()
+	if err != nil {
+		return nil, err
+	}
+	if version != s.version {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	return find(), nil
+}
+
+// load reads every asset of the file system along with its precompressed variants,
+// and compresses the ones without a gzip variant.
+func (s *Server) load() error {
+	files := make(map[string][]byte)
+	err := fs.WalkDir(s.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		files[name], err = fs.ReadFile(s.fsys, name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error reading assets: %w", err)
+	}
+
+	assets := make(map[string]*asset)
+	fingerprinted := make(map[string]*asset)
+	for name, data := range files {
+		if isVariant(name, files) {
+			continue
+		}
+
+		sum := sha256.Sum256(data)
+		a := &asset{name: name, hash: hex.EncodeToString(sum[:])[:hashLength], variants: map[string][]byte{"": data}}
+		for _, encoding := range encodings {
+			if variant, ok := files[name+"."+a.hash+encoding.ext]; ok {
+				a.variants[encoding.name] = variant
+			}
+		}
+		if _, ok := a.variants["gzip"]; !ok {
+			if compressed, err := compress(data); err != nil {
+				return fmt.Errorf("error compressing %s: %w", name, err)
+			} else if len(compressed) < len(data) {
+				a.variants["gzip"] = compressed
+			}
+		}
+
+		assets[name] = a
+		fingerprinted[fingerprint(name, a.hash)] = a
+	}
+
+	s.assets, s.fingerprinted = assets, fingerprinted
+	if s.reload {
+		s.version, err = s.stat()
+	}
+	return err
+}
+
+// stat sums up the names and modification times of the assets, to tell whether any of them changed.
+func (s *Server) stat() (string, error) {
+	var version strings.Builder
+	err := fs.WalkDir(s.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&version, "%s %d %s\n", name, info.Size(), info.ModTime().Format(time.RFC3339Nano))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error reading assets: %w", err)
+	}
+	return version.String(), nil
+}
+
+// fingerprint adds the hash to the file name, before its extension.
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// isVariant reports whether the file is a precompressed variant of another one, stale or not.
+func isVariant(name string, files map[string][]byte) bool {
+	for _, encoding := range encodings {
+		if hashed, ok := strings.CutSuffix(name, encoding.ext); ok {
+			if original := strings.TrimSuffix(hashed, path.Ext(hashed)); len(hashed)-len(original) == hashLength+1 {
+				if _, ok := files[original]; ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// negotiateEncoding picks the most preferred variant the Accept-Encoding header accepts,
+// or the uncompressed content, named "".
+func negotiateEncoding(acceptEncoding string, variants map[string][]byte) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if name, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && name == "q" {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(coding))] = q > 0
+	}
+
+	for _, encoding := range encodings {
+		if _, ok := variants[encoding.name]; ok && accepted[encoding.name] {
+			return encoding.name
+		}
+	}
+	return ""
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
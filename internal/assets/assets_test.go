@@ -0,0 +1,112 @@
+package assets
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var script = strings.Repeat("console.log('Hello');\n", 20)
+
+func hashOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+func get(s *Server, path string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	hash := hashOf(script)
+	s, err := New(fstest.MapFS{
+		"js/app.js":                 {Data: []byte(script)},
+		"js/app.js." + hash + ".br": {Data: []byte("brotli")},
+		"js/app.js.000000000000.br": {Data: []byte("stale")},
+		"css/tiny.css":              {Data: []byte("a{}")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := s.Path("js/app.js")
+	if err != nil || path != "/static/js/app."+hash+".js" {
+		t.Fatalf("expected a fingerprinted path, got %q %v", path, err)
+	}
+	if _, err := s.Path("js/missing.js"); err == nil {
+		t.Errorf("expected an error for a missing asset")
+	}
+
+	t.Run("Fingerprinted URLs are immutable", func(t *testing.T) {
+		rec := get(s, path, nil)
+		if rec.Body.String() != script || rec.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+			t.Errorf("expected the script cached for good, got %v", rec.Header())
+		}
+		if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/javascript") {
+			t.Errorf("expected a JavaScript content type, got %q", rec.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("Plain URLs are revalidated", func(t *testing.T) {
+		rec := get(s, "/static/js/app.js", nil)
+		if rec.Body.String() != script || rec.Header().Get("Cache-Control") != "no-cache" {
+			t.Errorf("expected the script to be revalidated, got %v", rec.Header())
+		}
+
+		rec = get(s, "/static/js/app.js", map[string]string{"If-None-Match": rec.Header().Get("ETag")})
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("expected a 304 for a matching ETag, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Brotli is preferred to gzip", func(t *testing.T) {
+		rec := get(s, path, map[string]string{"Accept-Encoding": "gzip, deflate, br"})
+		if rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != "brotli" {
+			t.Errorf("expected the brotli variant, got %v %q", rec.Header(), rec.Body)
+		}
+		if rec.Header().Get("ETag") != `"`+hash+`-br"` || rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("expected an ETag for the variant, got %v", rec.Header())
+		}
+	})
+
+	t.Run("gzip is compressed on load", func(t *testing.T) {
+		rec := get(s, path, map[string]string{"Accept-Encoding": "gzip, br;q=0"})
+		if rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected the gzip variant, got %v", rec.Header())
+		}
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := io.ReadAll(zr); string(data) != script {
+			t.Errorf("expected the gzip variant to hold the script, got %q", data)
+		}
+	})
+
+	t.Run("Small files aren't compressed", func(t *testing.T) {
+		rec := get(s, "/static/css/tiny.css", map[string]string{"Accept-Encoding": "gzip"})
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "a{}" {
+			t.Errorf("expected the file as it is, got %v", rec.Header())
+		}
+	})
+
+	t.Run("Variants aren't assets", func(t *testing.T) {
+		for _, path := range []string{"/static/js/app.js.000000000000.br", "/static/js/missing.js"} {
+			if rec := get(s, path, nil); rec.Code != http.StatusNotFound {
+				t.Errorf("expected %s not to be found, got %d", path, rec.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+const emailChangedEmail = `Hi %s,
+
+The email address of your Contacts App account was changed to %s.
+If you didn't make this change, reset your password at %s/auth/forgot-password and contact us.
+`
+
+// ChangeEmail changes the user's email address and sends a verification link to the new one.
+// The old address is told about the change, so a hijacked account doesn't go unnoticed.
+// The change is recorded in the audit trail along with it. Failing to send either email is logged rather than
+// returned, since the change itself succeeded.
+func ChangeEmail(db *sql.DB, r *http.Request, m mailer.Mailer, user *models.User, email string) error {
+	audit, err := NewUserAuditEvent(r, user, models.AuditEmailChanged, map[string]any{"from": user.Email, "to": email})
+	if err != nil {
+		return err
+	}
+	if err := database.UpdateUserEmail(db, user.Id, email, time.Now().UTC(), audit); err != nil {
+		return err
+	}
+
+	oldEmail := user.Email
+	user.Email = email
+	user.VerifiedAt = nil
+	user.VerificationSentAt = nil
+
+	if err := SendVerificationEmail(db, m, user); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+
+	err = m.Send(mailer.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body:    fmt.Sprintf(emailChangedEmail, user.Username, email, config.AppURL),
+	})
+	if err != nil {
+		log.Printf("Error sending email change notice: %v", err)
+	}
+
+	return nil
+}
+
+// ChangePassword sets the user's new password. Sessions issued before the change are invalidated.
+func ChangePassword(db *sql.DB, user *models.User, newPassword string) error {
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// JWT issued-at claims have a one second resolution.
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := database.UpdateUserPassword(db, user.Id, hashedPassword, now, nil); err != nil {
+		return err
+	}
+
+	user.Password = hashedPassword
+	user.SessionsValidAfter = now
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+func TestChangeEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	m := &mailer.MemoryMailer{}
+	verifiedAt := time.Now()
+	user := &models.User{Id: 1, Username: "testuser", Email: "old@example.com", VerifiedAt: &verifiedAt}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET email").WithArgs("new@example.com", user.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE password_resets").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE magic_links").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT hash FROM audit_events").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs(user.Id, "testuser", models.AuditEmailChanged, sqlmock.AnyArg(), "", sqlmock.AnyArg(), `{"from":"old@example.com","to":"new@example.com"}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE users SET verificationSentAt").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := ChangeEmail(db, httptest.NewRequest("POST", "/api/settings/email", nil), m, user, "new@example.com"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if user.Email != "new@example.com" || user.IsVerified() {
+		t.Errorf("expected the user to have an unverified new email, got %q verified=%v", user.Email, user.IsVerified())
+	}
+
+	messages := m.Messages()
+	if len(messages) != 2 || messages[0].To != "new@example.com" || messages[1].To != "old@example.com" {
+		t.Fatalf("expected a verification email to the new address and a notice to the old one, got %+v", messages)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+// ErrCannotImpersonate is returned when an admin tries to log in as themselves, another admin or a disabled user.
+var ErrCannotImpersonate = errors.New("user can't be impersonated")
+
+// ForcePasswordReset replaces the user's password with a random one nobody knows, logs them out everywhere
+// and emails them a link to choose a new password. The reset is recorded in the audit trail along with it.
+func ForcePasswordReset(db *sql.DB, r *http.Request, m mailer.Mailer, admin *models.UserContext, user *models.User) error {
+	audit, err := NewAuditEvent(r, admin, models.AuditUserPasswordReset, user, nil)
+	if err != nil {
+		return err
+	}
+
+	randomPassword, _, err := GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := HashPassword(randomPassword)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := database.UpdateUserPassword(db, user.Id, hashedPassword, now, audit); err != nil {
+		return err
+	}
+
+	if err := SendPasswordResetEmail(db, m, user); err != nil {
+		return fmt.Errorf("error sending password reset email: %w", err)
+	}
+
+	return nil
+}
+
+// StartImpersonation starts a session in which admin acts as target and returns its token.
+// The session is short-lived, and is recorded in the audit trail rather than the user's login history.
+func StartImpersonation(db *sql.DB, r *http.Request, admin *models.UserContext, target *models.User) (string, error) {
+	if target.Id == admin.Id || target.Role == models.RoleAdmin || target.IsDisabled() {
+		return "", ErrCannotImpersonate
+	}
+
+	now := time.Now().UTC()
+
+	session := &models.Session{
+		UserId:         target.Id,
+		Method:         models.LoginMethodImpersonation,
+		IP:             ClientIP(r),
+		UserAgent:      userAgent(r),
+		CreatedAt:      now,
+		IssuedAt:       now,
+		LastSeenAt:     now,
+		ExpiresAt:      now.Add(config.ImpersonationExpiration),
+		ImpersonatorId: admin.Id,
+	}
+	if err := database.CreateSession(db, session); err != nil {
+		return "", err
+	}
+
+	if err := RecordAudit(db, r, admin, models.AuditImpersonationStarted, target, nil); err != nil {
+		return "", err
+	}
+
+	return GenerateJWT(target.Id, session.Id, target.Username, target.Email, target.Role)
+}
+
+// StopImpersonation ends the impersonation session the request was made with.
+func StopImpersonation(db *sql.DB, r *http.Request, userCtx *models.UserContext) error {
+	target, err := database.GetUserById(db, userCtx.Id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := database.RevokeSession(db, userCtx.Id, userCtx.SessionId, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return RecordAudit(db, r, userCtx, models.AuditImpersonationStopped, target, nil)
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+var apiTokenColumns = []string{"id", "userId", "name", "prefix", "scopes", "expiresAt", "lastUsedAt", "createdAt"}
+
+func TestCreateAPIToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO api_tokens").
+		WithArgs(int64(1), "backup", sqlmock.AnyArg(), sqlmock.AnyArg(), "contacts:read contacts:write", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+
+	token, apiToken, err := CreateAPIToken(db, 1, "backup", []string{ScopeContactsRead, ScopeContactsWrite}, time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		t.Errorf("expected the token to start with %q, got %q", apiTokenPrefix, token)
+	}
+	if apiToken.Id != 5 || apiToken.Prefix != token[:apiTokenDisplayLength] {
+		t.Errorf("unexpected token record %+v", apiToken)
+	}
+	if apiToken.ExpiresAt == nil {
+		t.Errorf("expected the token to expire")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestCreateAPIToken_UnknownScope(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	if _, _, err := CreateAPIToken(db, 1, "backup", []string{"admin"}, 0); err != ErrUnknownScope {
+		t.Errorf("expected ErrUnknownScope, got %v", err)
+	}
+}
+
+func TestAuthenticateAPIToken(t *testing.T) {
+	token := apiTokenPrefix + "secret"
+	recently := time.Now().Add(-time.Second)
+
+	t.Run("valid token", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM api_tokens WHERE tokenHash = ?").
+			WithArgs(HashToken(token)).
+			WillReturnRows(sqlmock.NewRows(apiTokenColumns).AddRow(5, 1, "backup", "cat_secret", "contacts:read", nil, nil, time.Now()))
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE id = ?").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "testuser", "testuser@example.com", "hash", nil, nil, nil, nil, nil, nil, "user", nil))
+		mock.ExpectExec("UPDATE api_tokens SET lastUsedAt").
+			WithArgs(sqlmock.AnyArg(), 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		user, apiToken, err := AuthenticateAPIToken(db, token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if user.Id != 1 || apiToken.Id != 5 || !apiToken.HasScope(ScopeContactsRead) {
+			t.Errorf("unexpected user %+v or token %+v", user, apiToken)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("recently used token is not touched", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM api_tokens WHERE tokenHash = ?").
+			WithArgs(HashToken(token)).
+			WillReturnRows(sqlmock.NewRows(apiTokenColumns).AddRow(5, 1, "backup", "cat_secret", "contacts:read", nil, recently, time.Now()))
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE id = ?").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "testuser", "testuser@example.com", "hash", nil, nil, nil, nil, nil, nil, "user", nil))
+
+		if _, _, err := AuthenticateAPIToken(db, token); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM api_tokens WHERE tokenHash = ?").
+			WithArgs(HashToken(token)).
+			WillReturnRows(sqlmock.NewRows(apiTokenColumns).AddRow(5, 1, "backup", "cat_secret", "contacts:read", recently, nil, time.Now()))
+
+		if _, _, err := AuthenticateAPIToken(db, token); err != ErrInvalidAPIToken {
+			t.Errorf("expected ErrInvalidAPIToken, got %v", err)
+		}
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM api_tokens WHERE tokenHash = ?").
+			WithArgs(HashToken(token)).
+			WillReturnRows(sqlmock.NewRows(apiTokenColumns))
+
+		if _, _, err := AuthenticateAPIToken(db, token); err != ErrInvalidAPIToken {
+			t.Errorf("expected ErrInvalidAPIToken, got %v", err)
+		}
+	})
+
+	t.Run("wrong prefix", func(t *testing.T) {
+		if _, _, err := AuthenticateAPIToken(nil, "secret"); err != ErrInvalidAPIToken {
+			t.Errorf("expected ErrInvalidAPIToken, got %v", err)
+		}
+	})
+}
+
+func TestMiddleware_InvalidBearerToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	database.DB = db
+	defer func() { database.DB = nil }()
+
+	mock.ExpectQuery("SELECT (.+) FROM api_tokens WHERE tokenHash = ?").
+		WillReturnRows(sqlmock.NewRows(apiTokenColumns))
+
+	r := httptest.NewRequest("GET", "/contacts", nil)
+	r.Header.Set("Authorization", "Bearer "+apiTokenPrefix+"revoked")
+	// A valid-looking session cookie must not be used instead of the bad token.
+	r.AddCookie(&http.Cookie{Name: "token", Value: "session"})
+
+	rec := httptest.NewRecorder()
+	Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected the request to be rejected")
+	})).ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"invalid_token"`) {
+		t.Errorf("expected an invalid_token JSON error, got %s", rec.Body.String())
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected a WWW-Authenticate header")
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   *models.UserContext
+		status int
+	}{
+		{name: "session", user: &models.UserContext{Id: 1}, status: http.StatusOK},
+		{name: "token with scope", user: &models.UserContext{Id: 1, APITokenId: 5, Scopes: []string{ScopeContactsWrite}}, status: http.StatusOK},
+		{name: "token without scope", user: &models.UserContext{Id: 1, APITokenId: 5, Scopes: []string{ScopeContactsRead}}, status: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/v1/contacts", nil)
+			r = r.WithContext(context.WithValue(r.Context(), userContextKey, test.user))
+
+			rec := httptest.NewRecorder()
+			RequireScope(ScopeContactsWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, r)
+
+			if rec.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// RecordAudit adds an action taken by actor on target, if any, to the audit trail, with detail describing it further.
+// Actions taken while impersonating are attributed to the admin rather than the impersonated user.
+func RecordAudit(db *sql.DB, r *http.Request, actor *models.UserContext, action string, target *models.User, detail map[string]any) error {
+	event, err := NewAuditEvent(r, actor, action, target, detail)
+	if err != nil {
+		return err
+	}
+	return database.CreateAuditEvent(db, event)
+}
+
+// RecordUserAudit adds an action users took on their own account, e.g. logging in, to the audit trail.
+func RecordUserAudit(db *sql.DB, r *http.Request, user *models.User, action string, detail map[string]any) error {
+	event, err := NewUserAuditEvent(r, user, action, detail)
+	if err != nil {
+		return err
+	}
+	return database.CreateAuditEvent(db, event)
+}
+
+// NewAuditEvent describes an action as RecordAudit does, for a database function to record in the
+// same transaction as the change itself.
+func NewAuditEvent(r *http.Request, actor *models.UserContext, action string, target *models.User, detail map[string]any) (*models.AuditEvent, error) {
+	event := &models.AuditEvent{
+		ActorId:   actor.Id,
+		ActorName: actor.Username,
+		Action:    action,
+	}
+	if actor.IsImpersonated() {
+		event.ActorId = actor.ImpersonatorId
+		event.ActorName = actor.ImpersonatorName
+	}
+	if target != nil {
+		event.TargetId = target.Id
+		event.TargetName = target.Username
+	}
+
+	return event, describeAuditEvent(r, event, detail)
+}
+
+// NewUserAuditEvent describes an action as RecordUserAudit does, for a database function to record in the
+// same transaction as the change itself.
+func NewUserAuditEvent(r *http.Request, user *models.User, action string, detail map[string]any) (*models.AuditEvent, error) {
+	event := &models.AuditEvent{
+		ActorId:   user.Id,
+		ActorName: user.Username,
+		Action:    action,
+	}
+
+	return event, describeAuditEvent(r, event, detail)
+}
+
+// RecordUnknownLogin adds a failed attempt to log in with an email no account has to the audit trail.
+func RecordUnknownLogin(db *sql.DB, r *http.Request, email, method string) error {
+	event := &models.AuditEvent{Action: models.AuditLoginFailed}
+	if err := describeAuditEvent(r, event, map[string]any{"email": email, "method": method}); err != nil {
+		return err
+	}
+
+	return database.CreateAuditEvent(db, event)
+}
+
+// RecordLogout adds logging out to the audit trail, if the request carries a valid session cookie.
+// Logging out while impersonating is attributed to the admin.
+func RecordLogout(db *sql.DB, r *http.Request) error {
+	cookie, err := r.Cookie("token")
+	if err != nil {
+		return nil
+	}
+	claims, err := ValidateJWT(cookie.Value)
+	if err != nil {
+		return nil
+	}
+
+	actor := &models.UserContext{Id: claims.Sub, Username: claims.Username}
+	session, err := database.GetSession(db, claims.Sid)
+	if err != nil {
+		return err
+	}
+	if session != nil && session.ImpersonatorId != 0 {
+		admin, err := database.GetUserById(db, session.ImpersonatorId)
+		if err != nil {
+			return err
+		}
+		if admin != nil {
+			actor.ImpersonatorId = admin.Id
+			actor.ImpersonatorName = admin.Username
+		}
+	}
+
+	return RecordAudit(db, r, actor, models.AuditLogout, nil, map[string]any{"sessionId": claims.Sid})
+}
+
+// describeAuditEvent sets the detail of an event, and where and when it happened.
+func describeAuditEvent(r *http.Request, event *models.AuditEvent, detail map[string]any) error {
+	if len(detail) > 0 {
+		encoded, err := json.Marshal(detail)
+		if err != nil {
+			return fmt.Errorf("error encoding audit detail: %w", err)
+		}
+		event.Detail = string(encoded)
+	}
+	event.IP = ClientIP(r)
+	event.CreatedAt = time.Now().UTC()
+
+	return nil
+}
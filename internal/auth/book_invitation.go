@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+const bookInvitationEmail = `Hi,
+
+%s invited you to the address book "%s" on Contacts App, with %s access.
+
+Log in with this email address at %s/books to accept the invitation.%s
+The invitation expires on %s.
+`
+
+// InviteToAddressBook invites the email address to join the book with the given permission, and emails the invitation.
+// Whoever verifies the address can accept it, so it is only shown on the address books page of such a user.
+// Failing to send the email is logged rather than returned, since the invitation is shown there anyway.
+// It returns database.ErrBookInvitationExists if the address has a pending invitation to the book,
+// and database.ErrAddressBookNotFound if the book isn't one of the tenant's.
+func InviteToAddressBook(tenant *database.Tenant, m mailer.Mailer, inviter *models.UserContext, book *models.AddressBook, email, permission string) (*models.AddressBookInvitation, error) {
+	now := time.Now().UTC()
+	invitation := &models.AddressBookInvitation{
+		BookId:      book.Id,
+		BookName:    book.Name,
+		Email:       email,
+		Permission:  permission,
+		InvitedBy:   inviter.Id,
+		InviterName: inviter.Username,
+		ExpiresAt:   now.Add(config.BookInvitationExpiration),
+		CreatedAt:   now,
+	}
+
+	if err := tenant.CreateBookInvitation(invitation); err != nil {
+		return nil, err
+	}
+
+	register := ""
+	if config.RegistrationMode == config.RegistrationOpen {
+		register = fmt.Sprintf("\nIf you don't have an account yet, register at %s/auth/register with this email address first.", config.AppURL)
+	}
+
+	err := m.Send(mailer.Message{
+		To:      email,
+		Subject: fmt.Sprintf("%s shared an address book with you", inviter.Username),
+		Body: fmt.Sprintf(
+			bookInvitationEmail,
+			inviter.Username, book.Name, strings.ToLower(invitation.PermissionLabel()), config.AppURL, register,
+			invitation.ExpiresAt.Format("January 2, 2006 at 15:04 UTC"),
+		),
+	})
+	if err != nil {
+		log.Printf("Error sending address book invitation email: %v", err)
+	}
+
+	return invitation, nil
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/config"
+)
+
+// ClientIP returns the IP address of the client that made the request.
+// X-Forwarded-For is only honored when config.TrustProxy is set, since clients can send it themselves.
+func ClientIP(r *http.Request) string {
+	if config.TrustProxy {
+		// The last address is the one appended by our proxy; earlier ones are client controlled.
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
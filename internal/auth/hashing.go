@@ -3,19 +3,57 @@ package auth
 import (
 	"fmt"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/pkg/passhash"
 )
 
-// HashPassword hashes the given password using bcrypt.
+// ErrMismatchedHashAndPassword is returned by CheckPasswordHash when the password is wrong.
+var ErrMismatchedHashAndPassword = passhash.ErrMismatchedHashAndPassword
+
+// passwordHasher is implemented by passhash.Hasher and replaced in tests.
+type passwordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) error
+	NeedsRehash(hash string) bool
+}
+
+var hasher passwordHasher = passhash.New(passhash.Params{
+	Memory:      config.Argon2Memory,
+	Iterations:  config.Argon2Iterations,
+	Parallelism: config.Argon2Parallelism,
+	SaltLength:  passhash.DefaultParams.SaltLength,
+	KeyLength:   passhash.DefaultParams.KeyLength,
+})
+
+// hashSlots limits how many passwords are hashed or verified at once to config.Argon2Concurrency,
+// since each argon2id computation takes config.Argon2Memory.
+var hashSlots = make(chan struct{}, config.Argon2Concurrency)
+
+// acquireHashSlot waits until a password can be hashed, returning the function that frees the slot.
+func acquireHashSlot() func() {
+	hashSlots <- struct{}{}
+	return func() { <-hashSlots }
+}
+
+// HashPassword hashes the given password using argon2id.
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	defer acquireHashSlot()()
+
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
-	return string(hashedPassword), nil
+	return hashedPassword, nil
 }
 
-// CheckPasswordHash compares a bcrypt hashed password with its possible plaintext equivalent.
+// CheckPasswordHash compares an argon2id or bcrypt hashed password with its possible plaintext equivalent.
 func CheckPasswordHash(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	defer acquireHashSlot()()
+
+	return hasher.Verify(password, hash)
+}
+
+// NeedsRehash reports whether the hash should be upgraded to the current algorithm and parameters.
+func NeedsRehash(hash string) bool {
+	return hasher.NeedsRehash(hash)
 }
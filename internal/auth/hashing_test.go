@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/joangavelan/contacts-app/config"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -45,8 +48,65 @@ func TestCheckPasswordHash(t *testing.T) {
 		t.Fatalf("expected an error, but got none")
 	}
 
-	// Ensure the error is because of bcrypt comparison
-	if err != bcrypt.ErrMismatchedHashAndPassword {
-		t.Fatalf("expected bcrypt.ErrMismatchedHashAndPassword, but got %v", err)
+	// Ensure the error is because of the hash comparison
+	if err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, but got %v", err)
+	}
+}
+
+func TestCheckPasswordHash_Bcrypt(t *testing.T) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("mysecretpassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// Hashes created before the switch to argon2id keep working, but should be upgraded.
+	if err := CheckPasswordHash("mysecretpassword", string(hashedPassword)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if !NeedsRehash(string(hashedPassword)) {
+		t.Fatalf("expected bcrypt hash to need a rehash")
+	}
+}
+
+// concurrencyHasher records how many passwords are verified at once.
+type concurrencyHasher struct {
+	passwordHasher
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (h *concurrencyHasher) Verify(password, hash string) error {
+	h.mu.Lock()
+	h.running++
+	h.peak = max(h.peak, h.running)
+	h.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	h.mu.Lock()
+	h.running--
+	h.mu.Unlock()
+	return ErrMismatchedHashAndPassword
+}
+
+func TestCheckPasswordHash_Concurrency(t *testing.T) {
+	recorder := &concurrencyHasher{passwordHasher: hasher}
+	hasher = recorder
+	defer func() { hasher = recorder.passwordHasher }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4*config.Argon2Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			CheckPasswordHash("password", "hash")
+		}()
+	}
+	wg.Wait()
+
+	if recorder.peak > config.Argon2Concurrency {
+		t.Errorf("expected at most %d passwords to be verified at once, got %d", config.Argon2Concurrency, recorder.peak)
 	}
 }
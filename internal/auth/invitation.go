@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+var (
+	// ErrInvalidInvitation is returned when an invitation is unknown, revoked, expired or used up.
+	ErrInvalidInvitation = errors.New("invalid or expired invitation")
+	// ErrInvitationEmailMismatch is returned when an invitation bound to an email address is used with another one.
+	ErrInvitationEmailMismatch = errors.New("invitation is for another email address")
+)
+
+const invitationEmail = `Hi,
+
+%s invited you to join Contacts App. Follow the link below to create your account:
+
+%s
+%s`
+
+// CreateInvitation issues an invitation to register and returns the link to share. When email is set,
+// only that address can register with it, and the link is emailed there. A zero expiresIn never expires.
+// Failing to send the email is logged rather than returned, since the admin is shown the link anyway.
+// The invitation is recorded in the audit trail along with it.
+func CreateInvitation(db *sql.DB, r *http.Request, m mailer.Mailer, admin *models.UserContext, email string, maxUses int, expiresIn time.Duration) (string, *models.Invitation, error) {
+	token, hash, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UTC()
+	invitation := &models.Invitation{
+		CreatedBy: admin.Id,
+		Email:     email,
+		MaxUses:   maxUses,
+		CreatedAt: now,
+	}
+	if expiresIn > 0 {
+		expiresAt := now.Add(expiresIn)
+		invitation.ExpiresAt = &expiresAt
+	}
+
+	audit := func(invitation *models.Invitation) (*models.AuditEvent, error) {
+		return NewAuditEvent(r, admin, models.AuditInvitationCreated, nil, invitationDetail(invitation))
+	}
+	if err := database.CreateInvitation(db, invitation, hash, audit); err != nil {
+		return "", nil, err
+	}
+
+	link := fmt.Sprintf("%s/auth/register?invite=%s", config.AppURL, url.QueryEscape(token))
+
+	if email != "" {
+		expiry := ""
+		if invitation.ExpiresAt != nil {
+			expiry = fmt.Sprintf("\nThe link expires on %s.\n", invitation.ExpiresAt.Format("January 2, 2006 at 15:04 UTC"))
+		}
+
+		err := m.Send(mailer.Message{
+			To:      email,
+			Subject: "You're invited to Contacts App",
+			Body:    fmt.Sprintf(invitationEmail, admin.Username, link, expiry),
+		})
+		if err != nil {
+			log.Printf("Error sending invitation email: %v", err)
+		}
+	}
+
+	return link, invitation, nil
+}
+
+// CheckInvitation returns the invitation with the given token if it can be used to register with email.
+// An empty email skips the check of the address the invitation is bound to.
+func CheckInvitation(db *sql.DB, token, email string) (*models.Invitation, error) {
+	if token == "" {
+		return nil, ErrInvalidInvitation
+	}
+
+	invitation, err := database.GetInvitationByHash(db, HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || !invitation.IsUsable(time.Now().UTC()) {
+		return nil, ErrInvalidInvitation
+	}
+
+	if email != "" && invitation.Email != "" && !strings.EqualFold(invitation.Email, email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	return invitation, nil
+}
+
+// invitationDetail describes an invitation in the audit trail.
+func invitationDetail(invitation *models.Invitation) map[string]any {
+	detail := map[string]any{"invitationId": invitation.Id, "maxUses": invitation.MaxUses}
+	if invitation.Email != "" {
+		detail["email"] = invitation.Email
+	}
+	return detail
+}
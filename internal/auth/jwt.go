@@ -20,11 +20,14 @@ type Header struct {
 }
 
 type Claims struct {
-	Sub      int64  `json:"sub"`
+	Sub int64 `json:"sub"`
+	// Sid is the ID of the session the token was issued for.
+	Sid      int64  `json:"sid"`
 	Exp      int64  `json:"exp"`
 	Iat      int64  `json:"iat"`
 	Email    string `json:"email"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 }
 
 var jwtSecretKey = os.Getenv("JWT_SECRET_KEY")
@@ -47,9 +50,9 @@ func createHMAC(message, secret string) string {
 	return base64Encode(h.Sum(nil))
 }
 
-// GenerateJWT creates a JWT for a given user ID, username, and email.
+// GenerateJWT creates a JWT for a given user ID, session ID, username, email, and role.
 // It returns the JWT as a string and an error if any occurs during the process.
-func GenerateJWT(userId int64, username, email string) (string, error) {
+func GenerateJWT(userId, sessionId int64, username, email, role string) (string, error) {
 	header := Header{
 		Alg: "HS256",
 		Typ: "JWT",
@@ -63,10 +66,12 @@ func GenerateJWT(userId int64, username, email string) (string, error) {
 	now := time.Now().Unix()
 	claims := Claims{
 		Sub:      userId,
+		Sid:      sessionId,
 		Iat:      now,
 		Exp:      now + int64(config.JWTExpiration.Seconds()),
 		Email:    email,
 		Username: username,
+		Role:     role,
 	}
 
 	claimsJSON, err := json.Marshal(claims)
@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"os"
 	"strings"
@@ -17,10 +16,12 @@ func TestGenerateJWT(t *testing.T) {
 	os.Setenv("JWT_SECRET_KEY", jwtSecretKey)
 
 	userId := int64(1)
+	sessionId := int64(7)
 	username := "testuser"
 	email := "testuser@example.com"
+	role := "admin"
 
-	token, err := GenerateJWT(userId, username, email)
+	token, err := GenerateJWT(userId, sessionId, username, email, role)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -31,7 +32,7 @@ func TestGenerateJWT(t *testing.T) {
 	}
 
 	// Validate Header
-	headerJSON, err := base64.URLEncoding.DecodeString(parts[0])
+	headerJSON, err := base64Decode(parts[0])
 	if err != nil {
 		t.Fatalf("error decoding header: %v", err)
 	}
@@ -44,7 +45,7 @@ func TestGenerateJWT(t *testing.T) {
 	}
 
 	// Validate Claims
-	claimsJSON, err := base64.URLEncoding.DecodeString(parts[1])
+	claimsJSON, err := base64Decode(parts[1])
 	if err != nil {
 		t.Fatalf("error decoding claims: %v", err)
 	}
@@ -52,7 +53,7 @@ func TestGenerateJWT(t *testing.T) {
 	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
 		t.Fatalf("error unmarshalling claims: %v", err)
 	}
-	if claims.Sub != userId || claims.Email != email || claims.Username != username {
+	if claims.Sub != userId || claims.Sid != sessionId || claims.Email != email || claims.Username != username || claims.Role != role {
 		t.Fatalf("unexpected claims: %+v", claims)
 	}
 	now := time.Now().Unix()
@@ -79,7 +80,7 @@ func TestValidateJWT(t *testing.T) {
 	userID := int64(1)
 	username := "testuser"
 	email := "testuser@example.com"
-	token, err := GenerateJWT(userID, username, email)
+	token, err := GenerateJWT(userID, 7, username, email, "user")
 	if err != nil {
 		t.Fatalf("failed to generate token: %v", err)
 	}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/throttle"
+)
+
+// ErrInvalidCredentials is returned for both unknown emails and wrong passwords so callers can't tell them apart.
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+var (
+	accountThrottle = throttle.New(throttle.Policy{
+		FreeAttempts:    config.LoginFreeAttempts,
+		BaseDelay:       config.LoginBackoffBase,
+		MaxDelay:        config.LoginBackoffMax,
+		LockoutAfter:    config.LoginLockoutAttempts,
+		LockoutDuration: config.LoginLockoutDuration,
+		Window:          config.LoginFailureWindow,
+	})
+	ipThrottle = throttle.New(throttle.Policy{
+		FreeAttempts:    config.LoginIPFreeAttempts,
+		BaseDelay:       config.LoginBackoffBase,
+		MaxDelay:        config.LoginBackoffMax,
+		LockoutAfter:    config.LoginIPLockoutAttempts,
+		LockoutDuration: config.LoginIPLockoutDuration,
+		Window:          config.LoginFailureWindow,
+	})
+)
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// Authenticate returns the user with the given email if password matches.
+// It returns ErrAccountDisabled if the password matches but an admin disabled the account.
+// The password is hashed even when the account doesn't exist so response times don't reveal which emails are registered.
+func Authenticate(db *sql.DB, email, password string) (*models.User, error) {
+	user, err := database.GetUserByEmail(db, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		CheckPasswordHash(password, getDummyHash())
+		return nil, ErrInvalidCredentials
+	}
+
+	// Bcrypt and older argon2id hashes are cheaper to check than the dummy hash, so also pay for a comparison
+	// with the current algorithm and parameters. Otherwise legacy accounts would answer faster than unknown emails.
+	outdated := NeedsRehash(user.Password)
+	if outdated {
+		CheckPasswordHash(password, getDummyHash())
+	}
+
+	if err := CheckPasswordHash(password, user.Password); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Only tell someone who knows the password that the account is disabled.
+	if user.IsDisabled() {
+		return nil, ErrAccountDisabled
+	}
+
+	// Upgrade hashes using bcrypt or outdated argon2id parameters while we have the plaintext password.
+	if outdated {
+		if err := rehashPassword(db, user, password); err != nil {
+			log.Printf("Error upgrading password hash: %v", err)
+		}
+	}
+
+	return user, nil
+}
+
+// LoginRetryAfter returns how long login attempts for email from ip are blocked, or zero if they're allowed.
+func LoginRetryAfter(email, ip string) time.Duration {
+	return max(accountThrottle.Check(accountKey(email)), ipThrottle.Check(ip))
+}
+
+// RecordLoginFailure counts a failed password or second factor attempt against both the account and the client IP.
+// Unknown emails are tracked like existing ones so lockouts don't reveal which accounts exist.
+func RecordLoginFailure(email, ip string) {
+	accountThrottle.Fail(accountKey(email))
+	ipThrottle.Fail(ip)
+}
+
+// RecordLoginSuccess clears the failures recorded for the account.
+// The IP's failures are kept, so an attacker can't reset them by logging into their own account.
+func RecordLoginSuccess(email string) {
+	accountThrottle.Reset(accountKey(email))
+}
+
+func rehashPassword(db *sql.DB, user *models.User, password string) error {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := database.RehashUserPassword(db, user.Id, user.Password, hashedPassword); err != nil {
+		return err
+	}
+
+	user.Password = hashedPassword
+	return nil
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// getDummyHash returns a hash to compare passwords against when the account doesn't exist.
+// It's created by the configured hasher, so checking it costs the same as checking a current user's hash.
+func getDummyHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("dummy password used for constant time comparison")
+	})
+	return dummyHash
+}
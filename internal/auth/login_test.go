@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	hash, err := HashPassword("correctpassword")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	userRow := func() *sqlmock.Rows {
+		return sqlmock.NewRows(userColumns).
+			AddRow(1, "testuser", "testuser@example.com", hash, nil, nil, nil, nil, nil, nil, "user", nil)
+	}
+
+	t.Run("unknown email", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE email = ?").
+			WithArgs("nobody@example.com").
+			WillReturnRows(sqlmock.NewRows(userColumns))
+
+		if _, err := Authenticate(db, "nobody@example.com", "correctpassword"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials, got %v", err)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE email = ?").
+			WithArgs("testuser@example.com").
+			WillReturnRows(userRow())
+
+		if _, err := Authenticate(db, "testuser@example.com", "wrongpassword"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials, got %v", err)
+		}
+	})
+
+	t.Run("disabled account", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE email = ?").
+			WithArgs("testuser@example.com").
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(1, "testuser", "testuser@example.com", hash, nil, nil, nil, nil, nil, nil, "user", time.Now()))
+
+		if _, err := Authenticate(db, "testuser@example.com", "correctpassword"); !errors.Is(err, ErrAccountDisabled) {
+			t.Errorf("expected ErrAccountDisabled, got %v", err)
+		}
+	})
+
+	t.Run("correct password", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE email = ?").
+			WithArgs("testuser@example.com").
+			WillReturnRows(userRow())
+
+		user, err := Authenticate(db, "testuser@example.com", "correctpassword")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if user.Id != 1 {
+			t.Errorf("expected user 1, got %d", user.Id)
+		}
+	})
+
+	t.Run("upgrades bcrypt hash", func(t *testing.T) {
+		bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("correctpassword"), bcrypt.MinCost)
+
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE email = ?").
+			WithArgs("testuser@example.com").
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(1, "testuser", "testuser@example.com", string(bcryptHash), nil, nil, nil, nil, nil, nil, "user", nil))
+		mock.ExpectExec("UPDATE users SET password = (.+) WHERE id = (.+) AND password = ?").
+			WithArgs(sqlmock.AnyArg(), 1, string(bcryptHash)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		user, err := Authenticate(db, "testuser@example.com", "correctpassword")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if NeedsRehash(user.Password) {
+			t.Errorf("expected the user's hash to be upgraded, got %q", user.Password)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+// recordingHasher records the hashes passwords are checked against.
+type recordingHasher struct {
+	passwordHasher
+	verified []string
+}
+
+func (h *recordingHasher) Verify(password, hash string) error {
+	h.verified = append(h.verified, hash)
+	return h.passwordHasher.Verify(password, hash)
+}
+
+func TestAuthenticateTiming(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	recorder := &recordingHasher{passwordHasher: hasher}
+	hasher = recorder
+	defer func() { hasher = recorder.passwordHasher }()
+
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("correctpassword"), bcrypt.MinCost)
+
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE email = ?").
+		WithArgs("nobody@example.com").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+	Authenticate(db, "nobody@example.com", "wrongpassword")
+
+	if len(recorder.verified) != 1 || recorder.verified[0] != getDummyHash() || NeedsRehash(recorder.verified[0]) {
+		t.Fatalf("expected an unknown email to be checked against a current dummy hash, got %q", recorder.verified)
+	}
+
+	recorder.verified = nil
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE email = ?").
+		WithArgs("testuser@example.com").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(1, "testuser", "testuser@example.com", string(bcryptHash), nil, nil, nil, nil, nil, nil, "user", nil))
+	Authenticate(db, "testuser@example.com", "wrongpassword")
+
+	if len(recorder.verified) != 2 || recorder.verified[0] != getDummyHash() || recorder.verified[1] != string(bcryptHash) {
+		t.Errorf("expected a bcrypt account to be checked against the dummy hash too, got %q", recorder.verified)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestLoginThrottling(t *testing.T) {
+	email, ip := "throttled@example.com", "192.0.2.1"
+
+	for i := 0; i < config.LoginFreeAttempts; i++ {
+		RecordLoginFailure(email, ip)
+	}
+	if wait := LoginRetryAfter(email, ip); wait != 0 {
+		t.Fatalf("expected no delay within the free attempts, got %v", wait)
+	}
+
+	RecordLoginFailure(email, ip)
+	if wait := LoginRetryAfter(" Throttled@Example.com", "192.0.2.2"); wait <= 0 {
+		t.Errorf("expected the account to be throttled regardless of case and IP")
+	}
+
+	RecordLoginSuccess(email)
+	if wait := LoginRetryAfter(email, ip); wait != 0 {
+		t.Errorf("expected a successful login to clear the account's failures, got %v", wait)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:54321"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.2")
+
+	if ip := ClientIP(r); ip != "192.0.2.1" {
+		t.Errorf("expected X-Forwarded-For to be ignored without a trusted proxy, got %s", ip)
+	}
+
+	config.TrustProxy = true
+	defer func() { config.TrustProxy = false }()
+
+	if ip := ClientIP(r); ip != "198.51.100.2" {
+		t.Errorf("expected the address appended by the proxy, got %s", ip)
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+const magicLinkPurpose = "magic-link"
+
+var (
+	// ErrInvalidMagicLink is returned when a magic link is malformed, unknown, expired or already used.
+	ErrInvalidMagicLink = errors.New("invalid or expired magic link")
+	// ErrMagicLinkOtherBrowser is returned when a magic link is opened in a browser other than the one that asked for it,
+	// e.g. because the email was forwarded. The link is left unused.
+	ErrMagicLinkOtherBrowser = errors.New("magic link opened in another browser")
+	// ErrMagicLinkCooldown is returned when a magic link was sent too recently.
+	ErrMagicLinkCooldown = errors.New("magic link sent too recently")
+)
+
+const magicLinkEmail = `Hi %s,
+
+Follow the link below to sign in to your Contacts App account:
+
+%s
+
+Open it in the same browser you asked for it from. The link expires in %d minutes and can only be used once.
+If you didn't ask to sign in you can safely ignore this email.
+`
+
+// SendMagicLink emails the user a signed, single-use sign-in link that only works in the browser holding nonce.
+// It returns ErrMagicLinkCooldown without sending anything if a link was sent within the cooldown period.
+func SendMagicLink(db *sql.DB, m mailer.Mailer, user *models.User, nonce string) error {
+	now := time.Now().UTC()
+
+	lastSentAt, err := database.GetLatestMagicLinkCreatedAt(db, user.Id)
+	if err != nil {
+		return err
+	}
+	if lastSentAt != nil && now.Sub(*lastSentAt) < config.MagicLinkCooldown {
+		return ErrMagicLinkCooldown
+	}
+
+	secret, hash, err := GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := now.Add(config.MagicLinkExpiration)
+	if err := database.CreateMagicLink(db, user.Id, hash, HashToken(nonce), expiresAt, now); err != nil {
+		return err
+	}
+
+	// The signature lets forged and expired links be rejected without a database lookup.
+	token := SignToken(magicLinkPurpose, secret, expiresAt)
+	link := fmt.Sprintf("%s/auth/magic-link/sign-in?token=%s", config.AppURL, url.QueryEscape(token))
+
+	err = m.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body:    fmt.Sprintf(magicLinkEmail, user.Username, link, int(config.MagicLinkExpiration.Minutes())),
+	})
+	if err != nil {
+		return fmt.Errorf("error sending magic link email: %w", err)
+	}
+
+	return nil
+}
+
+// RedeemMagicLink uses up the magic link opened in the browser holding nonce and returns the user it signs in.
+func RedeemMagicLink(db *sql.DB, token, nonce string) (*models.User, error) {
+	secret, err := VerifySignedToken(magicLinkPurpose, token)
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+
+	now := time.Now().UTC()
+
+	link, err := database.GetMagicLinkByHash(db, HashToken(secret))
+	if err != nil {
+		return nil, err
+	}
+	if link == nil || !link.IsUsable(now) {
+		return nil, ErrInvalidMagicLink
+	}
+
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(HashToken(nonce)), []byte(link.NonceHash)) != 1 {
+		return nil, ErrMagicLinkOtherBrowser
+	}
+
+	user, err := database.GetUserById(db, link.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidMagicLink
+	}
+
+	// Following the link proves the user owns the address. Whoever registered an unverified account never did,
+	// and may have done so to get into it once its real owner signs in, so the account is reclaimed.
+	reclaimPasswordHash := ""
+	if !user.IsVerified() {
+		password, _, err := GenerateToken()
+		if err != nil {
+			return nil, err
+		}
+		if reclaimPasswordHash, err = HashPassword(password); err != nil {
+			return nil, err
+		}
+	}
+
+	// JWT issued-at claims have a one second resolution.
+	err = database.RedeemMagicLink(db, link, user.Email, reclaimPasswordHash, now.Truncate(time.Second))
+	if errors.Is(err, database.ErrMagicLinkUsed) {
+		return nil, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return database.GetUserById(db, user.Id)
+}
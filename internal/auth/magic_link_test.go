@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+func TestSendMagicLinkCooldown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	m := &mailer.MemoryMailer{}
+	user := &models.User{Id: 1, Username: "testuser", Email: "testuser@example.com"}
+
+	mock.ExpectQuery("SELECT createdAt FROM magic_links").
+		WithArgs(user.Id).
+		WillReturnRows(sqlmock.NewRows([]string{"createdAt"}).AddRow(time.Now().UTC().Add(-10 * time.Second)))
+
+	if err := SendMagicLink(db, m, user, "nonce"); err != ErrMagicLinkCooldown {
+		t.Errorf("expected ErrMagicLinkCooldown, got %v", err)
+	}
+	if len(m.Messages()) != 0 {
+		t.Errorf("expected no email to be sent, got %d", len(m.Messages()))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
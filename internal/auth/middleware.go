@@ -2,23 +2,39 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
 	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+	"github.com/joangavelan/contacts-app/pkg/toast"
 )
 
 type contextKey string
 
 const userContextKey = contextKey("user")
 
+// Middleware authenticates the request with either an API token in the Authorization header
+// or the session cookie. Browsers are redirected to the login page when that fails,
+// while API callers get a 401 JSON error.
 func Middleware(next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// A bearer token is never combined with the cookie, so a bad token can't fall back to the browser session.
+		if token, ok := bearerToken(r); ok {
+			authenticateBearer(w, r, token, next)
+			return
+		}
+
 		// Retrieve token from cookie
 		cookie, err := r.Cookie("token")
 		if err != nil {
 			log.Printf("No token provided: %v", err)
-			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			unauthorized(w, r, "Authentication required")
 			return
 		}
 
@@ -28,14 +44,80 @@ func Middleware(next http.Handler) http.HandlerFunc {
 		claims, err := ValidateJWT(token)
 		if err != nil {
 			log.Printf("Invalid token: %v", err)
-			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			unauthorized(w, r, "Your session has expired")
+			return
+		}
+
+		// Reject tokens issued before the user's sessions were invalidated, e.g. by a password reset,
+		// and tokens of disabled users
+		user, err := database.GetUserById(database.DB, claims.Sub)
+		if err != nil {
+			log.Printf("Error retrieving user: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if user == nil || claims.Iat < user.SessionsValidAfter.Unix() || user.IsDisabled() {
+			log.Printf("Session no longer valid for user %d", claims.Sub)
+			unauthorized(w, r, "Your session has expired")
+			return
+		}
+
+		// Reject tokens carrying a role the user no longer has, so demotions take effect immediately
+		if claims.Role != user.Role {
+			log.Printf("Role of user %d changed since the session was issued", claims.Sub)
+			unauthorized(w, r, "Your session has expired")
 			return
 		}
 
+		// Reject tokens of sessions that were revoked, e.g. from the security settings of another device
+		session, err := database.GetSession(database.DB, claims.Sid)
+		if err != nil {
+			log.Printf("Error retrieving session: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now().UTC()
+		if session == nil || session.UserId != user.Id || !session.IsActive(now, user.SessionsValidAfter) {
+			log.Printf("Session %d no longer valid for user %d", claims.Sid, claims.Sub)
+			unauthorized(w, r, "Your session has expired")
+			return
+		}
+
+		if now.Sub(session.LastSeenAt) >= config.SessionLastSeenResolution {
+			if err := database.TouchSession(database.DB, session.Id, now); err != nil {
+				log.Printf("Error recording session activity: %v", err)
+			}
+		}
+
 		// Create a UserContext object from claims
 		userCtx := &models.UserContext{
-			Id:       claims.Sub,
-			Username: claims.Username,
+			Id:        claims.Sub,
+			Username:  claims.Username,
+			Email:     user.Email,
+			Verified:  user.IsVerified(),
+			Role:      claims.Role,
+			SessionId: session.Id,
+		}
+
+		if session.ImpersonatorId != 0 {
+			admin, err := database.GetUserById(database.DB, session.ImpersonatorId)
+			if err != nil {
+				log.Printf("Error retrieving impersonator: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			// The admin may have been demoted or deleted since
+			if admin == nil || admin.IsDisabled() || admin.Role != models.RoleAdmin {
+				log.Printf("Impersonator %d of session %d is no longer an admin", session.ImpersonatorId, session.Id)
+				unauthorized(w, r, "Your session has expired")
+				return
+			}
+			userCtx.ImpersonatorId = admin.Id
+			userCtx.ImpersonatorName = admin.Username
+		}
+
+		if !resolveOrganization(w, r, userCtx, true) {
+			return
 		}
 
 		// Attach user context to request context
@@ -45,3 +127,164 @@ func Middleware(next http.Handler) http.HandlerFunc {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
+
+func authenticateBearer(w http.ResponseWriter, r *http.Request, token string, next http.Handler) {
+	user, apiToken, err := AuthenticateAPIToken(database.DB, token)
+	if errors.Is(err, ErrInvalidAPIToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		jsonapi.WriteError(w, http.StatusUnauthorized, "invalid_token", "The API token is invalid, expired or revoked")
+		return
+	}
+	if errors.Is(err, ErrAccountDisabled) {
+		jsonapi.WriteError(w, http.StatusForbidden, "account_disabled", "The account this API token belongs to is disabled")
+		return
+	}
+	if err != nil {
+		log.Printf("Error authenticating api token: %v", err)
+		jsonapi.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return
+	}
+
+	userCtx := &models.UserContext{
+		Id:         user.Id,
+		Username:   user.Username,
+		Email:      user.Email,
+		Verified:   user.IsVerified(),
+		Role:       user.Role,
+		APITokenId: apiToken.Id,
+		Scopes:     apiToken.Scopes,
+	}
+
+	if !resolveOrganization(w, r, userCtx, false) {
+		return
+	}
+
+	next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, userCtx)))
+}
+
+// RequireScope restricts a route to browser sessions and API tokens granted scope.
+// It must run after Middleware, which attaches the user to the request context.
+func RequireScope(scope string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r.Context())
+		if !ok {
+			http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+			return
+		}
+
+		if !user.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			jsonapi.WriteError(w, http.StatusForbidden, "insufficient_scope", "The API token lacks the "+scope+" scope")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireSession restricts a route to browser sessions, e.g. so API tokens can't be used to create more tokens.
+// It must run after Middleware, which attaches the user to the request context.
+func RequireSession(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r.Context())
+		if !ok {
+			http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+			return
+		}
+
+		if user.APITokenId != 0 {
+			jsonapi.WriteError(w, http.StatusForbidden, "session_required", "This endpoint can't be used with an API token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireRole restricts a route to users whose role grants everything role does.
+// It must run after Middleware, which attaches the user to the request context.
+func RequireRole(role string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r.Context())
+		if !ok {
+			http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+			return
+		}
+
+		if !user.HasRole(role) {
+			log.Printf("User %d with role %q trying to access %s", user.Id, user.Role, r.URL.Path)
+			if IsAPIRequest(r) {
+				jsonapi.WriteError(w, http.StatusForbidden, "insufficient_role", "Your role doesn't allow this")
+				return
+			}
+			if err := toast.Error("You don't have permission to do that").WriteToHeader(w); err != nil {
+				log.Printf("Error writing toast event: %v", err)
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// DenyImpersonation keeps admins logged in as another user from changing their credentials or account.
+// It must run after Middleware, which attaches the user to the request context.
+func DenyImpersonation(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r.Context())
+		if !ok {
+			http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+			return
+		}
+
+		if user.IsImpersonated() {
+			if IsAPIRequest(r) {
+				jsonapi.WriteError(w, http.StatusForbidden, "impersonation_denied", "This can't be done while impersonating a user")
+				return
+			}
+			if err := toast.Error("This can't be done while impersonating a user").WriteToHeader(w); err != nil {
+				log.Printf("Error writing toast event: %v", err)
+			}
+			http.Error(w, "This can't be done while impersonating a user", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// IsAPIRequest reports whether the caller expects JSON rather than HTML,
+// because it sent an API token, called a versioned API route or only accepts JSON.
+func IsAPIRequest(r *http.Request) bool {
+	if _, ok := bearerToken(r); ok {
+		return true
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		return true
+	}
+
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+// unauthorized redirects browsers to the login page and answers API callers with a JSON error.
+func unauthorized(w http.ResponseWriter, r *http.Request, detail string) {
+	if IsAPIRequest(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		jsonapi.WriteError(w, http.StatusUnauthorized, "unauthenticated", detail)
+		return
+	}
+
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+)
+
+const (
+	// OrgCookieName remembers the organization picked in the header of the browser app.
+	OrgCookieName = "org"
+	// OrgHeader selects the organization an API request works in.
+	OrgHeader = "X-Organization-Id"
+)
+
+// resolveOrganization sets the organization the request works in, along with the user's role in it.
+// The header is checked strictly, so API callers get a 403 JSON error for an organization they aren't a member of,
+// while a stale cookie, e.g. after being removed from the organization, falls back to the personal workspace.
+// It returns false if the request was answered.
+func resolveOrganization(w http.ResponseWriter, r *http.Request, userCtx *models.UserContext, useCookie bool) bool {
+	if value := r.Header.Get(OrgHeader); value != "" {
+		org, err := userOrganization(userCtx.Id, value)
+		if err != nil {
+			log.Printf("Error retrieving organization: %v", err)
+			jsonapi.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+			return false
+		}
+		if org == nil {
+			jsonapi.WriteError(w, http.StatusForbidden, "forbidden", "You aren't a member of this organization")
+			return false
+		}
+		userCtx.OrgId = org.Id
+		userCtx.OrgRole = org.Role
+		return true
+	}
+
+	if !useCookie {
+		return true
+	}
+	cookie, err := r.Cookie(OrgCookieName)
+	if err != nil {
+		return true
+	}
+
+	org, err := userOrganization(userCtx.Id, cookie.Value)
+	if err != nil {
+		log.Printf("Error retrieving organization: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if org != nil {
+		userCtx.OrgId = org.Id
+		userCtx.OrgRole = org.Role
+	}
+
+	return true
+}
+
+// userOrganization looks up an organization by the ID given in a request.
+// It returns nil without an error when the ID is malformed or the user isn't a member.
+func userOrganization(userId int64, value string) (*models.Organization, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, nil
+	}
+
+	return database.GetUserOrganization(database.DB, userId, id)
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/pkg/breached"
+	"github.com/joangavelan/contacts-app/pkg/strength"
+)
+
+// BreachedPasswords is the corpus new passwords are checked against. The check is skipped when it's nil.
+var BreachedPasswords *breached.Corpus
+
+// CheckNewPassword returns a message explaining why password can't be used, or an empty string if it's acceptable.
+// userInputs such as the username and email make passwords derived from them score lower.
+func CheckNewPassword(password string, userInputs ...string) string {
+	if !IsValidPassword(password) {
+		return fmt.Sprintf("Password must be between %d and %d characters long", MinPasswordLength, MaxPasswordLength)
+	}
+
+	if BreachedPasswords != nil {
+		count, err := BreachedPasswords.Count(password)
+		if err != nil {
+			// A broken corpus shouldn't stop people from signing up.
+			log.Printf("Error checking breached passwords: %v", err)
+		} else if count > 0 {
+			return "This password has appeared in a data breach, please choose another one"
+		}
+	}
+
+	result := strength.Estimate(password, userInputs...)
+	if result.Score >= config.MinPasswordScore {
+		return ""
+	}
+
+	message := "This password is too easy to guess."
+	if result.Warning != "" {
+		message = result.Warning + "."
+	}
+	if len(result.Suggestions) > 0 {
+		message += " " + result.Suggestions[0]
+	}
+	return message
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joangavelan/contacts-app/pkg/breached"
+)
+
+func TestCheckNewPassword(t *testing.T) {
+	if message := CheckNewPassword("short"); !strings.HasPrefix(message, "Password must be between") {
+		t.Errorf("expected a length error, got %q", message)
+	}
+
+	if message := CheckNewPassword("password123"); !strings.Contains(message, "common password") {
+		t.Errorf("expected a common password to be rejected, got %q", message)
+	}
+
+	if message := CheckNewPassword("kowalski1985", "kowalski", "jan.kowalski@example.com"); !strings.Contains(message, "your name or email") {
+		t.Errorf("expected a password derived from the email to be rejected, got %q", message)
+	}
+
+	if message := CheckNewPassword("violet-harbor-lantern-87"); message != "" {
+		t.Errorf("expected a strong password to be accepted, got %q", message)
+	}
+}
+
+func TestCheckNewPassword_Breached(t *testing.T) {
+	dir := t.TempDir()
+
+	// SHA-1 of "violet-harbor-lantern-87"
+	if _, err := breached.Build(strings.NewReader("8FB56C999B38E9254347BFA904CF8208221B34BA:1\n"), dir); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	corpus, err := breached.Open(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	BreachedPasswords = corpus
+	defer func() { BreachedPasswords = nil }()
+
+	if message := CheckNewPassword("violet-harbor-lantern-87"); !strings.Contains(message, "data breach") {
+		t.Errorf("expected a breached password to be rejected, got %q", message)
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+// ErrInvalidResetToken is returned when a password reset token is unknown, expired or already used.
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+const passwordResetEmail = `Hi %s,
+
+Someone asked to reset the password of your Contacts App account.
+If it was you, follow the link below to choose a new password:
+
+%s
+
+The link expires in %d minutes and can only be used once.
+If you didn't ask for a reset you can safely ignore this email.
+`
+
+// SendPasswordResetEmail creates a single-use reset token for the user and emails them a link to redeem it.
+func SendPasswordResetEmail(db *sql.DB, m mailer.Mailer, user *models.User) error {
+	token, hash, err := GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().UTC().Add(config.PasswordResetExpiration)
+	if err := database.CreatePasswordReset(db, user.Id, hash, expiresAt); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/auth/reset-password?token=%s", config.AppURL, url.QueryEscape(token))
+
+	err = m.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf(passwordResetEmail, user.Username, link, int(config.PasswordResetExpiration.Minutes())),
+	})
+	if err != nil {
+		return fmt.Errorf("error sending password reset email: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword redeems the reset token, sets the user's new password and returns the user.
+// Sessions issued before the reset are invalidated.
+func ResetPassword(db *sql.DB, token, newPassword string) (*models.User, error) {
+	now := time.Now().UTC()
+
+	reset, err := database.GetPasswordResetByHash(db, HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if reset == nil || !reset.IsUsable(now) {
+		return nil, ErrInvalidResetToken
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// JWT issued-at claims have a one second resolution.
+	err = database.RedeemPasswordReset(db, reset, hashedPassword, now.Truncate(time.Second))
+	if errors.Is(err, database.ErrPasswordResetUsed) {
+		return nil, ErrInvalidResetToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return database.GetUserById(db, reset.UserId)
+}
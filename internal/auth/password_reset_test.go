@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+func TestSendPasswordResetEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	user := &models.User{Id: 1, Username: "testuser", Email: "testuser@example.com"}
+
+	mock.ExpectExec("INSERT INTO password_resets").
+		WithArgs(user.Id, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := SendPasswordResetEmail(db, mailer.NewLogMailer(&buf), user); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	match := regexp.MustCompile(`/auth/reset-password\?token=(\S+)`).FindStringSubmatch(buf.String())
+	if match == nil {
+		t.Fatalf("expected email to contain a reset link, got %q", buf.String())
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil || token == "" {
+		t.Fatalf("expected a token in the reset link, got %q", match[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	t.Run("rejects expired tokens", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM password_resets").
+			WithArgs(HashToken("token")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "userId", "expiresAt", "usedAt"}).AddRow(1, 1, time.Now().Add(-time.Minute), nil))
+
+		if _, err := ResetPassword(db, "token", "newpassword"); err != ErrInvalidResetToken {
+			t.Errorf("expected ErrInvalidResetToken, got %v", err)
+		}
+	})
+
+	t.Run("rejects used tokens", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM password_resets").
+			WithArgs(HashToken("token")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "userId", "expiresAt", "usedAt"}).AddRow(1, 1, time.Now().Add(time.Minute), time.Now()))
+
+		if _, err := ResetPassword(db, "token", "newpassword"); err != ErrInvalidResetToken {
+			t.Errorf("expected ErrInvalidResetToken, got %v", err)
+		}
+	})
+
+	t.Run("updates the password and invalidates sessions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM password_resets").
+			WithArgs(HashToken("token")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "userId", "expiresAt", "usedAt"}).AddRow(1, 7, time.Now().Add(time.Minute), nil))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE password_resets SET usedAt").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE users SET password").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE password_resets SET usedAt").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE id").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(7, "testuser", "testuser@example.com", "hash", nil, nil, nil, nil, nil, nil, "user", nil))
+
+		user, err := ResetPassword(db, "token", "newpassword")
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		} else if user.Id != 7 {
+			t.Errorf("expected the user whose password was reset, got %+v", user)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %v", err)
+		}
+	})
+}
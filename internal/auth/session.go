@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// maxUserAgentLength bounds the User-Agent headers stored with sessions and login events.
+const maxUserAgentLength = 512
+
+// ErrAccountDisabled is returned when a user an admin disabled tries to log in.
+var ErrAccountDisabled = errors.New("account disabled")
+
+// StartSession records a login with method from the request's browser and returns a token for the new session.
+// It returns ErrAccountDisabled if the user is disabled.
+func StartSession(db *sql.DB, r *http.Request, user *models.User, method string) (string, error) {
+	if user.IsDisabled() {
+		return "", ErrAccountDisabled
+	}
+
+	now := time.Now().UTC()
+
+	session := &models.Session{
+		UserId:     user.Id,
+		Method:     method,
+		IP:         ClientIP(r),
+		UserAgent:  userAgent(r),
+		CreatedAt:  now,
+		IssuedAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(config.JWTExpiration),
+	}
+	if err := database.CreateSession(db, session); err != nil {
+		return "", err
+	}
+
+	if err := RecordLoginEvent(db, r, user, method, true); err != nil {
+		return "", err
+	}
+
+	return GenerateJWT(user.Id, session.Id, user.Username, user.Email, user.Role)
+}
+
+// ReissueSession returns a new token for an existing session, e.g. because the claims it carries changed.
+func ReissueSession(db *sql.DB, user *models.User, sessionId int64) (string, error) {
+	now := time.Now().UTC()
+	if err := database.ReissueSession(db, sessionId, now, now.Add(config.JWTExpiration)); err != nil {
+		return "", err
+	}
+
+	return GenerateJWT(user.Id, sessionId, user.Username, user.Email, user.Role)
+}
+
+// RecordLoginEvent adds a successful or failed attempt to log in from the request's browser to the user's history
+// and to the audit trail.
+func RecordLoginEvent(db *sql.DB, r *http.Request, user *models.User, method string, success bool) error {
+	now := time.Now().UTC()
+
+	event := &models.LoginEvent{
+		UserId:    user.Id,
+		Method:    method,
+		IP:        ClientIP(r),
+		UserAgent: userAgent(r),
+		Success:   success,
+		CreatedAt: now,
+	}
+	if err := database.CreateLoginEvent(db, event, now.Add(-config.LoginHistoryRetention)); err != nil {
+		return err
+	}
+
+	action := models.AuditLoginSucceeded
+	if !success {
+		action = models.AuditLoginFailed
+	}
+	return RecordUserAudit(db, r, user, action, map[string]any{"method": method})
+}
+
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return ua
+}
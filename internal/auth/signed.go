@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/signing"
+)
+
+var (
+	ErrInvalidSignedToken = errors.New("invalid signed token")
+	ErrExpiredSignedToken = errors.New("signed token has expired")
+)
+
+// SignToken creates a tamper-proof token carrying payload until expiresAt.
+// The purpose is part of the signature so a token issued for one flow can't be replayed in another.
+func SignToken(purpose, payload string, expiresAt time.Time) string {
+	encodedPayload := base64Encode([]byte(payload))
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+	signature := signing.Sign(purpose, encodedPayload+"|"+exp)
+
+	return fmt.Sprintf("%s.%s.%s", encodedPayload, exp, signature)
+}
+
+// VerifySignedToken checks a token created by SignToken for the same purpose and returns its payload.
+func VerifySignedToken(purpose, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidSignedToken
+	}
+
+	encodedPayload, exp, signature := parts[0], parts[1], parts[2]
+
+	if !signing.Verify(purpose, encodedPayload+"|"+exp, signature) {
+		return "", ErrInvalidSignedToken
+	}
+
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidSignedToken
+	}
+	if time.Now().Unix() > expiresAt {
+		return "", ErrExpiredSignedToken
+	}
+
+	payload, err := base64Decode(encodedPayload)
+	if err != nil {
+		return "", ErrInvalidSignedToken
+	}
+
+	return string(payload), nil
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignedToken(t *testing.T) {
+	jwtSecretKey = "testsecretkey"
+
+	token := SignToken("test", "42:user@example.com", time.Now().Add(time.Hour))
+
+	payload, err := VerifySignedToken("test", token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if payload != "42:user@example.com" {
+		t.Errorf("expected payload %q, got %q", "42:user@example.com", payload)
+	}
+
+	// A token signed for a different purpose is rejected
+	if _, err := VerifySignedToken("other", token); err != ErrInvalidSignedToken {
+		t.Errorf("expected ErrInvalidSignedToken, got %v", err)
+	}
+
+	// A tampered token is rejected
+	if _, err := VerifySignedToken("test", "x"+token); err != ErrInvalidSignedToken {
+		t.Errorf("expected ErrInvalidSignedToken, got %v", err)
+	}
+
+	// An expired token is rejected
+	expired := SignToken("test", "payload", time.Now().Add(-time.Minute))
+	if _, err := VerifySignedToken("test", expired); err != ErrExpiredSignedToken {
+		t.Errorf("expected ErrExpiredSignedToken, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// TemplateFuncs makes the logged in user available to templates as currentUser,
+// and the organizations they can switch to as organizations. Both are empty on pages that don't require logging in.
+func TemplateFuncs(r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"currentUser": func() *models.UserContext {
+			user, _ := GetUser(r.Context())
+			return user
+		},
+		"organizations": func() []models.Organization {
+			user, ok := GetUser(r.Context())
+			if !ok {
+				return nil
+			}
+			orgs, err := database.ListUserOrganizations(database.DB, user.Id)
+			if err != nil {
+				log.Printf("Error listing organizations: %v", err)
+			}
+			return orgs
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenBytes is the amount of random bytes in tokens sent to users.
+const tokenBytes = 32
+
+// GenerateToken creates a random URL-safe token and returns it together with its hash.
+// Only the hash should be stored; the token itself is handed to the user once.
+func GenerateToken() (token, hash string, err error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("error generating token: %w", err)
+	}
+
+	token = base64Encode(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash of a token.
+// A fast hash is enough here since tokens carry 256 bits of entropy.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
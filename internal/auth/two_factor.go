@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/totp"
+)
+
+const (
+	totpIssuer = "Contacts App"
+	// totpSkew is the number of time steps accepted on either side of the current one.
+	totpSkew          = 1
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet is Crockford's base32, which leaves out easily confused letters.
+	recoveryCodeAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+	twoFactorChallengePurpose = "two-factor-challenge"
+)
+
+var (
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrTwoFactorNotPending  = errors.New("two-factor enrollment is not pending")
+)
+
+// BeginTOTPEnrollment returns the secret and otpauth:// URI the user should add to their authenticator app.
+// A pending secret is reused so reloading the enrollment page doesn't invalidate an already scanned code.
+func BeginTOTPEnrollment(db *sql.DB, user *models.User) (secret, uri string, err error) {
+	if user.TwoFactorEnabled() {
+		return "", "", ErrTwoFactorNotPending
+	}
+
+	secret = user.TOTPSecret
+	if secret == "" {
+		secret, err = totp.GenerateSecret()
+		if err != nil {
+			return "", "", err
+		}
+		if err := database.SetTOTPSecret(db, user.Id, secret); err != nil {
+			return "", "", err
+		}
+	}
+
+	return secret, totp.URI(totpIssuer, user.Email, secret), nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication once the user proves their app generates valid codes.
+// It returns the recovery codes, which must be shown to the user since only their hashes are stored.
+func ConfirmTOTPEnrollment(db *sql.DB, user *models.User, code string) ([]string, error) {
+	if user.TwoFactorEnabled() || user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotPending
+	}
+
+	counter, ok := totp.Validate(user.TOTPSecret, normalizeCode(code), time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.EnableTOTP(db, user.Id, user.TOTPSecret, counter, hashes, time.Now().UTC())
+	if errors.Is(err, database.ErrTOTPNotPending) {
+		return nil, ErrTwoFactorNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifySecondFactor accepts either a code from the user's authenticator app or one of their recovery codes.
+// Each app code and recovery code can only be used once.
+func VerifySecondFactor(db *sql.DB, user *models.User, code string) error {
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotPending
+	}
+
+	code = normalizeCode(code)
+
+	if len(code) == totp.Digits {
+		counter, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		fresh, err := database.UseTOTPCounter(db, user.Id, counter)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidTwoFactorCode // replayed code
+		}
+		return nil
+	}
+
+	used, err := database.UseRecoveryCode(db, user.Id, HashToken(code), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after verifying a second factor.
+func RegenerateRecoveryCodes(db *sql.DB, user *models.User, code string) ([]string, error) {
+	if err := VerifySecondFactor(db, user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.ReplaceRecoveryCodes(db, user.Id, hashes, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off after verifying a second factor,
+// recording it in the audit trail.
+func DisableTwoFactor(db *sql.DB, r *http.Request, user *models.User, code string) error {
+	if err := VerifySecondFactor(db, user, code); err != nil {
+		return err
+	}
+
+	audit, err := NewUserAuditEvent(r, user, models.AuditTwoFactorDisabled, nil)
+	if err != nil {
+		return err
+	}
+	return database.DisableTOTP(db, user.Id, audit)
+}
+
+// TwoFactorChallengeToken creates the short-lived token proving the user already passed the first step
+// of logging in with the given method.
+func TwoFactorChallengeToken(userId int64, method string) string {
+	payload := fmt.Sprintf("%d:%s", userId, method)
+	return SignToken(twoFactorChallengePurpose, payload, time.Now().Add(config.TwoFactorChallengeExpiration))
+}
+
+// ParseTwoFactorChallengeToken returns the ID of the user a challenge token was issued for,
+// and the method they started logging in with.
+func ParseTwoFactorChallengeToken(token string) (int64, string, error) {
+	payload, err := VerifySignedToken(twoFactorChallengePurpose, token)
+	if err != nil {
+		return 0, "", err
+	}
+
+	idPart, method, found := strings.Cut(payload, ":")
+	if !found {
+		return 0, "", ErrInvalidSignedToken
+	}
+
+	userId, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return 0, "", ErrInvalidSignedToken
+	}
+
+	return userId, method, nil
+}
+
+// generateRecoveryCodes returns new recovery codes formatted as xxxxx-xxxxx along with their hashes.
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("error generating recovery code: %w", err)
+		}
+
+		var code strings.Builder
+		for j, v := range b {
+			if j == 5 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[v&31])
+		}
+
+		codes = append(codes, code.String())
+		hashes = append(hashes, HashToken(normalizeCode(code.String())))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeCode strips the formatting users may type along with a code.
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
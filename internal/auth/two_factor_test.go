@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/totp"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+func TestConfirmTOTPEnrollment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	user := &models.User{Id: 1, Email: "testuser@example.com", TOTPSecret: testTOTPSecret}
+
+	expired, _ := totp.CodeAt(testTOTPSecret, time.Now().Add(-10*totp.Period))
+	if _, err := ConfirmTOTPEnrollment(db, user, expired); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("expected ErrInvalidTwoFactorCode for an expired code, got %v", err)
+	}
+
+	code, _ := totp.CodeAt(testTOTPSecret, time.Now())
+
+	// The enrollment was completed or cancelled concurrently.
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET totpEnabledAt").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), user.Id, testTOTPSecret).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if _, err := ConfirmTOTPEnrollment(db, user, code); !errors.Is(err, ErrTwoFactorNotPending) {
+		t.Errorf("expected ErrTwoFactorNotPending, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET totpEnabledAt").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), user.Id, testTOTPSecret).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_codes").
+		WithArgs(user.Id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for i := 0; i < recoveryCodeCount; i++ {
+		mock.ExpectExec("INSERT INTO recovery_codes").
+			WithArgs(user.Id, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+	}
+	mock.ExpectCommit()
+
+	codes, err := ConfirmTOTPEnrollment(db, user, code)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+	for _, c := range codes {
+		if !regexp.MustCompile(`^[0-9a-z]{5}-[0-9a-z]{5}$`).MatchString(c) {
+			t.Errorf("unexpected recovery code format %q", c)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestVerifySecondFactor_TOTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	enabledAt := time.Now()
+	user := &models.User{Id: 1, TOTPSecret: testTOTPSecret, TOTPEnabledAt: &enabledAt}
+	code, _ := totp.CodeAt(testTOTPSecret, time.Now())
+
+	mock.ExpectExec("UPDATE users SET totpLastCounter").
+		WithArgs(sqlmock.AnyArg(), user.Id, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := VerifySecondFactor(db, user, code); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// A code whose time step was already used is rejected.
+	mock.ExpectExec("UPDATE users SET totpLastCounter").
+		WithArgs(sqlmock.AnyArg(), user.Id, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := VerifySecondFactor(db, user, code); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("expected ErrInvalidTwoFactorCode for a replayed code, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestVerifySecondFactor_RecoveryCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	enabledAt := time.Now()
+	user := &models.User{Id: 1, TOTPSecret: testTOTPSecret, TOTPEnabledAt: &enabledAt}
+
+	// Recovery codes are matched regardless of case and formatting.
+	mock.ExpectExec("UPDATE recovery_codes SET usedAt").
+		WithArgs(sqlmock.AnyArg(), user.Id, HashToken("abcde12345")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := VerifySecondFactor(db, user, " ABCDE-12345 "); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	mock.ExpectExec("UPDATE recovery_codes SET usedAt").
+		WithArgs(sqlmock.AnyArg(), user.Id, HashToken("abcde12345")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := VerifySecondFactor(db, user, "abcde-12345"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("expected ErrInvalidTwoFactorCode for a used recovery code, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestTwoFactorChallengeToken(t *testing.T) {
+	jwtSecretKey = "testsecretkey"
+
+	userId, method, err := ParseTwoFactorChallengeToken(TwoFactorChallengeToken(42, models.LoginMethodMagicLink))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if userId != 42 || method != models.LoginMethodMagicLink {
+		t.Errorf("expected user ID 42 and the magic link method, got %d and %q", userId, method)
+	}
+
+	// Tokens signed for other purposes are not accepted as challenge tokens.
+	token := SignToken(emailVerificationPurpose, "42:password", time.Now().Add(time.Minute))
+	if _, _, err := ParseTwoFactorChallengeToken(token); !errors.Is(err, ErrInvalidSignedToken) {
+		t.Errorf("expected ErrInvalidSignedToken, got %v", err)
+	}
+}
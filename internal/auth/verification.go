@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+const emailVerificationPurpose = "email-verification"
+
+var (
+	// ErrInvalidVerificationToken is returned when a verification link is malformed,
+	// expired, or no longer matches the user's email address.
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+	// ErrVerificationCooldown is returned when a verification email was sent too recently.
+	ErrVerificationCooldown = errors.New("verification email sent too recently")
+)
+
+const verificationEmail = `Hi %s,
+
+Please confirm that %s is your email address by following the link below:
+
+%s
+
+The link expires in %d hours.
+`
+
+// SendVerificationEmail emails the user a signed link that verifies their current email address.
+func SendVerificationEmail(db *sql.DB, m mailer.Mailer, user *models.User) error {
+	now := time.Now().UTC()
+	if err := sendVerificationEmail(m, user, now); err != nil {
+		return err
+	}
+
+	return database.UpdateVerificationSentAt(db, user.Id, now)
+}
+
+// ResendVerificationEmail sends a new verification email unless one was sent within the cooldown period.
+// It returns the time left before another email can be sent along with ErrVerificationCooldown.
+// The slot is claimed in the database before sending, so concurrent requests send at most one email.
+func ResendVerificationEmail(db *sql.DB, m mailer.Mailer, user *models.User) (time.Duration, error) {
+	now := time.Now().UTC()
+
+	claimed, err := database.ClaimVerificationSend(db, user.Id, now, now.Add(-config.VerificationResendCooldown))
+	if err != nil {
+		return 0, err
+	}
+	if !claimed {
+		remaining := config.VerificationResendCooldown
+		if user.VerificationSentAt != nil {
+			remaining -= now.Sub(*user.VerificationSentAt)
+		}
+		return max(remaining, time.Second), ErrVerificationCooldown
+	}
+
+	user.VerificationSentAt = &now
+	return 0, sendVerificationEmail(m, user, now)
+}
+
+func sendVerificationEmail(m mailer.Mailer, user *models.User, now time.Time) error {
+	payload := fmt.Sprintf("%d:%s", user.Id, user.Email)
+	token := SignToken(emailVerificationPurpose, payload, now.Add(config.EmailVerificationExpiration))
+	link := fmt.Sprintf("%s/auth/verify-email?token=%s", config.AppURL, url.QueryEscape(token))
+
+	err := m.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    fmt.Sprintf(verificationEmail, user.Username, user.Email, link, int(config.EmailVerificationExpiration.Hours())),
+	})
+	if err != nil {
+		return fmt.Errorf("error sending verification email: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyEmail validates a verification token and marks the email address it was issued for as verified.
+// Tokens issued for an address the user no longer has are rejected.
+func VerifyEmail(db *sql.DB, token string) (*models.User, error) {
+	payload, err := VerifySignedToken(emailVerificationPurpose, token)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	idPart, email, found := strings.Cut(payload, ":")
+	if !found {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	userId, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := database.GetUserById(db, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Email != email {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	// Following an already used link is harmless.
+	if user.IsVerified() {
+		return user, nil
+	}
+
+	now := time.Now().UTC()
+	if _, err := database.MarkEmailVerified(db, user.Id, email, now); err != nil {
+		return nil, err
+	}
+	user.VerifiedAt = &now
+
+	return user, nil
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/mailer"
+)
+
+var userColumns = []string{
+	"id", "username", "email", "password", "sessionsValidAfter", "verifiedAt", "verificationSentAt",
+	"totpSecret", "totpEnabledAt", "totpLastCounter", "role", "disabledAt",
+}
+
+// verificationTokenFrom extracts the verification token from the link in the email body.
+func verificationTokenFrom(t *testing.T, body string) string {
+	t.Helper()
+
+	match := regexp.MustCompile(`/auth/verify-email\?token=(\S+)`).FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("expected email to contain a verification link, got %q", body)
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("error unescaping token: %v", err)
+	}
+	return token
+}
+
+func TestSendVerificationEmail(t *testing.T) {
+	jwtSecretKey = "testsecretkey"
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	m := &mailer.MemoryMailer{}
+	user := &models.User{Id: 1, Username: "testuser", Email: "testuser@example.com"}
+
+	mock.ExpectExec("UPDATE users SET verificationSentAt").
+		WithArgs(sqlmock.AnyArg(), user.Id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := SendVerificationEmail(db, m, user); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	messages := m.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if messages[0].To != user.Email {
+		t.Errorf("expected message to %s, got %s", user.Email, messages[0].To)
+	}
+
+	token := verificationTokenFrom(t, messages[0].Body)
+	payload, err := VerifySignedToken(emailVerificationPurpose, token)
+	if err != nil {
+		t.Fatalf("expected a valid signed token, got %v", err)
+	}
+	if payload != "1:testuser@example.com" {
+		t.Errorf("unexpected token payload %q", payload)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	jwtSecretKey = "testsecretkey"
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	m := &mailer.MemoryMailer{}
+	user := &models.User{Id: 1, Username: "testuser", Email: "testuser@example.com"}
+
+	mock.ExpectExec("UPDATE users SET verificationSentAt = (.+) WHERE id = (.+) AND \\(verificationSentAt IS NULL OR verificationSentAt < \\?\\)").
+		WithArgs(sqlmock.AnyArg(), user.Id, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := ResendVerificationEmail(db, m, user); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(m.Messages()) != 1 || user.VerificationSentAt == nil {
+		t.Errorf("expected a message to be sent once the slot was claimed")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestResendVerificationEmail_Cooldown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	m := &mailer.MemoryMailer{}
+	sentAt := time.Now().Add(-10 * time.Second)
+	user := &models.User{Id: 1, Username: "testuser", Email: "testuser@example.com", VerificationSentAt: &sentAt}
+
+	// Another request claimed the slot first.
+	mock.ExpectExec("UPDATE users SET verificationSentAt").
+		WithArgs(sqlmock.AnyArg(), user.Id, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	remaining, err := ResendVerificationEmail(db, m, user)
+	if err != ErrVerificationCooldown {
+		t.Fatalf("expected ErrVerificationCooldown, got %v", err)
+	}
+	if remaining <= 0 {
+		t.Errorf("expected a positive remaining cooldown, got %v", remaining)
+	}
+	if len(m.Messages()) != 0 {
+		t.Errorf("expected no message to be sent during the cooldown")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	jwtSecretKey = "testsecretkey"
+
+	t.Run("marks the email as verified", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		token := SignToken(emailVerificationPurpose, "1:testuser@example.com", time.Now().Add(time.Hour))
+
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE id = ?").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "testuser", "testuser@example.com", "hash", nil, nil, nil, nil, nil, nil, "user", nil))
+		mock.ExpectExec("UPDATE users SET verifiedAt").
+			WithArgs(sqlmock.AnyArg(), 1, "testuser@example.com").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		user, err := VerifyEmail(db, token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !user.IsVerified() {
+			t.Errorf("expected user to be verified")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("rejects links issued for a previous email address", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		token := SignToken(emailVerificationPurpose, "1:old@example.com", time.Now().Add(time.Hour))
+
+		mock.ExpectQuery("SELECT (.+) FROM users WHERE id = ?").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, "testuser", "new@example.com", "hash", nil, nil, nil, nil, nil, nil, "user", nil))
+
+		if _, err := VerifyEmail(db, token); err != ErrInvalidVerificationToken {
+			t.Errorf("expected ErrInvalidVerificationToken, got %v", err)
+		}
+	})
+
+	t.Run("rejects tampered links", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		token := SignToken(emailVerificationPurpose, "1:testuser@example.com", time.Now().Add(time.Hour))
+		tampered := strings.Replace(token, token[:4], "AAAA", 1)
+
+		if _, err := VerifyEmail(db, tampered); err != ErrInvalidVerificationToken {
+			t.Errorf("expected ErrInvalidVerificationToken, got %v", err)
+		}
+	})
+}
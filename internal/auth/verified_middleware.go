@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+)
+
+const verifyEmailNoticeURL = "/auth/verify-email/notice"
+
+// VerifiedMiddleware restricts a route to users who verified their email address.
+// Unverified users are redirected to a page explaining how to verify it.
+// It must run after Middleware, which attaches the user to the request context.
+func VerifiedMiddleware(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r.Context())
+		if !ok {
+			http.Error(w, "Could not retrieve user information", http.StatusInternalServerError)
+			return
+		}
+
+		if !user.Verified {
+			log.Printf("Unverified user %d trying to access %s", user.Id, r.URL.Path)
+			if IsAPIRequest(r) {
+				jsonapi.WriteError(w, http.StatusForbidden, "email_unverified", "Verify your email address to use the API")
+				return
+			}
+			http.Redirect(w, r, verifyEmailNoticeURL, http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,124 @@
+// Package csrf protects state-changing requests against cross-site request forgery
+// using signed double-submit tokens.
+//
+// Every visitor gets a random token in an HttpOnly cookie, signed so it can't be planted by a
+// sibling subdomain. Pages render the same token into base.html, from where htmx sends it back in the
+// X-CSRF-Token header. A cross-site attacker can make the browser send the cookie but can't read the
+// token to put it in the header.
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/signing"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+const (
+	CookieName = "csrf_token"
+	HeaderName = "X-CSRF-Token"
+	// FormField is accepted for plain form posts that can't set headers.
+	FormField = "csrf_token"
+
+	tokenSize = 32
+	// signingPurpose keeps CSRF cookies from being accepted as other signed values, and the other way around.
+	signingPurpose = "csrf"
+)
+
+type contextKey struct{}
+
+// Middleware makes sure every request has a CSRF token and rejects unsafe requests that don't send it back.
+// Requests authenticated with a bearer token are exempt, since browsers never attach those automatically.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, valid := tokenFromCookie(r)
+		if !valid {
+			token = newToken()
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    token + "." + signing.Sign(signingPurpose, token),
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		if !isSafeMethod(r.Method) && !hasBearerToken(r) {
+			sent := r.Header.Get(HeaderName)
+			if sent == "" {
+				sent = r.PostFormValue(FormField)
+			}
+
+			if !valid || !hmac.Equal([]byte(sent), []byte(token)) {
+				reject(w)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, token)))
+	})
+}
+
+// Token returns the CSRF token of the request, to be sent back with unsafe requests.
+func Token(r *http.Request) string {
+	token, _ := r.Context().Value(contextKey{}).(string)
+	return token
+}
+
+// TemplateFuncs exposes the request's token to templates as csrfToken.
+func TemplateFuncs(r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"csrfToken": func() string { return Token(r) },
+	}
+}
+
+func reject(w http.ResponseWriter) {
+	if err := toast.Error("Your session has expired, please reload the page and try again").WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+	http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+}
+
+// tokenFromCookie returns the token in the CSRF cookie and whether its signature is valid.
+func tokenFromCookie(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return "", false
+	}
+
+	token, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || token == "" || !signing.Verify(signingPurpose, token, signature) {
+		return "", false
+	}
+
+	return token, true
+}
+
+func newToken() string {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		panic("csrf: error generating token: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func hasBearerToken(r *http.Request) bool {
+	scheme, _, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	return ok && strings.EqualFold(scheme, "Bearer")
+}
@@ -0,0 +1,100 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(Token(r)))
+})
+
+// issueToken makes a GET request and returns the CSRF cookie and token it was given.
+func issueToken(t *testing.T) (*http.Cookie, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	Middleware(okHandler).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != CookieName {
+		t.Fatalf("expected a CSRF cookie to be set, got %v", cookies)
+	}
+
+	token := rec.Body.String()
+	if token == "" || !strings.HasPrefix(cookies[0].Value, token+".") {
+		t.Fatalf("expected the cookie %q to carry the token %q", cookies[0].Value, token)
+	}
+	return cookies[0], token
+}
+
+func TestMiddleware(t *testing.T) {
+	cookie, token := issueToken(t)
+
+	tests := []struct {
+		name   string
+		header string
+		form   string
+		cookie *http.Cookie
+		bearer bool
+		status int
+	}{
+		{name: "header", header: token, cookie: cookie, status: http.StatusOK},
+		{name: "form field", form: token, cookie: cookie, status: http.StatusOK},
+		{name: "missing token", cookie: cookie, status: http.StatusForbidden},
+		{name: "wrong token", header: "wrong", cookie: cookie, status: http.StatusForbidden},
+		{name: "missing cookie", header: token, status: http.StatusForbidden},
+		{name: "forged cookie", header: "forged", cookie: &http.Cookie{Name: CookieName, Value: "forged.signature"}, status: http.StatusForbidden},
+		{name: "bearer token", bearer: true, status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			if test.form != "" {
+				form.Set(FormField, test.form)
+			}
+
+			r := httptest.NewRequest("POST", "/api/logout", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.header != "" {
+				r.Header.Set(HeaderName, test.header)
+			}
+			if test.cookie != nil {
+				r.AddCookie(test.cookie)
+			}
+			if test.bearer {
+				r.Header.Set("Authorization", "Bearer token")
+			}
+
+			rec := httptest.NewRecorder()
+			Middleware(okHandler).ServeHTTP(rec, r)
+
+			if rec.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rec.Code)
+			}
+			if rec.Code == http.StatusForbidden && !strings.Contains(rec.Header().Get("HX-Trigger"), "triggerToast") {
+				t.Errorf("expected a toast explaining the failure")
+			}
+		})
+	}
+}
+
+func TestMiddleware_KeepsValidCookie(t *testing.T) {
+	cookie, token := issueToken(t)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	Middleware(okHandler).ServeHTTP(rec, r)
+
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("expected no new cookie for a request with a valid one")
+	}
+	if rec.Body.String() != token {
+		t.Errorf("expected the token from the cookie, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+var (
+	// ErrLastBookOwner is returned when a change would leave an address book without an owner.
+	ErrLastBookOwner = errors.New("address book would be left without an owner")
+	// ErrSoleBookOwner is returned when deleting a user who is the only owner of an address book shared with others.
+	ErrSoleBookOwner = errors.New("user is the only owner of a shared address book")
+)
+
+// ListAddressBooks returns the address books of the tenant the user is a member of, the default book first.
+func (t *Tenant) ListAddressBooks(userId int64) ([]models.AddressBook, error) {
+	rows, err := t.db.Query(listAddressBooksQuery, userId, t.orgId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query address books: %w", err)
+	}
+	defer rows.Close()
+
+	var books []models.AddressBook
+	for rows.Next() {
+		book, err := scanAddressBook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan address book: %w", err)
+		}
+		books = append(books, *book)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate address books: %w", err)
+	}
+
+	return books, nil
+}
+
+// GetAddressBook retrieves an address book along with the user's permission on it.
+// It returns nil without an error when the user isn't a member of a book of the tenant with that ID.
+func (t *Tenant) GetAddressBook(userId, id int64) (*models.AddressBook, error) {
+	book, err := scanAddressBook(t.db.QueryRow(getAddressBookQuery, id, userId, t.orgId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query address book: %w", err)
+	}
+
+	return book, nil
+}
+
+// CreateAddressBook stores a new shared address book of the tenant owned by the user, setting its ID.
+func (t *Tenant) CreateAddressBook(userId int64, book *models.AddressBook) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(insertAddressBookQuery, t.orgId, book.Name, book.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert address book: %w", err)
+	}
+
+	book.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if _, err := tx.Exec(insertBookMemberQuery, book.Id, userId, models.BookOwner, book.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert address book member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	book.Permission = models.BookOwner
+	book.MemberCount = 1
+
+	return nil
+}
+
+// DeleteAddressBook deletes a shared address book along with its contacts, members and invitations
+// in a single transaction. It returns false if the tenant has no such book, or if it is a default book.
+func (t *Tenant) DeleteAddressBook(id int64) (bool, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(deleteSharedBookQuery, id, t.orgId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete address book: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	for _, query := range []string{deleteBookContactsQuery, deleteBookInvitationsQuery, deleteBookMembersQuery} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return false, fmt.Errorf("failed to delete address book data: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// ListBookMembers returns the members of the address book ordered by username.
+func (t *Tenant) ListBookMembers(bookId int64) ([]models.AddressBookMember, error) {
+	rows, err := t.db.Query(listBookMembersQuery, bookId, t.orgId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query address book members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []models.AddressBookMember
+	for rows.Next() {
+		member, err := scanBookMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan address book member: %w", err)
+		}
+		members = append(members, *member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate address book members: %w", err)
+	}
+
+	return members, nil
+}
+
+// GetBookMember retrieves a member of the address book.
+// It returns nil without an error when the user isn't a member.
+func (t *Tenant) GetBookMember(bookId, userId int64) (*models.AddressBookMember, error) {
+	member, err := scanBookMember(t.db.QueryRow(getBookMemberQuery, bookId, userId, t.orgId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query address book member: %w", err)
+	}
+
+	return member, nil
+}
+
+// BookMemberEmailExists reports whether the user with the given email address is a member of the address book.
+func (t *Tenant) BookMemberEmailExists(bookId int64, email string) (bool, error) {
+	var exists bool
+
+	if err := t.db.QueryRow(bookMemberEmailExistsQuery, bookId, email, t.orgId).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to query address book members: %w", err)
+	}
+
+	return exists, nil
+}
+
+// UpdateBookMember changes the permission of a member of the address book.
+// It returns false if the user isn't a member, and ErrLastBookOwner if the book would be left without an owner.
+func (t *Tenant) UpdateBookMember(bookId, userId int64, permission string) (bool, error) {
+	return t.changeBookMember(bookId, updateBookMemberQuery, permission, bookId, userId, t.orgId)
+}
+
+// RemoveBookMember takes the user's access to the address book away.
+// It returns false if the user isn't a member, and ErrLastBookOwner if the book would be left without an owner.
+func (t *Tenant) RemoveBookMember(bookId, userId int64) (bool, error) {
+	return t.changeBookMember(bookId, deleteBookMemberQuery, bookId, userId, t.orgId)
+}
+
+// changeBookMember runs a statement changing one member of the book, and rolls it back if no owner is left.
+func (t *Tenant) changeBookMember(bookId int64, query string, args ...any) (bool, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to change address book member: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	var owners int
+	if err := tx.QueryRow(countBookOwnersQuery, bookId).Scan(&owners); err != nil {
+		return false, fmt.Errorf("failed to count address book owners: %w", err)
+	}
+	if owners == 0 {
+		return false, ErrLastBookOwner
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// createPersonalBook gives a new user their personal address book.
+func createPersonalBook(q queryer, userId int64, now time.Time) error {
+	result, err := q.Exec(insertPersonalBookQuery, userId, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert personal address book: %w", err)
+	}
+
+	bookId, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if _, err := q.Exec(insertBookMemberQuery, bookId, userId, models.BookOwner, now); err != nil {
+		return fmt.Errorf("failed to insert address book member: %w", err)
+	}
+
+	return nil
+}
+
+// bookPermission returns the user's permission on the address book,
+// or an empty string if they aren't a member or it belongs to another tenant.
+func (t *Tenant) bookPermission(q queryer, userId, bookId int64) (string, error) {
+	var permission string
+
+	err := q.QueryRow(getBookPermissionQuery, bookId, userId, t.orgId).Scan(&permission)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query address book permission: %w", err)
+	}
+
+	return permission, nil
+}
+
+func scanAddressBook(row rowScanner) (*models.AddressBook, error) {
+	var book models.AddressBook
+
+	err := row.Scan(
+		&book.Id, &book.Name, &book.Personal, &book.Default, &book.Permission, &book.MemberCount, &book.ContactCount, &book.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &book, nil
+}
+
+func scanBookMember(row rowScanner) (*models.AddressBookMember, error) {
+	var member models.AddressBookMember
+
+	err := row.Scan(&member.BookId, &member.UserId, &member.Username, &member.Email, &member.Permission, &member.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
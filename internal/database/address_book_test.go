@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func TestMigrate_BackfillsPersonalBooks(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	// A user and a contact from before address books existed.
+	db.Exec(`INSERT INTO users (username, email, password) VALUES ('old_timer', 'old@example.com', 'hash')`)
+	db.Exec(`INSERT INTO contacts (userId, firstName, lastName, email, phoneNumber) VALUES (1, 'Ada', 'Lovelace', '', '')`)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database again: %v", err)
+	}
+
+	books, err := ForTenant(db, 0).ListAddressBooks(1)
+	if err != nil || len(books) != 1 || !books[0].Personal || !books[0].IsOwner() || books[0].ContactCount != 1 {
+		t.Fatalf("expected a personal book holding the contact, got %+v (%v)", books, err)
+	}
+	if contact, _ := ForTenant(db, 0).GetContact(1, 1); contact == nil || contact.BookId != books[0].Id {
+		t.Errorf("expected the contact to be moved to the personal book, got %+v", contact)
+	}
+}
+
+func TestSharedAddressBook(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	now := time.Now().UTC()
+	ownerId, _ := CreateUser(db, "book_owner", "owner@example.com", "hash")
+	memberId, _ := CreateUser(db, "book_member", "member@example.com", "hash")
+
+	book := &models.AddressBook{Name: "Family", CreatedAt: now}
+	if err := ForTenant(db, 0).CreateAddressBook(ownerId, book); err != nil {
+		t.Fatalf("error creating address book: %v", err)
+	}
+
+	invitation := &models.AddressBookInvitation{
+		BookId: book.Id, Email: "Member@example.com", Permission: models.BookViewer, InvitedBy: ownerId,
+		ExpiresAt: now.Add(time.Hour), CreatedAt: now,
+	}
+	if err := ForTenant(db, 0).CreateBookInvitation(invitation); err != nil {
+		t.Fatalf("error creating invitation: %v", err)
+	}
+	if err := ForTenant(db, 0).CreateBookInvitation(&models.AddressBookInvitation{BookId: book.Id, Email: "member@example.com", Permission: models.BookEditor, ExpiresAt: now.Add(time.Hour), CreatedAt: now}); !errors.Is(err, ErrBookInvitationExists) {
+		t.Errorf("expected ErrBookInvitationExists, got %v", err)
+	}
+
+	if accepted, _ := ForTenant(db, 0).AcceptBookInvitation(invitation.Id, ownerId, "owner@example.com", now); accepted != nil {
+		t.Errorf("expected an invitation to be accepted only with the address it was sent to")
+	}
+	accepted, err := ForTenant(db, 0).AcceptBookInvitation(invitation.Id, memberId, "member@example.com", now)
+	if err != nil || accepted == nil {
+		t.Fatalf("expected the invitation to be accepted, got %v", err)
+	}
+
+	contact := &models.Contact{BookId: book.Id, UserId: ownerId, FirstName: "Ada", LastName: "Lovelace"}
+	if err := ForTenant(db, 0).CreateContact(contact); err != nil {
+		t.Fatalf("error creating contact: %v", err)
+	}
+
+	// Viewers read the book's contacts but can't change them.
+	shared, _ := ForTenant(db, 0).GetContact(memberId, contact.Id)
+	if shared == nil || shared.CanEdit() {
+		t.Fatalf("expected the member to read the contact, got %+v", shared)
+	}
+	if err := ForTenant(db, 0).CreateContact(&models.Contact{BookId: book.Id, UserId: memberId, FirstName: "Alan", LastName: "Turing"}); !errors.Is(err, ErrAddressBookReadOnly) {
+		t.Errorf("expected ErrAddressBookReadOnly, got %v", err)
+	}
+
+	// A book always keeps an owner, so its only one can't leave while others depend on it.
+	if _, err := ForTenant(db, 0).RemoveBookMember(book.Id, ownerId); !errors.Is(err, ErrLastBookOwner) {
+		t.Errorf("expected ErrLastBookOwner, got %v", err)
+	}
+	if err := DeleteUser(db, ownerId, nil); !errors.Is(err, ErrSoleBookOwner) {
+		t.Errorf("expected ErrSoleBookOwner, got %v", err)
+	}
+
+	if _, err := ForTenant(db, 0).UpdateBookMember(book.Id, memberId, models.BookOwner); err != nil {
+		t.Fatalf("error updating member: %v", err)
+	}
+	if err := DeleteUser(db, ownerId, nil); err != nil {
+		t.Fatalf("error deleting user: %v", err)
+	}
+	if books, _ := ForTenant(db, 0).ListAddressBooks(memberId); len(books) != 2 || books[1].ContactCount != 1 || books[1].MemberCount != 1 {
+		t.Errorf("expected the shared book and its contacts to outlive the user who created them, got %+v", books)
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// likeEscaper escapes the wildcards of LIKE patterns, which are declared with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUsers returns up to limit users whose username or email contains search, oldest first.
+// An empty search matches every user.
+func ListUsers(db *sql.DB, search string, limit int) ([]models.User, error) {
+	pattern := "%" + likeEscaper.Replace(search) + "%"
+
+	rows, err := db.Query(listUsersQuery, pattern, pattern, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := readUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate users: %w", err)
+	}
+
+	return users, nil
+}
+
+// DisableUser keeps the user from logging in and invalidates their sessions.
+// It returns false if the user was already disabled, in which case the audit event isn't recorded.
+func DisableUser(db *sql.DB, userId int64, now time.Time, audit *models.AuditEvent) (bool, error) {
+	return updateUserAudited(db, audit, "failed to disable user", disableUserQuery, now, now, userId)
+}
+
+// EnableUser lets a disabled user log in again.
+// It returns false if the user wasn't disabled, in which case the audit event isn't recorded.
+func EnableUser(db *sql.DB, userId int64, audit *models.AuditEvent) (bool, error) {
+	return updateUserAudited(db, audit, "failed to enable user", enableUserQuery, userId)
+}
+
+// UpdateUserRole changes the user's role.
+// It returns false if the user already had the role, in which case the audit event isn't recorded.
+func UpdateUserRole(db *sql.DB, userId int64, role string, audit *models.AuditEvent) (bool, error) {
+	return updateUserAudited(db, audit, "failed to update role", updateUserRoleQuery, role, userId, role)
+}
+
+// updateUserAudited runs an update of a single user and records the audit event, if any, in the same transaction,
+// provided the update changed the user. It reports whether it did.
+func updateUserAudited(db *sql.DB, audit *models.AuditEvent, failure, query string, args ...any) (bool, error) {
+	defer lockAuditChain(audit)()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", failure, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	if err := appendAuditEvent(tx, audit); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// PromoteAdmin makes the user with the given email an admin, provided they verified it.
+// It returns false if there is no such user.
+func PromoteAdmin(db *sql.DB, email string) (bool, error) {
+	result, err := db.Exec(promoteAdminQuery, email)
+	if err != nil {
+		return false, fmt.Errorf("failed to promote admin: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func TestListUsers(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	CreateUser(db, "ada_lovelace", "ada@example.com", "hash")
+	CreateUser(db, "adaXlovelace", "ada.x@example.com", "hash")
+	CreateUser(db, "alan", "alan@example.org", "hash")
+
+	tests := []struct {
+		search string
+		want   int
+	}{
+		{"", 3},
+		{"ada", 2},
+		{"example.org", 1},
+		// Wildcards are matched literally.
+		{"a_l", 1},
+		{"%", 0},
+	}
+
+	for _, tt := range tests {
+		users, err := ListUsers(db, tt.search, 10)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(users) != tt.want {
+			t.Errorf("search %q: expected %d users, got %d", tt.search, tt.want, len(users))
+		}
+	}
+
+	users, _ := ListUsers(db, "", 10)
+	if users[0].Role != "user" || users[0].IsDisabled() {
+		t.Errorf("expected new users to be enabled users, got %+v", users[0])
+	}
+}
+
+func TestDisableUser(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	userId, _ := CreateUser(db, "suspect", "suspect@example.com", "hash")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	event := func(action string) *models.AuditEvent {
+		return &models.AuditEvent{ActorId: 1, ActorName: "admin", Action: action, TargetId: userId, CreatedAt: now}
+	}
+
+	if disabled, err := DisableUser(db, userId, now, event(models.AuditUserDisabled)); err != nil || !disabled {
+		t.Fatalf("expected the user to be disabled, got %v, %v", disabled, err)
+	}
+	if disabled, _ := DisableUser(db, userId, now.Add(time.Minute), event(models.AuditUserDisabled)); disabled {
+		t.Errorf("expected disabling twice to do nothing")
+	}
+
+	user, _ := GetUserById(db, userId)
+	if !user.IsDisabled() || !user.SessionsValidAfter.Equal(now) {
+		t.Errorf("expected the user to be disabled and their sessions invalidated, got %+v", user)
+	}
+
+	if enabled, err := EnableUser(db, userId, event(models.AuditUserEnabled)); err != nil || !enabled {
+		t.Fatalf("expected the user to be enabled, got %v, %v", enabled, err)
+	}
+	if enabled, _ := EnableUser(db, userId, event(models.AuditUserEnabled)); enabled {
+		t.Errorf("expected enabling twice to do nothing")
+	}
+	if user, _ := GetUserById(db, userId); user.IsDisabled() {
+		t.Errorf("expected the user to be enabled")
+	}
+
+	// Only the changes are recorded in the audit trail.
+	events, _ := ListAuditEvents(db, models.AuditFilter{}, 10)
+	if len(events) != 2 || events[0].Action != models.AuditUserEnabled || events[1].Action != models.AuditUserDisabled {
+		t.Errorf("expected one event per change, got %+v", events)
+	}
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// CreateAPIToken stores a new API token and returns its ID.
+func CreateAPIToken(db *sql.DB, token *models.APIToken, tokenHash string) (int64, error) {
+	result, err := db.Exec(
+		insertAPITokenQuery,
+		token.UserId, token.Name, token.Prefix, tokenHash, strings.Join(token.Scopes, " "), token.ExpiresAt, token.CreatedAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert api token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetAPITokenByHash retrieves an API token by the hash of its secret.
+// It returns nil without an error when no token was found.
+func GetAPITokenByHash(db *sql.DB, tokenHash string) (*models.APIToken, error) {
+	token, err := scanAPIToken(db.QueryRow(getAPITokenByHashQuery, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ListAPITokens returns the user's API tokens, newest first.
+func ListAPITokens(db *sql.DB, userId int64) ([]models.APIToken, error) {
+	rows, err := db.Query(listAPITokensQuery, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api token: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate api tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// TouchAPIToken records when the token was last used.
+func TouchAPIToken(db *sql.DB, id int64, now time.Time) error {
+	if _, err := db.Exec(touchAPITokenQuery, now, id); err != nil {
+		return fmt.Errorf("failed to update api token: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAPIToken revokes one of the user's API tokens.
+// It returns false if the user has no token with that ID.
+func DeleteAPIToken(db *sql.DB, userId, id int64) (bool, error) {
+	result, err := db.Exec(deleteAPITokenQuery, id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete api token: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIToken(row rowScanner) (*models.APIToken, error) {
+	var token models.APIToken
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+
+	err := row.Scan(&token.Id, &token.UserId, &token.Name, &token.Prefix, &scopes, &expiresAt, &lastUsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	token.ExpiresAt = nullTimePtr(expiresAt)
+	token.LastUsedAt = nullTimePtr(lastUsedAt)
+
+	return &token, nil
+}
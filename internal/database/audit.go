@@ -0,0 +1,199 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// auditChainMu serializes appending to the audit trail, since each event's hash depends on the one before it.
+var auditChainMu sync.Mutex
+
+// CreateAuditEvent appends an event to the audit trail, chaining it to the last one, and sets its ID and hash.
+func CreateAuditEvent(db *sql.DB, event *models.AuditEvent) error {
+	defer lockAuditChain(event)()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := appendAuditEvent(tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// lockAuditChain locks auditChainMu if an event is to be appended, returning the function unlocking it.
+// Changes recorded in the same transaction as their audit event hold it until they are committed,
+// so no other event is chained to the last one in the meantime.
+func lockAuditChain(event *models.AuditEvent) func() {
+	if event == nil {
+		return func() {}
+	}
+	auditChainMu.Lock()
+	return auditChainMu.Unlock
+}
+
+// appendAuditEvent adds an event to the audit trail in tx, which commits it along with the change it records,
+// and sets its ID and hash. A nil event is nothing to record. The caller holds the lock of lockAuditChain.
+func appendAuditEvent(tx *sql.Tx, event *models.AuditEvent) error {
+	if event == nil {
+		return nil
+	}
+
+	var prevHash string
+	err := tx.QueryRow(lastAuditEventHashQuery).Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to query last audit event: %w", err)
+	}
+
+	hash := auditEventHash(prevHash, event)
+	result, err := tx.Exec(
+		insertAuditEventQuery,
+		event.ActorId, event.ActorName, event.Action, nullInt64(event.TargetId), event.TargetName,
+		event.IP, event.Detail, event.CreatedAt, hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit event: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	event.Id = id
+	event.Hash = hash
+	return nil
+}
+
+// ListAuditEvents returns the limit most recent events of the audit trail matching the filter, newest first.
+// A negative limit returns every matching event.
+func ListAuditEvents(db *sql.DB, filter models.AuditFilter, limit int) ([]models.AuditEvent, error) {
+	pattern := "%" + likeEscaper.Replace(filter.Actor) + "%"
+	to := sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()}
+
+	rows, err := db.Query(listAuditEventsQuery, pattern, filter.From, to, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// VerifyAuditChain recomputes the hash of every event of the audit trail, oldest first.
+// It returns the ID of the first event that doesn't match its hash or the one before it,
+// which was altered or follows a removed event, or zero if the trail is intact.
+func VerifyAuditChain(db *sql.DB) (int64, error) {
+	rows, err := db.Query(chainAuditEventsQuery)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var prevHash string
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if event.Hash != auditEventHash(prevHash, event) {
+			return event.Id, nil
+		}
+		prevHash = event.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate audit events: %w", err)
+	}
+
+	return 0, nil
+}
+
+// chainAuditEvents hashes the events recorded before the audit trail was chained, in the order they were recorded.
+func chainAuditEvents(db *sql.DB) error {
+	rows, err := db.Query(chainAuditEventsQuery)
+	if err != nil {
+		return fmt.Errorf("failed to query audit events: %w", err)
+	}
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate audit events: %w", err)
+	}
+
+	var prevHash string
+	for _, event := range events {
+		if event.Hash == "" {
+			event.Hash = auditEventHash(prevHash, event)
+			if _, err := db.Exec(setAuditEventHashQuery, event.Hash, event.Id); err != nil {
+				return fmt.Errorf("failed to chain audit event: %w", err)
+			}
+		}
+		prevHash = event.Hash
+	}
+
+	return nil
+}
+
+// auditEventHash hashes the event's fields along with the hash of the event before it.
+func auditEventHash(prevHash string, event *models.AuditEvent) string {
+	// Encoding the fields as a JSON array keeps them apart, whatever they contain.
+	fields, _ := json.Marshal([]any{
+		prevHash, event.ActorId, event.ActorName, event.Action, event.TargetId, event.TargetName,
+		event.IP, event.Detail, event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+func scanAuditEvent(row rowScanner) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	var targetId sql.NullInt64
+
+	err := row.Scan(
+		&event.Id, &event.ActorId, &event.ActorName, &event.Action, &targetId, &event.TargetName,
+		&event.IP, &event.Detail, &event.CreatedAt, &event.Hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	event.TargetId = targetId.Int64
+	return &event, nil
+}
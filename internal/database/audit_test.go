@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func TestAuditChain(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	now := time.Now().UTC()
+	events := []*models.AuditEvent{
+		{ActorId: 1, ActorName: "ada", Action: models.AuditLoginSucceeded, IP: "192.0.2.1", Detail: `{"method":"password"}`, CreatedAt: now.Add(-48 * time.Hour)},
+		{Action: models.AuditLoginFailed, IP: "192.0.2.9", Detail: `{"email":"eve@example.com"}`, CreatedAt: now.Add(-time.Hour)},
+		{ActorId: 1, ActorName: "ada", Action: models.AuditUserDisabled, TargetId: 2, TargetName: "alan", IP: "192.0.2.1", CreatedAt: now},
+	}
+	for _, event := range events {
+		if err := CreateAuditEvent(db, event); err != nil {
+			t.Fatalf("error creating audit event: %v", err)
+		}
+	}
+	if events[1].Hash == "" || events[1].Hash == events[0].Hash {
+		t.Errorf("expected every event to get its own hash, got %q and %q", events[0].Hash, events[1].Hash)
+	}
+
+	tests := []struct {
+		filter models.AuditFilter
+		want   int
+	}{
+		{models.AuditFilter{}, 3},
+		{models.AuditFilter{Actor: "ad"}, 2},
+		{models.AuditFilter{From: now.Add(-2 * time.Hour)}, 2},
+		{models.AuditFilter{To: now.Add(-2 * time.Hour)}, 1},
+		{models.AuditFilter{Actor: "ada", From: now.Add(-2 * time.Hour), To: now.Add(time.Hour)}, 1},
+	}
+	for _, tt := range tests {
+		if list, err := ListAuditEvents(db, tt.filter, -1); err != nil || len(list) != tt.want {
+			t.Errorf("filter %+v: expected %d events, got %d (%v)", tt.filter, tt.want, len(list), err)
+		}
+	}
+
+	if brokenAt, err := VerifyAuditChain(db); err != nil || brokenAt != 0 {
+		t.Fatalf("expected the trail to be intact, got event %d (%v)", brokenAt, err)
+	}
+
+	if _, err := db.Exec("UPDATE audit_events SET ip = '' WHERE id = ?", events[1].Id); err == nil {
+		t.Errorf("expected audit events not to be updated")
+	}
+	if _, err := db.Exec("DELETE FROM audit_events WHERE id = ?", events[1].Id); err == nil {
+		t.Errorf("expected audit events not to be deleted")
+	}
+
+	// Someone with access to the database file can drop the triggers, but not forge the hashes that follow.
+	db.Exec("DROP TRIGGER audit_events_no_update")
+	db.Exec("UPDATE audit_events SET actorName = 'mallory' WHERE id = ?", events[1].Id)
+	if brokenAt, _ := VerifyAuditChain(db); brokenAt != events[1].Id {
+		t.Errorf("expected the altered event %d to be detected, got %d", events[1].Id, brokenAt)
+	}
+	db.Exec("UPDATE audit_events SET actorName = '' WHERE id = ?", events[1].Id)
+
+	db.Exec("DROP TRIGGER audit_events_no_delete")
+	db.Exec("DELETE FROM audit_events WHERE id = ?", events[1].Id)
+	if brokenAt, _ := VerifyAuditChain(db); brokenAt != events[2].Id {
+		t.Errorf("expected the event after the removed one to be detected, got %d", brokenAt)
+	}
+}
+
+func TestMigrateChainsAuditEvents(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	// Events recorded before the trail was chained have no hash.
+	CreateAuditEvent(db, &models.AuditEvent{ActorId: 1, ActorName: "ada", Action: models.AuditUserEnabled, IP: "192.0.2.1", CreatedAt: time.Now().UTC()})
+	db.Exec("DROP TRIGGER audit_events_no_update")
+	db.Exec("UPDATE audit_events SET hash = ''")
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	events, _ := ListAuditEvents(db, models.AuditFilter{}, 10)
+	if len(events) != 1 || events[0].Hash == "" {
+		t.Fatalf("expected the event to be hashed, got %+v", events)
+	}
+	if brokenAt, err := VerifyAuditChain(db); err != nil || brokenAt != 0 {
+		t.Errorf("expected the trail to be intact, got event %d (%v)", brokenAt, err)
+	}
+	if _, err := db.Exec("UPDATE audit_events SET ip = ''"); err == nil {
+		t.Errorf("expected the triggers to be created again")
+	}
+}
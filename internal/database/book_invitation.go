@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+var (
+	// ErrBookInvitationExists is returned when inviting an email address that already has a pending invitation to the book.
+	ErrBookInvitationExists = errors.New("email address was already invited to the address book")
+	// ErrAddressBookNotFound is returned when inviting to a book that doesn't belong to the tenant.
+	ErrAddressBookNotFound = errors.New("address book not found")
+)
+
+// CreateBookInvitation stores an invitation to join an address book of the tenant, setting its ID.
+// Expired invitations for the same address are replaced, while pending ones make it return ErrBookInvitationExists.
+func (t *Tenant) CreateBookInvitation(invitation *models.AddressBookInvitation) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteExpiredBookInvitationQuery, invitation.BookId, invitation.Email, invitation.CreatedAt); err != nil {
+		return fmt.Errorf("failed to delete expired invitation: %w", err)
+	}
+
+	var exists bool
+	if err := tx.QueryRow(bookInvitationExistsQuery, invitation.BookId, invitation.Email).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to query invitations: %w", err)
+	}
+	if exists {
+		return ErrBookInvitationExists
+	}
+
+	result, err := tx.Exec(
+		insertBookInvitationQuery,
+		invitation.BookId, invitation.Email, invitation.Permission, invitation.InvitedBy, invitation.ExpiresAt, invitation.CreatedAt,
+		invitation.BookId, t.orgId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert invitation: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return ErrAddressBookNotFound
+	}
+
+	invitation.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListBookInvitations returns the pending invitations to join the address book, newest first.
+func (t *Tenant) ListBookInvitations(bookId int64, now time.Time) ([]models.AddressBookInvitation, error) {
+	return t.listBookInvitations(listBookInvitationsQuery, bookId, t.orgId, now)
+}
+
+// ListInvitationsForEmail returns the pending invitations to join address books of the tenant
+// sent to the email address, newest first.
+func (t *Tenant) ListInvitationsForEmail(email string, now time.Time) ([]models.AddressBookInvitation, error) {
+	return t.listBookInvitations(listInvitationsForEmailQuery, email, t.orgId, now)
+}
+
+// RevokeBookInvitation deletes a pending invitation to join the address book.
+// It returns false if the book has no invitation with that ID.
+func (t *Tenant) RevokeBookInvitation(bookId, id int64) (bool, error) {
+	result, err := t.db.Exec(deleteBookInvitationQuery, id, bookId, t.orgId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// AcceptBookInvitation makes the user a member of the address book they were invited to with the given email address,
+// and deletes the invitation, in a single transaction. Users who already are members keep their permission.
+// It returns nil without an error when the tenant has no pending invitation with that ID for the address.
+func (t *Tenant) AcceptBookInvitation(id, userId int64, email string, now time.Time) (*models.AddressBookInvitation, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invitation, err := scanBookInvitation(tx.QueryRow(getInvitationForEmailQuery, id, email, t.orgId, now))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitation: %w", err)
+	}
+
+	if _, err := tx.Exec(insertBookMemberQuery, invitation.BookId, userId, invitation.Permission, now); err != nil {
+		return nil, fmt.Errorf("failed to insert address book member: %w", err)
+	}
+
+	if _, err := tx.Exec(deleteBookInvitationQuery, invitation.Id, invitation.BookId, t.orgId); err != nil {
+		return nil, fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// DeclineBookInvitation deletes an invitation sent to the email address.
+// It returns false if the tenant has no invitation with that ID for the address.
+func (t *Tenant) DeclineBookInvitation(id int64, email string) (bool, error) {
+	result, err := t.db.Exec(declineBookInvitationQuery, id, email, t.orgId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+func (t *Tenant) listBookInvitations(query string, args ...any) ([]models.AddressBookInvitation, error) {
+	rows, err := t.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []models.AddressBookInvitation
+	for rows.Next() {
+		invitation, err := scanBookInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, *invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+func scanBookInvitation(row rowScanner) (*models.AddressBookInvitation, error) {
+	var invitation models.AddressBookInvitation
+
+	err := row.Scan(
+		&invitation.Id, &invitation.BookId, &invitation.BookName, &invitation.Email, &invitation.Permission,
+		&invitation.InvitedBy, &invitation.InviterName, &invitation.ExpiresAt, &invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
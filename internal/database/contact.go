@@ -0,0 +1,302 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+var (
+	ErrContactNotFound        = errors.New("contact not found")
+	ErrContactVersionMismatch = errors.New("contact was changed concurrently")
+	// ErrAddressBookReadOnly is returned when the user can't change contacts in the address book.
+	ErrAddressBookReadOnly = errors.New("address book is read-only for this user")
+)
+
+// ContactOperationError reports which operation of a bulk request failed.
+type ContactOperationError struct {
+	Index int
+	Err   error
+}
+
+func (e *ContactOperationError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *ContactOperationError) Unwrap() error {
+	return e.Err
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// ListContacts returns a page of the contacts in the address books of the tenant the user is a member of,
+// ordered by name, and how many there are in total. A non-zero bookId only lists the contacts of that book.
+func (t *Tenant) ListContacts(userId, bookId int64, limit, offset int) ([]models.Contact, int, error) {
+	var total int
+	if err := t.db.QueryRow(countContactsQuery, userId, t.orgId, bookId, bookId).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count contacts: %w", err)
+	}
+
+	rows, err := t.db.Query(listContactsQuery, userId, t.orgId, bookId, bookId, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query contacts: %w", err)
+	}
+	defer rows.Close()
+
+	var contacts []models.Contact
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		contacts = append(contacts, *contact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate contacts: %w", err)
+	}
+
+	return contacts, total, nil
+}
+
+// GetContact retrieves a contact from one of the address books of the tenant the user is a member of,
+// along with the user's permission on that book.
+// It returns nil without an error when the user has no access to a contact with that ID.
+func (t *Tenant) GetContact(userId, id int64) (*models.Contact, error) {
+	return t.getContact(t.db, userId, id)
+}
+
+// CreateContact stores a new contact created by its UserId, setting its ID and initial version.
+// Contacts without a BookId go to the default book of the tenant.
+// It returns ErrAddressBookReadOnly if the user can't add contacts to the book.
+func (t *Tenant) CreateContact(contact *models.Contact) error {
+	return t.inTransaction(func(tx *sql.Tx) error { return t.createContact(tx, contact) })
+}
+
+// UpdateContact saves a contact read with GetContact if it still has the version it was read with,
+// and increments the version. It returns ErrAddressBookReadOnly if the user can't change the contact
+// or move it to its new BookId, and ErrContactVersionMismatch if it was changed or deleted in the meantime.
+func (t *Tenant) UpdateContact(userId int64, contact *models.Contact) error {
+	return t.inTransaction(func(tx *sql.Tx) error { return t.updateContact(tx, userId, contact) })
+}
+
+// DeleteContact deletes a contact read with GetContact if it still has the version it was read with.
+// It returns ErrAddressBookReadOnly if the user can't change the contact,
+// and ErrContactVersionMismatch if it was changed or deleted in the meantime.
+func (t *Tenant) DeleteContact(userId int64, contact *models.Contact) error {
+	return t.inTransaction(func(tx *sql.Tx) error { return t.deleteContact(tx, userId, contact) })
+}
+
+// ApplyContactOperations runs the operations in order within a single transaction,
+// so either all of them are applied or none are. Failures are reported as a *ContactOperationError.
+func (t *Tenant) ApplyContactOperations(userId int64, ops []models.ContactOperation) ([]models.ContactOperationResult, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	results := make([]models.ContactOperationResult, 0, len(ops))
+	for i, op := range ops {
+		result, err := t.applyContactOperation(tx, userId, op)
+		if err != nil {
+			return nil, &ContactOperationError{Index: i, Err: err}
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return results, nil
+}
+
+func (t *Tenant) applyContactOperation(tx *sql.Tx, userId int64, op models.ContactOperation) (models.ContactOperationResult, error) {
+	result := models.ContactOperationResult{Op: op.Op, Id: op.Id}
+
+	if op.Op == models.ContactOpCreate {
+		contact := &models.Contact{UserId: userId}
+		op.Data.Apply(contact)
+		if err := t.createContact(tx, contact); err != nil {
+			return result, err
+		}
+		result.Id, result.Contact = contact.Id, contact
+		return result, nil
+	}
+
+	contact, err := t.getContact(tx, userId, op.Id)
+	if err != nil {
+		return result, err
+	}
+	if contact == nil {
+		return result, ErrContactNotFound
+	}
+	if op.Version != 0 && op.Version != contact.Version {
+		return result, ErrContactVersionMismatch
+	}
+	result.PreviousBookId = contact.BookId
+
+	switch op.Op {
+	case models.ContactOpUpdate:
+		op.Data.Apply(contact)
+		if err := t.updateContact(tx, userId, contact); err != nil {
+			return result, err
+		}
+		result.Contact = contact
+	case models.ContactOpDelete:
+		if err := t.deleteContact(tx, userId, contact); err != nil {
+			return result, err
+		}
+	default:
+		return result, fmt.Errorf("unknown contact operation %q", op.Op)
+	}
+
+	return result, nil
+}
+
+func (t *Tenant) getContact(q queryer, userId, id int64) (*models.Contact, error) {
+	contact, err := scanContact(q.QueryRow(getContactQuery, id, userId, t.orgId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contact: %w", err)
+	}
+
+	return contact, nil
+}
+
+func (t *Tenant) createContact(q queryer, contact *models.Contact) error {
+	if contact.BookId == 0 {
+		if err := q.QueryRow(getDefaultBookIdQuery, t.orgId, contact.UserId, t.orgId).Scan(&contact.BookId); err != nil {
+			return fmt.Errorf("failed to query default address book: %w", err)
+		}
+	}
+
+	result, err := q.Exec(
+		insertContactQuery,
+		contact.BookId, contact.UserId, contact.FirstName, contact.LastName, contact.Email, contact.PhoneNumber,
+		contact.BookId, contact.UserId, contact.BookId, t.orgId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert contact: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return ErrAddressBookReadOnly
+	}
+
+	contact.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	contact.Version = 1
+
+	return t.enqueueWebhookEvent(q, models.WebhookContactCreated, contact)
+}
+
+func (t *Tenant) updateContact(q queryer, userId int64, contact *models.Contact) error {
+	if !contact.CanEdit() {
+		return ErrAddressBookReadOnly
+	}
+
+	// The contact may be moving to another book, which the user must be able to add contacts to as well.
+	permission, err := t.bookPermission(q, userId, contact.BookId)
+	if err != nil {
+		return err
+	}
+	if !models.BookPermissionIncludes(permission, models.BookEditor) {
+		return ErrAddressBookReadOnly
+	}
+
+	result, err := q.Exec(
+		updateContactQuery,
+		contact.BookId, contact.FirstName, contact.LastName, contact.Email, contact.PhoneNumber,
+		contact.Id, contact.Version, userId, t.orgId, contact.BookId, userId, contact.BookId, t.orgId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update contact: %w", err)
+	}
+
+	if err := expectOneRow(result); err != nil {
+		return err
+	}
+	contact.Version++
+	contact.Permission = permission
+
+	return t.enqueueWebhookEvent(q, models.WebhookContactUpdated, contact)
+}
+
+func (t *Tenant) deleteContact(q queryer, userId int64, contact *models.Contact) error {
+	if !contact.CanEdit() {
+		return ErrAddressBookReadOnly
+	}
+
+	result, err := q.Exec(deleteContactQuery, contact.Id, contact.Version, userId, t.orgId)
+	if err != nil {
+		return fmt.Errorf("failed to delete contact: %w", err)
+	}
+
+	if err := expectOneRow(result); err != nil {
+		return err
+	}
+
+	return t.enqueueWebhookEvent(q, models.WebhookContactDeleted, contact)
+}
+
+// inTransaction runs fn within a transaction, which is committed if fn succeeds.
+// Single contact changes use one so the webhook events they queue are stored along with them.
+func (t *Tenant) inTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// expectOneRow returns ErrContactVersionMismatch unless the statement affected exactly one row.
+func expectOneRow(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return ErrContactVersionMismatch
+	}
+
+	return nil
+}
+
+func scanContact(row rowScanner) (*models.Contact, error) {
+	var contact models.Contact
+
+	err := row.Scan(
+		&contact.Id, &contact.BookId, &contact.UserId, &contact.FirstName, &contact.LastName, &contact.Email,
+		&contact.PhoneNumber, &contact.Version, &contact.Permission,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contact, nil
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func TestUpdateContact(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	contact := &models.Contact{Id: 7, BookId: 3, UserId: 1, FirstName: "Ada", LastName: "Lovelace", Version: 2, Permission: models.BookEditor}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(getBookPermissionQuery).
+		WithArgs(int64(3), int64(1), int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"permission"}).AddRow(models.BookEditor))
+	mock.ExpectExec(updateContactQuery).
+		WithArgs(int64(3), "Ada", "Lovelace", "", "", int64(7), int64(2), int64(1), int64(0), int64(3), int64(1), int64(3), int64(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(enqueueWebhookDeliveriesQuery).
+		WithArgs(models.WebhookContactUpdated, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), int64(3), models.WebhookContactUpdated).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if err := ForTenant(db, 0).UpdateContact(1, contact); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if contact.Version != 3 {
+		t.Errorf("expected version 3, got %d", contact.Version)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestUpdateContact_VersionMismatch(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	contact := &models.Contact{Id: 7, BookId: 3, UserId: 1, FirstName: "Ada", LastName: "Lovelace", Version: 2, Permission: models.BookEditor}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(getBookPermissionQuery).
+		WithArgs(int64(3), int64(1), int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"permission"}).AddRow(models.BookEditor))
+	mock.ExpectExec(updateContactQuery).
+		WithArgs(int64(3), "Ada", "Lovelace", "", "", int64(7), int64(2), int64(1), int64(0), int64(3), int64(1), int64(3), int64(0)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := ForTenant(db, 0).UpdateContact(1, contact); !errors.Is(err, ErrContactVersionMismatch) {
+		t.Errorf("expected ErrContactVersionMismatch, got %v", err)
+	}
+	if contact.Version != 2 {
+		t.Errorf("expected the version to stay 2, got %d", contact.Version)
+	}
+}
+
+func TestUpdateContact_ReadOnly(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	viewed := &models.Contact{Id: 7, BookId: 3, UserId: 1, FirstName: "Ada", LastName: "Lovelace", Version: 2, Permission: models.BookViewer}
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	if err := ForTenant(db, 0).UpdateContact(2, viewed); !errors.Is(err, ErrAddressBookReadOnly) {
+		t.Errorf("expected ErrAddressBookReadOnly for a viewer, got %v", err)
+	}
+
+	// Moving a contact needs the right to add contacts to the other book too.
+	moved := &models.Contact{Id: 7, BookId: 4, UserId: 1, FirstName: "Ada", LastName: "Lovelace", Version: 2, Permission: models.BookOwner}
+	mock.ExpectBegin()
+	mock.ExpectQuery(getBookPermissionQuery).
+		WithArgs(int64(4), int64(2), int64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"permission"}).AddRow(models.BookViewer))
+	mock.ExpectRollback()
+	if err := ForTenant(db, 0).UpdateContact(2, moved); !errors.Is(err, ErrAddressBookReadOnly) {
+		t.Errorf("expected ErrAddressBookReadOnly when moving to a read-only book, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// ReserveIdempotencyKey claims the key for a new request, after forgetting keys created before expiredBefore,
+// and returns the ID of the reservation. It returns zero if the user already used the key,
+// in which case GetIdempotencyKey returns the earlier request.
+func ReserveIdempotencyKey(db *sql.DB, userId int64, key, requestHash string, now, expiredBefore time.Time) (int64, error) {
+	if _, err := db.Exec(deleteExpiredIdempotencyKeysQuery, expiredBefore); err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	result, err := db.Exec(reserveIdempotencyKeyQuery, userId, key, requestHash, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return 0, nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetIdempotencyKey retrieves a key used by the user.
+// It returns nil without an error when the key was not used.
+func GetIdempotencyKey(db *sql.DB, userId int64, key string) (*models.IdempotencyKey, error) {
+	var k models.IdempotencyKey
+
+	err := db.QueryRow(getIdempotencyKeyQuery, userId, key).Scan(
+		&k.Id, &k.UserId, &k.Key, &k.RequestHash, &k.Status, &k.ContentType, &k.ETag, &k.Location, &k.Body, &k.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query idempotency key: %w", err)
+	}
+
+	return &k, nil
+}
+
+// CompleteIdempotencyKey stores the response to the request that reserved the key.
+func CompleteIdempotencyKey(db *sql.DB, key *models.IdempotencyKey) error {
+	_, err := db.Exec(completeIdempotencyKeyQuery, key.Status, key.ContentType, key.ETag, key.Location, key.Body, key.Id)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteIdempotencyKey releases a key so the request can be retried.
+func DeleteIdempotencyKey(db *sql.DB, id int64) error {
+	if _, err := db.Exec(deleteIdempotencyKeyQuery, id); err != nil {
+		return fmt.Errorf("failed to delete idempotency key: %w", err)
+	}
+
+	return nil
+}
@@ -9,7 +9,8 @@ import (
 
 var DB *sql.DB
 
-// InitDB initializes the database connection and assigns it to the global DB variable
+// InitDB initializes the database connection, applies pending schema
+// migrations and assigns the connection to the global DB variable
 func InitDB(dbName string) (*sql.DB, error) {
 	var err error
 
@@ -20,5 +21,9 @@ func InitDB(dbName string) (*sql.DB, error) {
 		return nil, fmt.Errorf("error connecting to database: %v", err)
 	}
 
+	if err := Migrate(DB); err != nil {
+		return nil, fmt.Errorf("error migrating database: %v", err)
+	}
+
 	return DB, nil
-}
\ No newline at end of file
+}
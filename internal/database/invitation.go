@@ -0,0 +1,183 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// ErrInvitationUsedUp is returned when an invitation was used up, revoked or expired before it could be used.
+var ErrInvitationUsedUp = errors.New("invitation can no longer be used")
+
+// CreateInvitation stores an invitation along with the hash of its token and sets its ID.
+// If audit is set, the event it describes the stored invitation with is recorded in the same transaction.
+func CreateInvitation(db *sql.DB, invitation *models.Invitation, tokenHash string, audit func(*models.Invitation) (*models.AuditEvent, error)) error {
+	// The event describes the invitation by its ID, so it's created once the invitation was inserted.
+	if audit != nil {
+		auditChainMu.Lock()
+		defer auditChainMu.Unlock()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		insertInvitationQuery,
+		tokenHash, invitation.CreatedBy, invitation.Email, invitation.MaxUses, invitation.ExpiresAt, invitation.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert invitation: %w", err)
+	}
+
+	invitation.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if audit != nil {
+		event, err := audit(invitation)
+		if err != nil {
+			return err
+		}
+		if err := appendAuditEvent(tx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetInvitationByHash retrieves an invitation by the hash of its token.
+// It returns nil without an error when no invitation was found.
+func GetInvitationByHash(db *sql.DB, tokenHash string) (*models.Invitation, error) {
+	invitation, err := scanInvitation(db.QueryRow(getInvitationQuery, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// ListUsableInvitations returns the invitations that can still be used at the given time, newest first.
+func ListUsableInvitations(db *sql.DB, now time.Time) ([]models.Invitation, error) {
+	rows, err := db.Query(listUsableInvitationsQuery, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []models.Invitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, *invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// RevokeInvitation keeps an invitation from being used again.
+// It returns false if there is no such invitation or it was already revoked, in which case the audit event,
+// if any, isn't recorded either.
+func RevokeInvitation(db *sql.DB, id int64, now time.Time, audit *models.AuditEvent) (bool, error) {
+	defer lockAuditChain(audit)()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(revokeInvitationQuery, now, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	if err := appendAuditEvent(tx, audit); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// CreateUserWithInvitation uses up one use of the invitation and creates the user in a single transaction,
+// so an invitation can't be used more often than allowed by concurrent registrations.
+// It returns ErrInvitationUsedUp if the invitation can no longer be used.
+func CreateUserWithInvitation(db *sql.DB, username, email, hashedPassword string, invitationId int64, now time.Time) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(useInvitationQuery, invitationId, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to use invitation: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return 0, ErrInvitationUsedUp
+	}
+
+	userId, err := insertUser(tx, username, email, hashedPassword, now)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userId, nil
+}
+
+func scanInvitation(row rowScanner) (*models.Invitation, error) {
+	var invitation models.Invitation
+	var expiresAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&invitation.Id, &invitation.CreatedBy, &invitation.Email, &invitation.MaxUses, &invitation.Uses,
+		&expiresAt, &revokedAt, &invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation.ExpiresAt = nullTimePtr(expiresAt)
+	invitation.RevokedAt = nullTimePtr(revokedAt)
+
+	return &invitation, nil
+}
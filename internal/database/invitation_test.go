@@ -0,0 +1,52 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func TestCreateUserWithInvitation(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	now := time.Now().UTC()
+	expired := now.Add(-time.Minute)
+
+	invitation := &models.Invitation{CreatedBy: 1, MaxUses: 2, CreatedAt: now}
+	CreateInvitation(db, invitation, "hash", nil)
+	CreateInvitation(db, &models.Invitation{CreatedBy: 1, MaxUses: 1, ExpiresAt: &expired, CreatedAt: now}, "expired-hash", nil)
+
+	for _, username := range []string{"first", "second"} {
+		if _, err := CreateUserWithInvitation(db, username, username+"@example.com", "hash", invitation.Id, now); err != nil {
+			t.Fatalf("expected %s to register, got %v", username, err)
+		}
+	}
+
+	_, err = CreateUserWithInvitation(db, "third", "third@example.com", "hash", invitation.Id, now)
+	if !errors.Is(err, ErrInvitationUsedUp) {
+		t.Errorf("expected ErrInvitationUsedUp, got %v", err)
+	}
+	if exists, _ := EmailExists(db, "third@example.com"); exists {
+		t.Errorf("expected no user to be created with a used up invitation")
+	}
+
+	if invitation, _ := GetInvitationByHash(db, "hash"); invitation.Uses != 2 || invitation.IsUsable(now) {
+		t.Errorf("expected the invitation to be used up, got %+v", invitation)
+	}
+
+	if invitations, _ := ListUsableInvitations(db, now); len(invitations) != 0 {
+		t.Errorf("expected no usable invitations, got %+v", invitations)
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// ErrMagicLinkUsed is returned when a magic link has already been used.
+var ErrMagicLinkUsed = errors.New("magic link already used")
+
+// CreateMagicLink stores the hashes of a magic link token and of the nonce of the browser that asked for it.
+func CreateMagicLink(db *sql.DB, userId int64, tokenHash, nonceHash string, expiresAt, now time.Time) error {
+	_, err := db.Exec(insertMagicLinkQuery, userId, tokenHash, nonceHash, expiresAt, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert magic link: %w", err)
+	}
+
+	return nil
+}
+
+// GetMagicLinkByHash retrieves a magic link by the hash of its token.
+func GetMagicLinkByHash(db *sql.DB, tokenHash string) (*models.MagicLink, error) {
+	var link models.MagicLink
+	var usedAt sql.NullTime
+
+	err := db.QueryRow(getMagicLinkQuery, tokenHash).Scan(
+		&link.Id, &link.UserId, &link.NonceHash, &link.ExpiresAt, &usedAt, &link.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No link found
+		}
+		return nil, fmt.Errorf("failed to query magic link: %w", err)
+	}
+
+	link.UsedAt = nullTimePtr(usedAt)
+
+	return &link, nil
+}
+
+// GetLatestMagicLinkCreatedAt returns when the user was last sent a magic link, or nil if they never were.
+func GetLatestMagicLinkCreatedAt(db *sql.DB, userId int64) (*time.Time, error) {
+	var createdAt time.Time
+
+	err := db.QueryRow(getLatestMagicLinkCreatedAtQuery, userId).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query magic link: %w", err)
+	}
+
+	return &createdAt, nil
+}
+
+// RedeemMagicLink marks the link and the user's other outstanding links as used.
+// With a non-empty reclaimPasswordHash, the user's password is also replaced, their sessions are ended
+// and email is marked as verified, all in a single transaction.
+// It returns ErrMagicLinkUsed if the link was used concurrently.
+func RedeemMagicLink(db *sql.DB, link *models.MagicLink, email, reclaimPasswordHash string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(useMagicLinkQuery, now, link.Id)
+	if err != nil {
+		return fmt.Errorf("failed to mark magic link as used: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return ErrMagicLinkUsed
+	}
+
+	if _, err := tx.Exec(useUserMagicLinksQuery, now, link.UserId); err != nil {
+		return fmt.Errorf("failed to invalidate magic links: %w", err)
+	}
+
+	if reclaimPasswordHash != "" {
+		if _, err := tx.Exec(updateUserPasswordQuery, reclaimPasswordHash, now, link.UserId); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		if _, err := tx.Exec(markEmailVerifiedQuery, now, link.UserId, email); err != nil {
+			return fmt.Errorf("failed to mark email as verified: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
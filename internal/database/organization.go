@@ -0,0 +1,349 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// ErrLastOrgAdmin is returned when a change would leave an organization with members but without an admin.
+var ErrLastOrgAdmin = errors.New("organization would be left without an admin")
+
+// ListUserOrganizations returns the organizations the user is a member of, ordered by name.
+func ListUserOrganizations(db *sql.DB, userId int64) ([]models.Organization, error) {
+	rows, err := db.Query(listUserOrganizationsQuery, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	var orgs []models.Organization
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, *org)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate organizations: %w", err)
+	}
+
+	return orgs, nil
+}
+
+// GetUserOrganization retrieves an organization along with the user's role in it.
+// It returns nil without an error when the user isn't a member of an organization with that ID.
+func GetUserOrganization(db *sql.DB, userId, id int64) (*models.Organization, error) {
+	org, err := scanOrganization(db.QueryRow(getUserOrganizationQuery, id, userId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// CreateOrganization stores a new organization administered by the user, along with its address book
+// and the audit event recording it, if any, in a single transaction. It sets the IDs of both.
+func CreateOrganization(db *sql.DB, userId int64, org *models.Organization, audit *models.AuditEvent) error {
+	defer lockAuditChain(audit)()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(insertOrganizationQuery, org.Name, org.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert organization: %w", err)
+	}
+
+	org.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	result, err = tx.Exec(insertAddressBookQuery, org.Id, org.Name, org.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert address book: %w", err)
+	}
+
+	org.BookId, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if _, err := tx.Exec(setOrganizationBookQuery, org.BookId, org.Id); err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	if _, err := tx.Exec(insertOrgMemberQuery, org.Id, userId, models.OrgAdmin, org.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert organization member: %w", err)
+	}
+
+	if _, err := tx.Exec(insertBookMemberQuery, org.BookId, userId, models.BookOwner, org.CreatedAt); err != nil {
+		return fmt.Errorf("failed to insert address book member: %w", err)
+	}
+
+	if err := appendAuditEvent(tx, audit); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	org.MembersCanCreateBooks = true
+	org.DefaultPermission = models.BookEditor
+	org.Role = models.OrgAdmin
+
+	return nil
+}
+
+// UpdateOrganization saves the name and settings of the organization. Its address book is renamed along with it.
+func UpdateOrganization(db *sql.DB, org *models.Organization) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(updateOrganizationQuery, org.Name, org.MembersCanCreateBooks, org.DefaultPermission, org.Id); err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	if _, err := tx.Exec(renameOrganizationBookQuery, org.Name, org.Id); err != nil {
+		return fmt.Errorf("failed to rename address book: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListOrgMembers returns the members of the organization ordered by username.
+func ListOrgMembers(db *sql.DB, orgId int64) ([]models.OrgMembership, error) {
+	rows, err := db.Query(listOrgMembersQuery, orgId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organization members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []models.OrgMembership
+	for rows.Next() {
+		member, err := scanOrgMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		members = append(members, *member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate organization members: %w", err)
+	}
+
+	return members, nil
+}
+
+// GetOrgMember retrieves a member of the organization.
+// It returns nil without an error when the user isn't a member.
+func GetOrgMember(db *sql.DB, orgId, userId int64) (*models.OrgMembership, error) {
+	member, err := scanOrgMember(db.QueryRow(getOrgMemberQuery, orgId, userId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organization member: %w", err)
+	}
+
+	return member, nil
+}
+
+// OrgMemberEmailExists reports whether the user with the given email address is a member of the organization.
+func OrgMemberEmailExists(db *sql.DB, orgId int64, email string) (bool, error) {
+	var exists bool
+
+	if err := db.QueryRow(orgMemberEmailExistsQuery, orgId, email).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to query organization members: %w", err)
+	}
+
+	return exists, nil
+}
+
+// AddOrgMember makes the user a member of the organization and of its address book, in a single transaction.
+// Admins own the book, while members get the default permission of the organization.
+// It returns false if the user already is a member.
+func AddOrgMember(db *sql.DB, org *models.Organization, userId int64, role string, now time.Time) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(insertOrgMemberQuery, org.Id, userId, role, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert organization member: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	permission := org.DefaultPermission
+	if role == models.OrgAdmin {
+		permission = models.BookOwner
+	}
+	if _, err := tx.Exec(insertBookMemberQuery, org.BookId, userId, permission, now); err != nil {
+		return false, fmt.Errorf("failed to insert address book member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// UpdateOrgMember changes the role of a member of the organization in a single transaction.
+// Their permission on its address book follows: admins own it, while members get the default permission.
+// It returns false if the user isn't a member, and ErrLastOrgAdmin if the organization would be left without an admin.
+func UpdateOrgMember(db *sql.DB, org *models.Organization, userId int64, role string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(updateOrgMemberQuery, role, org.Id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to update organization member: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	if err := checkOrgAdmins(tx, org.Id); err != nil {
+		return false, err
+	}
+
+	permission := org.DefaultPermission
+	if role == models.OrgAdmin {
+		permission = models.BookOwner
+	}
+	if _, err := tx.Exec(updateBookMemberQuery, permission, org.BookId, userId, org.Id); err != nil {
+		return false, fmt.Errorf("failed to update address book member: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// RemoveOrgMember takes the user out of the organization and its address books in a single transaction.
+// The books nobody else is a member of are deleted along with their contacts.
+// It returns false if the user isn't a member, ErrLastOrgAdmin if the organization would be left without an admin,
+// and ErrSoleBookOwner if the user is the only owner of one of its books shared with others.
+func RemoveOrgMember(db *sql.DB, orgId, userId int64) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(deleteOrgMemberQuery, orgId, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete organization member: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	if err := checkOrgAdmins(tx, orgId); err != nil {
+		return false, err
+	}
+
+	var soleOwnedBooks int
+	if err := tx.QueryRow(countSoleOwnedOrgBooksQuery, userId, orgId).Scan(&soleOwnedBooks); err != nil {
+		return false, fmt.Errorf("failed to count address books: %w", err)
+	}
+	if soleOwnedBooks > 0 {
+		return false, ErrSoleBookOwner
+	}
+
+	for _, query := range []string{deleteSoleMemberOrgBookContactsQuery, deleteSoleMemberOrgBookInvitationsQuery, deleteSoleMemberOrgBooksQuery} {
+		if _, err := tx.Exec(query, userId, userId, orgId); err != nil {
+			return false, fmt.Errorf("failed to delete address books: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(deleteOrgBookMembershipsQuery, userId, orgId); err != nil {
+		return false, fmt.Errorf("failed to delete address book members: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// checkOrgAdmins returns ErrLastOrgAdmin if the organization has members left, but no admin.
+func checkOrgAdmins(q queryer, orgId int64) error {
+	var lacksAdmin bool
+	if err := q.QueryRow(orgLacksAdminQuery, orgId, orgId).Scan(&lacksAdmin); err != nil {
+		return fmt.Errorf("failed to query organization admins: %w", err)
+	}
+	if lacksAdmin {
+		return ErrLastOrgAdmin
+	}
+
+	return nil
+}
+
+func scanOrganization(row rowScanner) (*models.Organization, error) {
+	var org models.Organization
+
+	err := row.Scan(
+		&org.Id, &org.Name, &org.BookId, &org.MembersCanCreateBooks, &org.DefaultPermission, &org.CreatedAt, &org.Role,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func scanOrgMember(row rowScanner) (*models.OrgMembership, error) {
+	var member models.OrgMembership
+
+	err := row.Scan(&member.OrgId, &member.UserId, &member.Username, &member.Email, &member.Role, &member.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
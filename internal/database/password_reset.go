@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// ErrPasswordResetUsed is returned when a password reset has already been redeemed.
+var ErrPasswordResetUsed = errors.New("password reset already used")
+
+// CreatePasswordReset stores the hash of a password reset token for the given user.
+func CreatePasswordReset(db *sql.DB, userId int64, tokenHash string, expiresAt time.Time) error {
+	_, err := db.Exec(insertPasswordResetQuery, userId, tokenHash, expiresAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to insert password reset: %w", err)
+	}
+
+	return nil
+}
+
+// GetPasswordResetByHash retrieves a password reset by the hash of its token.
+func GetPasswordResetByHash(db *sql.DB, tokenHash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	var usedAt sql.NullTime
+
+	err := db.QueryRow(getPasswordResetQuery, tokenHash).Scan(&reset.Id, &reset.UserId, &reset.ExpiresAt, &usedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No reset found
+		}
+		return nil, fmt.Errorf("failed to query password reset: %w", err)
+	}
+
+	reset.UsedAt = nullTimePtr(usedAt)
+
+	return &reset, nil
+}
+
+// RedeemPasswordReset marks the reset as used, stores the new password hash and
+// invalidates the user's existing sessions and outstanding reset tokens, all in a single transaction.
+// It returns ErrPasswordResetUsed if the reset was redeemed concurrently.
+func RedeemPasswordReset(db *sql.DB, reset *models.PasswordReset, hashedPassword string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(usePasswordResetQuery, now, reset.Id)
+	if err != nil {
+		return fmt.Errorf("failed to mark password reset as used: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return ErrPasswordResetUsed
+	}
+
+	if _, err := tx.Exec(updateUserPasswordQuery, hashedPassword, now, reset.UserId); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if _, err := tx.Exec(useUserPasswordResetsQuery, now, reset.UserId); err != nil {
+		return fmt.Errorf("failed to invalidate password resets: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
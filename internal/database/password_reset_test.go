@@ -0,0 +1,83 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func TestGetPasswordResetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expiresAt := time.Now().Add(time.Hour)
+	mock.ExpectQuery(getPasswordResetQuery).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "userId", "expiresAt", "usedAt"}).AddRow(3, 1, expiresAt, nil))
+
+	reset, err := GetPasswordResetByHash(db, "hash")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if reset == nil || reset.Id != 3 || reset.UserId != 1 || reset.UsedAt != nil {
+		t.Errorf("unexpected password reset: %+v", reset)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRedeemPasswordReset(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	reset := &models.PasswordReset{Id: 3, UserId: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(usePasswordResetQuery).WithArgs(now, reset.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(updateUserPasswordQuery).WithArgs("newhash", now, reset.UserId).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(useUserPasswordResetsQuery).WithArgs(now, reset.UserId).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := RedeemPasswordReset(db, reset, "newhash", now); err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestRedeemPasswordReset_AlreadyUsed(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	reset := &models.PasswordReset{Id: 3, UserId: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(usePasswordResetQuery).WithArgs(now, reset.Id).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := RedeemPasswordReset(db, reset, "newhash", now); err != ErrPasswordResetUsed {
+		t.Errorf("expected ErrPasswordResetUsed, but got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
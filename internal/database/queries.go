@@ -1,16 +1,665 @@
 package database
 
 const (
+	userColumns = `id, username, email, password, sessionsValidAfter, verifiedAt, verificationSentAt,
+		totpSecret, totpEnabledAt, totpLastCounter, role, disabledAt`
+
 	insertUserQuery = `
 		INSERT INTO users (username, email, password)
 		VALUES (?, ?, ?)
 	`
 
 	getUserQuery = `
-		SELECT id, username, email, password FROM users WHERE email = ? LIMIT 1
+		SELECT ` + userColumns + ` FROM users WHERE email = ? LIMIT 1
+	`
+
+	getUserByIdQuery = `
+		SELECT ` + userColumns + ` FROM users WHERE id = ? LIMIT 1
 	`
 
 	emailExistsQuery = `
 		SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)
 	`
+
+	updateUserPasswordQuery = `
+		UPDATE users SET password = ?, sessionsValidAfter = ? WHERE id = ?
+	`
+
+	updateUsernameQuery = `
+		UPDATE users SET username = ? WHERE id = ?
+	`
+
+	updateUserEmailQuery = `
+		UPDATE users SET email = ?, verifiedAt = NULL, verificationSentAt = NULL WHERE id = ?
+	`
+
+	deleteUserQuery = `
+		DELETE FROM users WHERE id = ?
+	`
+
+	rehashUserPasswordQuery = `
+		UPDATE users SET password = ? WHERE id = ? AND password = ?
+	`
+
+	markEmailVerifiedQuery = `
+		UPDATE users SET verifiedAt = ? WHERE id = ? AND email = ? AND verifiedAt IS NULL
+	`
+
+	updateVerificationSentAtQuery = `
+		UPDATE users SET verificationSentAt = ? WHERE id = ?
+	`
+
+	claimVerificationSendQuery = `
+		UPDATE users SET verificationSentAt = ?
+		WHERE id = ? AND (verificationSentAt IS NULL OR verificationSentAt < ?)
+	`
+
+	insertPasswordResetQuery = `
+		INSERT INTO password_resets (userId, tokenHash, expiresAt, createdAt)
+		VALUES (?, ?, ?, ?)
+	`
+
+	getPasswordResetQuery = `
+		SELECT id, userId, expiresAt, usedAt FROM password_resets WHERE tokenHash = ? LIMIT 1
+	`
+
+	usePasswordResetQuery = `
+		UPDATE password_resets SET usedAt = ? WHERE id = ? AND usedAt IS NULL
+	`
+
+	useUserPasswordResetsQuery = `
+		UPDATE password_resets SET usedAt = ? WHERE userId = ? AND usedAt IS NULL
+	`
+
+	setTOTPSecretQuery = `
+		UPDATE users SET totpSecret = ?, totpLastCounter = NULL WHERE id = ? AND totpEnabledAt IS NULL
+	`
+
+	enableTOTPQuery = `
+		UPDATE users SET totpEnabledAt = ?, totpLastCounter = ?
+		WHERE id = ? AND totpSecret = ? AND totpEnabledAt IS NULL
+	`
+
+	disableTOTPQuery = `
+		UPDATE users SET totpSecret = NULL, totpEnabledAt = NULL, totpLastCounter = NULL WHERE id = ?
+	`
+
+	useTOTPCounterQuery = `
+		UPDATE users SET totpLastCounter = ?
+		WHERE id = ? AND (totpLastCounter IS NULL OR totpLastCounter < ?)
+	`
+
+	insertRecoveryCodeQuery = `
+		INSERT INTO recovery_codes (userId, codeHash, createdAt) VALUES (?, ?, ?)
+	`
+
+	deleteRecoveryCodesQuery = `
+		DELETE FROM recovery_codes WHERE userId = ?
+	`
+
+	useRecoveryCodeQuery = `
+		UPDATE recovery_codes SET usedAt = ? WHERE userId = ? AND codeHash = ? AND usedAt IS NULL
+	`
+
+	countRecoveryCodesQuery = `
+		SELECT COUNT(*) FROM recovery_codes WHERE userId = ? AND usedAt IS NULL
+	`
+
+	insertAPITokenQuery = `
+		INSERT INTO api_tokens (userId, name, prefix, tokenHash, scopes, expiresAt, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	apiTokenColumns = `id, userId, name, prefix, scopes, expiresAt, lastUsedAt, createdAt`
+
+	getAPITokenByHashQuery = `
+		SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE tokenHash = ? LIMIT 1
+	`
+
+	listAPITokensQuery = `
+		SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE userId = ? ORDER BY createdAt DESC, id DESC
+	`
+
+	touchAPITokenQuery = `
+		UPDATE api_tokens SET lastUsedAt = ? WHERE id = ?
+	`
+
+	deleteAPITokenQuery = `
+		DELETE FROM api_tokens WHERE id = ? AND userId = ?
+	`
+
+	// contactColumns are selected from contacts c joined with the address_book_members m of the requesting user.
+	contactColumns = `c.id, c.bookId, c.userId, c.firstName, c.lastName, c.email, c.phoneNumber, c.version, m.permission`
+
+	// editableBookIds selects the address books the user given as its parameter can change contacts in.
+	editableBookIds = `SELECT bookId FROM address_book_members WHERE userId = ? AND permission IN ('editor', 'owner')`
+
+	// tenantBookIds selects the address books of the organization given as its parameter,
+	// or of personal workspaces for 0. Every query on the data of a Tenant is limited to them.
+	tenantBookIds = `SELECT id FROM address_books WHERE orgId = ?`
+
+	listContactsQuery = `
+		SELECT ` + contactColumns + ` FROM contacts c JOIN address_book_members m ON m.bookId = c.bookId
+		WHERE m.userId = ? AND c.bookId IN (` + tenantBookIds + `) AND (? = 0 OR c.bookId = ?)
+		ORDER BY c.lastName, c.firstName, c.id LIMIT ? OFFSET ?
+	`
+
+	countContactsQuery = `
+		SELECT COUNT(*) FROM contacts c JOIN address_book_members m ON m.bookId = c.bookId
+		WHERE m.userId = ? AND c.bookId IN (` + tenantBookIds + `) AND (? = 0 OR c.bookId = ?)
+	`
+
+	getContactQuery = `
+		SELECT ` + contactColumns + ` FROM contacts c JOIN address_book_members m ON m.bookId = c.bookId
+		WHERE c.id = ? AND m.userId = ? AND c.bookId IN (` + tenantBookIds + `) LIMIT 1
+	`
+
+	insertContactQuery = `
+		INSERT INTO contacts (bookId, userId, firstName, lastName, email, phoneNumber, version)
+		SELECT ?, ?, ?, ?, ?, ?, 1 WHERE ? IN (` + editableBookIds + `) AND ? IN (` + tenantBookIds + `)
+	`
+
+	updateContactQuery = `
+		UPDATE contacts SET bookId = ?, firstName = ?, lastName = ?, email = ?, phoneNumber = ?, version = version + 1
+		WHERE id = ? AND version = ? AND bookId IN (` + editableBookIds + `) AND bookId IN (` + tenantBookIds + `)
+		AND ? IN (` + editableBookIds + `) AND ? IN (` + tenantBookIds + `)
+	`
+
+	deleteContactQuery = `
+		DELETE FROM contacts WHERE id = ? AND version = ? AND bookId IN (` + editableBookIds + `)
+		AND bookId IN (` + tenantBookIds + `)
+	`
+
+	deleteExpiredIdempotencyKeysQuery = `
+		DELETE FROM idempotency_keys WHERE createdAt < ?
+	`
+
+	reserveIdempotencyKeyQuery = `
+		INSERT INTO idempotency_keys (userId, key, requestHash, createdAt) VALUES (?, ?, ?, ?)
+		ON CONFLICT (userId, key) DO NOTHING
+	`
+
+	getIdempotencyKeyQuery = `
+		SELECT id, userId, key, requestHash, status, contentType, etag, location, body, createdAt
+		FROM idempotency_keys WHERE userId = ? AND key = ? LIMIT 1
+	`
+
+	completeIdempotencyKeyQuery = `
+		UPDATE idempotency_keys SET status = ?, contentType = ?, etag = ?, location = ?, body = ? WHERE id = ?
+	`
+
+	deleteIdempotencyKeyQuery = `
+		DELETE FROM idempotency_keys WHERE id = ?
+	`
+
+	getUserIdentityQuery = `
+		SELECT id, userId, provider, subject, email, createdAt FROM user_identities
+		WHERE provider = ? AND subject = ? LIMIT 1
+	`
+
+	insertUserIdentityQuery = `
+		INSERT INTO user_identities (userId, provider, subject, email, createdAt) VALUES (?, ?, ?, ?, ?)
+	`
+
+	insertMagicLinkQuery = `
+		INSERT INTO magic_links (userId, tokenHash, nonceHash, expiresAt, createdAt)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	getMagicLinkQuery = `
+		SELECT id, userId, nonceHash, expiresAt, usedAt, createdAt FROM magic_links WHERE tokenHash = ? LIMIT 1
+	`
+
+	getLatestMagicLinkCreatedAtQuery = `
+		SELECT createdAt FROM magic_links WHERE userId = ? ORDER BY createdAt DESC LIMIT 1
+	`
+
+	useMagicLinkQuery = `
+		UPDATE magic_links SET usedAt = ? WHERE id = ? AND usedAt IS NULL
+	`
+
+	useUserMagicLinksQuery = `
+		UPDATE magic_links SET usedAt = ? WHERE userId = ? AND usedAt IS NULL
+	`
+
+	sessionColumns = `id, userId, method, ip, userAgent, createdAt, issuedAt, lastSeenAt, expiresAt, revokedAt,
+		impersonatorId`
+
+	deleteExpiredSessionsQuery = `
+		DELETE FROM sessions WHERE userId = ? AND expiresAt < ?
+	`
+
+	insertSessionQuery = `
+		INSERT INTO sessions (userId, method, ip, userAgent, createdAt, issuedAt, lastSeenAt, expiresAt, impersonatorId)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	getSessionQuery = `
+		SELECT ` + sessionColumns + ` FROM sessions WHERE id = ? LIMIT 1
+	`
+
+	listSessionsQuery = `
+		SELECT ` + sessionColumns + ` FROM sessions WHERE userId = ? AND revokedAt IS NULL ORDER BY lastSeenAt DESC
+	`
+
+	reissueSessionQuery = `
+		UPDATE sessions SET issuedAt = ?, expiresAt = ? WHERE id = ?
+	`
+
+	touchSessionQuery = `
+		UPDATE sessions SET lastSeenAt = ? WHERE id = ?
+	`
+
+	revokeSessionQuery = `
+		UPDATE sessions SET revokedAt = ? WHERE id = ? AND userId = ? AND revokedAt IS NULL
+	`
+
+	deleteOldLoginEventsQuery = `
+		DELETE FROM login_events WHERE userId = ? AND createdAt < ?
+	`
+
+	insertLoginEventQuery = `
+		INSERT INTO login_events (userId, method, ip, userAgent, success, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	listLoginEventsQuery = `
+		SELECT id, userId, method, ip, userAgent, success, createdAt FROM login_events
+		WHERE userId = ? AND createdAt >= ? ORDER BY createdAt DESC, id DESC LIMIT ?
+	`
+
+	listUsersQuery = `
+		SELECT ` + userColumns + ` FROM users
+		WHERE username LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\'
+		ORDER BY id LIMIT ?
+	`
+
+	disableUserQuery = `
+		UPDATE users SET disabledAt = ?, sessionsValidAfter = ? WHERE id = ? AND disabledAt IS NULL
+	`
+
+	enableUserQuery = `
+		UPDATE users SET disabledAt = NULL WHERE id = ? AND disabledAt IS NOT NULL
+	`
+
+	updateUserRoleQuery = `
+		UPDATE users SET role = ? WHERE id = ? AND role != ?
+	`
+
+	promoteAdminQuery = `
+		UPDATE users SET role = 'admin' WHERE email = ? AND verifiedAt IS NOT NULL
+	`
+
+	auditEventColumns = `id, actorId, actorName, action, targetId, targetName, ip, detail, createdAt, hash`
+
+	lastAuditEventHashQuery = `
+		SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1
+	`
+
+	insertAuditEventQuery = `
+		INSERT INTO audit_events (actorId, actorName, action, targetId, targetName, ip, detail, createdAt, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	listAuditEventsQuery = `
+		SELECT ` + auditEventColumns + ` FROM audit_events
+		WHERE actorName LIKE ? ESCAPE '\' AND createdAt >= ? AND (? IS NULL OR createdAt < ?)
+		ORDER BY createdAt DESC, id DESC LIMIT ?
+	`
+
+	chainAuditEventsQuery = `
+		SELECT ` + auditEventColumns + ` FROM audit_events ORDER BY id
+	`
+
+	setAuditEventHashQuery = `
+		UPDATE audit_events SET hash = ? WHERE id = ?
+	`
+
+	invitationColumns = `id, createdBy, email, maxUses, uses, expiresAt, revokedAt, createdAt`
+
+	insertInvitationQuery = `
+		INSERT INTO invitations (tokenHash, createdBy, email, maxUses, expiresAt, createdAt)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	getInvitationQuery = `
+		SELECT ` + invitationColumns + ` FROM invitations WHERE tokenHash = ? LIMIT 1
+	`
+
+	listUsableInvitationsQuery = `
+		SELECT ` + invitationColumns + ` FROM invitations
+		WHERE revokedAt IS NULL AND uses < maxUses AND (expiresAt IS NULL OR expiresAt > ?)
+		ORDER BY createdAt DESC, id DESC
+	`
+
+	useInvitationQuery = `
+		UPDATE invitations SET uses = uses + 1
+		WHERE id = ? AND revokedAt IS NULL AND uses < maxUses AND (expiresAt IS NULL OR expiresAt > ?)
+	`
+
+	revokeInvitationQuery = `
+		UPDATE invitations SET revokedAt = ? WHERE id = ? AND revokedAt IS NULL
+	`
+
+	// isDefaultBook tells the personal books and the books of organizations apart from the books users create.
+	isDefaultBook = `(b.personalUserId IS NOT NULL OR b.id IN (SELECT bookId FROM organizations))`
+
+	// addressBookColumns are selected from address_books b joined with the address_book_members m of the requesting user.
+	addressBookColumns = `b.id, b.name, b.personalUserId IS NOT NULL, ` + isDefaultBook + `, m.permission,
+		(SELECT COUNT(*) FROM address_book_members WHERE bookId = b.id),
+		(SELECT COUNT(*) FROM contacts WHERE bookId = b.id), b.createdAt`
+
+	listAddressBooksQuery = `
+		SELECT ` + addressBookColumns + ` FROM address_books b JOIN address_book_members m ON m.bookId = b.id
+		WHERE m.userId = ? AND b.orgId = ? ORDER BY NOT ` + isDefaultBook + `, b.name COLLATE NOCASE, b.id
+	`
+
+	getAddressBookQuery = `
+		SELECT ` + addressBookColumns + ` FROM address_books b JOIN address_book_members m ON m.bookId = b.id
+		WHERE b.id = ? AND m.userId = ? AND b.orgId = ? LIMIT 1
+	`
+
+	getBookPermissionQuery = `
+		SELECT permission FROM address_book_members WHERE bookId = ? AND userId = ? AND bookId IN (` + tenantBookIds + `)
+		LIMIT 1
+	`
+
+	// getDefaultBookIdQuery selects the personal book of a user in their personal workspace,
+	// and the book of the organization otherwise.
+	getDefaultBookIdQuery = `
+		SELECT id FROM address_books
+		WHERE orgId = ? AND (personalUserId = ? OR id = (SELECT bookId FROM organizations WHERE id = ?)) LIMIT 1
+	`
+
+	insertPersonalBookQuery = `
+		INSERT INTO address_books (name, personalUserId, createdAt) VALUES ('Personal', ?, ?)
+	`
+
+	insertAddressBookQuery = `
+		INSERT INTO address_books (orgId, name, createdAt) VALUES (?, ?, ?)
+	`
+
+	deleteBookContactsQuery = `
+		DELETE FROM contacts WHERE bookId = ?
+	`
+
+	deleteBookInvitationsQuery = `
+		DELETE FROM address_book_invitations WHERE bookId = ?
+	`
+
+	deleteBookMembersQuery = `
+		DELETE FROM address_book_members WHERE bookId = ?
+	`
+
+	deleteSharedBookQuery = `
+		DELETE FROM address_books
+		WHERE id = ? AND orgId = ? AND personalUserId IS NULL AND id NOT IN (SELECT bookId FROM organizations)
+	`
+
+	insertBookMemberQuery = `
+		INSERT INTO address_book_members (bookId, userId, permission, createdAt) VALUES (?, ?, ?, ?)
+		ON CONFLICT (bookId, userId) DO NOTHING
+	`
+
+	bookMemberColumns = `m.bookId, m.userId, u.username, u.email, m.permission, m.createdAt`
+
+	listBookMembersQuery = `
+		SELECT ` + bookMemberColumns + ` FROM address_book_members m JOIN users u ON u.id = m.userId
+		WHERE m.bookId = ? AND m.bookId IN (` + tenantBookIds + `) ORDER BY u.username COLLATE NOCASE, u.id
+	`
+
+	getBookMemberQuery = `
+		SELECT ` + bookMemberColumns + ` FROM address_book_members m JOIN users u ON u.id = m.userId
+		WHERE m.bookId = ? AND m.userId = ? AND m.bookId IN (` + tenantBookIds + `) LIMIT 1
+	`
+
+	bookMemberEmailExistsQuery = `
+		SELECT EXISTS(
+			SELECT 1 FROM address_book_members m JOIN users u ON u.id = m.userId
+			WHERE m.bookId = ? AND u.email = ? COLLATE NOCASE AND m.bookId IN (` + tenantBookIds + `)
+		)
+	`
+
+	updateBookMemberQuery = `
+		UPDATE address_book_members SET permission = ?
+		WHERE bookId = ? AND userId = ? AND bookId IN (` + tenantBookIds + `)
+	`
+
+	deleteBookMemberQuery = `
+		DELETE FROM address_book_members WHERE bookId = ? AND userId = ? AND bookId IN (` + tenantBookIds + `)
+	`
+
+	countBookOwnersQuery = `
+		SELECT COUNT(*) FROM address_book_members WHERE bookId = ? AND permission = 'owner'
+	`
+
+	// soleMemberBookIds selects the address books the user given as both of its parameters is the only member of.
+	soleMemberBookIds = `
+		SELECT bookId FROM address_book_members WHERE userId = ?
+		AND bookId NOT IN (SELECT bookId FROM address_book_members WHERE userId != ?)
+	`
+
+	// countSoleOwnedSharedBooksQuery counts the books that would be left without an owner, but not without members,
+	// if the user left.
+	countSoleOwnedSharedBooksQuery = `
+		SELECT COUNT(*) FROM address_book_members m WHERE m.userId = ? AND m.permission = 'owner'
+		AND NOT EXISTS (SELECT 1 FROM address_book_members o WHERE o.bookId = m.bookId AND o.userId != m.userId AND o.permission = 'owner')
+		AND EXISTS (SELECT 1 FROM address_book_members o WHERE o.bookId = m.bookId AND o.userId != m.userId)
+	`
+
+	deleteSoleMemberBookContactsQuery = `
+		DELETE FROM contacts WHERE bookId IN (` + soleMemberBookIds + `)
+	`
+
+	deleteSoleMemberBookInvitationsQuery = `
+		DELETE FROM address_book_invitations WHERE bookId IN (` + soleMemberBookIds + `)
+	`
+
+	deleteSoleMemberBooksQuery = `
+		DELETE FROM address_books WHERE id IN (` + soleMemberBookIds + `)
+	`
+
+	// bookInvitationColumns are selected from address_book_invitations i joined with their address_books b.
+	bookInvitationColumns = `i.id, i.bookId, b.name, i.email, i.permission, i.invitedBy,
+		COALESCE((SELECT username FROM users WHERE id = i.invitedBy), ''), i.expiresAt, i.createdAt`
+
+	deleteExpiredBookInvitationQuery = `
+		DELETE FROM address_book_invitations WHERE bookId = ? AND email = ? AND expiresAt <= ?
+	`
+
+	bookInvitationExistsQuery = `
+		SELECT EXISTS(SELECT 1 FROM address_book_invitations WHERE bookId = ? AND email = ?)
+	`
+
+	insertBookInvitationQuery = `
+		INSERT INTO address_book_invitations (bookId, email, permission, invitedBy, expiresAt, createdAt)
+		SELECT ?, ?, ?, ?, ?, ? WHERE ? IN (` + tenantBookIds + `)
+	`
+
+	listBookInvitationsQuery = `
+		SELECT ` + bookInvitationColumns + ` FROM address_book_invitations i JOIN address_books b ON b.id = i.bookId
+		WHERE i.bookId = ? AND b.orgId = ? AND i.expiresAt > ? ORDER BY i.createdAt DESC, i.id DESC
+	`
+
+	listInvitationsForEmailQuery = `
+		SELECT ` + bookInvitationColumns + ` FROM address_book_invitations i JOIN address_books b ON b.id = i.bookId
+		WHERE i.email = ? AND b.orgId = ? AND i.expiresAt > ? ORDER BY i.createdAt DESC, i.id DESC
+	`
+
+	getInvitationForEmailQuery = `
+		SELECT ` + bookInvitationColumns + ` FROM address_book_invitations i JOIN address_books b ON b.id = i.bookId
+		WHERE i.id = ? AND i.email = ? AND b.orgId = ? AND i.expiresAt > ? LIMIT 1
+	`
+
+	deleteBookInvitationQuery = `
+		DELETE FROM address_book_invitations WHERE id = ? AND bookId = ? AND bookId IN (` + tenantBookIds + `)
+	`
+
+	declineBookInvitationQuery = `
+		DELETE FROM address_book_invitations WHERE id = ? AND email = ? AND bookId IN (` + tenantBookIds + `)
+	`
+
+	// organizationColumns are selected from organizations o joined with the organization_members m of the requesting user.
+	organizationColumns = `o.id, o.name, o.bookId, o.membersCanCreateBooks, o.defaultPermission, o.createdAt, m.role`
+
+	listUserOrganizationsQuery = `
+		SELECT ` + organizationColumns + ` FROM organizations o JOIN organization_members m ON m.orgId = o.id
+		WHERE m.userId = ? ORDER BY o.name COLLATE NOCASE, o.id
+	`
+
+	getUserOrganizationQuery = `
+		SELECT ` + organizationColumns + ` FROM organizations o JOIN organization_members m ON m.orgId = o.id
+		WHERE o.id = ? AND m.userId = ? LIMIT 1
+	`
+
+	insertOrganizationQuery = `
+		INSERT INTO organizations (name, createdAt) VALUES (?, ?)
+	`
+
+	setOrganizationBookQuery = `
+		UPDATE organizations SET bookId = ? WHERE id = ?
+	`
+
+	updateOrganizationQuery = `
+		UPDATE organizations SET name = ?, membersCanCreateBooks = ?, defaultPermission = ? WHERE id = ?
+	`
+
+	renameOrganizationBookQuery = `
+		UPDATE address_books SET name = ? WHERE id = (SELECT bookId FROM organizations WHERE id = ?)
+	`
+
+	orgMemberColumns = `m.orgId, m.userId, u.username, u.email, m.role, m.createdAt`
+
+	listOrgMembersQuery = `
+		SELECT ` + orgMemberColumns + ` FROM organization_members m JOIN users u ON u.id = m.userId
+		WHERE m.orgId = ? ORDER BY u.username COLLATE NOCASE, u.id
+	`
+
+	getOrgMemberQuery = `
+		SELECT ` + orgMemberColumns + ` FROM organization_members m JOIN users u ON u.id = m.userId
+		WHERE m.orgId = ? AND m.userId = ? LIMIT 1
+	`
+
+	orgMemberEmailExistsQuery = `
+		SELECT EXISTS(
+			SELECT 1 FROM organization_members m JOIN users u ON u.id = m.userId
+			WHERE m.orgId = ? AND u.email = ? COLLATE NOCASE
+		)
+	`
+
+	insertOrgMemberQuery = `
+		INSERT INTO organization_members (orgId, userId, role, createdAt) VALUES (?, ?, ?, ?)
+		ON CONFLICT (orgId, userId) DO NOTHING
+	`
+
+	updateOrgMemberQuery = `
+		UPDATE organization_members SET role = ? WHERE orgId = ? AND userId = ?
+	`
+
+	deleteOrgMemberQuery = `
+		DELETE FROM organization_members WHERE orgId = ? AND userId = ?
+	`
+
+	// orgLacksAdminQuery takes the organization twice and reports whether it has members left, but no admin.
+	orgLacksAdminQuery = `
+		SELECT EXISTS (SELECT 1 FROM organization_members WHERE orgId = ?)
+		AND NOT EXISTS (SELECT 1 FROM organization_members WHERE orgId = ? AND role = 'admin')
+	`
+
+	// countSoleAdminOrgsQuery counts the organizations that would be left without an admin, but not without members,
+	// if the user left.
+	countSoleAdminOrgsQuery = `
+		SELECT COUNT(*) FROM organization_members m WHERE m.userId = ? AND m.role = 'admin'
+		AND NOT EXISTS (SELECT 1 FROM organization_members o WHERE o.orgId = m.orgId AND o.userId != m.userId AND o.role = 'admin')
+		AND EXISTS (SELECT 1 FROM organization_members o WHERE o.orgId = m.orgId AND o.userId != m.userId)
+	`
+
+	// countSoleOwnedOrgBooksQuery is like countSoleOwnedSharedBooksQuery, limited to the books of an organization.
+	countSoleOwnedOrgBooksQuery = countSoleOwnedSharedBooksQuery + ` AND m.bookId IN (` + tenantBookIds + `)`
+
+	// The following take the user twice and then the organization, to clean up its books when a member leaves it.
+
+	deleteSoleMemberOrgBookContactsQuery = `
+		DELETE FROM contacts WHERE bookId IN (` + soleMemberBookIds + ` AND bookId IN (` + tenantBookIds + `))
+	`
+
+	deleteSoleMemberOrgBookInvitationsQuery = `
+		DELETE FROM address_book_invitations WHERE bookId IN (` + soleMemberBookIds + ` AND bookId IN (` + tenantBookIds + `))
+	`
+
+	deleteSoleMemberOrgBooksQuery = `
+		DELETE FROM address_books WHERE id IN (` + soleMemberBookIds + ` AND bookId IN (` + tenantBookIds + `))
+	`
+
+	deleteOrgBookMembershipsQuery = `
+		DELETE FROM address_book_members WHERE userId = ? AND bookId IN (` + tenantBookIds + `)
+	`
+
+	webhookColumns = `id, userId, url, secret, events, createdAt`
+
+	insertWebhookQuery = `
+		INSERT INTO webhooks (userId, url, secret, events, createdAt) VALUES (?, ?, ?, ?, ?)
+	`
+
+	listWebhooksQuery = `
+		SELECT ` + webhookColumns + ` FROM webhooks WHERE userId = ? ORDER BY createdAt DESC, id DESC
+	`
+
+	getWebhookQuery = `
+		SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ? AND userId = ?
+	`
+
+	deleteWebhookDeliveriesQuery = `
+		DELETE FROM webhook_deliveries WHERE webhookId = (SELECT id FROM webhooks WHERE id = ? AND userId = ?)
+	`
+
+	deleteWebhookQuery = `
+		DELETE FROM webhooks WHERE id = ? AND userId = ?
+	`
+
+	deleteUserWebhookDeliveriesQuery = `
+		DELETE FROM webhook_deliveries WHERE webhookId IN (SELECT id FROM webhooks WHERE userId = ?)
+	`
+
+	// enqueueWebhookDeliveriesQuery queues an event for the webhooks subscribed to it
+	// of every member of the contact's address book.
+	enqueueWebhookDeliveriesQuery = `
+		INSERT INTO webhook_deliveries (webhookId, event, payload, status, nextAttemptAt, createdAt)
+		SELECT w.id, ?, ?, 'pending', ?, ? FROM webhooks w
+		JOIN address_book_members m ON m.userId = w.userId
+		WHERE m.bookId = ? AND instr(' ' || w.events || ' ', ' ' || ? || ' ') > 0
+	`
+
+	webhookDeliveryColumns = `id, webhookId, event, payload, status, attempts, nextAttemptAt, lastAttemptAt,
+		responseStatus, error, createdAt`
+
+	listWebhookDeliveriesQuery = `
+		SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE webhookId = ?
+		ORDER BY createdAt DESC, id DESC LIMIT ?
+	`
+
+	getWebhookDeliveryQuery = `
+		SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = ? AND webhookId = ?
+	`
+
+	insertWebhookDeliveryQuery = `
+		INSERT INTO webhook_deliveries (webhookId, event, payload, status, nextAttemptAt, createdAt)
+		VALUES (?, ?, ?, 'pending', ?, ?)
+	`
+
+	listDueWebhookDeliveriesQuery = `
+		SELECT d.id, d.webhookId, d.event, d.payload, d.status, d.attempts, d.nextAttemptAt, d.lastAttemptAt,
+		d.responseStatus, d.error, d.createdAt, w.id, w.userId, w.url, w.secret, w.events, w.createdAt
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhookId
+		WHERE d.status = 'pending' AND d.nextAttemptAt <= ? ORDER BY d.nextAttemptAt, d.id LIMIT ?
+	`
+
+	updateWebhookDeliveryQuery = `
+		UPDATE webhook_deliveries SET status = ?, attempts = ?, nextAttemptAt = ?, lastAttemptAt = ?,
+		responseStatus = ?, error = ? WHERE id = ?
+	`
+
+	pruneWebhookDeliveriesQuery = `
+		DELETE FROM webhook_deliveries WHERE status != 'pending' AND createdAt < ?
+	`
 )
@@ -0,0 +1,315 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// tableStatements create every table used by the application.
+// They are safe to run against an existing database.
+var tableStatements = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL,
+		password TEXT NOT NULL,
+		email TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS contacts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		firstName TEXT NOT NULL,
+		lastName TEXT NOT NULL,
+		email TEXT NOT NULL,
+		phoneNumber TEXT NOT NULL,
+		userId INTEGER NOT NULL,
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS password_resets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		tokenHash TEXT NOT NULL UNIQUE,
+		expiresAt DATETIME NOT NULL,
+		usedAt DATETIME,
+		createdAt DATETIME NOT NULL,
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS magic_links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		tokenHash TEXT NOT NULL UNIQUE,
+		nonceHash TEXT NOT NULL,
+		expiresAt DATETIME NOT NULL,
+		usedAt DATETIME,
+		createdAt DATETIME NOT NULL,
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS recovery_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		codeHash TEXT NOT NULL,
+		usedAt DATETIME,
+		createdAt DATETIME NOT NULL,
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		tokenHash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		expiresAt DATETIME,
+		lastUsedAt DATETIME,
+		createdAt DATETIME NOT NULL,
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_identities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		email TEXT NOT NULL,
+		createdAt DATETIME NOT NULL,
+			UNIQUE (provider, subject),
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		method TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		userAgent TEXT NOT NULL,
+		createdAt DATETIME NOT NULL,
+		issuedAt DATETIME NOT NULL,
+		lastSeenAt DATETIME NOT NULL,
+		expiresAt DATETIME NOT NULL,
+		revokedAt DATETIME,
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS login_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		method TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		userAgent TEXT NOT NULL,
+		success BOOLEAN NOT NULL,
+		createdAt DATETIME NOT NULL,
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS idempotency_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		requestHash TEXT NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		contentType TEXT NOT NULL DEFAULT '',
+		etag TEXT NOT NULL DEFAULT '',
+		location TEXT NOT NULL DEFAULT '',
+		body BLOB,
+		createdAt DATETIME NOT NULL,
+			UNIQUE (userId, key),
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS invitations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tokenHash TEXT NOT NULL UNIQUE,
+		createdBy INTEGER NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		maxUses INTEGER NOT NULL,
+		uses INTEGER NOT NULL DEFAULT 0,
+		expiresAt DATETIME,
+		revokedAt DATETIME,
+		createdAt DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actorId INTEGER NOT NULL,
+		actorName TEXT NOT NULL,
+		action TEXT NOT NULL,
+		targetId INTEGER,
+		targetName TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL,
+		detail TEXT NOT NULL DEFAULT '',
+		createdAt DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS address_books (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		personalUserId INTEGER UNIQUE,
+		createdAt DATETIME NOT NULL,
+			FOREIGN KEY (personalUserId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS address_book_members (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		bookId INTEGER NOT NULL,
+		userId INTEGER NOT NULL,
+		permission TEXT NOT NULL,
+		createdAt DATETIME NOT NULL,
+			UNIQUE (bookId, userId),
+			FOREIGN KEY (bookId) REFERENCES address_books(id),
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS address_book_invitations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		bookId INTEGER NOT NULL,
+		email TEXT NOT NULL COLLATE NOCASE,
+		permission TEXT NOT NULL,
+		invitedBy INTEGER NOT NULL,
+		expiresAt DATETIME NOT NULL,
+		createdAt DATETIME NOT NULL,
+			UNIQUE (bookId, email),
+			FOREIGN KEY (bookId) REFERENCES address_books(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS organizations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		bookId INTEGER NOT NULL DEFAULT 0,
+		membersCanCreateBooks BOOLEAN NOT NULL DEFAULT 1,
+		defaultPermission TEXT NOT NULL DEFAULT 'editor',
+		createdAt DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS organization_members (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		orgId INTEGER NOT NULL,
+		userId INTEGER NOT NULL,
+		role TEXT NOT NULL,
+		createdAt DATETIME NOT NULL,
+			UNIQUE (orgId, userId),
+			FOREIGN KEY (orgId) REFERENCES organizations(id),
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		createdAt DATETIME NOT NULL,
+			FOREIGN KEY (userId) REFERENCES users(id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhookId INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		nextAttemptAt DATETIME,
+		lastAttemptAt DATETIME,
+		responseStatus INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		createdAt DATETIME NOT NULL,
+			FOREIGN KEY (webhookId) REFERENCES webhooks(id)
+	)`,
+}
+
+// columnMigrations add columns to tables created before the column existed.
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"users", "sessionsValidAfter", "DATETIME"},
+	{"users", "verifiedAt", "DATETIME"},
+	{"users", "verificationSentAt", "DATETIME"},
+	{"users", "totpSecret", "TEXT"},
+	{"users", "totpEnabledAt", "DATETIME"},
+	{"users", "totpLastCounter", "INTEGER"},
+	{"contacts", "version", "INTEGER NOT NULL DEFAULT 1"},
+	{"users", "role", "TEXT NOT NULL DEFAULT 'user'"},
+	{"users", "disabledAt", "DATETIME"},
+	{"sessions", "impersonatorId", "INTEGER"},
+	{"contacts", "bookId", "INTEGER"},
+	// Zero for books of personal workspaces, which is all of them before organizations were introduced.
+	{"address_books", "orgId", "INTEGER NOT NULL DEFAULT 0"},
+	// Empty for events recorded before the audit trail was chained, until Migrate hashes them.
+	{"audit_events", "hash", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// columnBackfills run once, right after the column named by their key was added.
+var columnBackfills = map[string]string{
+	// Users who registered before email verification was required keep access to their accounts.
+	"users.verifiedAt": `UPDATE users SET verifiedAt = CURRENT_TIMESTAMP WHERE verifiedAt IS NULL`,
+}
+
+// dataMigrations fill in data that rows created before a schema change lack. They are safe to run repeatedly.
+var dataMigrations = []string{
+	// Users registered before address books were introduced get their personal book,
+	// which takes over the contacts they owned.
+	`INSERT INTO address_books (name, personalUserId, createdAt)
+		SELECT 'Personal', id, CURRENT_TIMESTAMP FROM users
+		WHERE id NOT IN (SELECT personalUserId FROM address_books WHERE personalUserId IS NOT NULL)`,
+	`INSERT INTO address_book_members (bookId, userId, permission, createdAt)
+		SELECT id, personalUserId, 'owner', createdAt FROM address_books
+		WHERE personalUserId IS NOT NULL AND id NOT IN (SELECT bookId FROM address_book_members)`,
+	`UPDATE contacts SET bookId = (SELECT id FROM address_books WHERE personalUserId = contacts.userId)
+		WHERE bookId IS NULL`,
+}
+
+// triggerStatements keep the audit trail append-only. They are created once events recorded before
+// the trail was chained have been hashed.
+var triggerStatements = []string{
+	`CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+		BEGIN SELECT RAISE(ABORT, 'audit events are append-only'); END`,
+	`CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+		BEGIN SELECT RAISE(ABORT, 'audit events are append-only'); END`,
+}
+
+// Migrate brings the database schema up to date.
+func Migrate(db *sql.DB) error {
+	for _, stmt := range tableStatements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+
+	for _, m := range columnMigrations {
+		exists, err := columnExists(db, m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.table, m.column, err)
+		}
+
+		if backfill, ok := columnBackfills[m.table+"."+m.column]; ok {
+			if _, err := db.Exec(backfill); err != nil {
+				return fmt.Errorf("failed to backfill column %s.%s: %w", m.table, m.column, err)
+			}
+		}
+	}
+
+	for _, stmt := range dataMigrations {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate data: %w", err)
+		}
+	}
+
+	if err := chainAuditEvents(db); err != nil {
+		return err
+	}
+
+	for _, stmt := range triggerStatements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create trigger: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// columnExists reports whether the given table already has the given column.
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	var exists bool
+
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = ?)", table, column).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+
+	return exists, nil
+}
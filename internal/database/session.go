@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// CreateSession stores a new session and sets its ID. The user's expired sessions are removed at the same time.
+func CreateSession(db *sql.DB, session *models.Session) error {
+	if _, err := db.Exec(deleteExpiredSessionsQuery, session.UserId, session.CreatedAt); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	result, err := db.Exec(
+		insertSessionQuery,
+		session.UserId, session.Method, session.IP, session.UserAgent,
+		session.CreatedAt, session.IssuedAt, session.LastSeenAt, session.ExpiresAt, nullInt64(session.ImpersonatorId),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert session: %w", err)
+	}
+
+	session.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession retrieves a session by its ID.
+// It returns nil without an error when no session was found.
+func GetSession(db *sql.DB, id int64) (*models.Session, error) {
+	session, err := scanSession(db.QueryRow(getSessionQuery, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session: %w", err)
+	}
+
+	return session, nil
+}
+
+// ListSessions returns the user's sessions that weren't revoked, most recently seen first.
+// Some may have expired or been invalidated by a password change.
+func ListSessions(db *sql.DB, userId int64) ([]models.Session, error) {
+	rows, err := db.Query(listSessionsQuery, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// ReissueSession records that a new cookie was issued for the session.
+func ReissueSession(db *sql.DB, id int64, issuedAt, expiresAt time.Time) error {
+	if _, err := db.Exec(reissueSessionQuery, issuedAt, expiresAt, id); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return nil
+}
+
+// TouchSession records when the session was last used.
+func TouchSession(db *sql.DB, id int64, now time.Time) error {
+	if _, err := db.Exec(touchSessionQuery, now, id); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeSession ends one of the user's sessions.
+// It returns false if the user has no active session with that ID.
+func RevokeSession(db *sql.DB, userId, id int64, now time.Time) (bool, error) {
+	result, err := db.Exec(revokeSessionQuery, now, id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// CreateLoginEvent adds an attempt to the user's login history,
+// removing the attempts made before keepAfter at the same time.
+func CreateLoginEvent(db *sql.DB, event *models.LoginEvent, keepAfter time.Time) error {
+	if _, err := db.Exec(deleteOldLoginEventsQuery, event.UserId, keepAfter); err != nil {
+		return fmt.Errorf("failed to delete old login events: %w", err)
+	}
+
+	result, err := db.Exec(
+		insertLoginEventQuery,
+		event.UserId, event.Method, event.IP, event.UserAgent, event.Success, event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert login event: %w", err)
+	}
+
+	event.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return nil
+}
+
+// ListLoginEvents returns up to limit of the user's login attempts made since the given time, newest first.
+func ListLoginEvents(db *sql.DB, userId int64, since time.Time, limit int) ([]models.LoginEvent, error) {
+	rows, err := db.Query(listLoginEventsQuery, userId, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.LoginEvent
+	for rows.Next() {
+		var event models.LoginEvent
+		err := rows.Scan(&event.Id, &event.UserId, &event.Method, &event.IP, &event.UserAgent, &event.Success, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan login event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate login events: %w", err)
+	}
+
+	return events, nil
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var session models.Session
+	var revokedAt sql.NullTime
+	var impersonatorId sql.NullInt64
+
+	err := row.Scan(
+		&session.Id, &session.UserId, &session.Method, &session.IP, &session.UserAgent,
+		&session.CreatedAt, &session.IssuedAt, &session.LastSeenAt, &session.ExpiresAt, &revokedAt,
+		&impersonatorId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	session.RevokedAt = nullTimePtr(revokedAt)
+	session.ImpersonatorId = impersonatorId.Int64
+
+	return &session, nil
+}
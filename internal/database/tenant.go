@@ -0,0 +1,17 @@
+package database
+
+import "database/sql"
+
+// Tenant reads and writes the address books and contacts of one workspace: an organization,
+// or the personal workspaces of users. Every query it runs is limited to the books of that workspace,
+// so data of another tenant can't be read or changed through it, whatever IDs it is given.
+type Tenant struct {
+	db    *sql.DB
+	orgId int64
+}
+
+// ForTenant returns the repository of the organization with the given ID, or of personal workspaces for 0.
+// Callers check that the user is a member of the organization.
+func ForTenant(db *sql.DB, orgId int64) *Tenant {
+	return &Tenant{db: db, orgId: orgId}
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	now := time.Now().UTC()
+	userId, _ := CreateUser(db, "consultant", "consultant@example.com", "hash")
+
+	// The user works for two organizations and on their own, with a contact in each workspace.
+	acme := &models.Organization{Name: "Acme", CreatedAt: now}
+	globex := &models.Organization{Name: "Globex", CreatedAt: now}
+	for _, org := range []*models.Organization{acme, globex} {
+		if err := CreateOrganization(db, userId, org, nil); err != nil {
+			t.Fatalf("error creating organization: %v", err)
+		}
+	}
+
+	personal, inAcme, inGlobex := ForTenant(db, 0), ForTenant(db, acme.Id), ForTenant(db, globex.Id)
+	contacts := map[*Tenant]*models.Contact{}
+	for tenant, name := range map[*Tenant]string{personal: "Ada", inAcme: "Alan", inGlobex: "Grace"} {
+		contact := &models.Contact{UserId: userId, FirstName: name, LastName: "Doe"}
+		if err := tenant.CreateContact(contact); err != nil {
+			t.Fatalf("error creating contact: %v", err)
+		}
+		contacts[tenant] = contact
+	}
+	if contacts[inAcme].BookId != acme.BookId {
+		t.Errorf("expected the contact to go to the organization's book, got book %d", contacts[inAcme].BookId)
+	}
+
+	for tenant, own := range contacts {
+		if list, total, _ := tenant.ListContacts(userId, 0, 10, 0); total != 1 || list[0].Id != own.Id {
+			t.Errorf("expected tenant %d to list only its own contact, got %+v", tenant.orgId, list)
+		}
+		if books, _ := tenant.ListAddressBooks(userId); len(books) != 1 || books[0].Id != own.BookId || !books[0].Default {
+			t.Errorf("expected tenant %d to list only its own book, got %+v", tenant.orgId, books)
+		}
+
+		for other, theirs := range contacts {
+			if other == tenant {
+				continue
+			}
+
+			// The user is a member of every book, so only the tenant keeps them apart.
+			if contact, _ := tenant.GetContact(userId, theirs.Id); contact != nil {
+				t.Errorf("expected tenant %d not to read contact %d of tenant %d", tenant.orgId, theirs.Id, other.orgId)
+			}
+			if list, _, _ := tenant.ListContacts(userId, theirs.BookId, 10, 0); len(list) != 0 {
+				t.Errorf("expected tenant %d not to list book %d of tenant %d", tenant.orgId, theirs.BookId, other.orgId)
+			}
+			if book, _ := tenant.GetAddressBook(userId, theirs.BookId); book != nil {
+				t.Errorf("expected tenant %d not to read book %d of tenant %d", tenant.orgId, theirs.BookId, other.orgId)
+			}
+			if members, _ := tenant.ListBookMembers(theirs.BookId); len(members) != 0 {
+				t.Errorf("expected tenant %d not to list the members of book %d", tenant.orgId, theirs.BookId)
+			}
+
+			// Nor can it write through IDs belonging to another tenant.
+			if err := tenant.CreateContact(&models.Contact{BookId: theirs.BookId, UserId: userId, FirstName: "Eve"}); !errors.Is(err, ErrAddressBookReadOnly) {
+				t.Errorf("expected tenant %d not to add contacts to book %d, got %v", tenant.orgId, theirs.BookId, err)
+			}
+			moved, _ := tenant.GetContact(userId, contacts[tenant].Id)
+			moved.BookId = theirs.BookId
+			if err := tenant.UpdateContact(userId, moved); !errors.Is(err, ErrAddressBookReadOnly) {
+				t.Errorf("expected tenant %d not to move contacts to book %d, got %v", tenant.orgId, theirs.BookId, err)
+			}
+			stolen := *theirs
+			stolen.Permission = models.BookOwner
+			if err := tenant.DeleteContact(userId, &stolen); !errors.Is(err, ErrContactVersionMismatch) {
+				t.Errorf("expected tenant %d not to delete contact %d, got %v", tenant.orgId, theirs.Id, err)
+			}
+			if err := tenant.CreateBookInvitation(&models.AddressBookInvitation{BookId: theirs.BookId, Email: "x@example.com", Permission: models.BookViewer, ExpiresAt: now.Add(time.Hour), CreatedAt: now}); !errors.Is(err, ErrAddressBookNotFound) {
+				t.Errorf("expected tenant %d not to invite to book %d, got %v", tenant.orgId, theirs.BookId, err)
+			}
+		}
+	}
+
+	if contact, _ := inGlobex.GetContact(userId, contacts[inGlobex].Id); contact == nil || contact.FirstName != "Grace" {
+		t.Errorf("expected the contacts of every tenant to be left untouched, got %+v", contact)
+	}
+}
+
+func TestOrganizationMembers(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	now := time.Now().UTC()
+	adminId, _ := CreateUser(db, "org_admin", "admin@example.com", "hash")
+	memberId, _ := CreateUser(db, "org_member", "member@example.com", "hash")
+
+	org := &models.Organization{Name: "Acme", CreatedAt: now}
+	if err := CreateOrganization(db, adminId, org, nil); err != nil {
+		t.Fatalf("error creating organization: %v", err)
+	}
+	org.DefaultPermission = models.BookViewer
+	if err := UpdateOrganization(db, org); err != nil {
+		t.Fatalf("error updating organization: %v", err)
+	}
+
+	if added, err := AddOrgMember(db, org, memberId, models.OrgMember, now); err != nil || !added {
+		t.Fatalf("expected the member to be added, got %v", err)
+	}
+	if added, _ := AddOrgMember(db, org, memberId, models.OrgAdmin, now); added {
+		t.Errorf("expected a member not to be added twice")
+	}
+
+	tenant := ForTenant(db, org.Id)
+	book, _ := tenant.GetAddressBook(memberId, org.BookId)
+	if book == nil || book.Permission != models.BookViewer {
+		t.Fatalf("expected the member to get the default permission on the organization's book, got %+v", book)
+	}
+	if orgs, _ := ListUserOrganizations(db, memberId); len(orgs) != 1 || orgs[0].IsAdmin() || !orgs[0].CanCreateBooks() {
+		t.Errorf("expected the member to see the organization, got %+v", orgs)
+	}
+
+	// A member's own book goes when they leave, but the organization's stays.
+	own := &models.AddressBook{Name: "Leads", CreatedAt: now}
+	tenant.CreateAddressBook(memberId, own)
+	if deleted, _ := tenant.DeleteAddressBook(org.BookId); deleted {
+		t.Errorf("expected the organization's book not to be deleted")
+	}
+
+	if _, err := RemoveOrgMember(db, org.Id, adminId); !errors.Is(err, ErrLastOrgAdmin) {
+		t.Errorf("expected ErrLastOrgAdmin, got %v", err)
+	}
+	if err := DeleteUser(db, adminId, nil); !errors.Is(err, ErrLastOrgAdmin) {
+		t.Errorf("expected ErrLastOrgAdmin, got %v", err)
+	}
+
+	if removed, err := RemoveOrgMember(db, org.Id, memberId); err != nil || !removed {
+		t.Fatalf("expected the member to be removed, got %v", err)
+	}
+	if book, _ := tenant.GetAddressBook(memberId, org.BookId); book != nil {
+		t.Errorf("expected the member to lose access to the organization's book")
+	}
+	if book, _ := tenant.GetAddressBook(adminId, own.Id); book != nil {
+		t.Errorf("expected the book only the member used to be deleted")
+	}
+	if book, _ := tenant.GetAddressBook(adminId, org.BookId); book == nil || book.MemberCount != 1 {
+		t.Errorf("expected the organization's book to stay, got %+v", book)
+	}
+}
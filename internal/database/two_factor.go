@@ -0,0 +1,164 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// ErrTOTPNotPending is returned by EnableTOTP when the enrollment with the secret was completed, restarted
+// or cancelled in the meantime.
+var ErrTOTPNotPending = errors.New("totp enrollment is not pending")
+
+// SetTOTPSecret stores the secret of a pending two-factor enrollment.
+// It has no effect once two-factor authentication is enabled.
+func SetTOTPSecret(db *sql.DB, userId int64, secret string) error {
+	if _, err := db.Exec(setTOTPSecretQuery, secret, userId); err != nil {
+		return fmt.Errorf("failed to store totp secret: %w", err)
+	}
+
+	return nil
+}
+
+// EnableTOTP completes the two-factor enrollment and stores the hashes of the user's recovery codes.
+// The counter of the code used to confirm the enrollment is recorded so it can't be replayed.
+// It returns ErrTOTPNotPending, without storing the recovery codes, unless the enrollment with secret is pending.
+func EnableTOTP(db *sql.DB, userId int64, secret string, counter int64, recoveryCodeHashes []string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(enableTOTPQuery, now, counter, userId, secret)
+	if err != nil {
+		return fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		return ErrTOTPNotPending
+	}
+
+	if err := replaceRecoveryCodes(tx, userId, recoveryCodeHashes, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DisableTOTP turns two-factor authentication off and removes the user's recovery codes,
+// recording the audit event, if any, in the same transaction.
+func DisableTOTP(db *sql.DB, userId int64, audit *models.AuditEvent) error {
+	defer lockAuditChain(audit)()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(disableTOTPQuery, userId); err != nil {
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+
+	if _, err := tx.Exec(deleteRecoveryCodesQuery, userId); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := appendAuditEvent(tx, audit); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPCounter records the time step of an accepted code.
+// It returns false if a code from the same or a later time step was already used.
+func UseTOTPCounter(db *sql.DB, userId, counter int64) (bool, error) {
+	result, err := db.Exec(useTOTPCounterQuery, counter, userId, counter)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp counter: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes and stores new ones.
+func ReplaceRecoveryCodes(db *sql.DB, userId int64, codeHashes []string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userId, codeHashes, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userId int64, codeHashes []string, now time.Time) error {
+	if _, err := tx.Exec(deleteRecoveryCodesQuery, userId); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(insertRecoveryCodeQuery, userId, hash, now); err != nil {
+			return fmt.Errorf("failed to insert recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode redeems one of the user's recovery codes.
+// It returns false if the code doesn't exist or was already used.
+func UseRecoveryCode(db *sql.DB, userId int64, codeHash string, now time.Time) (bool, error) {
+	result, err := db.Exec(useRecoveryCodeQuery, now, userId, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left.
+func CountRecoveryCodes(db *sql.DB, userId int64) (int, error) {
+	var count int
+
+	if err := db.QueryRow(countRecoveryCodesQuery, userId).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
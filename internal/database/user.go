@@ -3,13 +3,35 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/joangavelan/contacts-app/internal/models"
 )
 
-// CreateUser inserts a new user into the database and returns the ID of the newly inserted user.
+// CreateUser inserts a new user into the database along with their personal address book,
+// and returns the ID of the newly inserted user.
 func CreateUser(db *sql.DB, username, email, hashedPassword string) (int64, error) {
-	result, err := db.Exec(insertUserQuery, username, email, hashedPassword)
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	id, err := insertUser(tx, username, email, hashedPassword, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+// insertUser inserts a new user and their personal address book, and returns the ID of the user.
+func insertUser(q queryer, username, email, hashedPassword string, now time.Time) (int64, error) {
+	result, err := q.Exec(insertUserQuery, username, email, hashedPassword)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert user: %w", err)
 	}
@@ -19,15 +41,37 @@ func CreateUser(db *sql.DB, username, email, hashedPassword string) (int64, erro
 		return 0, fmt.Errorf("failed to get last insert id: %w", err)
 	}
 
+	if err := createPersonalBook(q, id, now); err != nil {
+		return 0, err
+	}
+
 	return id, nil
 }
 
 // GetUserByEmail retrieves a user from the database by their email address.
 func GetUserByEmail(db *sql.DB, email string) (*models.User, error) {
-	row := db.QueryRow(getUserQuery, email)
+	return scanUser(db.QueryRow(getUserQuery, email))
+}
 
-	var user models.User
-	err := row.Scan(&user.Id, &user.Username, &user.Email, &user.Password)
+// GetUserById retrieves a user from the database by their ID.
+func GetUserById(db *sql.DB, id int64) (*models.User, error) {
+	return scanUser(db.QueryRow(getUserByIdQuery, id))
+}
+
+// RehashUserPassword replaces the user's password hash with an upgraded hash of the same password.
+// Sessions stay valid, and nothing changes if the password was changed since oldHash was read.
+func RehashUserPassword(db *sql.DB, id int64, oldHash, newHash string) error {
+	if _, err := db.Exec(rehashUserPasswordQuery, newHash, id, oldHash); err != nil {
+		return fmt.Errorf("failed to update password hash: %w", err)
+	}
+
+	return nil
+}
+
+// scanUser reads a single user row selected with userColumns.
+// It returns nil without an error when no user was found.
+func scanUser(row *sql.Row) (*models.User, error) {
+	user, err := readUser(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No user found
@@ -35,6 +79,32 @@ func GetUserByEmail(db *sql.DB, email string) (*models.User, error) {
 		return nil, fmt.Errorf("failed to query user: %w", err)
 	}
 
+	return user, nil
+}
+
+// readUser reads the columns of userColumns from row.
+func readUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var sessionsValidAfter, verifiedAt, verificationSentAt, totpEnabledAt, disabledAt sql.NullTime
+	var totpSecret sql.NullString
+	var totpLastCounter sql.NullInt64
+
+	err := row.Scan(
+		&user.Id, &user.Username, &user.Email, &user.Password, &sessionsValidAfter, &verifiedAt, &verificationSentAt,
+		&totpSecret, &totpEnabledAt, &totpLastCounter, &user.Role, &disabledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user.SessionsValidAfter = sessionsValidAfter.Time
+	user.VerifiedAt = nullTimePtr(verifiedAt)
+	user.VerificationSentAt = nullTimePtr(verificationSentAt)
+	user.TOTPSecret = totpSecret.String
+	user.TOTPEnabledAt = nullTimePtr(totpEnabledAt)
+	user.TOTPLastCounter = totpLastCounter.Int64
+	user.DisabledAt = nullTimePtr(disabledAt)
+
 	return &user, nil
 }
 
@@ -49,3 +119,214 @@ func EmailExists(db *sql.DB, email string) (bool, error) {
 
 	return exists, nil
 }
+
+// MarkEmailVerified records that the user verified the given email address.
+// It returns false when the address no longer belongs to the user or was already verified.
+func MarkEmailVerified(db *sql.DB, userId int64, email string, verifiedAt time.Time) (bool, error) {
+	result, err := db.Exec(markEmailVerifiedQuery, verifiedAt, userId, email)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark email as verified: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// UpdateVerificationSentAt records when the last verification email was sent to the user.
+func UpdateVerificationSentAt(db *sql.DB, userId int64, sentAt time.Time) error {
+	if _, err := db.Exec(updateVerificationSentAtQuery, sentAt, userId); err != nil {
+		return fmt.Errorf("failed to update verification sent time: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimVerificationSend records sentAt as the time of the last verification email unless one was sent after cutoff.
+// It returns false when another email was sent since, so concurrent requests can't both send one.
+func ClaimVerificationSend(db *sql.DB, userId int64, sentAt, cutoff time.Time) (bool, error) {
+	result, err := db.Exec(claimVerificationSendQuery, sentAt, userId, cutoff)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim verification email: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// userDataTables hold rows owned by a user through their userId column, removed along with the user.
+var userDataTables = []string{
+	"password_resets",
+	"magic_links",
+	"recovery_codes",
+	"api_tokens",
+	"user_identities",
+	"idempotency_keys",
+	"sessions",
+	"login_events",
+	"webhooks",
+	"address_book_members",
+	"organization_members",
+}
+
+// userAuthoredTables record who created each row in their userId column, but the rows belong to an address book
+// and outlive their author. They are removed along with the books the user was the only member of.
+var userAuthoredTables = []string{
+	"contacts",
+}
+
+// UpdateUsername changes the user's username.
+func UpdateUsername(db *sql.DB, userId int64, username string) error {
+	if _, err := db.Exec(updateUsernameQuery, username, userId); err != nil {
+		return fmt.Errorf("failed to update username: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateUserEmail changes the user's email address, which must then be verified again.
+// Password reset and magic links already sent to the old address are invalidated, and the audit event,
+// if any, is recorded in the same transaction.
+func UpdateUserEmail(db *sql.DB, userId int64, email string, now time.Time, audit *models.AuditEvent) error {
+	defer lockAuditChain(audit)()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(updateUserEmailQuery, email, userId); err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+
+	if _, err := tx.Exec(useUserPasswordResetsQuery, now, userId); err != nil {
+		return fmt.Errorf("failed to invalidate password resets: %w", err)
+	}
+
+	if _, err := tx.Exec(useUserMagicLinksQuery, now, userId); err != nil {
+		return fmt.Errorf("failed to invalidate magic links: %w", err)
+	}
+
+	if err := appendAuditEvent(tx, audit); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateUserPassword stores the user's new password hash and invalidates their sessions
+// and outstanding reset tokens, all in a single transaction along with the audit event, if any.
+func UpdateUserPassword(db *sql.DB, userId int64, hashedPassword string, now time.Time, audit *models.AuditEvent) error {
+	defer lockAuditChain(audit)()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(updateUserPasswordQuery, hashedPassword, now, userId); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if _, err := tx.Exec(useUserPasswordResetsQuery, now, userId); err != nil {
+		return fmt.Errorf("failed to invalidate password resets: %w", err)
+	}
+
+	if err := appendAuditEvent(tx, audit); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUser deletes the user along with everything they own, such as the address books nobody else is a member of
+// and their contacts, in a single transaction. It returns ErrSoleBookOwner if the user is the only owner
+// of an address book shared with others, who would be left unable to manage it, and ErrLastOrgAdmin if they are
+// the only admin of an organization with other members. The audit event, if any, is recorded in the same
+// transaction, and outlives the user like the rest of the audit trail.
+func DeleteUser(db *sql.DB, userId int64, audit *models.AuditEvent) error {
+	defer lockAuditChain(audit)()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var soleAdminOrgs int
+	if err := tx.QueryRow(countSoleAdminOrgsQuery, userId).Scan(&soleAdminOrgs); err != nil {
+		return fmt.Errorf("failed to count organizations: %w", err)
+	}
+	if soleAdminOrgs > 0 {
+		return ErrLastOrgAdmin
+	}
+
+	var soleOwnedBooks int
+	if err := tx.QueryRow(countSoleOwnedSharedBooksQuery, userId).Scan(&soleOwnedBooks); err != nil {
+		return fmt.Errorf("failed to count address books: %w", err)
+	}
+	if soleOwnedBooks > 0 {
+		return ErrSoleBookOwner
+	}
+
+	// Books are found through the memberships, which go with the rest of the user's data below.
+	for _, query := range []string{deleteSoleMemberBookContactsQuery, deleteSoleMemberBookInvitationsQuery, deleteSoleMemberBooksQuery} {
+		if _, err := tx.Exec(query, userId, userId); err != nil {
+			return fmt.Errorf("failed to delete address books: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(deleteUserWebhookDeliveriesQuery, userId); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+
+	for _, table := range userDataTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE userId = ?", userId); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", table, err)
+		}
+	}
+
+	if _, err := tx.Exec(deleteUserQuery, userId); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	if err := appendAuditEvent(tx, audit); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// nullTimePtr converts a nullable time column into a pointer that is nil when the column is NULL.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// nullInt64 converts an optional ID into a value stored as NULL when it is zero.
+func nullInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v, Valid: v != 0}
+}
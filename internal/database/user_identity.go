@@ -0,0 +1,99 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// GetUserIdentity retrieves the link to a provider account.
+// It returns nil without an error when the account isn't linked to any user.
+func GetUserIdentity(db *sql.DB, provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+
+	err := db.QueryRow(getUserIdentityQuery, provider, subject).Scan(
+		&identity.Id, &identity.UserId, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user identity: %w", err)
+	}
+
+	return &identity, nil
+}
+
+// LinkUserIdentity links a provider account to an existing user.
+// With a non-empty reclaimPasswordHash, the user's password is also replaced, their sessions are ended
+// and their email is marked as verified, all in a single transaction.
+func LinkUserIdentity(db *sql.DB, identity *models.UserIdentity, reclaimPasswordHash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if reclaimPasswordHash != "" {
+		if _, err := tx.Exec(updateUserPasswordQuery, reclaimPasswordHash, identity.CreatedAt, identity.UserId); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		if _, err := tx.Exec(markEmailVerifiedQuery, identity.CreatedAt, identity.UserId, identity.Email); err != nil {
+			return fmt.Errorf("failed to mark email as verified: %w", err)
+		}
+	}
+
+	if err := insertUserIdentity(tx, identity); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CreateUserWithIdentity creates a user with a verified email for a provider account, links them,
+// and returns the ID of the new user.
+func CreateUserWithIdentity(db *sql.DB, username, hashedPassword string, identity *models.UserIdentity) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	identity.UserId, err = insertUser(tx, username, identity.Email, hashedPassword, identity.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(markEmailVerifiedQuery, identity.CreatedAt, identity.UserId, identity.Email); err != nil {
+		return 0, fmt.Errorf("failed to mark email as verified: %w", err)
+	}
+
+	if err := insertUserIdentity(tx, identity); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return identity.UserId, nil
+}
+
+func insertUserIdentity(tx *sql.Tx, identity *models.UserIdentity) error {
+	result, err := tx.Exec(insertUserIdentityQuery, identity.UserId, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert user identity: %w", err)
+	}
+
+	identity.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return nil
+}
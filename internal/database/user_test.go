@@ -3,9 +3,12 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/joangavelan/contacts-app/internal/models"
 )
 
 func TestCreateUser(t *testing.T) {
@@ -19,7 +22,11 @@ func TestCreateUser(t *testing.T) {
 	email := "testuser@example.com"
 	hashedPassword := "hashedpassword"
 
+	mock.ExpectBegin()
 	mock.ExpectExec(insertUserQuery).WithArgs(username, email, hashedPassword).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertPersonalBookQuery).WithArgs(int64(1), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectExec(insertBookMemberQuery).WithArgs(int64(5), int64(1), models.BookOwner, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	id, err := CreateUser(db, username, email, hashedPassword)
 	if err != nil {
@@ -46,9 +53,11 @@ func TestCreateUser_Error(t *testing.T) {
 	email := "testuser@example.com"
 	hashedPassword := "hashedpassword"
 
+	mock.ExpectBegin()
 	mock.ExpectExec(insertUserQuery).
 		WithArgs(username, email, hashedPassword).
 		WillReturnError(fmt.Errorf("insert failed"))
+	mock.ExpectRollback()
 
 	_, err = CreateUser(db, username, email, hashedPassword)
 	if err == nil {
@@ -69,8 +78,8 @@ func TestGetUserByEmail(t *testing.T) {
 
 	email := "testuser@example.com"
 
-	rows := sqlmock.NewRows([]string{"id", "username", "email", "password"}).
-		AddRow(1, "testuser", email, "hashedpassword")
+	rows := sqlmock.NewRows([]string{"id", "username", "email", "password", "sessionsValidAfter", "verifiedAt", "verificationSentAt", "totpSecret", "totpEnabledAt", "totpLastCounter", "role", "disabledAt"}).
+		AddRow(1, "testuser", email, "hashedpassword", nil, nil, nil, nil, nil, nil, "user", nil)
 
 	mock.ExpectQuery(getUserQuery).
 		WithArgs(email).
@@ -172,3 +181,119 @@ func TestEmailExists(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
+
+func TestDeleteUser(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	// Every connection to :memory: is a separate database.
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	// Rows owned by a user that are left behind when they're deleted would be inherited by whoever gets their id.
+	rows, err := db.Query(`SELECT m.name FROM sqlite_master m, pragma_table_info(m.name) c WHERE m.type = 'table' AND c.name = 'userId'`)
+	if err != nil {
+		t.Fatalf("error listing tables: %v", err)
+	}
+	for rows.Next() {
+		var table string
+		rows.Scan(&table)
+		if !slices.Contains(userDataTables, table) && !slices.Contains(userAuthoredTables, table) {
+			t.Errorf("expected table %s to be in userDataTables", table)
+		}
+	}
+	rows.Close()
+
+	userId, _ := CreateUser(db, "leaving", "leaving@example.com", "hash")
+	keptId, _ := CreateUser(db, "staying", "staying@example.com", "hash")
+	ForTenant(db, 0).CreateContact(&models.Contact{UserId: userId, FirstName: "Ada", LastName: "Lovelace"})
+	ForTenant(db, 0).CreateContact(&models.Contact{UserId: keptId, FirstName: "Alan", LastName: "Turing"})
+
+	// A book shared with someone else outlives the user, along with the contacts they added to it.
+	shared := &models.AddressBook{Name: "Suppliers", CreatedAt: time.Now().UTC()}
+	ForTenant(db, 0).CreateAddressBook(userId, shared)
+	db.Exec("INSERT INTO address_book_members (bookId, userId, permission, createdAt) VALUES (?, ?, 'editor', ?)", shared.Id, keptId, shared.CreatedAt)
+	ForTenant(db, 0).CreateContact(&models.Contact{BookId: shared.Id, UserId: userId, FirstName: "Grace", LastName: "Hopper"})
+
+	if err := DeleteUser(db, userId, nil); err != ErrSoleBookOwner {
+		t.Fatalf("expected ErrSoleBookOwner, got %v", err)
+	}
+	ForTenant(db, 0).UpdateBookMember(shared.Id, keptId, models.BookOwner)
+
+	if err := DeleteUser(db, userId, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if user, _ := GetUserById(db, userId); user != nil {
+		t.Errorf("expected the user to be deleted")
+	}
+	var left int
+	db.QueryRow("SELECT COUNT(*) FROM contacts WHERE userId = ? AND bookId != ?", userId, shared.Id).Scan(&left)
+	if left != 0 {
+		t.Errorf("expected the user's personal contacts to be deleted, got %d", left)
+	}
+	if _, total, _ := ForTenant(db, 0).ListContacts(keptId, 0, 10, 0); total != 2 {
+		t.Errorf("expected other users' contacts and the shared book to be kept, got %d", total)
+	}
+}
+
+func TestMigrate_VerifiesExistingUsers(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// A user from before email verification was required.
+	db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT NOT NULL, password TEXT NOT NULL, email TEXT NOT NULL)`)
+	db.Exec(`INSERT INTO users (username, email, password) VALUES ('old_timer', 'old@example.com', 'hash')`)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	newId, _ := CreateUser(db, "newcomer", "new@example.com", "hash")
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database again: %v", err)
+	}
+
+	if user, _ := GetUserById(db, 1); user == nil || !user.IsVerified() {
+		t.Errorf("expected the existing user to be verified, got %+v", user)
+	}
+	if user, _ := GetUserById(db, newId); user == nil || user.IsVerified() {
+		t.Errorf("expected users registered after the migration to stay unverified, got %+v", user)
+	}
+}
+
+func TestClaimVerificationSend(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+	userId, _ := CreateUser(db, "testuser", "testuser@example.com", "hash")
+
+	now := time.Now().UTC()
+	cooldown := time.Minute
+
+	if claimed, err := ClaimVerificationSend(db, userId, now, now.Add(-cooldown)); err != nil || !claimed {
+		t.Fatalf("expected the first send to be claimed, got %v (%v)", claimed, err)
+	}
+	if claimed, _ := ClaimVerificationSend(db, userId, now.Add(time.Second), now.Add(time.Second-cooldown)); claimed {
+		t.Errorf("expected a second send within the cooldown to be refused")
+	}
+	if claimed, _ := ClaimVerificationSend(db, userId, now.Add(2*cooldown), now.Add(cooldown)); !claimed {
+		t.Errorf("expected a send after the cooldown to be claimed")
+	}
+}
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// CreateWebhook stores a new webhook and sets its ID.
+func CreateWebhook(db *sql.DB, webhook *models.Webhook) error {
+	result, err := db.Exec(
+		insertWebhookQuery,
+		webhook.UserId, webhook.URL, webhook.Secret, strings.Join(webhook.Events, " "), webhook.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook: %w", err)
+	}
+
+	webhook.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return nil
+}
+
+// ListWebhooks returns the user's webhooks, newest first.
+func ListWebhooks(db *sql.DB, userId int64) ([]models.Webhook, error) {
+	rows, err := db.Query(listWebhooksQuery, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhook retrieves one of the user's webhooks.
+// It returns nil without an error when the user has no webhook with that ID.
+func GetWebhook(db *sql.DB, userId, id int64) (*models.Webhook, error) {
+	webhook, err := scanWebhook(db.QueryRow(getWebhookQuery, id, userId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhook deletes one of the user's webhooks along with its deliveries, including those still pending.
+// It returns false if the user has no webhook with that ID.
+func DeleteWebhook(db *sql.DB, userId, id int64) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteWebhookDeliveriesQuery, id, userId); err != nil {
+		return false, fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+
+	result, err := tx.Exec(deleteWebhookQuery, id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// ListWebhookDeliveries returns the limit most recent deliveries of the webhook, newest first.
+func ListWebhookDeliveries(db *sql.DB, webhookId int64, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.Query(listWebhookDeliveriesQuery, webhookId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// GetWebhookDelivery retrieves a delivery of the webhook.
+// It returns nil without an error when the webhook has no delivery with that ID.
+func GetWebhookDelivery(db *sql.DB, webhookId, id int64) (*models.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(db.QueryRow(getWebhookDeliveryQuery, id, webhookId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// RedeliverWebhookDelivery queues the payload of a past delivery again, as a new delivery that is due at now.
+// The original delivery is kept in the log as it was.
+func RedeliverWebhookDelivery(db *sql.DB, delivery *models.WebhookDelivery, now time.Time) (*models.WebhookDelivery, error) {
+	result, err := db.Exec(insertWebhookDeliveryQuery, delivery.WebhookId, delivery.Event, delivery.Payload, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return &models.WebhookDelivery{
+		Id:            id,
+		WebhookId:     delivery.WebhookId,
+		Event:         delivery.Event,
+		Payload:       delivery.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries whose next attempt is due at now,
+// longest due first, along with their webhook.
+func ListDueWebhookDeliveries(db *sql.DB, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.Query(listDueWebhookDeliveriesQuery, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var webhook models.Webhook
+		var events string
+		var nextAttemptAt, lastAttemptAt sql.NullTime
+
+		err := rows.Scan(
+			&delivery.Id, &delivery.WebhookId, &delivery.Event, &delivery.Payload, &delivery.Status, &delivery.Attempts,
+			&nextAttemptAt, &lastAttemptAt, &delivery.ResponseStatus, &delivery.Error, &delivery.CreatedAt,
+			&webhook.Id, &webhook.UserId, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+
+		delivery.NextAttemptAt = nullTimePtr(nextAttemptAt)
+		delivery.LastAttemptAt = nullTimePtr(lastAttemptAt)
+		webhook.Events = strings.Fields(events)
+		delivery.Webhook = &webhook
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery saves the outcome of an attempt to send the delivery.
+func UpdateWebhookDelivery(db *sql.DB, delivery *models.WebhookDelivery) error {
+	_, err := db.Exec(
+		updateWebhookDeliveryQuery,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastAttemptAt,
+		delivery.ResponseStatus, delivery.Error, delivery.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// PruneWebhookDeliveries removes deliveries created before the given time from the log, unless they are still pending.
+func PruneWebhookDeliveries(db *sql.DB, before time.Time) error {
+	if _, err := db.Exec(pruneWebhookDeliveriesQuery, before); err != nil {
+		return fmt.Errorf("failed to prune webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// enqueueWebhookEvent queues a contact event for the webhooks subscribed to it of every member of the contact's
+// address book. It is run within the transaction that changed the contact, so no event is lost or sent for
+// a change that was rolled back.
+func (t *Tenant) enqueueWebhookEvent(q queryer, event string, contact *models.Contact) error {
+	now := time.Now().UTC()
+
+	payload, err := json.Marshal(models.WebhookPayload{Event: event, OccurredAt: now, OrgId: t.orgId, Contact: contact})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	if _, err := q.Exec(enqueueWebhookDeliveriesQuery, event, string(payload), now, now, contact.BookId, event); err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var events string
+
+	err := row.Scan(&webhook.Id, &webhook.UserId, &webhook.URL, &webhook.Secret, &events, &webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Events = strings.Fields(events)
+	return &webhook, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var nextAttemptAt, lastAttemptAt sql.NullTime
+
+	err := row.Scan(
+		&delivery.Id, &delivery.WebhookId, &delivery.Event, &delivery.Payload, &delivery.Status, &delivery.Attempts,
+		&nextAttemptAt, &lastAttemptAt, &delivery.ResponseStatus, &delivery.Error, &delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.NextAttemptAt = nullTimePtr(nextAttemptAt)
+	delivery.LastAttemptAt = nullTimePtr(lastAttemptAt)
+	return &delivery, nil
+}
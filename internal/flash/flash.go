@@ -0,0 +1,185 @@
+// Package flash carries toasts across a redirect in a signed cookie, for requests that aren't made by htmx
+// and so can't show the toasts of the HX-Trigger header.
+//
+// The toasts are shown by the next full page the browser renders, which base.html does through the flashes
+// template function, and the cookie is cleared along with it. Redirects don't clear it, so flashes survive
+// a chain of them.
+package flash
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/signing"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+const CookieName = "flash"
+
+// signingPurpose keeps flash cookies from being accepted as other signed values, and the other way around.
+const signingPurpose = "flash"
+
+type contextKey struct{}
+
+// state is the flashes of a request: the ones added for the next page and the ones shown by this one.
+type state struct {
+	added []toast.Toast
+	shown []toast.Toast
+}
+
+// Middleware loads the flashes of page navigations, to be shown by the page and cleared once it's rendered.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := &state{}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, s))
+
+		if !isPageNavigation(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(CookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		toasts, ok := decode(cookie.Value)
+		if ok {
+			s.shown = toasts
+		}
+		next.ServeHTTP(&clearingWriter{ResponseWriter: w, state: s}, r)
+	})
+}
+
+// Add flashes the toasts, to be shown by the next page the browser renders.
+func Add(w http.ResponseWriter, r *http.Request, toasts ...toast.Toast) {
+	if len(toasts) == 0 {
+		return
+	}
+
+	s, ok := r.Context().Value(contextKey{}).(*state)
+	if !ok {
+		s = &state{}
+	}
+	s.added = append(s.added, toasts...)
+
+	value, err := encode(s.added)
+	if err != nil {
+		log.Printf("Error encoding flash: %v", err)
+		return
+	}
+
+	// Replace the cookie set by a previous call rather than send both.
+	var cookies []string
+	for _, c := range w.Header().Values("Set-Cookie") {
+		if !strings.HasPrefix(c, CookieName+"=") {
+			cookies = append(cookies, c)
+		}
+	}
+	w.Header()["Set-Cookie"] = cookies
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Show shows the toasts on the page rendered by this response.
+func Show(r *http.Request, toasts ...toast.Toast) {
+	if s, ok := r.Context().Value(contextKey{}).(*state); ok {
+		s.shown = append(s.shown, toasts...)
+	}
+}
+
+// TemplateFuncs exposes the toasts the page shows to templates as flashes.
+func TemplateFuncs(r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"flashes": func() []toast.Toast {
+			if s, ok := r.Context().Value(contextKey{}).(*state); ok {
+				return s.shown
+			}
+			return nil
+		},
+	}
+}
+
+// clearingWriter clears the flash cookie once a page is rendered, unless the page flashed new toasts itself.
+type clearingWriter struct {
+	http.ResponseWriter
+	state       *state
+	wroteHeader bool
+}
+
+func (cw *clearingWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if (status < 300 || status >= 400) && len(cw.state.added) == 0 {
+			http.SetCookie(cw.ResponseWriter, &http.Cookie{
+				Name:     CookieName,
+				Path:     "/",
+				MaxAge:   -1,
+				HttpOnly: true,
+				Secure:   true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *clearingWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *clearingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// isPageNavigation reports whether the browser is loading a page, rather than htmx, an event stream or an asset.
+func isPageNavigation(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		r.Header.Get("HX-Request") != "true" &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func encode(toasts []toast.Toast) (string, error) {
+	data, err := json.Marshal(toasts)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signing.Sign(signingPurpose, payload), nil
+}
+
+// decode returns the toasts of a flash cookie and whether its signature is valid.
+func decode(value string) ([]toast.Toast, bool) {
+	payload, signature, ok := strings.Cut(value, ".")
+	if !ok || !signing.Verify(signingPurpose, payload, signature) {
+		return nil, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false
+	}
+
+	var toasts []toast.Toast
+	if err := json.Unmarshal(data, &toasts); err != nil {
+		return nil, false
+	}
+	return toasts, true
+}
@@ -0,0 +1,94 @@
+package flash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// flashCookie returns the flash cookie the response set, if any.
+func flashCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == CookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// navigate loads a page with the flash cookie, the way a browser does after a redirect.
+func navigate(handler http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/contacts", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	r.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+var showFlashes = Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	for _, t := range TemplateFuncs(r)["flashes"].(func() []toast.Toast)() {
+		w.Write([]byte(t.Message + "\n"))
+	}
+}))
+
+func TestFlash(t *testing.T) {
+	post := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Add(w, r, toast.Success("Logged in"))
+		Add(w, r, toast.Info("Welcome back").WithTitle("Hi"))
+		http.Redirect(w, r, "/contacts", http.StatusSeeOther)
+	}))
+
+	rec := httptest.NewRecorder()
+	post.ServeHTTP(rec, httptest.NewRequest("POST", "/api/login", nil))
+	if got := len(rec.Header().Values("Set-Cookie")); got != 1 {
+		t.Fatalf("expected the flashes to be sent in one cookie, got %d", got)
+	}
+	cookie := flashCookie(rec)
+
+	t.Run("Redirects keep the flashes", func(t *testing.T) {
+		redirect := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/auth/verify-email/notice", http.StatusSeeOther)
+		}))
+		if rec := navigate(redirect, cookie); flashCookie(rec) != nil {
+			t.Errorf("expected the flash cookie to be kept across the redirect")
+		}
+	})
+
+	t.Run("Pages show and clear the flashes", func(t *testing.T) {
+		rec := navigate(showFlashes, cookie)
+		if rec.Body.String() != "Logged in\nWelcome back\n" {
+			t.Errorf("expected both flashes to be shown, got %q", rec.Body)
+		}
+		if cleared := flashCookie(rec); cleared == nil || cleared.MaxAge >= 0 {
+			t.Errorf("expected the flash cookie to be cleared, got %+v", cleared)
+		}
+	})
+
+	t.Run("Tampered cookies are ignored", func(t *testing.T) {
+		payload, signature, _ := strings.Cut(cookie.Value, ".")
+		forged, _ := encode([]toast.Toast{toast.Error("Forged")})
+		forgedPayload, _, _ := strings.Cut(forged, ".")
+
+		for _, value := range []string{forgedPayload + "." + signature, payload, payload + ".invalid"} {
+			rec := navigate(showFlashes, &http.Cookie{Name: CookieName, Value: value})
+			if rec.Body.Len() != 0 {
+				t.Errorf("expected %q to be rejected, got %q", value, rec.Body)
+			}
+		}
+	})
+
+	t.Run("Requests other than page navigations leave the flashes", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/api/events", nil)
+		r.Header.Set("Accept", "text/event-stream")
+		r.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		showFlashes.ServeHTTP(rec, r)
+		if rec.Body.Len() != 0 || flashCookie(rec) != nil {
+			t.Errorf("expected the event stream not to consume the flashes")
+		}
+	})
+}
@@ -0,0 +1,191 @@
+// Package htmx tells requests made by htmx apart from plain browser requests, so that every form works without
+// JavaScript: htmx requests are answered with HX-* headers, and plain ones with real redirects and flashes.
+package htmx
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/flash"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// IsRequest reports whether the request was made by htmx.
+func IsRequest(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// Redirect sends the browser to url, with the HX-Redirect header for htmx requests and a 303 otherwise.
+// htmx navigates away as soon as it gets the header, so the toasts of the response are flashed to url instead.
+func Redirect(w http.ResponseWriter, r *http.Request, url string) {
+	if !IsRequest(r) {
+		http.Redirect(w, r, url, http.StatusSeeOther)
+		return
+	}
+	flash.Add(w, r, takeToasts(w)...)
+	w.Header().Set("HX-Redirect", url)
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+// Refresh reloads the page the request was made from, with the HX-Refresh header for htmx requests
+// and a 303 back to it otherwise.
+func Refresh(w http.ResponseWriter, r *http.Request) {
+	if !IsRequest(r) {
+		http.Redirect(w, r, BackURL(r), http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// Back answers a plain request with a 303 back to the page it was made from, and reports whether it did.
+// Handlers that answer htmx with a piece of the page call it first, so browsers without JavaScript load the
+// whole page again instead of the piece.
+func Back(w http.ResponseWriter, r *http.Request) bool {
+	if IsRequest(r) {
+		return false
+	}
+	http.Redirect(w, r, BackURL(r), http.StatusSeeOther)
+	return true
+}
+
+// BackURL returns the path of the page the request was made from, or the home page if it came from another site.
+// Paths browsers would take for another site, such as //evil.example, lead home too.
+func BackURL(r *http.Request) string {
+	referer, err := url.Parse(r.Referer())
+	if err != nil || referer.Path == "" || (referer.Host != "" && referer.Host != r.Host) {
+		return "/"
+	}
+
+	path := referer.Path
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return referer.RequestURI()
+}
+
+// Layout renders a fragment a handler answered a plain form post with as a full page.
+type Layout func(w http.ResponseWriter, r *http.Request, status int, fragment template.HTML)
+
+// Fallback answers plain form posts, the ones browsers make without JavaScript, in a way browsers understand
+// without htmx. The toasts of the response are flashed across its redirect, HTML fragments are rendered as a
+// full page with the layout, full pages are sent as they are, and other responses redirect back to the form
+// with their toasts, or the error they describe.
+func Fallback(layout Layout, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsRequest(r) || !isFormPost(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := newBufferedWriter()
+		next.ServeHTTP(buf, r)
+
+		toasts := takeToasts(buf)
+		buf.header.Del("Content-Length")
+		for key, values := range buf.header {
+			w.Header()[key] = values
+		}
+
+		switch {
+		case buf.status >= 300 && buf.status < 400, isDocument(buf):
+			flash.Add(w, r, toasts...)
+			w.WriteHeader(buf.status)
+			w.Write(buf.body.Bytes())
+		case buf.body.Len() > 0 && isHTML(buf):
+			flash.Show(r, toasts...)
+			layout(w, r, buf.status, template.HTML(buf.body.String()))
+		default:
+			if len(toasts) == 0 && buf.status >= 400 {
+				message := strings.TrimSpace(buf.body.String())
+				if message == "" {
+					message = http.StatusText(buf.status)
+				}
+				toasts = append(toasts, toast.Error(message))
+			}
+			w.Header().Del("Content-Type")
+			flash.Add(w, r, toasts...)
+			http.Redirect(w, r, BackURL(r), http.StatusSeeOther)
+		}
+	})
+}
+
+// MethodField is the form field that makes a posted form reach the route of another method.
+const MethodField = "_method"
+
+// MethodOverride lets forms, which browsers can only post, delete and update resources. A posted form
+// with a MethodField of DELETE, PATCH or PUT is routed as a request of that method. It goes inside Fallback,
+// which needs to see the form post to answer it without JavaScript.
+func MethodOverride(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			switch method := strings.ToUpper(r.PostFormValue(MethodField)); method {
+			case http.MethodDelete, http.MethodPatch, http.MethodPut:
+				r.Method = method
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// takeToasts removes the HX-Trigger headers of the response, returning the toasts they carry.
+func takeToasts(w http.ResponseWriter) []toast.Toast {
+	toasts, err := toast.Collect(w).Toasts()
+	if err != nil {
+		log.Printf("Error reading toasts: %v", err)
+	}
+	for _, timing := range []toast.Timing{toast.Immediately, toast.AfterSwap, toast.AfterSettle} {
+		w.Header().Del(string(timing))
+	}
+	return toasts
+}
+
+// bufferedWriter holds a response back, so Fallback can decide how to answer once the handler is done.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedWriter() *bufferedWriter {
+	return &bufferedWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	bw.status = status
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	return bw.body.Write(b)
+}
+
+// isFormPost reports whether a browser submitted a form. Scripts posting forms to the API don't ask for HTML.
+func isFormPost(r *http.Request) bool {
+	if r.Method != http.MethodPost || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+// isHTML reports whether the response is a rendered template. Templates are executed without setting a
+// Content-Type, unlike http.Error and JSON responses, and sniffing it doesn't recognize fragments like forms.
+func isHTML(bw *bufferedWriter) bool {
+	contentType := bw.header.Get("Content-Type")
+	return contentType == "" || strings.HasPrefix(contentType, "text/html")
+}
+
+// isDocument reports whether the response is a full page rather than a fragment.
+func isDocument(bw *bufferedWriter) bool {
+	start := bytes.TrimSpace(bw.body.Bytes())
+	return len(start) >= len("<!doctype") && strings.EqualFold(string(start[:len("<!doctype")]), "<!doctype")
+}
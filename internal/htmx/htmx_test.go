@@ -0,0 +1,186 @@
+package htmx
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/joangavelan/contacts-app/internal/flash"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+)
+
+// layout renders the fragment along with the flashes it shows, standing in for the base layout.
+func layout(w http.ResponseWriter, r *http.Request, status int, fragment template.HTML) {
+	w.WriteHeader(status)
+	for _, t := range flash.TemplateFuncs(r)["flashes"].(func() []toast.Toast)() {
+		fmt.Fprintf(w, "[%s] ", t.Message)
+	}
+	fmt.Fprintf(w, "<main>%s</main>", fragment)
+}
+
+// post submits a form to the handler the way a browser without JavaScript does, from the login page.
+func post(handler http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/api/login", strings.NewReader(url.Values{"email": {"ada@example.com"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	r.Header.Set("Referer", "http://example.com/auth/login")
+	rec := httptest.NewRecorder()
+	flash.Middleware(Fallback(layout, handler)).ServeHTTP(rec, r)
+	return rec
+}
+
+func hasFlash(rec *httptest.ResponseRecorder) bool {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == flash.CookieName {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRedirect(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/login", nil)
+	rec := httptest.NewRecorder()
+	Redirect(rec, r, "/contacts")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/contacts" || rec.Header().Get("HX-Redirect") != "" {
+		t.Errorf("expected a plain redirect, got %d %v", rec.Code, rec.Header())
+	}
+
+	r.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	toast.Success("Contact deleted").WriteToHeader(rec)
+	Redirect(rec, r, "/contacts")
+	if rec.Header().Get("HX-Redirect") != "/contacts" || rec.Header().Get("Location") != "" {
+		t.Errorf("expected htmx to be told to redirect, got %v", rec.Header())
+	}
+	if rec.Header().Get("HX-Trigger") != "" || !hasFlash(rec) {
+		t.Errorf("expected the toast to be flashed to the next page, got %v", rec.Header())
+	}
+}
+
+func TestBackURL(t *testing.T) {
+	for referer, want := range map[string]string{
+		"http://example.com/books/3?tab=members": "/books/3?tab=members",
+		"http://evil.example/phishing":           "/",
+		"http://example.com//evil.example/x":     "/",
+		"http://example.com/\\evil.example/x":    "/",
+		"/\\evil.example/x":                      "/",
+		"books/3":                                "/",
+		"":                                       "/",
+	} {
+		r := httptest.NewRequest("POST", "/api/books", nil)
+		r.Header.Set("Referer", referer)
+		if got := BackURL(r); got != want {
+			t.Errorf("expected %q to go back to %q, got %q", referer, want, got)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	t.Run("Redirects flash their toasts", func(t *testing.T) {
+		rec := post(func(w http.ResponseWriter, r *http.Request) {
+			toast.Success("Welcome back").WriteToHeader(w)
+			Redirect(w, r, "/contacts")
+		})
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/contacts" || !hasFlash(rec) {
+			t.Errorf("expected a redirect with a flash, got %d %v", rec.Code, rec.Header())
+		}
+		if rec.Header().Get("HX-Trigger") != "" {
+			t.Errorf("expected the toast to be taken out of the HX-Trigger header")
+		}
+	})
+
+	t.Run("Fragments are rendered as a page", func(t *testing.T) {
+		rec := post(func(w http.ResponseWriter, r *http.Request) {
+			toast.Warning("Check the form").WriteToHeader(w)
+			w.Write([]byte(`<form action="/api/login" method="post"><span>Invalid email address</span></form>`))
+		})
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "[Check the form] <main><form") || hasFlash(rec) {
+			t.Errorf("expected the form to be rendered in the layout with its toast, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("Errors go back with their message", func(t *testing.T) {
+		rec := post(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		})
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/auth/login" || !hasFlash(rec) {
+			t.Errorf("expected to go back to the form with a flash, got %d %v", rec.Code, rec.Header())
+		}
+	})
+
+	t.Run("Scripts are left alone", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/api/v1/contacts", strings.NewReader("firstName=Ada"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", "*/*")
+		rec := httptest.NewRecorder()
+		Fallback(layout, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+		})).ServeHTTP(rec, r)
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("expected the response to pass through, got %d", rec.Code)
+		}
+	})
+
+	t.Run("htmx requests are left alone", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/api/login", nil)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		Fallback(layout, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		})).ServeHTTP(rec, r)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected the response to pass through, got %d", rec.Code)
+		}
+	})
+}
+
+func TestMethodOverride(t *testing.T) {
+	deleted := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /api/tokens/{id}", func(w http.ResponseWriter, r *http.Request) {
+		deleted++
+		toast.Success("Token revoked").WriteToHeader(w)
+		w.WriteHeader(http.StatusOK)
+	})
+	send := func(method string) *httptest.ResponseRecorder {
+		form := url.Values{MethodField: {method}}
+		r := httptest.NewRequest("POST", "/api/tokens/1", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", "text/html")
+		r.Header.Set("Referer", "http://example.com/settings/tokens")
+		rec := httptest.NewRecorder()
+		flash.Middleware(Fallback(layout, MethodOverride(mux))).ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := send("delete")
+	if deleted != 1 || rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/settings/tokens" || !hasFlash(rec) {
+		t.Errorf("expected the form to delete the token and go back with a flash, got %d %v", rec.Code, rec.Header())
+	}
+
+	send("GET")
+	if deleted != 1 {
+		t.Errorf("expected only unsafe methods to be overridden")
+	}
+}
+
+func TestBack(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/org/members/2", nil)
+	r.Header.Set("Referer", "http://example.com/orgs/1")
+	rec := httptest.NewRecorder()
+	if !Back(rec, r) || rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/orgs/1" {
+		t.Errorf("expected a plain request to go back, got %d %v", rec.Code, rec.Header())
+	}
+
+	r.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	if Back(rec, r) || rec.Code != http.StatusOK || len(rec.Header()) != 0 {
+		t.Errorf("expected htmx requests to be left to the handler, got %d %v", rec.Code, rec.Header())
+	}
+}
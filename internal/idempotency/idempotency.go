@@ -0,0 +1,156 @@
+// Package idempotency lets clients safely retry POST requests by sending an Idempotency-Key header.
+//
+// The first request with a key is handled normally and its response is stored. Retries with the same key
+// and the same body get the stored response back, marked with an Idempotent-Replayed header, without the
+// request being applied again. Reusing a key for a different request is an error.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+)
+
+const (
+	HeaderName = "Idempotency-Key"
+	// ReplayedHeaderName is set on responses replayed from an earlier request.
+	ReplayedHeaderName = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+// Middleware replays the stored response for POST requests with a known Idempotency-Key.
+// It must run after auth.Middleware, since keys are scoped to the user.
+func Middleware(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderName)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			jsonapi.WriteErrors(w, http.StatusBadRequest, headerError(http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be at most 255 characters long"))
+			return
+		}
+
+		user, ok := auth.GetUser(r.Context())
+		if !ok {
+			jsonapi.WriteError(w, http.StatusInternalServerError, "internal_error", "Could not retrieve user information")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, jsonapi.MaxBodySize))
+		if err != nil {
+			jsonapi.WriteError(w, http.StatusRequestEntityTooLarge, "request_too_large", "Request body is too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := hashRequest(r, body)
+		now := time.Now().UTC()
+
+		id, err := database.ReserveIdempotencyKey(database.DB, user.Id, key, requestHash, now, now.Add(-config.IdempotencyKeyExpiration))
+		if err != nil {
+			log.Printf("Error reserving idempotency key: %v", err)
+			jsonapi.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+			return
+		}
+
+		if id == 0 {
+			replay(w, user.Id, key, requestHash)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// Server errors may be transient, so the request can be retried with the same key.
+		if rec.status >= http.StatusInternalServerError {
+			if err := database.DeleteIdempotencyKey(database.DB, id); err != nil {
+				log.Printf("Error releasing idempotency key: %v", err)
+			}
+			return
+		}
+
+		stored := &models.IdempotencyKey{
+			Id:          id,
+			Status:      rec.status,
+			ContentType: w.Header().Get("Content-Type"),
+			ETag:        w.Header().Get("ETag"),
+			Location:    w.Header().Get("Location"),
+			Body:        rec.body.Bytes(),
+		}
+		if err := database.CompleteIdempotencyKey(database.DB, stored); err != nil {
+			log.Printf("Error storing idempotent response: %v", err)
+		}
+	}
+}
+
+// replay answers a request whose key was already used with the response to the earlier request.
+func replay(w http.ResponseWriter, userId int64, key, requestHash string) {
+	stored, err := database.GetIdempotencyKey(database.DB, userId, key)
+	if err != nil {
+		log.Printf("Error retrieving idempotency key: %v", err)
+		jsonapi.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+		return
+	}
+
+	switch {
+	case stored != nil && stored.RequestHash != requestHash:
+		jsonapi.WriteErrors(w, http.StatusUnprocessableEntity, headerError(http.StatusUnprocessableEntity, "idempotency_key_reused", "This Idempotency-Key was already used for a different request"))
+	// A missing key was released by a failed request after our reservation attempt.
+	case stored == nil || !stored.IsComplete():
+		jsonapi.WriteErrors(w, http.StatusConflict, headerError(http.StatusConflict, "idempotency_key_in_use", "A request with this Idempotency-Key is still being processed, retry shortly"))
+	default:
+		for name, value := range map[string]string{"Content-Type": stored.ContentType, "ETag": stored.ETag, "Location": stored.Location} {
+			if value != "" {
+				w.Header().Set(name, value)
+			}
+		}
+		w.Header().Set(ReplayedHeaderName, "true")
+		w.WriteHeader(stored.Status)
+		w.Write(stored.Body)
+	}
+}
+
+func headerError(status int, code, detail string) jsonapi.Error {
+	err := jsonapi.NewError(status, code, detail)
+	err.Source = &jsonapi.ErrorSource{Header: HeaderName}
+	return err
+}
+
+// hashRequest identifies the request a key was used for, so the key can't be reused for a different one.
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes the response through while keeping a copy to store.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
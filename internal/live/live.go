@@ -0,0 +1,137 @@
+// Package live pushes Server-Sent Events to the open tabs of logged-in users, such as changes to the contacts
+// they have access to and toast notifications. Subscriptions are kept in memory, so events only reach the tabs
+// connected to the same instance of the app.
+package live
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Event names the pages listen to with sse-swap.
+const (
+	// ContactEvent carries out-of-band swaps of the rows of the contact list.
+	ContactEvent = "contact"
+	// ToastEvent carries a toast to append to the toast container.
+	ToastEvent = "toast"
+)
+
+// bufferSize is how many events a subscription can fall behind by. Further events are dropped for it
+// rather than hold up the request that published them.
+const bufferSize = 16
+
+// Event is a Server-Sent Event whose data is the HTML swapped in by the elements listening to its name.
+type Event struct {
+	Name string
+	Data string
+}
+
+// Subscription receives the events published to a user's tab, within the workspace the tab was opened in.
+type Subscription struct {
+	userId int64
+	orgId  int64
+	events chan Event
+}
+
+// Events returns the channel the subscription's events are sent on.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Broker fans events out to subscriptions.
+type Broker struct {
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+}
+
+// Default is the broker of the app.
+var Default = NewBroker()
+
+func NewBroker() *Broker {
+	return &Broker{subscriptions: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a tab of the user open in the workspace of the organization with the given ID,
+// or their personal one for 0. Callers must Unsubscribe once the tab is gone.
+func (b *Broker) Subscribe(userId, orgId int64) *Subscription {
+	s := &Subscription{userId: userId, orgId: orgId, events: make(chan Event, bufferSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions[s] = struct{}{}
+
+	return s
+}
+
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscriptions, s)
+}
+
+// Listening reports whether any tab is open in the workspace of orgId, so publishers can skip
+// preparing events nobody would receive.
+func (b *Broker) Listening(orgId int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscriptions {
+		if s.orgId == orgId {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish sends the event to the tabs of the users that are open in the workspace of orgId.
+// Callers check that the users may see what the event carries.
+func (b *Broker) Publish(orgId int64, userIds []int64, event Event) {
+	recipients := make(map[int64]bool, len(userIds))
+	for _, id := range userIds {
+		recipients[id] = true
+	}
+
+	b.send(event, func(s *Subscription) bool { return s.orgId == orgId && recipients[s.userId] })
+}
+
+// Notify sends the event to every tab of the user, whatever workspace it is open in.
+func (b *Broker) Notify(userId int64, event Event) {
+	b.send(event, func(s *Subscription) bool { return s.userId == userId })
+}
+
+func (b *Broker) send(event Event, match func(*Subscription) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscriptions {
+		if !match(s) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+		}
+	}
+}
+
+// Write writes the event in the text/event-stream format. Data spanning several lines is sent as one data field
+// per line, which browsers join back together.
+func Write(w io.Writer, event Event) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "event: %s\n", event.Name)
+	for _, line := range strings.Split(strings.ReplaceAll(event.Data, "\r\n", "\n"), "\n") {
+		fmt.Fprintf(&sb, "data: %s\n", line)
+	}
+	sb.WriteString("\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteHeartbeat writes a comment, which browsers ignore, to keep an idle stream from being closed by proxies.
+func WriteHeartbeat(w io.Writer) error {
+	_, err := io.WriteString(w, ": heartbeat\n\n")
+	return err
+}
@@ -0,0 +1,73 @@
+package live
+
+import (
+	"strings"
+	"testing"
+)
+
+// received drains the events the subscription got so far.
+func received(s *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case event := <-s.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+
+	personal := b.Subscribe(1, 0)
+	org := b.Subscribe(1, 7)
+	colleague := b.Subscribe(2, 7)
+	outsider := b.Subscribe(3, 7)
+
+	if !b.Listening(7) || b.Listening(8) {
+		t.Errorf("expected tabs to be open in the organization only")
+	}
+
+	// Published events only reach the chosen users, in the workspace they were published to.
+	b.Publish(7, []int64{1, 2}, Event{Name: ContactEvent, Data: "<li></li>"})
+	if len(received(org)) != 1 || len(received(colleague)) != 1 {
+		t.Errorf("expected both members to receive the event")
+	}
+	if len(received(personal)) != 0 || len(received(outsider)) != 0 {
+		t.Errorf("expected the event not to reach other workspaces or users")
+	}
+
+	// Notifications reach every tab of the user.
+	b.Notify(1, Event{Name: ToastEvent, Data: "<span></span>"})
+	if len(received(personal)) != 1 || len(received(org)) != 1 || len(received(colleague)) != 0 {
+		t.Errorf("expected the notification to reach every tab of the user only")
+	}
+
+	// A tab that falls behind misses events rather than blocking publishers.
+	for i := 0; i < bufferSize+5; i++ {
+		b.Notify(2, Event{Name: ToastEvent})
+	}
+	if got := len(received(colleague)); got != bufferSize {
+		t.Errorf("expected %d buffered events, got %d", bufferSize, got)
+	}
+
+	b.Unsubscribe(outsider)
+	b.Publish(7, []int64{3}, Event{Name: ContactEvent})
+	if len(received(outsider)) != 0 {
+		t.Errorf("expected no events after unsubscribing")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var sb strings.Builder
+	if err := Write(&sb, Event{Name: ContactEvent, Data: "<ul>\r\n<li>Ada</li>\n</ul>"}); err != nil {
+		t.Fatalf("error writing event: %v", err)
+	}
+
+	want := "event: contact\ndata: <ul>\ndata: <li>Ada</li>\ndata: </ul>\n\n"
+	if sb.String() != want {
+		t.Errorf("expected %q, got %q", want, sb.String())
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import "time"
+
+// Permissions a member can have on an address book. Each permission includes the ones below it.
+const (
+	BookOwner  = "owner"
+	BookEditor = "editor"
+	BookViewer = "viewer"
+)
+
+// BookPermissions lists the permissions from most to least privileged.
+var BookPermissions = []string{BookOwner, BookEditor, BookViewer}
+
+var bookPermissionRanks = map[string]int{
+	BookOwner:  3,
+	BookEditor: 2,
+	BookViewer: 1,
+}
+
+var bookPermissionLabels = map[string]string{
+	BookOwner:  "Owner",
+	BookEditor: "Editor",
+	BookViewer: "Viewer",
+}
+
+// IsValidBookPermission reports whether permission is one of the known address book permissions.
+func IsValidBookPermission(permission string) bool {
+	_, ok := bookPermissionRanks[permission]
+	return ok
+}
+
+// BookPermissionIncludes reports whether permission grants everything required does.
+// Unknown permissions, such as the empty permission of non-members, grant nothing.
+func BookPermissionIncludes(permission, required string) bool {
+	rank, ok := bookPermissionRanks[permission]
+	return ok && rank >= bookPermissionRanks[required]
+}
+
+// BookPermissionLabel describes a permission for people.
+func BookPermissionLabel(permission string) string {
+	if label, ok := bookPermissionLabels[permission]; ok {
+		return label
+	}
+	return permission
+}
+
+// AddressBook holds contacts shared by its members.
+// Every user has a personal book, which can't be shared, and can create shared ones.
+// Books belong to the user's personal workspace or to an organization.
+type AddressBook struct {
+	Id       int64  `json:"id"`
+	Name     string `json:"name"`
+	Personal bool   `json:"personal"`
+	// Default is set for the book contacts created without one go to: the user's personal book,
+	// or the book of the organization, which every member of it joins. Default books can't be deleted.
+	Default bool `json:"default"`
+	// Permission is the permission of the user the book was loaded for.
+	Permission   string    `json:"permission"`
+	MemberCount  int       `json:"memberCount"`
+	ContactCount int       `json:"contactCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// CanEdit reports whether the user the book was loaded for can change its contacts.
+func (b AddressBook) CanEdit() bool {
+	return BookPermissionIncludes(b.Permission, BookEditor)
+}
+
+// IsOwner reports whether the user the book was loaded for can manage its members.
+func (b AddressBook) IsOwner() bool {
+	return BookPermissionIncludes(b.Permission, BookOwner)
+}
+
+// PermissionLabel describes the permission of the user the book was loaded for.
+func (b AddressBook) PermissionLabel() string {
+	return BookPermissionLabel(b.Permission)
+}
+
+// AddressBookMember is a user with access to an address book.
+type AddressBookMember struct {
+	BookId     int64
+	UserId     int64
+	Username   string
+	Email      string
+	Permission string
+	CreatedAt  time.Time
+}
+
+// PermissionLabel describes the member's permission for people.
+func (m AddressBookMember) PermissionLabel() string {
+	return BookPermissionLabel(m.Permission)
+}
+
+// AddressBookInvitation offers membership of an address book to whoever verifies the invited email address.
+type AddressBookInvitation struct {
+	Id         int64
+	BookId     int64
+	BookName   string
+	Email      string
+	Permission string
+	InvitedBy  int64
+	// InviterName is empty if the user who sent the invitation was deleted.
+	InviterName string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
+
+// PermissionLabel describes the permission the invitation grants.
+func (i AddressBookInvitation) PermissionLabel() string {
+	return BookPermissionLabel(i.Permission)
+}
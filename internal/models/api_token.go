@@ -0,0 +1,29 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// APIToken is a personal access token used by scripts to call the JSON API.
+// Only a hash of the token is stored; Prefix is kept so users can recognize their tokens.
+type APIToken struct {
+	Id         int64
+	UserId     int64
+	Name       string
+	Prefix     string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// HasScope reports whether the token was granted the given scope.
+func (t APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// IsExpired reports whether the token can no longer be used at the given time.
+func (t APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Actions recorded in the audit trail.
+const (
+	AuditLoginSucceeded       = "auth.login_succeeded"
+	AuditLoginFailed          = "auth.login_failed"
+	AuditLogout               = "auth.logout"
+	AuditPasswordChanged      = "auth.password_changed"
+	AuditPasswordResetUsed    = "auth.password_reset"
+	AuditEmailChanged         = "auth.email_changed"
+	AuditTwoFactorDisabled    = "auth.two_factor_disabled"
+	AuditAccountDeleted       = "auth.account_deleted"
+	AuditContactsBulkDeleted  = "contacts.bulk_deleted"
+	AuditTrailExported        = "audit.exported"
+	AuditUserDisabled         = "user.disabled"
+	AuditUserEnabled          = "user.enabled"
+	AuditUserRoleChanged      = "user.role_changed"
+	AuditUserPasswordReset    = "user.password_reset_forced"
+	AuditImpersonationStarted = "impersonation.started"
+	AuditImpersonationStopped = "impersonation.stopped"
+	AuditInvitationCreated    = "invitation.created"
+	AuditInvitationRevoked    = "invitation.revoked"
+	AuditOrgCreated           = "organization.created"
+)
+
+var auditActionLabels = map[string]string{
+	AuditLoginSucceeded:       "Logged in",
+	AuditLoginFailed:          "Failed to log in",
+	AuditLogout:               "Logged out",
+	AuditPasswordChanged:      "Changed password",
+	AuditPasswordResetUsed:    "Reset password",
+	AuditEmailChanged:         "Changed email address",
+	AuditTwoFactorDisabled:    "Disabled two-factor authentication",
+	AuditAccountDeleted:       "Deleted account",
+	AuditContactsBulkDeleted:  "Deleted contacts in bulk",
+	AuditTrailExported:        "Exported audit trail",
+	AuditUserDisabled:         "Disabled account",
+	AuditUserEnabled:          "Enabled account",
+	AuditUserRoleChanged:      "Changed role",
+	AuditUserPasswordReset:    "Forced password reset",
+	AuditImpersonationStarted: "Started impersonating",
+	AuditImpersonationStopped: "Stopped impersonating",
+	AuditInvitationCreated:    "Created invitation",
+	AuditInvitationRevoked:    "Revoked invitation",
+	AuditOrgCreated:           "Created organization",
+}
+
+// AuditEvent records a security relevant action, such as a login or an action an admin took.
+// Names are copied when the event is recorded so the trail stays readable after the users involved
+// are renamed or deleted. Events are append-only and chained by hash, so altering or removing one is detectable.
+type AuditEvent struct {
+	Id int64
+	// ActorId is zero when nobody could be identified, e.g. for a failed login with an unknown email.
+	ActorId    int64
+	ActorName  string
+	Action     string
+	TargetId   int64
+	TargetName string
+	IP         string
+	// Detail is a JSON object describing the action further, e.g. the role a user was given.
+	// Events recorded before details were JSON hold plain text.
+	Detail    string
+	CreatedAt time.Time
+	// Hash covers the event and the hash of the event before it.
+	Hash string
+}
+
+// AuditFilter narrows down the audit trail. Zero fields match every event.
+type AuditFilter struct {
+	// Actor matches events whose actor's name contains it.
+	Actor string
+	// From and To bound the time events were recorded at, To excluded.
+	From time.Time
+	To   time.Time
+}
+
+// ActionLabel describes the action for people.
+func (e AuditEvent) ActionLabel() string {
+	if label, ok := auditActionLabels[e.Action]; ok {
+		return label
+	}
+	return e.Action
+}
+
+// DetailSummary describes the detail for people, as comma-separated key: value pairs.
+func (e AuditEvent) DetailSummary() string {
+	var detail map[string]any
+	if err := json.Unmarshal([]byte(e.Detail), &detail); err != nil {
+		return e.Detail
+	}
+
+	keys := make([]string, 0, len(detail))
+	for key := range detail {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s: %v", key, detail[key])
+	}
+	return strings.Join(pairs, ", ")
+}
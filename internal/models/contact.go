@@ -0,0 +1,77 @@
+package models
+
+// Contact is an entry in an address book.
+// Version is incremented on every change and is used for optimistic concurrency control.
+type Contact struct {
+	Id     int64 `json:"id"`
+	BookId int64 `json:"bookId"`
+	// UserId is the user who created the contact, who may since have left its address book.
+	UserId      int64  `json:"-"`
+	FirstName   string `json:"firstName"`
+	LastName    string `json:"lastName"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phoneNumber"`
+	Version     int64  `json:"version"`
+	// Permission is the permission on the contact's address book of the user the contact was loaded for.
+	Permission string `json:"-"`
+}
+
+// CanEdit reports whether the user the contact was loaded for can change it.
+func (c Contact) CanEdit() bool {
+	return BookPermissionIncludes(c.Permission, BookEditor)
+}
+
+// ContactPatch holds the contact fields to change; nil fields are left as they are.
+type ContactPatch struct {
+	// BookId moves the contact to another address book, or chooses the book of a new one.
+	BookId      *int64  `json:"bookId"`
+	FirstName   *string `json:"firstName"`
+	LastName    *string `json:"lastName"`
+	Email       *string `json:"email"`
+	PhoneNumber *string `json:"phoneNumber"`
+}
+
+// Apply copies the fields set in the patch to the contact.
+func (p ContactPatch) Apply(c *Contact) {
+	if p.BookId != nil {
+		c.BookId = *p.BookId
+	}
+	if p.FirstName != nil {
+		c.FirstName = *p.FirstName
+	}
+	if p.LastName != nil {
+		c.LastName = *p.LastName
+	}
+	if p.Email != nil {
+		c.Email = *p.Email
+	}
+	if p.PhoneNumber != nil {
+		c.PhoneNumber = *p.PhoneNumber
+	}
+}
+
+// Operations accepted by bulk contact requests.
+const (
+	ContactOpCreate = "create"
+	ContactOpUpdate = "update"
+	ContactOpDelete = "delete"
+)
+
+// ContactOperation is one step of a bulk contact request.
+// Id is required to update or delete, and a non-zero Version must match the contact's current version.
+type ContactOperation struct {
+	Op      string       `json:"op"`
+	Id      int64        `json:"id,omitempty"`
+	Version int64        `json:"version,omitempty"`
+	Data    ContactPatch `json:"data"`
+}
+
+// ContactOperationResult reports the outcome of a ContactOperation.
+// Contact is the created or updated contact and is nil for deletions.
+// PreviousBookId is the book an updated or deleted contact was in before the operation.
+type ContactOperationResult struct {
+	Op             string   `json:"op"`
+	Id             int64    `json:"id"`
+	Contact        *Contact `json:"contact,omitempty"`
+	PreviousBookId int64    `json:"-"`
+}
@@ -1,18 +1,24 @@
 package models
 
+import "slices"
+
 type RegisterFormFields struct {
 	Username string
 	Email    string
 	Password string
+	// Invite is the invitation token while registration is invite-only.
+	Invite string
 }
 
 type RegisterForm struct {
 	Values RegisterFormFields
 	Errors RegisterFormFields
+	// EmailBound is set when the invitation can only be used with the email address it was sent to.
+	EmailBound bool
 }
 
 func (f RegisterForm) HasErrors() bool {
-	return f.Errors.Username != "" || f.Errors.Email != "" || f.Errors.Password != ""
+	return f.Errors.Username != "" || f.Errors.Email != "" || f.Errors.Password != "" || f.Errors.Invite != ""
 }
 
 type LoginFormFields struct {
@@ -28,3 +34,252 @@ type LoginForm struct {
 func (f LoginForm) HasErrors() bool {
 	return f.Errors.Email != "" || f.Errors.Password != ""
 }
+
+type ForgotPasswordFormFields struct {
+	Email string
+}
+
+type ForgotPasswordForm struct {
+	Values ForgotPasswordFormFields
+	Errors ForgotPasswordFormFields
+}
+
+func (f ForgotPasswordForm) HasErrors() bool {
+	return f.Errors.Email != ""
+}
+
+type MagicLinkFormFields struct {
+	Email string
+}
+
+type MagicLinkForm struct {
+	Values MagicLinkFormFields
+	Errors MagicLinkFormFields
+}
+
+func (f MagicLinkForm) HasErrors() bool {
+	return f.Errors.Email != ""
+}
+
+type ResetPasswordFormFields struct {
+	Token           string
+	Password        string
+	ConfirmPassword string
+}
+
+type ResetPasswordForm struct {
+	Values ResetPasswordFormFields
+	Errors ResetPasswordFormFields
+}
+
+func (f ResetPasswordForm) HasErrors() bool {
+	return f.Errors.Token != "" || f.Errors.Password != "" || f.Errors.ConfirmPassword != ""
+}
+
+type TwoFactorFormFields struct {
+	Code string
+}
+
+type TwoFactorForm struct {
+	Values TwoFactorFormFields
+	Errors TwoFactorFormFields
+}
+
+func (f TwoFactorForm) HasErrors() bool {
+	return f.Errors.Code != ""
+}
+
+// TwoFactorActionForm is a two-factor code form posting to one of the two-factor settings endpoints.
+type TwoFactorActionForm struct {
+	Action string
+	Submit string
+	TwoFactorForm
+}
+
+type APITokenFormValues struct {
+	Name      string
+	Scopes    []string
+	ExpiresIn string
+}
+
+// HasScope reports whether scope was selected, to keep the checkbox checked when the form is rendered again.
+func (v APITokenFormValues) HasScope(scope string) bool {
+	return slices.Contains(v.Scopes, scope)
+}
+
+type APITokenFormErrors struct {
+	Name      string
+	Scopes    string
+	ExpiresIn string
+}
+
+type APITokenForm struct {
+	Values APITokenFormValues
+	Errors APITokenFormErrors
+}
+
+func (f APITokenForm) HasErrors() bool {
+	return f.Errors.Name != "" || f.Errors.Scopes != "" || f.Errors.ExpiresIn != ""
+}
+
+type UsernameFormFields struct {
+	Username string
+}
+
+type UsernameForm struct {
+	Values UsernameFormFields
+	Errors UsernameFormFields
+}
+
+func (f UsernameForm) HasErrors() bool {
+	return f.Errors.Username != ""
+}
+
+type ChangeEmailFormFields struct {
+	Email    string
+	Password string
+}
+
+type ChangeEmailForm struct {
+	Values ChangeEmailFormFields
+	Errors ChangeEmailFormFields
+}
+
+func (f ChangeEmailForm) HasErrors() bool {
+	return f.Errors.Email != "" || f.Errors.Password != ""
+}
+
+type ChangePasswordFormFields struct {
+	CurrentPassword string
+	NewPassword     string
+}
+
+type ChangePasswordForm struct {
+	Values ChangePasswordFormFields
+	Errors ChangePasswordFormFields
+}
+
+func (f ChangePasswordForm) HasErrors() bool {
+	return f.Errors.CurrentPassword != "" || f.Errors.NewPassword != ""
+}
+
+type DeleteAccountFormFields struct {
+	Password string
+}
+
+type DeleteAccountForm struct {
+	Values DeleteAccountFormFields
+	Errors DeleteAccountFormFields
+}
+
+func (f DeleteAccountForm) HasErrors() bool {
+	return f.Errors.Password != ""
+}
+
+type InvitationFormFields struct {
+	Email     string
+	MaxUses   string
+	ExpiresIn string
+}
+
+type InvitationForm struct {
+	Values InvitationFormFields
+	Errors InvitationFormFields
+}
+
+func (f InvitationForm) HasErrors() bool {
+	return f.Errors.Email != "" || f.Errors.MaxUses != "" || f.Errors.ExpiresIn != ""
+}
+
+type AddressBookFormFields struct {
+	Name string
+}
+
+type AddressBookForm struct {
+	Values AddressBookFormFields
+	Errors AddressBookFormFields
+}
+
+func (f AddressBookForm) HasErrors() bool {
+	return f.Errors.Name != ""
+}
+
+type BookInvitationFormFields struct {
+	Email      string
+	Permission string
+}
+
+type BookInvitationForm struct {
+	Values BookInvitationFormFields
+	Errors BookInvitationFormFields
+}
+
+func (f BookInvitationForm) HasErrors() bool {
+	return f.Errors.Email != "" || f.Errors.Permission != ""
+}
+
+type OrganizationFormFields struct {
+	Name string
+}
+
+type OrganizationForm struct {
+	Values OrganizationFormFields
+	Errors OrganizationFormFields
+}
+
+func (f OrganizationForm) HasErrors() bool {
+	return f.Errors.Name != ""
+}
+
+type OrgSettingsFormFields struct {
+	Name                  string
+	MembersCanCreateBooks string
+	DefaultPermission     string
+}
+
+type OrgSettingsForm struct {
+	Values OrgSettingsFormFields
+	Errors OrgSettingsFormFields
+}
+
+func (f OrgSettingsForm) HasErrors() bool {
+	return f.Errors.Name != "" || f.Errors.MembersCanCreateBooks != "" || f.Errors.DefaultPermission != ""
+}
+
+type OrgMemberFormFields struct {
+	Email string
+	Role  string
+}
+
+type OrgMemberForm struct {
+	Values OrgMemberFormFields
+	Errors OrgMemberFormFields
+}
+
+func (f OrgMemberForm) HasErrors() bool {
+	return f.Errors.Email != "" || f.Errors.Role != ""
+}
+
+type WebhookFormValues struct {
+	URL    string
+	Events []string
+}
+
+// HasEvent reports whether event was selected, to keep the checkbox checked when the form is rendered again.
+func (v WebhookFormValues) HasEvent(event string) bool {
+	return slices.Contains(v.Events, event)
+}
+
+type WebhookFormErrors struct {
+	URL    string
+	Events string
+}
+
+type WebhookForm struct {
+	Values WebhookFormValues
+	Errors WebhookFormErrors
+}
+
+func (f WebhookForm) HasErrors() bool {
+	return f.Errors.URL != "" || f.Errors.Events != ""
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a request sent with an Idempotency-Key header,
+// so a retried request gets the same response instead of being applied twice.
+// Status is zero while the original request is still being handled.
+type IdempotencyKey struct {
+	Id          int64
+	UserId      int64
+	Key         string
+	RequestHash string
+	Status      int
+	ContentType string
+	ETag        string
+	Location    string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+// IsComplete reports whether the original request has finished and its response was stored.
+func (k IdempotencyKey) IsComplete() bool {
+	return k.Status != 0
+}
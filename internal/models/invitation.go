@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Invitation lets people register while registration is invite-only.
+type Invitation struct {
+	Id        int64
+	CreatedBy int64
+	// Email is the only address the invitation can be used with, or empty if anyone holding the link can use it.
+	Email     string
+	MaxUses   int
+	Uses      int
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// IsUsable reports whether someone can still register with the invitation at the given time.
+func (i Invitation) IsUsable(now time.Time) bool {
+	return i.RevokedAt == nil && i.Uses < i.MaxUses && (i.ExpiresAt == nil || now.Before(*i.ExpiresAt))
+}
+
+// RemainingUses is how many more people can register with the invitation.
+func (i Invitation) RemainingUses() int {
+	return max(i.MaxUses-i.Uses, 0)
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type MagicLink struct {
+	Id        int64
+	UserId    int64
+	NonceHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// IsUsable reports whether the link can still be used to sign in at the given time.
+func (m MagicLink) IsUsable(now time.Time) bool {
+	return m.UsedAt == nil && now.Before(m.ExpiresAt)
+}
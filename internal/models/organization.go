@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Roles a member can have in an organization.
+const (
+	OrgAdmin  = "admin"
+	OrgMember = "member"
+)
+
+var orgRoleLabels = map[string]string{
+	OrgAdmin:  "Admin",
+	OrgMember: "Member",
+}
+
+// IsValidOrgRole reports whether role is one of the known organization roles.
+func IsValidOrgRole(role string) bool {
+	_, ok := orgRoleLabels[role]
+	return ok
+}
+
+// OrgRoleLabel describes an organization role for people.
+func OrgRoleLabel(role string) string {
+	if label, ok := orgRoleLabels[role]; ok {
+		return label
+	}
+	return role
+}
+
+// Organization is a workspace shared by a group of users, such as a department.
+// Its address books and their contacts are only visible while working in it.
+type Organization struct {
+	Id   int64
+	Name string
+	// BookId is the address book every member joins, where contacts created without a book go.
+	BookId int64
+	// MembersCanCreateBooks lets members who aren't admins create address books in the organization.
+	MembersCanCreateBooks bool
+	// DefaultPermission is the permission new members get on the organization's address book.
+	DefaultPermission string
+	CreatedAt         time.Time
+	// Role is the role of the user the organization was loaded for.
+	Role string
+}
+
+// IsAdmin reports whether the user the organization was loaded for manages it.
+func (o Organization) IsAdmin() bool {
+	return o.Role == OrgAdmin
+}
+
+// CanCreateBooks reports whether the user the organization was loaded for can create address books in it.
+func (o Organization) CanCreateBooks() bool {
+	return o.IsAdmin() || o.MembersCanCreateBooks
+}
+
+// OrgMembership is a user's membership of an organization.
+type OrgMembership struct {
+	OrgId     int64
+	UserId    int64
+	Username  string
+	Email     string
+	Role      string
+	CreatedAt time.Time
+}
+
+// RoleLabel describes the member's role for people.
+func (m OrgMembership) RoleLabel() string {
+	return OrgRoleLabel(m.Role)
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type PasswordReset struct {
+	Id        int64
+	UserId    int64
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// IsUsable reports whether the reset can still be redeemed at the given time.
+func (p PasswordReset) IsUsable(now time.Time) bool {
+	return p.UsedAt == nil && now.Before(p.ExpiresAt)
+}
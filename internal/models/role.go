@@ -0,0 +1,44 @@
+package models
+
+// Roles a user can have. Each role can do everything the roles below it can.
+const (
+	RoleAdmin    = "admin"
+	RoleUser     = "user"
+	RoleReadOnly = "read_only"
+)
+
+// Roles lists the roles from most to least privileged.
+var Roles = []string{RoleAdmin, RoleUser, RoleReadOnly}
+
+var roleRanks = map[string]int{
+	RoleAdmin:    3,
+	RoleUser:     2,
+	RoleReadOnly: 1,
+}
+
+var roleLabels = map[string]string{
+	RoleAdmin:    "Admin",
+	RoleUser:     "User",
+	RoleReadOnly: "Read-only",
+}
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleIncludes reports whether role grants everything required does.
+// Unknown roles grant nothing.
+func RoleIncludes(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
+
+// RoleLabel describes a role for people.
+func RoleLabel(role string) string {
+	if label, ok := roleLabels[role]; ok {
+		return label
+	}
+	return role
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/joangavelan/contacts-app/pkg/useragent"
+)
+
+// Ways of logging in, recorded with sessions and login events.
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodMagicLink = "magic_link"
+	LoginMethodOIDC      = "oidc"
+	// Sessions an admin started to act as the user.
+	LoginMethodImpersonation = "impersonation"
+)
+
+var loginMethodLabels = map[string]string{
+	LoginMethodPassword:      "Password",
+	LoginMethodMagicLink:     "Magic link",
+	LoginMethodOIDC:          "Single sign-on",
+	LoginMethodImpersonation: "Admin impersonation",
+}
+
+// LoginMethodLabel describes a login method for people.
+func LoginMethodLabel(method string) string {
+	if label, ok := loginMethodLabels[method]; ok {
+		return label
+	}
+	return method
+}
+
+// Session is a browser the user is logged in on. Its ID is carried by the session cookie.
+type Session struct {
+	Id        int64
+	UserId    int64
+	Method    string
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+	// IssuedAt is when the session cookie was last issued, which it is again when the user's details change.
+	IssuedAt   time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	// ImpersonatorId is the admin who started the session to act as the user, if any.
+	ImpersonatorId int64
+}
+
+// IsActive reports whether the session can still be used at the given time,
+// given the moment before which the user's sessions were invalidated.
+func (s Session) IsActive(now, validAfter time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt) && s.IssuedAt.Unix() >= validAfter.Unix()
+}
+
+// Device describes the browser and operating system the session was started from.
+func (s Session) Device() string {
+	return useragent.Parse(s.UserAgent).String()
+}
+
+// MethodLabel describes how the session was started.
+func (s Session) MethodLabel() string {
+	return LoginMethodLabel(s.Method)
+}
+
+// LoginEvent is a successful or failed attempt to log in to an account.
+type LoginEvent struct {
+	Id        int64
+	UserId    int64
+	Method    string
+	IP        string
+	UserAgent string
+	Success   bool
+	CreatedAt time.Time
+}
+
+// Device describes the browser and operating system the attempt was made from.
+func (e LoginEvent) Device() string {
+	return useragent.Parse(e.UserAgent).String()
+}
+
+// MethodLabel describes how the user tried to log in.
+func (e LoginEvent) MethodLabel() string {
+	return LoginMethodLabel(e.Method)
+}
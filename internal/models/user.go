@@ -1,13 +1,80 @@
 package models
 
+import (
+	"slices"
+	"time"
+)
+
 type User struct {
 	Id       int64
 	Username string
 	Email    string
 	Password string
+	// SessionsValidAfter is the moment before which issued sessions are no longer accepted.
+	SessionsValidAfter time.Time
+	VerifiedAt         *time.Time
+	VerificationSentAt *time.Time
+	// TOTPSecret is set as soon as enrollment starts; two-factor is only enforced once TOTPEnabledAt is set.
+	TOTPSecret      string
+	TOTPEnabledAt   *time.Time
+	TOTPLastCounter int64
+	Role            string
+	// DisabledAt is set while an admin keeps the user from logging in.
+	DisabledAt *time.Time
+}
+
+// IsVerified reports whether the user verified their email address.
+func (u User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+// IsDisabled reports whether an admin disabled the account.
+func (u User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// RoleLabel describes the user's role for people.
+func (u User) RoleLabel() string {
+	return RoleLabel(u.Role)
+}
+
+// TwoFactorEnabled reports whether logging in requires a second factor.
+func (u User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
 }
 
 type UserContext struct {
 	Id       int64
 	Username string
+	Email    string
+	Verified bool
+	Role     string
+	// SessionId is set when the request was authenticated with the session cookie.
+	SessionId int64
+	// APITokenId is set when the request was authenticated with an API token rather than the session cookie.
+	APITokenId int64
+	Scopes     []string
+	// ImpersonatorId and ImpersonatorName are set when an admin is logged in as the user.
+	ImpersonatorId   int64
+	ImpersonatorName string
+	// OrgId is the organization the request works in, with the user's OrgRole in it.
+	// It is zero for the user's personal workspace.
+	OrgId   int64
+	OrgRole string
+}
+
+// HasRole reports whether the user's role grants everything role does.
+func (u UserContext) HasRole(role string) bool {
+	return RoleIncludes(u.Role, role)
+}
+
+// IsImpersonated reports whether the request was made by an admin logged in as the user.
+func (u UserContext) IsImpersonated() bool {
+	return u.ImpersonatorId != 0
+}
+
+// HasScope reports whether the request may act within scope.
+// Browser sessions are not restricted; API tokens only have the scopes they were granted.
+func (u UserContext) HasScope(scope string) bool {
+	return u.APITokenId == 0 || slices.Contains(u.Scopes, scope)
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at an external identity provider.
+// Subject is the provider's stable ID for the account; Email is the address it had when linked.
+type UserIdentity struct {
+	Id        int64
+	UserId    int64
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
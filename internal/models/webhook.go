@@ -0,0 +1,68 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Contact lifecycle events webhooks can subscribe to.
+const (
+	WebhookContactCreated = "contact.created"
+	WebhookContactUpdated = "contact.updated"
+	WebhookContactDeleted = "contact.deleted"
+)
+
+// WebhookEvents lists every event, in the order they are offered.
+var WebhookEvents = []string{WebhookContactCreated, WebhookContactUpdated, WebhookContactDeleted}
+
+// Statuses of webhook deliveries.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a URL a user registered to be told about changes to the contacts of the address books they are a member of.
+// Payloads are signed with Secret so the receiver can check they come from us.
+type Webhook struct {
+	Id        int64
+	UserId    int64
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+// Subscribes reports whether the webhook wants to be told about the event.
+func (w Webhook) Subscribes(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+// WebhookDelivery is an event queued for, or sent to, a webhook. Failed attempts are retried until
+// they succeed or WebhookMaxAttempts is reached, keeping the response to the last one.
+type WebhookDelivery struct {
+	Id        int64
+	WebhookId int64
+	Event     string
+	Payload   string
+	Status    string
+	Attempts  int
+	// NextAttemptAt is set while the delivery is pending.
+	NextAttemptAt  *time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus int
+	// Error describes why the last attempt failed.
+	Error     string
+	CreatedAt time.Time
+	// Webhook is the endpoint the delivery is sent to, only loaded for deliveries that are due.
+	Webhook *Webhook
+}
+
+// WebhookPayload is the JSON body sent to webhooks.
+type WebhookPayload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurredAt"`
+	// OrgId is the organization the contact belongs to, zero for personal workspaces.
+	OrgId   int64    `json:"orgId"`
+	Contact *Contact `json:"contact"`
+}
@@ -0,0 +1,261 @@
+// Package render answers a request in the format it asks for, from one view model: a full page for browsers
+// navigating, a fragment for htmx to swap in, or JSON for scripts. Errors are rendered the same way, as a
+// toast for the UI and as JSON:API error objects for scripts.
+package render
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/joangavelan/contacts-app/internal/assets"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/csrf"
+	"github.com/joangavelan/contacts-app/internal/flash"
+	"github.com/joangavelan/contacts-app/internal/htmx"
+	"github.com/joangavelan/contacts-app/internal/templates"
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+	"github.com/joangavelan/contacts-app/pkg/toast"
+	"github.com/joangavelan/contacts-app/web"
+)
+
+// Format is a representation a response can be rendered in.
+type Format int
+
+const (
+	Page Format = iota
+	Fragment
+	JSON
+)
+
+// Templates is the registry templates are rendered from, parsed from the files embedded in the binary
+// unless ReadTemplatesFrom is called.
+var Templates = mustLoad(templates.New(web.Templates, templateFuncs()))
+
+// ReadTemplatesFrom makes templates be read from dir, and parsed again whenever they change, for development.
+func ReadTemplatesFrom(dir string) error {
+	reg, err := templates.Dir(dir, templateFuncs())
+	if err != nil {
+		return err
+	}
+	Templates = reg
+	return nil
+}
+
+// templateFuncs are the functions templates are parsed with: asset, which links to a static file by its
+// fingerprinted URL, and placeholders for the functions of a request.
+func templateFuncs() template.FuncMap {
+	funcs := Funcs(&http.Request{})
+	funcs["asset"] = assets.Path
+	return funcs
+}
+
+func mustLoad(reg *templates.Registry, err error) *templates.Registry {
+	if err != nil {
+		panic(err)
+	}
+	return reg
+}
+
+// Negotiate picks the format to answer the request in. Requests with an API token get JSON, htmx requests get
+// a fragment, unless they restore a page missing from the history cache, and other requests get a full page if
+// their Accept header asks for HTML at least as much as for JSON, as browsers navigating do, and JSON otherwise.
+func Negotiate(r *http.Request) Format {
+	if scheme, _, _ := strings.Cut(r.Header.Get("Authorization"), " "); strings.EqualFold(scheme, "Bearer") {
+		return JSON
+	}
+	if r.Header.Get("HX-History-Restore-Request") == "true" {
+		return Page
+	}
+	if htmx.IsRequest(r) {
+		return Fragment
+	}
+
+	html, json := acceptQuality(r.Header.Get("Accept"))
+	if html > 0 && html >= json {
+		return Page
+	}
+	return JSON
+}
+
+// View is how a handler's model is rendered in each format. Formats without templates render the model as JSON.
+type View struct {
+	// Page lists the templates of the full page, starting with its layout.
+	Page []string
+	// Fragment lists the templates of the fragment htmx swaps in,
+	// and Block names the one to execute, the first of them if empty.
+	Fragment []string
+	Block    string
+	// Redirect is where browsers are sent once a change is made, rather than render its page,
+	// and where htmx is sent when there is no fragment to swap in.
+	Redirect string
+}
+
+// Render writes the model in the format the request asks for.
+func Render(w http.ResponseWriter, r *http.Request, status int, view View, model any) {
+	w.Header().Add("Vary", "Accept, HX-Request")
+
+	format := Negotiate(r)
+	var files []string
+	var block string
+	switch format {
+	case Page:
+		files = view.Page
+	case Fragment:
+		files, block = view.Fragment, view.Block
+	}
+
+	// Browsers are sent to another page once a change is made, so reloading it doesn't repeat the change.
+	changed := r.Method != http.MethodGet && r.Method != http.MethodHead
+	if format != JSON && changed && view.Redirect != "" && (format == Page || len(files) == 0) {
+		htmx.Redirect(w, r, view.Redirect)
+		return
+	}
+
+	if len(files) == 0 {
+		if model == nil {
+			w.WriteHeader(status)
+			return
+		}
+		jsonapi.Write(w, status, model)
+		return
+	}
+
+	lookup := PartialTemplate
+	if format == Page {
+		lookup = PageTemplate
+	}
+	if block == "" {
+		block = path.Base(files[0])
+	}
+	tmpl := lookup(r, files...)
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, block, model); err != nil {
+		log.Printf("Error rendering %s: %v", block, err)
+		Error(w, r, http.StatusInternalServerError, "internal_error", "Unable to render template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// PageTemplate returns the templates of a full page, starting with its layout, with the functions of the request.
+// Templates that can't be found are logged and fail to execute, so the request fails rather than the server.
+func PageTemplate(r *http.Request, files ...string) *template.Template {
+	tmpl, err := Templates.Page(files...)
+	return withFuncs(r, files, tmpl, err)
+}
+
+// PartialTemplate returns the templates of a fragment, starting with the one to execute,
+// with the functions of the request, which may be nil for fragments that don't use them.
+func PartialTemplate(r *http.Request, files ...string) *template.Template {
+	tmpl, err := Templates.Partial(files...)
+	return withFuncs(r, files, tmpl, err)
+}
+
+func withFuncs(r *http.Request, files []string, tmpl *template.Template, err error) *template.Template {
+	if err != nil {
+		log.Printf("Error loading templates %v: %v", files, err)
+		return template.New("missing")
+	}
+	if r == nil {
+		return tmpl
+	}
+	return tmpl.Funcs(Funcs(r))
+}
+
+// Toast shows the toast to the UI, along with the response. Scripts don't get it.
+func Toast(w http.ResponseWriter, r *http.Request, t toast.Toast) {
+	if Negotiate(r) == JSON {
+		return
+	}
+	if err := t.WriteToHeader(w); err != nil {
+		log.Printf("Error writing toast event: %v", err)
+	}
+}
+
+// Error writes a single error in the format the request asks for.
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Errors(w, r, status, jsonapi.NewError(status, code, detail))
+}
+
+// Errors writes the error objects, which should share the response status, as JSON for scripts. The UI gets
+// their details in an error toast and as plain text, which htmx.Fallback flashes to plain form posts.
+func Errors(w http.ResponseWriter, r *http.Request, status int, errs ...jsonapi.Error) {
+	if Negotiate(r) == JSON {
+		jsonapi.WriteErrors(w, status, errs...)
+		return
+	}
+
+	details := make([]string, 0, len(errs))
+	for _, err := range errs {
+		detail := err.Detail
+		if detail == "" {
+			detail = err.Title
+		}
+		if err.Source != nil && err.Source.Pointer != "" {
+			detail = path.Base(err.Source.Pointer) + ": " + detail
+		}
+		details = append(details, detail)
+	}
+	message := strings.Join(details, "; ")
+
+	Toast(w, r, toast.Error(message))
+	http.Error(w, message, status)
+}
+
+// Funcs returns the template functions of the request: its CSRF token, logged in user and flashes.
+func Funcs(r *http.Request) template.FuncMap {
+	funcs := template.FuncMap{}
+	for _, fm := range []template.FuncMap{csrf.TemplateFuncs(r), auth.TemplateFuncs(r), flash.TemplateFuncs(r)} {
+		for name, fn := range fm {
+			funcs[name] = fn
+		}
+	}
+	return funcs
+}
+
+// acceptQuality returns the quality the Accept header gives to HTML and to JSON, -1 if it doesn't accept them.
+// HTML has to be named, since scripts commonly accept */*, and JSON gets the quality of its most specific match.
+func acceptQuality(accept string) (html, json float64) {
+	html, json = -1, -1
+	jsonSpecificity := 0
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if name, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && name == "q" {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		switch mediaType {
+		case "text/html":
+			html = q
+		case jsonapi.ContentType:
+			json, jsonSpecificity = q, 3
+		case "application/*":
+			if jsonSpecificity < 2 {
+				json, jsonSpecificity = q, 2
+			}
+		case "*/*":
+			if jsonSpecificity < 1 {
+				json, jsonSpecificity = q, 1
+			}
+		}
+	}
+
+	return html, json
+}
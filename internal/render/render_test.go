@@ -0,0 +1,115 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joangavelan/contacts-app/pkg/jsonapi"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    Format
+	}{
+		{"Browser navigation", map[string]string{"Accept": "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}, Page},
+		{"htmx request", map[string]string{"HX-Request": "true", "Accept": "*/*"}, Fragment},
+		{"htmx history restore", map[string]string{"HX-Request": "true", "HX-History-Restore-Request": "true"}, Page},
+		{"API token", map[string]string{"Authorization": "Bearer abc", "Accept": "text/html"}, JSON},
+		{"curl", map[string]string{"Accept": "*/*"}, JSON},
+		{"No Accept header", nil, JSON},
+		{"JSON preferred", map[string]string{"Accept": "application/json, text/html;q=0.5"}, JSON},
+		{"HTML refused", map[string]string{"Accept": "text/html;q=0, application/json"}, JSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/contacts", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			if got := Negotiate(r); got != tt.want {
+				t.Errorf("expected format %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "row.html"), []byte(`{{ define "row" }}<li>{{ .Name }}</li>{{ end }}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	embedded := Templates
+	defer func() { Templates = embedded }()
+	if err := ReadTemplatesFrom(dir); err != nil {
+		t.Fatal(err)
+	}
+	view := View{Fragment: []string{"row.html"}, Block: "row", Redirect: "/contacts"}
+	model := struct{ Name string }{"Ada"}
+
+	t.Run("htmx gets the fragment", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/api/v1/contacts/1", nil)
+		r.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		Render(rec, r, http.StatusOK, view, model)
+		if rec.Body.String() != "<li>Ada</li>" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+			t.Errorf("expected the fragment, got %q", rec.Body)
+		}
+	})
+
+	t.Run("Scripts get JSON", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Render(rec, httptest.NewRequest("GET", "/api/v1/contacts/1", nil), http.StatusOK, view, model)
+		if rec.Header().Get("Content-Type") != jsonapi.ContentType || strings.TrimSpace(rec.Body.String()) != `{"Name":"Ada"}` {
+			t.Errorf("expected JSON, got %q", rec.Body)
+		}
+		if rec.Header().Get("Vary") != "Accept, HX-Request" {
+			t.Errorf("expected the response to vary on the negotiated headers, got %q", rec.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("Browsers are redirected after a change without a page", func(t *testing.T) {
+		r := httptest.NewRequest("DELETE", "/api/v1/contacts/1", nil)
+		r.Header.Set("Accept", "text/html")
+		rec := httptest.NewRecorder()
+		Render(rec, r, http.StatusNoContent, view, nil)
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/contacts" {
+			t.Errorf("expected a redirect to /contacts, got %d %v", rec.Code, rec.Header())
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+	invalid := jsonapi.NewError(http.StatusUnprocessableEntity, "invalid", "Invalid email address")
+	invalid.Source = &jsonapi.ErrorSource{Pointer: "/email"}
+
+	t.Run("Scripts get error objects", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Errors(rec, httptest.NewRequest("POST", "/api/v1/contacts", nil), http.StatusUnprocessableEntity, invalid)
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"pointer":"/email"`) {
+			t.Errorf("expected a JSON error, got %d %q", rec.Code, rec.Body)
+		}
+		if rec.Header().Get("HX-Trigger") != "" {
+			t.Errorf("expected no toast for scripts")
+		}
+	})
+
+	t.Run("The UI gets a toast", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/api/v1/contacts", nil)
+		r.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		Errors(rec, r, http.StatusUnprocessableEntity, invalid)
+		if strings.TrimSpace(rec.Body.String()) != "email: Invalid email address" {
+			t.Errorf("expected the error as text, got %q", rec.Body)
+		}
+		if !strings.Contains(rec.Header().Get("HX-Trigger"), "email: Invalid email address") {
+			t.Errorf("expected an error toast, got %v", rec.Header())
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package signing
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	signature := Sign("csrf", "token")
+
+	if !Verify("csrf", "token", signature) {
+		t.Errorf("expected the signature to be valid")
+	}
+	if Verify("flash", "token", signature) {
+		t.Errorf("expected a signature made for another purpose to be rejected")
+	}
+	if Verify("csrf", "other", signature) {
+		t.Errorf("expected a signature of another message to be rejected")
+	}
+}
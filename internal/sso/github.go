@@ -0,0 +1,86 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/pkg/oidc"
+)
+
+const githubAPIURL = "https://api.github.com"
+
+// githubProvider signs users in with GitHub, which issues no ID tokens,
+// so the account is read from the API with the access token instead.
+type githubProvider struct {
+	config config.OIDCProvider
+	rp     *oidc.Provider
+}
+
+// NewGitHubProvider returns a provider for a GitHub OAuth app.
+func NewGitHubProvider(c config.OIDCProvider) Provider {
+	rp := oidc.NewProvider(oidc.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectURL(),
+		Scopes:       c.Scopes,
+	}, oidc.Metadata{
+		Issuer:                "https://github.com",
+		AuthorizationEndpoint: "https://github.com/login/oauth/authorize",
+		TokenEndpoint:         "https://github.com/login/oauth/access_token",
+	})
+
+	return &githubProvider{config: c, rp: rp}
+}
+
+func (p *githubProvider) Name() string        { return p.config.Name }
+func (p *githubProvider) DisplayName() string { return p.config.DisplayName }
+
+// AuthCodeURL ignores the nonce, which only applies to ID tokens; the state and PKCE still protect the flow.
+func (p *githubProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	return p.rp.AuthCodeURL(state, "", codeVerifier), nil
+}
+
+func (p *githubProvider) Identify(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	token, err := p.rp.Exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		Id    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.rp.GetJSON(ctx, githubAPIURL+"/user", token.AccessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.Id == 0 {
+		return nil, errors.New("github user has no id")
+	}
+
+	// The public profile email may be unverified, so the verified primary address is used instead.
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.rp.GetJSON(ctx, githubAPIURL+"/user/emails", token.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider: p.config.Name,
+		Subject:  strconv.FormatInt(user.Id, 10),
+		Name:     user.Name,
+		Username: user.Login,
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email, identity.EmailVerified = e.Email, e.Verified
+		}
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,86 @@
+package sso
+
+import (
+	"context"
+	"sync"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/pkg/oidc"
+)
+
+// oidcProvider signs users in with an OpenID Connect provider.
+// The provider is discovered on first use, so the app starts even if the provider is unreachable.
+type oidcProvider struct {
+	config config.OIDCProvider
+
+	mu sync.Mutex
+	rp *oidc.Provider
+}
+
+// NewOIDCProvider returns a provider discovered from its issuer URL.
+func NewOIDCProvider(c config.OIDCProvider) Provider {
+	return &oidcProvider{config: c}
+}
+
+func (p *oidcProvider) Name() string        { return p.config.Name }
+func (p *oidcProvider) DisplayName() string { return p.config.DisplayName }
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	rp, err := p.relyingParty(ctx)
+	if err != nil {
+		return "", err
+	}
+	return rp.AuthCodeURL(state, nonce, codeVerifier), nil
+}
+
+func (p *oidcProvider) Identify(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	rp, err := p.relyingParty(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := rp.Exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, oidc.ErrNoIDToken
+	}
+
+	claims, err := rp.VerifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		Provider:      p.config.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Username:      claims.PreferredUsername,
+	}, nil
+}
+
+func (p *oidcProvider) relyingParty(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rp != nil {
+		return p.rp, nil
+	}
+
+	rp, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       p.config.Issuer,
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL(),
+		Scopes:       p.config.Scopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.rp = rp
+	return rp, nil
+}
@@ -0,0 +1,126 @@
+package sso
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/auth"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// ErrRegistrationClosed is returned for a first sign in with an account no user has the email address of,
+// while registration isn't open. Invited users register with a password first and can then sign in with the provider.
+var ErrRegistrationClosed = errors.New("registration is closed")
+
+// ErrEmailNotVerified is returned for a first sign in with an account whose email the provider hasn't verified,
+// since the account can then neither be linked nor used to create one.
+var ErrEmailNotVerified = errors.New("provider did not verify the email address")
+
+// SignIn returns the user the provider account belongs to. On the first sign in with the account,
+// it's linked to the user with the same email address, or a user is created for it.
+func SignIn(db *sql.DB, identity *Identity, now time.Time) (*models.User, error) {
+	link, err := database.GetUserIdentity(db, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if link != nil {
+		user, err := database.GetUserById(db, link.UserId)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user %d of identity %d does not exist", link.UserId, link.Id)
+		}
+		return user, nil
+	}
+
+	if !identity.EmailVerified || !auth.IsValidEmail(identity.Email) {
+		return nil, ErrEmailNotVerified
+	}
+
+	newLink := &models.UserIdentity{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: now,
+	}
+
+	// Accounts created this way have a random password; users can set one with the forgotten password flow.
+	password, _, err := auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := database.GetUserByEmail(db, identity.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		if config.RegistrationMode != config.RegistrationOpen {
+			return nil, ErrRegistrationClosed
+		}
+
+		userId, err := database.CreateUserWithIdentity(db, usernameFor(identity), hashedPassword, newLink)
+		if err != nil {
+			return nil, err
+		}
+		return database.GetUserById(db, userId)
+	}
+
+	// Whoever registered an unverified account never proved they own the address, and may have done so to
+	// get into the account once its real owner signs in with the provider. The provider proved ownership,
+	// so the account is reclaimed: its password is replaced and its sessions are ended.
+	reclaimPasswordHash := ""
+	if !user.IsVerified() {
+		reclaimPasswordHash = hashedPassword
+	}
+
+	newLink.UserId = user.Id
+	if err := database.LinkUserIdentity(db, newLink, reclaimPasswordHash); err != nil {
+		return nil, err
+	}
+
+	return database.GetUserById(db, user.Id)
+}
+
+// usernameFor picks a username from the provider account, padded or truncated to a valid length.
+func usernameFor(identity *Identity) string {
+	username := identity.Username
+	if username == "" {
+		username = identity.Name
+	}
+	if username == "" {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	for len(username) > auth.MaxUsernameLength {
+		_, size := utf8.DecodeLastRuneInString(username)
+		username = username[:len(username)-size]
+	}
+
+	if len(username) < auth.MinUsernameLength {
+		suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			suffix = big.NewInt(0)
+		}
+		username = fmt.Sprintf("%s%04d", username, suffix)
+		if len(username) < auth.MinUsernameLength {
+			username = "user" + username
+		}
+	}
+
+	return username
+}
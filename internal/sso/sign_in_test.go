@@ -0,0 +1,28 @@
+package sso
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joangavelan/contacts-app/internal/auth"
+)
+
+func TestUsernameFor(t *testing.T) {
+	tests := []struct {
+		identity Identity
+		prefix   string
+	}{
+		{Identity{Username: "octocat", Name: "The Octocat"}, "octocat"},
+		{Identity{Name: "Ada Lovelace", Email: "ada@example.com"}, "Ada Lovelace"},
+		{Identity{Email: "ada@example.com"}, "ada"},
+		{Identity{Username: strings.Repeat("x", 40)}, strings.Repeat("x", auth.MaxUsernameLength)},
+		{Identity{Username: "a"}, "usera"},
+	}
+
+	for _, test := range tests {
+		username := usernameFor(&test.identity)
+		if !strings.HasPrefix(username, test.prefix) || !auth.IsValidUsername(username) {
+			t.Errorf("usernameFor(%+v) = %q; want a valid username starting with %q", test.identity, username, test.prefix)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package sso signs users in with external identity providers: OpenID Connect providers like Google or Keycloak,
+// and GitHub, which only supports plain OAuth 2.0.
+package sso
+
+import (
+	"context"
+	"sync"
+
+	"github.com/joangavelan/contacts-app/config"
+)
+
+// Identity is the account a user signed in with at a provider.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
+
+// Provider is an identity provider users can sign in with.
+type Provider interface {
+	Name() string
+	DisplayName() string
+	// AuthCodeURL returns the URL to send the user to for signing in.
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	// Identify finishes the sign in with the code the provider redirected back with.
+	Identify(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers []Provider
+)
+
+// Configure registers the configured providers.
+func Configure(configs []config.OIDCProvider) {
+	for _, c := range configs {
+		if c.Name == "github" {
+			Register(NewGitHubProvider(c))
+		} else {
+			Register(NewOIDCProvider(c))
+		}
+	}
+}
+
+// Register adds a provider, replacing any provider with the same name.
+func Register(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, existing := range providers {
+		if existing.Name() == p.Name() {
+			providers[i] = p
+			return
+		}
+	}
+	providers = append(providers, p)
+}
+
+// Providers returns the registered providers in the order they were registered.
+func Providers() []Provider {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return append([]Provider(nil), providers...)
+}
+
+// Lookup returns the provider with the given name.
+func Lookup(name string) (Provider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, p := range providers {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,196 @@
+// Package templates parses the HTML templates once and composes the sets handlers render from them.
+//
+// Templates are named by their path under web/templates, like "pages/contacts/contacts.html". A set is a
+// layout or fragment followed by the files it uses, and executes the first of them, as template.ParseFiles
+// does. Pages also get every partial in commons/, such as the header and the toasts.
+//
+// In production the templates are parsed from the file system embedded in the binary when the registry is
+// created. In development they are read from disk and parsed again whenever one of them changes.
+package templates
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template/parse"
+	"time"
+)
+
+// partialsDir holds the partials included in every page.
+const partialsDir = "commons"
+
+// Registry holds the parsed templates and the sets composed from them.
+type Registry struct {
+	fsys   fs.FS
+	funcs  template.FuncMap
+	reload bool
+
+	mu       sync.Mutex
+	files    map[string]*template.Template
+	partials []string
+	sets     map[string]*template.Template
+	version  string
+}
+
+// New parses the templates of fsys. Funcs are the custom functions templates may call, and must include
+// placeholders for the functions bound to a request, which are added to the set executed for it.
+func New(fsys fs.FS, funcs template.FuncMap) (*Registry, error) {
+	reg := &Registry{fsys: fsys, funcs: funcs}
+	if err := reg.load(); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// Dir parses the templates in dir, and parses them again whenever one of them is added, changed or removed.
+func Dir(dir string, funcs template.FuncMap) (*Registry, error) {
+	reg := &Registry{fsys: os.DirFS(dir), funcs: funcs, reload: true}
+	if err := reg.load(); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// Page returns the set of a full page, starting with its layout, along with the partials.
+func (reg *Registry) Page(files ...string) (*template.Template, error) {
+	return reg.lookup(true, files)
+}
+
+// Partial returns the set of a fragment swapped into a page, starting with the template to execute.
+func (reg *Registry) Partial(files ...string) (*template.Template, error) {
+	return reg.lookup(false, files)
+}
+
+// lookup returns a clone of the set, composing it on first use. Clones can be given the functions of
+// a request and executed, which the cached set never is so it can go on being cloned.
+func (reg *Registry) lookup(page bool, files []string) (*template.Template, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no templates given")
+	}
+
+	reg.mu.Lock()
+	if reg.reload {
+		if err := reg.refresh(); err != nil {
+			reg.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	key := fmt.Sprint(page, files)
+	set, ok := reg.sets[key]
+	if !ok {
+		var err error
+		if set, err = reg.compose(page, files); err != nil {
+			reg.mu.Unlock()
+			return nil, err
+		}
+		reg.sets[key] = set
+	}
+	reg.mu.Unlock()
+
+	return set.Clone()
+}
+
+// compose builds a set from the parse trees of its files. Each set gets copies of the trees, since executing
+// an HTML template escapes them in place. Like template.ParseFiles, later files redefine the templates of
+// earlier ones, unless they define them as empty.
+func (reg *Registry) compose(page bool, files []string) (*template.Template, error) {
+	set := template.New(path.Base(files[0])).Funcs(reg.funcs)
+
+	names := files
+	if page {
+		names = append(append([]string{}, reg.partials...), files...)
+	}
+
+	for _, name := range names {
+		file, ok := reg.files[name]
+		if !ok {
+			return nil, fmt.Errorf("template %s not found", name)
+		}
+		for _, t := range file.Templates() {
+			if t.Tree == nil || (parse.IsEmptyTree(t.Tree.Root) && set.Lookup(t.Name()) != nil) {
+				continue
+			}
+			if _, err := set.AddParseTree(t.Name(), t.Tree.Copy()); err != nil {
+				return nil, fmt.Errorf("error adding %s: %w", name, err)
+			}
+		}
+	}
+
+	return set, nil
+}
+
+// load parses every template of the file system, replacing the sets composed so far.
+func (reg *Registry) load() error {
+	files := make(map[string]*template.Template)
+	var partials []string
+
+	err := fs.WalkDir(reg.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".html" {
+			return err
+		}
+
+		data, err := fs.ReadFile(reg.fsys, name)
+		if err != nil {
+			return err
+		}
+		t, err := template.New(path.Base(name)).Funcs(reg.funcs).Parse(string(data))
+		if err != nil {
+			return err
+		}
+
+		files[name] = t
+		if path.Dir(name) == partialsDir {
+			partials = append(partials, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error parsing templates: %w", err)
+	}
+
+	sort.Strings(partials)
+	reg.files, reg.partials = files, partials
+	reg.sets = make(map[string]*template.Template)
+	if reg.reload {
+		reg.version, err = reg.stat()
+	}
+	return err
+}
+
+// refresh parses the templates again if any of them changed since they were last parsed.
+func (reg *Registry) refresh() error {
+	version, err := reg.stat()
+	if err != nil {
+		return err
+	}
+	if version == reg.version {
+		return nil
+	}
+	return reg.load()
+}
+
+// stat sums up the names and modification times of the templates, to tell whether any of them changed.
+func (reg *Registry) stat() (string, error) {
+	var version strings.Builder
+	err := fs.WalkDir(reg.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&version, "%s %d %s\n", name, info.Size(), info.ModTime().Format(time.RFC3339Nano))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error reading templates: %w", err)
+	}
+	return version.String(), nil
+}
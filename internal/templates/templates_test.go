@@ -0,0 +1,108 @@
+package templates
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/joangavelan/contacts-app/web"
+)
+
+var funcs = template.FuncMap{"greeting": func() string { return "" }}
+
+func execute(t *testing.T, tmpl *template.Template, err error) string {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, "Ada"); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestRegistry(t *testing.T) {
+	reg, err := New(fstest.MapFS{
+		"layouts/base.html":   {Data: []byte(`{{ template "header" }}[{{ template "app" . }}]`)},
+		"commons/header.html": {Data: []byte(`{{ define "header" }}<h1>{{ greeting }}</h1>{{ end }}`)},
+		"pages/home.html":     {Data: []byte(`{{ define "app" }}Hi {{ . }}{{ end }}`)},
+		"pages/row.html":      {Data: []byte(`{{ block "row" . }}<li>{{ . }}</li>{{ end }}`)},
+		"pages/override.html": {Data: []byte(`{{ define "row" }}<tr>{{ . }}</tr>{{ end }}`)},
+	}, funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Pages get the partials", func(t *testing.T) {
+		tmpl, err := reg.Page("layouts/base.html", "pages/home.html")
+		tmpl = tmpl.Funcs(template.FuncMap{"greeting": func() string { return "Welcome" }})
+		if got := execute(t, tmpl, err); got != "<h1>Welcome</h1>[Hi Ada]" {
+			t.Errorf("expected the page with its header, got %q", got)
+		}
+	})
+
+	t.Run("Later files redefine templates", func(t *testing.T) {
+		tmpl, err := reg.Partial("pages/row.html", "pages/override.html")
+		if got := execute(t, tmpl, err); got != "<tr>Ada</tr>" {
+			t.Errorf("expected the row to be redefined, got %q", got)
+		}
+
+		tmpl, err = reg.Partial("pages/row.html")
+		if got := execute(t, tmpl, err); got != "<li>Ada</li>" {
+			t.Errorf("expected other sets to keep the original row, got %q", got)
+		}
+	})
+
+	t.Run("Missing files are errors", func(t *testing.T) {
+		if _, err := reg.Partial("pages/missing.html"); err == nil {
+			t.Errorf("expected an error for a missing template")
+		}
+	})
+}
+
+func TestEmbedded(t *testing.T) {
+	if _, err := New(web.Templates, template.FuncMap{
+		"csrfToken":     func() string { return "" },
+		"currentUser":   func() any { return nil },
+		"organizations": func() any { return nil },
+		"flashes":       func() any { return nil },
+		"asset":         func(string) string { return "" },
+	}); err != nil {
+		t.Errorf("expected the embedded templates to parse, got %v", err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "row.html")
+	write := func(content string, modTime time.Time) {
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	write(`<li>{{ . }}</li>`, now)
+	reg, err := Dir(dir, funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := reg.Partial("row.html")
+	if got := execute(t, tmpl, err); got != "<li>Ada</li>" {
+		t.Fatalf("expected the row, got %q", got)
+	}
+
+	write(`<tr>{{ . }}</tr>`, now.Add(time.Second))
+	tmpl, err = reg.Partial("row.html")
+	if got := execute(t, tmpl, err); got != "<tr>Ada</tr>" {
+		t.Errorf("expected the changed row to be parsed again, got %q", got)
+	}
+}
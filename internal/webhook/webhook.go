@@ -0,0 +1,327 @@
+// Package webhook sends the contact events queued in the database to the webhooks users registered.
+// Payloads are signed with the webhook's secret, and failed deliveries are retried with exponential backoff.
+//
+// Webhooks are only sent to public addresses, so users can't have the server reach its own network. Hosts
+// are checked when the webhook is registered and again when each delivery connects, since a name may
+// resolve to another address by then.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joangavelan/contacts-app/config"
+	"github.com/joangavelan/contacts-app/internal/database"
+	"github.com/joangavelan/contacts-app/internal/models"
+)
+
+// Headers sent with every delivery.
+const (
+	// SignatureHeader carries the signature of the payload, see Sign.
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader carries the Unix time the delivery was sent at, which is signed along with the payload
+	// so receivers can reject old deliveries being replayed.
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	// DeliveryHeader carries the ID of the delivery, which stays the same across retries.
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// batchSize caps how many deliveries are sent each time the queue is checked.
+const batchSize = 50
+
+// maxErrorLength caps how much of an error is kept in the delivery log.
+const maxErrorLength = 200
+
+// ErrInvalidURL is returned when registering a webhook whose URL isn't an absolute http or https URL.
+var ErrInvalidURL = errors.New("invalid webhook url")
+
+// ErrPrivateAddress is returned when a webhook's host is, or resolves to, a loopback, private, link-local,
+// unspecified or multicast address.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// deniedNetworks are special-use networks that pass for global unicast but aren't public, or that embed IPv4
+// addresses which may be private. Webhooks are never sent to them.
+var deniedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT, often routed to cloud infrastructure
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // Discard-only
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, including Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+}
+
+// allowedNetworks are networks webhooks may be sent to even though they aren't public,
+// which tests set to reach their local receivers.
+var allowedNetworks []netip.Prefix
+
+// ErrUnknownEvent is returned when registering a webhook for an event that doesn't exist.
+var ErrUnknownEvent = errors.New("unknown webhook event")
+
+// secretBytes is the length of the random part of webhook secrets.
+const secretBytes = 24
+
+// Register stores a webhook of the user for the events, with a new random secret to sign its payloads with.
+func Register(db *sql.DB, userId int64, rawURL string, events []string) (*models.Webhook, error) {
+	if !validURL(rawURL) {
+		return nil, ErrInvalidURL
+	}
+	if privateHost(rawURL) {
+		return nil, ErrPrivateAddress
+	}
+	for _, event := range events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return nil, ErrUnknownEvent
+		}
+	}
+
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("error generating webhook secret: %w", err)
+	}
+
+	webhook := &models.Webhook{
+		UserId:    userId,
+		URL:       rawURL,
+		Secret:    "whsec_" + base64.RawURLEncoding.EncodeToString(b),
+		Events:    events,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := database.CreateWebhook(db, webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// validURL reports whether raw is an absolute http or https URL without credentials.
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.User == nil
+}
+
+// privateHost reports whether the host of a valid URL is a local name or an address that isn't public.
+// Other names are checked once they resolve, when deliveries connect.
+func privateHost(raw string) bool {
+	u, _ := url.Parse(raw)
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && !publicAddr(addr)
+}
+
+// publicAddr reports whether webhooks may be sent to addr: it's a global unicast address outside the private
+// ranges and the denied networks, or in one of the allowed networks.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, network := range deniedNetworks {
+		if network.Contains(addr) {
+			return false
+		}
+	}
+	for _, network := range allowedNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	// Loopback, link-local, unspecified and multicast addresses aren't global unicast.
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// checkAddress refuses connections to addresses that aren't public. Dialers call it with the resolved
+// address, after DNS, so names that resolve to a private address are caught however they were registered.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addr)
+	}
+	return nil
+}
+
+// Sign returns the signature of a payload sent at timestamp: the hex-encoded HMAC-SHA256 of
+// "<timestamp>.<payload>" keyed with the secret, prefixed with "sha256=".
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of a payload sent at timestamp, in constant time.
+func Verify(secret, signature string, timestamp int64, payload []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, payload)))
+}
+
+// Backoff returns how long to wait before retrying a delivery that failed attempts times in a row.
+func Backoff(attempts int) time.Duration {
+	delay := config.WebhookBackoffBase
+	for i := 1; i < attempts && delay < config.WebhookBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, config.WebhookBackoffMax)
+}
+
+// Dispatcher sends due deliveries from the queue. Only one should run against a database,
+// since deliveries aren't claimed before being sent.
+type Dispatcher struct {
+	DB     *sql.DB
+	Client *http.Client
+}
+
+// NewDispatcher returns a dispatcher whose client gives up on slow receivers and doesn't follow redirects,
+// which count as failures. It only connects to public addresses, and never through a proxy, which would
+// connect on its behalf.
+func NewDispatcher(db *sql.DB) *Dispatcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   config.WebhookTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   checkAddress,
+	}).DialContext
+
+	return &Dispatcher{
+		DB: db,
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   config.WebhookTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Run checks the queue every WebhookPollInterval until ctx is done. Deliveries left pending when
+// the app stopped are picked up again on the next start.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(config.WebhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now().UTC()
+		if _, err := d.DeliverDue(ctx, now); err != nil {
+			log.Printf("Error delivering webhooks: %v", err)
+		}
+		if err := database.PruneWebhookDeliveries(d.DB, now.Add(-config.WebhookDeliveryRetention)); err != nil {
+			log.Printf("Error pruning webhook deliveries: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends the deliveries due at now and returns how many it attempted.
+// Failures of the receivers are recorded on the deliveries rather than returned.
+func (d *Dispatcher) DeliverDue(ctx context.Context, now time.Time) (int, error) {
+	deliveries, err := database.ListDueWebhookDeliveries(d.DB, now, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		d.attempt(ctx, delivery, now)
+		if err := database.UpdateWebhookDelivery(d.DB, delivery); err != nil {
+			return i, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// attempt sends the delivery and records the outcome on it, scheduling a retry if it failed
+// and attempts remain.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery, now time.Time) {
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus, delivery.Error = d.send(ctx, delivery, now)
+
+	switch {
+	case delivery.Error == "":
+		delivery.Status = models.DeliverySucceeded
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= config.WebhookMaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(Backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+}
+
+// send posts the signed payload to the webhook. It returns the status of the response, if any,
+// and why the delivery failed, which is empty for 2xx responses. Response bodies are never read,
+// so receivers can't use the delivery log to show what the server reached.
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery, now time.Time) (int, string) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "contacts-app-webhooks")
+	req.Header.Set(SignatureHeader, Sign(delivery.Webhook.Secret, timestamp, payload))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, truncate(err.Error())
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, ""
+	}
+	return resp.StatusCode, truncate("Unexpected status " + resp.Status)
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		return strings.ToValidUTF8(s[:maxErrorLength], "")
+	}
+	return s
+}
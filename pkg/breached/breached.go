@@ -0,0 +1,146 @@
+// Package breached checks passwords against a local copy of a breached password corpus,
+// so no network access is needed and passwords never leave the server.
+//
+// The corpus is a directory laid out like the Have I Been Pwned range API: one file per
+// 5 character uppercase SHA-1 prefix (00000 to FFFFF), each listing the remaining 35 characters
+// of every breached hash starting with that prefix and how often it was seen, one per line:
+//
+//	0018A45C4D1DEF81644B54AB7F969B88D65:10
+//
+// Only the file for the password's prefix is read, so the full corpus never needs to fit in memory.
+// Use Build to create the directory from the downloadable "ordered by hash" dump.
+package breached
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const prefixLength = 5
+
+// Corpus is a directory of hash range files.
+type Corpus struct {
+	dir string
+}
+
+// Open returns the corpus stored in dir.
+func Open(dir string) (*Corpus, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening breached password corpus: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password corpus %s is not a directory", dir)
+	}
+
+	return &Corpus{dir: dir}, nil
+}
+
+// Count returns how many times password appears in the corpus, or zero if it was never breached.
+func (c *Corpus) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	f, err := os.Open(filepath.Join(c.dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil // Corpora may leave out empty ranges.
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error opening hash range %s: %w", prefix, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineSuffix, count, _ := strings.Cut(line, ":")
+		if !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return 1, nil // Listed without a usable count.
+		}
+		return n, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("error reading hash range %s: %w", prefix, err)
+	}
+
+	return 0, nil
+}
+
+// Build splits a list of "HASH:COUNT" lines sorted by hash into range files in dir.
+// Lines with a hash that isn't a full SHA-1 are skipped.
+func Build(r io.Reader, dir string) (hashes int, err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("error creating corpus directory: %w", err)
+	}
+
+	var (
+		current string
+		out     *bufio.Writer
+		file    *os.File
+	)
+
+	closeRange := func() error {
+		if file == nil {
+			return nil
+		}
+		if err := out.Flush(); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		hash, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+		hash = strings.ToUpper(hash)
+
+		if prefix := hash[:prefixLength]; prefix != current {
+			if err := closeRange(); err != nil {
+				return hashes, fmt.Errorf("error writing hash range %s: %w", current, err)
+			}
+
+			file, err = os.Create(filepath.Join(dir, prefix))
+			if err != nil {
+				return hashes, fmt.Errorf("error creating hash range %s: %w", prefix, err)
+			}
+			out = bufio.NewWriter(file)
+			current = prefix
+		}
+
+		if count == "" {
+			count = "1"
+		}
+		if _, err := fmt.Fprintf(out, "%s:%s\n", hash[prefixLength:], count); err != nil {
+			return hashes, fmt.Errorf("error writing hash range %s: %w", current, err)
+		}
+		hashes++
+	}
+	if err := scanner.Err(); err != nil {
+		return hashes, fmt.Errorf("error reading hashes: %w", err)
+	}
+
+	if err := closeRange(); err != nil {
+		return hashes, fmt.Errorf("error writing hash range %s: %w", current, err)
+	}
+
+	return hashes, nil
+}
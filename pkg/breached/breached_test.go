@@ -0,0 +1,56 @@
+package breached
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SHA-1 of "password" and "123456".
+const dump = `5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824
+7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195
+`
+
+func TestBuildAndCount(t *testing.T) {
+	dir := t.TempDir()
+
+	hashes, err := Build(strings.NewReader(dump+"not a hash\n"), dir)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if hashes != 2 {
+		t.Errorf("expected 2 hashes, got %d", hashes)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "5BAA6")); err != nil {
+		t.Errorf("expected a range file for prefix 5BAA6: %v", err)
+	}
+
+	corpus, err := Open(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	tests := map[string]int{
+		"password":                     9545824,
+		"123456":                       37359195,
+		"correct horse battery staple": 0,
+	}
+
+	for password, want := range tests {
+		count, err := corpus.Count(password)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if count != want {
+			t.Errorf("Count(%q) = %d; want %d", password, count, want)
+		}
+	}
+}
+
+func TestOpen_MissingDirectory(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,93 @@
+// Package jsonapi writes JSON responses, with errors shaped like JSON:API error objects:
+//
+//	{"errors": [{"status": "404", "code": "not_found", "title": "Not Found", "detail": "Contact 7 does not exist"}]}
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const (
+	ContentType = "application/json"
+	// MaxBodySize limits request bodies read by Read.
+	MaxBodySize = 1 << 20
+)
+
+// Error is a JSON:API error object.
+type Error struct {
+	Status string       `json:"status"`
+	Code   string       `json:"code,omitempty"`
+	Title  string       `json:"title"`
+	Detail string       `json:"detail,omitempty"`
+	Source *ErrorSource `json:"source,omitempty"`
+}
+
+// ErrorSource points to the part of the request that caused an error.
+type ErrorSource struct {
+	// Pointer is a JSON pointer into the request body, like /email.
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Header    string `json:"header,omitempty"`
+}
+
+// NewError returns an error object for the status, titled with the status text.
+func NewError(status int, code, detail string) Error {
+	return Error{
+		Status: strconv.Itoa(status),
+		Code:   code,
+		Title:  http.StatusText(status),
+		Detail: detail,
+	}
+}
+
+// Write writes v as the JSON response body.
+func Write(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// WriteError writes a single error object.
+func WriteError(w http.ResponseWriter, status int, code, detail string) {
+	WriteErrors(w, status, NewError(status, code, detail))
+}
+
+// WriteErrors writes the error objects, which should share the response status.
+func WriteErrors(w http.ResponseWriter, status int, errs ...Error) {
+	Write(w, status, struct {
+		Errors []Error `json:"errors"`
+	}{errs})
+}
+
+// Read decodes the JSON request body into v, rejecting unknown fields.
+// If the body can't be decoded it writes an error response and returns false.
+func Read(w http.ResponseWriter, r *http.Request, v any) bool {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != ContentType {
+		WriteError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Request body must be "+ContentType)
+		return false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			WriteError(w, http.StatusRequestEntityTooLarge, "request_too_large", "Request body is too large")
+			return false
+		}
+
+		WriteError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON: "+err.Error())
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// LogMailer writes messages to an io.Writer instead of delivering them.
+// It is meant for development and tests.
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogMailer creates a LogMailer writing to w.
+func NewLogMailer(w io.Writer) *LogMailer {
+	return &LogMailer{w: w}
+}
+
+// NewFileMailer creates a LogMailer appending to the file at path.
+func NewFileMailer(path string) (*LogMailer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("mailer file path is empty")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening mailer file: %w", err)
+	}
+
+	return NewLogMailer(f), nil
+}
+
+// Send writes the message to the underlying writer.
+func (m *LogMailer) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "To: %s\nSubject: %s\n\n%s\n---\n", msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("error writing mail: %w", err)
+	}
+
+	return nil
+}
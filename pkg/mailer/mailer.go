@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Message is a plain text email ready to be delivered.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default is the mailer used by the application handlers.
+// It logs messages to stdout until replaced, typically with the result of FromEnv.
+var Default Mailer = NewLogMailer(os.Stdout)
+
+var errHeaderInjection = errors.New("mail header contains a line break")
+
+// validate rejects messages whose headers could be used to inject extra headers.
+func (m Message) validate() error {
+	if m.To == "" {
+		return errors.New("mail recipient is empty")
+	}
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return errHeaderInjection
+	}
+	return nil
+}
+
+// FromEnv builds a Mailer from the MAILER_DRIVER environment variable.
+//
+//   - "smtp" delivers through SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM.
+//   - "file" appends messages to the file at MAILER_FILE.
+//   - "log" (the default) writes messages to stdout.
+func FromEnv() (Mailer, error) {
+	switch driver := os.Getenv("MAILER_DRIVER"); driver {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}, nil
+	case "file":
+		return NewFileMailer(os.Getenv("MAILER_FILE"))
+	case "", "log":
+		return NewLogMailer(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver %q", driver)
+	}
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf)
+
+	err := m.Send(Message{To: "user@example.com", Subject: "Hello", Body: "Body text"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"To: user@example.com", "Subject: Hello", "Body text"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got %q", want, out)
+		}
+	}
+}
+
+func TestMessageHeaderInjection(t *testing.T) {
+	m := NewLogMailer(&bytes.Buffer{})
+
+	tests := []Message{
+		{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hi"},
+		{To: "user@example.com", Subject: "Hi\nBcc: victim@example.com"},
+		{To: "", Subject: "Hi"},
+	}
+
+	for _, msg := range tests {
+		if err := m.Send(msg); err == nil {
+			t.Errorf("expected an error for message %+v, got none", msg)
+		}
+	}
+}
+
+func TestSMTPMailerBuild(t *testing.T) {
+	m := &SMTPMailer{From: "noreply@example.com"}
+	date := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	raw := string(m.build(Message{To: "user@example.com", Subject: "Hello", Body: "Body text"}, date))
+
+	for _, want := range []string{
+		"From: noreply@example.com\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Hello\r\n",
+		"Date: Sat, 01 Jun 2024 12:00:00 +0000\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\nBody text",
+	} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("expected message to contain %q, got %q", want, raw)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package oidc implements the relying party side of OpenID Connect's authorization code flow:
+// provider discovery, PKCE, the authorization redirect, the code exchange and ID token verification.
+//
+// A login goes through these steps:
+//
+//  1. Generate a state, a nonce and a PKCE code verifier with RandomString, and remember them
+//     in the user's browser, e.g. in a signed cookie.
+//  2. Redirect the user to AuthCodeURL.
+//  3. When the provider redirects back, check the state, call Exchange with the code and the verifier,
+//     and VerifyIDToken with the nonce.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+	ErrNoIDToken      = errors.New("token response has no id token")
+)
+
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Config identifies the application to a provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes requested in addition to openid. Defaults to email and profile.
+	Scopes     []string
+	HTTPClient *http.Client
+}
+
+// Metadata holds the provider endpoints published in its discovery document.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Token is the response of the token endpoint.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Provider is an OpenID provider the application is registered with.
+type Provider struct {
+	config   Config
+	metadata Metadata
+	keys     *keySet
+}
+
+// Discover fetches the provider's discovery document from its issuer URL.
+func Discover(ctx context.Context, config Config) (*Provider, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = defaultHTTPClient
+	}
+
+	wellKnown := strings.TrimRight(config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	var metadata Metadata
+	if err := getJSON(ctx, config.HTTPClient, wellKnown, "", &metadata); err != nil {
+		return nil, fmt.Errorf("error fetching discovery document: %w", err)
+	}
+
+	// The issuer must be exactly the one configured, or tokens from another issuer could be accepted.
+	if metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", metadata.Issuer, config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing required endpoints")
+	}
+
+	return NewProvider(config, metadata), nil
+}
+
+// NewProvider returns a provider with known endpoints, e.g. for OAuth 2.0 providers without discovery.
+// Without a JWKS URI, VerifyIDToken always fails.
+func NewProvider(config Config, metadata Metadata) *Provider {
+	if config.HTTPClient == nil {
+		config.HTTPClient = defaultHTTPClient
+	}
+	if config.Scopes == nil {
+		config.Scopes = []string{"email", "profile"}
+	}
+
+	return &Provider{
+		config:   config,
+		metadata: metadata,
+		keys:     newKeySet(metadata.JWKSURI, config.HTTPClient),
+	}
+}
+
+// Metadata returns the provider's endpoints.
+func (p *Provider) Metadata() Metadata {
+	return p.metadata
+}
+
+// AuthCodeURL returns the URL to send the user to for signing in.
+// The nonce is left out when empty, for OAuth 2.0 providers that don't issue ID tokens.
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	scopes := p.config.Scopes
+	if p.metadata.JWKSURI != "" {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if nonce != "" {
+		params.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange trades the authorization code for tokens.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.config.ClientID},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("error reading token response: %w", err)
+	}
+
+	// Some providers report errors with a 200 status, so the error field is checked either way.
+	var result struct {
+		Token
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding token response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("token endpoint error %s: %s", result.Error, result.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return nil, fmt.Errorf("unexpected token response with status %d", resp.StatusCode)
+	}
+
+	return &result.Token, nil
+}
+
+// getJSON fetches url and decodes the JSON response into v, authenticating with accessToken if it's set.
+func getJSON(ctx context.Context, client *http.Client, url, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// GetJSON fetches a JSON resource from the provider's API, like a userinfo endpoint, with the access token.
+func (p *Provider) GetJSON(ctx context.Context, url, accessToken string, v any) error {
+	return getJSON(ctx, p.config.HTTPClient, url, accessToken, v)
+}
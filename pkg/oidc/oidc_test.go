@@ -0,0 +1,134 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joangavelan/contacts-app/pkg/oidc"
+	"github.com/joangavelan/contacts-app/pkg/oidc/oidctest"
+)
+
+const redirectURL = "http://app.test/callback"
+
+func discover(t *testing.T, server *oidctest.Server) *oidc.Provider {
+	t.Helper()
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  redirectURL,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return provider
+}
+
+// authorize follows the authorization URL and returns the query the provider redirected back with.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), redirectURL) {
+		t.Fatalf("expected a redirect to %s, got %q (status %d)", redirectURL, resp.Header.Get("Location"), resp.StatusCode)
+	}
+	return location.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	server := oidctest.NewServer("client", "secret")
+	defer server.Close()
+	server.SetUser(oidctest.User{Subject: "42", Email: "ada@example.com", EmailVerified: true, Name: "Ada"})
+
+	provider := discover(t, server)
+	ctx := context.Background()
+
+	verifier, _ := oidc.RandomString()
+	callback := authorize(t, provider.AuthCodeURL("the-state", "the-nonce", verifier))
+	if callback.Get("state") != "the-state" {
+		t.Errorf("expected the state to be returned, got %q", callback.Get("state"))
+	}
+
+	if _, err := provider.Exchange(ctx, callback.Get("code"), "wrong-verifier"); err == nil {
+		t.Errorf("expected the exchange to fail with the wrong PKCE verifier")
+	}
+
+	callback = authorize(t, provider.AuthCodeURL("the-state", "the-nonce", verifier))
+	token, err := provider.Exchange(ctx, callback.Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, "the-nonce")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if claims.Subject != "42" || claims.Email != "ada@example.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	if _, err := provider.VerifyIDToken(ctx, token.IDToken, "other-nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("expected a nonce mismatch to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyIDToken_Rejects(t *testing.T) {
+	server := oidctest.NewServer("client", "secret")
+	defer server.Close()
+
+	provider := discover(t, server)
+	user := oidctest.User{Subject: "42", Email: "ada@example.com", EmailVerified: true}
+
+	tests := map[string]func(claims map[string]any) string{
+		"other audience": func(c map[string]any) string { c["aud"] = "other-client"; return server.Sign(c) },
+		"other issuer":   func(c map[string]any) string { c["iss"] = "https://evil.example.com"; return server.Sign(c) },
+		"expired":        func(c map[string]any) string { c["exp"] = time.Now().Add(-time.Hour).Unix(); return server.Sign(c) },
+		"no subject":     func(c map[string]any) string { c["sub"] = ""; return server.Sign(c) },
+		"multiple audiences without azp": func(c map[string]any) string {
+			c["aud"] = []string{"client", "other-client"}
+			return server.Sign(c)
+		},
+		"tampered claims": func(c map[string]any) string {
+			signature := strings.Split(server.Sign(c), ".")[2]
+			c["sub"] = "1"
+			parts := strings.Split(server.Sign(c), ".")
+			return parts[0] + "." + parts[1] + "." + signature
+		},
+		"unsigned": func(c map[string]any) string {
+			parts := strings.Split(server.Sign(c), ".")
+			return "eyJhbGciOiJub25lIn0." + parts[1] + "."
+		},
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(context.Background(), token(server.Claims(user, "nonce")), "nonce")
+			if !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDiscover_IssuerMismatch(t *testing.T) {
+	server := oidctest.NewServer("client", "secret")
+	defer server.Close()
+
+	_, err := oidc.Discover(context.Background(), oidc.Config{Issuer: server.URL + "/", ClientID: "client"})
+	if err == nil {
+		t.Errorf("expected an issuer that doesn't match the discovery document to be rejected")
+	}
+}
@@ -0,0 +1,96 @@
+package passhash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testParams keep the tests fast; they aren't meant for production use.
+var testParams = Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashAndVerify(t *testing.T) {
+	h := New(testParams)
+
+	hash, err := h.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected hash format %q", hash)
+	}
+
+	if err := h.Verify("correct horse battery staple", hash); err != nil {
+		t.Errorf("expected password to match, got %v", err)
+	}
+
+	if err := h.Verify("wrong password", hash); !errors.Is(err, ErrMismatchedHashAndPassword) {
+		t.Errorf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+
+	// Passwords longer than bcrypt's 72 byte limit aren't truncated.
+	long := strings.Repeat("a", 80)
+	hash, _ = h.Hash(long + "b")
+	if err := h.Verify(long+"c", hash); !errors.Is(err, ErrMismatchedHashAndPassword) {
+		t.Errorf("expected passwords differing after 72 bytes not to match, got %v", err)
+	}
+}
+
+func TestVerify_Bcrypt(t *testing.T) {
+	h := New(testParams)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := h.Verify("password", string(hash)); err != nil {
+		t.Errorf("expected bcrypt hash to match, got %v", err)
+	}
+
+	if err := h.Verify("wrong", string(hash)); !errors.Is(err, ErrMismatchedHashAndPassword) {
+		t.Errorf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+}
+
+func TestVerify_InvalidHashes(t *testing.T) {
+	h := New(testParams)
+
+	tests := map[string]error{
+		"plaintext": ErrUnsupportedHash,
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5":  ErrUnsupportedHash,
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5": ErrUnsupportedHash,
+		"$argon2id$v=19$m=1024$c2FsdA$a2V5":         ErrMalformedHash,
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA":      ErrMalformedHash,
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5":    ErrMalformedHash,
+	}
+
+	for hash, want := range tests {
+		if err := h.Verify("password", hash); !errors.Is(err, want) {
+			t.Errorf("Verify(%q) = %v; want %v", hash, err, want)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	h := New(testParams)
+
+	hash, _ := h.Hash("password")
+	if h.NeedsRehash(hash) {
+		t.Errorf("expected hash with current parameters not to need a rehash")
+	}
+
+	stronger := testParams
+	stronger.Iterations = 2
+	if !New(stronger).NeedsRehash(hash) {
+		t.Errorf("expected hash with outdated parameters to need a rehash")
+	}
+
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if !h.NeedsRehash(string(bcryptHash)) {
+		t.Errorf("expected bcrypt hash to need a rehash")
+	}
+}
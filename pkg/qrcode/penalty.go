@@ -0,0 +1,94 @@
+package qrcode
+
+// Penalty weights from the mask evaluation rules of the specification.
+const (
+	penaltyN1 = 3
+	penaltyN2 = 3
+	penaltyN3 = 40
+	penaltyN4 = 10
+)
+
+// finderLike is the 1:1:3:1:1 ratio pattern preceded or followed by four light modules.
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penaltyScore evaluates how hard the current symbol is to read. Lower is better.
+func (c *Code) penaltyScore() int {
+	result := 0
+
+	// Rules 1 and 3 on rows and columns
+	for i := 0; i < c.Size; i++ {
+		row := make([]bool, c.Size)
+		col := make([]bool, c.Size)
+		for j := 0; j < c.Size; j++ {
+			row[j] = c.modules[i][j]
+			col[j] = c.modules[j][i]
+		}
+		result += runPenalty(row) + finderPenalty(row)
+		result += runPenalty(col) + finderPenalty(col)
+	}
+
+	// Rule 2: 2x2 blocks of the same color
+	for y := 0; y < c.Size-1; y++ {
+		for x := 0; x < c.Size-1; x++ {
+			color := c.modules[y][x]
+			if color == c.modules[y][x+1] && color == c.modules[y+1][x] && color == c.modules[y+1][x+1] {
+				result += penaltyN2
+			}
+		}
+	}
+
+	// Rule 4: balance of dark and light modules
+	dark := 0
+	for _, row := range c.modules {
+		for _, module := range row {
+			if module {
+				dark++
+			}
+		}
+	}
+	total := c.Size * c.Size
+	k := abs(dark*20-total*10) / total
+	result += k * penaltyN4
+
+	return result
+}
+
+// runPenalty penalizes runs of five or more modules of the same color.
+func runPenalty(line []bool) int {
+	result := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += penaltyN1 + run - 5
+		}
+		run = 1
+	}
+	return result
+}
+
+// finderPenalty penalizes patterns that look like finder patterns.
+func finderPenalty(line []bool) int {
+	result := 0
+	for i := 0; i+11 <= len(line); i++ {
+		for _, pattern := range finderLike {
+			match := true
+			for j, dark := range pattern {
+				if line[i+j] != dark {
+					match = false
+					break
+				}
+			}
+			if match {
+				result += penaltyN3
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// "HELLO WORLD" at version 1-M, from the worked example of the specification
+	data := []byte{0x20, 0x5B, 0x0B, 0x78, 0xD1, 0x72, 0xDC, 0x4D, 0x43, 0x40, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xC4, 0x23, 0x27, 0x77, 0xEB, 0xD7, 0xE7, 0xE2, 0x5D, 0x17}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %X, got %X", want, got)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		data    string
+		level   Level
+		version int
+	}{
+		{"a", Medium, 1},
+		{strings.Repeat("a", 14), Medium, 1},
+		{strings.Repeat("a", 15), Medium, 2},
+		{strings.Repeat("a", 150), Medium, 8},
+		{strings.Repeat("a", 150), High, 12},
+	}
+
+	for _, test := range tests {
+		code, err := Encode([]byte(test.data), test.level)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if code.Version != test.version {
+			t.Errorf("Encode(%d bytes, %d): expected version %d, got %d", len(test.data), test.level, test.version, code.Version)
+		}
+		if code.Size != test.version*4+17 {
+			t.Errorf("expected size %d, got %d", test.version*4+17, code.Size)
+		}
+
+		// Each finder pattern has a dark center and a light separator ring.
+		for _, corner := range [][2]int{{3, 3}, {code.Size - 4, 3}, {3, code.Size - 4}} {
+			x, y := corner[0], corner[1]
+			if !code.Dark(x, y) || code.Dark(x+2, y) || !code.Dark(x+3, y) {
+				t.Errorf("expected finder pattern centered at (%d, %d)", x, y)
+			}
+		}
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	if _, err := Encode(make([]byte, 3000), Medium); err != ErrDataTooLong {
+		t.Errorf("expected ErrDataTooLong, got %v", err)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	code, err := EncodeString("otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP", Medium)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	svg := code.SVG()
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>") {
+		t.Errorf("expected an svg element, got %q", svg)
+	}
+
+	dim := code.Size + 2*quietZone
+	viewBox := fmt.Sprintf(`viewBox="0 0 %d %d"`, dim, dim)
+	if !strings.Contains(svg, viewBox) {
+		t.Errorf("expected svg to contain %s", viewBox)
+	}
+}
@@ -0,0 +1,30 @@
+package qrcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// quietZone is the light border, in modules, required around the symbol.
+const quietZone = 4
+
+// SVG renders the code as a scalable SVG image drawn with a single path.
+// The module size is one user unit, so the image scales with its width and height attributes.
+func (c *Code) SVG() string {
+	dim := c.Size + 2*quietZone
+
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+
+	return fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+			`<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		dim, dim, path.String(),
+	)
+}
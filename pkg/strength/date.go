@@ -0,0 +1,102 @@
+package strength
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	minYear = 1000
+	maxYear = 2050
+	// minYearSpace keeps years close to the reference year from looking too easy to guess.
+	minYearSpace = 20
+)
+
+var (
+	yearPattern          = regexp.MustCompile(`^(19|20)\d\d$`)
+	datePattern          = regexp.MustCompile(`^\d{4,8}$`)
+	separatedDatePattern = regexp.MustCompile(`^(\d{1,4})([\s/\\_.-])(\d{1,2})([\s/\\_.-])(\d{1,4})$`)
+)
+
+// referenceYear is the year dates and years are assumed to be close to.
+var referenceYear = time.Now().Year()
+
+// dateMatches finds years like 1987 and dates like 13/05/1987 or 130587.
+func dateMatches(password []rune) []Match {
+	var matches []Match
+	for i := range password {
+		for j := i + 3; j < len(password) && j-i < 10; j++ {
+			token := string(password[i : j+1])
+
+			if yearPattern.MatchString(token) {
+				matches = append(matches, Match{Pattern: Date, I: i, J: j, Token: token})
+				continue
+			}
+
+			if datePattern.MatchString(token) && len(token) >= 6 && isDigitDate(token) {
+				matches = append(matches, Match{Pattern: Date, I: i, J: j, Token: token})
+				continue
+			}
+
+			if parts := separatedDatePattern.FindStringSubmatch(token); parts != nil && parts[2] == parts[4] {
+				if isDate(atoi(parts[1]), atoi(parts[3]), atoi(parts[5])) {
+					matches = append(matches, Match{Pattern: Date, I: i, J: j, Token: token})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// isDigitDate reports whether some split of token into day, month and year makes a valid date.
+func isDigitDate(token string) bool {
+	for _, first := range []int{1, 2, 4} {
+		for _, second := range []int{1, 2} {
+			rest := len(token) - first - second
+			if rest < 1 || rest > 4 {
+				continue
+			}
+			if isDate(atoi(token[:first]), atoi(token[first:first+second]), atoi(token[first+second:])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDate reports whether the three numbers form a date in day-month-year, month-day-year or year-month-day order.
+func isDate(a, b, c int) bool {
+	return validDate(a, b, c) || validDate(b, a, c) || validDate(c, b, a)
+}
+
+func validDate(day, month, year int) bool {
+	if year < 100 {
+		year += 1900
+		if year < 1950 {
+			year += 100
+		}
+	}
+	return day >= 1 && day <= 31 && month >= 1 && month <= 12 && year >= minYear && year <= maxYear
+}
+
+// yearSpace is the number of years an attacker guessing year would have to try.
+func yearSpace(token string) float64 {
+	year := referenceYear
+	if yearPattern.MatchString(token) {
+		year = atoi(token)
+	}
+	return float64(max(abs(year-referenceYear), minYearSpace))
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+package strength
+
+import (
+	"strings"
+	"unicode"
+)
+
+const defaultSuggestion = "Add another word or two. Uncommon words are better."
+
+// feedback explains the weakest part of a password scoring below 3.
+// Like zxcvbn, it focuses on the longest pattern found, since that's what the attacker guesses first.
+func feedback(score int, sequence []Match) (string, []string) {
+	if score >= 3 {
+		return "", nil
+	}
+
+	var longest *Match
+	for i := range sequence {
+		m := &sequence[i]
+		if m.Pattern == Bruteforce {
+			continue
+		}
+		if longest == nil || len(m.Token) > len(longest.Token) {
+			longest = m
+		}
+	}
+
+	if longest == nil {
+		return "", []string{defaultSuggestion, "Use a longer password, or a few random words together."}
+	}
+
+	warning, suggestions := matchFeedback(*longest, len(sequence) == 1)
+	return warning, append([]string{defaultSuggestion}, suggestions...)
+}
+
+func matchFeedback(m Match, soleMatch bool) (string, []string) {
+	switch m.Pattern {
+	case Dictionary:
+		return dictionaryFeedback(m, soleMatch)
+	case Spatial:
+		if m.Turns == 1 {
+			return "Straight rows of keys are easy to guess", []string{"Use a longer keyboard pattern with more turns"}
+		}
+		return "Short keyboard patterns are easy to guess", []string{"Use a longer keyboard pattern with more turns"}
+	case Repeat:
+		if len([]rune(m.BaseToken)) == 1 {
+			return `Repeats like "aaa" are easy to guess`, []string{"Avoid repeated words and characters"}
+		}
+		return `Repeats like "abcabcabc" are only slightly harder to guess than "abc"`, []string{"Avoid repeated words and characters"}
+	case Sequence:
+		return "Sequences like abc or 6543 are easy to guess", []string{"Avoid sequences"}
+	case Date:
+		return "Dates and recent years are often easy to guess", []string{"Avoid dates and years that are associated with you"}
+	}
+	return "", nil
+}
+
+func dictionaryFeedback(m Match, soleMatch bool) (string, []string) {
+	var warning string
+	switch m.DictionaryName {
+	case Passwords:
+		switch {
+		case soleMatch && !m.L33t && !m.Reversed && m.Rank <= 10:
+			warning = "This is a top-10 common password"
+		case soleMatch && !m.L33t && !m.Reversed && m.Rank <= 100:
+			warning = "This is a top-100 common password"
+		case soleMatch && !m.L33t && !m.Reversed:
+			warning = "This is a very common password"
+		default:
+			warning = "This is similar to a commonly used password"
+		}
+	case English:
+		if soleMatch {
+			warning = "A word by itself is easy to guess"
+		}
+	case Names:
+		if soleMatch {
+			warning = "Names and surnames by themselves are easy to guess"
+		} else {
+			warning = "Common names and surnames are easy to guess"
+		}
+	case UserInputs:
+		warning = "Avoid using your name or email address in your password"
+	}
+
+	var suggestions []string
+	runes := []rune(m.Token)
+	if unicode.IsUpper(runes[0]) && uppercaseVariations(m.Token) == 2 && strings.ToUpper(m.Token) != m.Token {
+		suggestions = append(suggestions, "Capitalization doesn't help very much")
+	} else if strings.ToUpper(m.Token) == m.Token && strings.ToLower(m.Token) != m.Token {
+		suggestions = append(suggestions, "All-uppercase is almost as easy to guess as all-lowercase")
+	}
+	if m.Reversed && len(runes) >= 4 {
+		suggestions = append(suggestions, "Reversed words aren't much harder to guess")
+	}
+	if m.L33t {
+		suggestions = append(suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much")
+	}
+
+	return warning, suggestions
+}
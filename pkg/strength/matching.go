@@ -0,0 +1,333 @@
+package strength
+
+import (
+	"bufio"
+	"embed"
+	"strings"
+	"unicode"
+)
+
+// Pattern names, as used in Match.Pattern.
+const (
+	Dictionary = "dictionary"
+	Spatial    = "spatial"
+	Sequence   = "sequence"
+	Repeat     = "repeat"
+	Date       = "date"
+	Bruteforce = "bruteforce"
+)
+
+// Dictionary names, as used in Match.DictionaryName.
+const (
+	Passwords  = "passwords"
+	English    = "english"
+	Names      = "names"
+	UserInputs = "user_inputs"
+)
+
+// Match is a part of the password that follows a guessable pattern.
+type Match struct {
+	Pattern string
+	// I and J are the indexes of the first and last rune of the match.
+	I, J    int
+	Token   string
+	Guesses float64
+
+	// Dictionary matches
+	DictionaryName string
+	Rank           int
+	Reversed       bool
+	L33t           bool
+
+	// Spatial matches
+	Turns int
+
+	// Repeat matches
+	BaseToken   string
+	BaseGuesses float64
+}
+
+//go:embed data/*.txt
+var data embed.FS
+
+// rankedDictionaries map words to their rank, 1 being the most common.
+var rankedDictionaries = map[string]map[string]int{
+	Passwords: loadDictionary("data/passwords.txt"),
+	English:   loadDictionary("data/english.txt"),
+	Names:     loadDictionary("data/names.txt"),
+}
+
+func loadDictionary(name string) map[string]int {
+	f, err := data.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	ranked := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for rank := 1; scanner.Scan(); rank++ {
+		word := strings.TrimSpace(scanner.Text())
+		if _, ok := ranked[word]; !ok && word != "" {
+			ranked[word] = rank
+		}
+	}
+	return ranked
+}
+
+// l33tTable maps common substitutions to the letters they replace.
+var l33tTable = map[rune][]rune{
+	'4': {'a'}, '@': {'a'},
+	'8': {'b'},
+	'(': {'c'}, '{': {'c'}, '[': {'c'}, '<': {'c'},
+	'3': {'e'},
+	'6': {'g'}, '9': {'g'},
+	'1': {'i', 'l'}, '!': {'i'}, '|': {'i', 'l'},
+	'0': {'o'},
+	'$': {'s'}, '5': {'s'},
+	'+': {'t'}, '7': {'t', 'l'},
+	'%': {'x'},
+	'2': {'z'},
+}
+
+type matcher struct {
+	dictionaries map[string]map[string]int
+}
+
+func newMatcher(userInputs []string) *matcher {
+	dictionaries := make(map[string]map[string]int, len(rankedDictionaries)+1)
+	for name, dictionary := range rankedDictionaries {
+		dictionaries[name] = dictionary
+	}
+
+	inputs := make(map[string]int)
+	for i, word := range userInputWords(userInputs) {
+		if _, ok := inputs[word]; !ok && len(word) >= 3 {
+			inputs[word] = i + 1
+		}
+	}
+	dictionaries[UserInputs] = inputs
+
+	return &matcher{dictionaries: dictionaries}
+}
+
+// matches returns every guessable pattern found in password. They may overlap.
+func (m *matcher) matches(password []rune) []Match {
+	var matches []Match
+	matches = append(matches, m.dictionaryMatches(password)...)
+	matches = append(matches, m.reversedDictionaryMatches(password)...)
+	matches = append(matches, m.l33tMatches(password)...)
+	matches = append(matches, spatialMatches(password)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, m.repeatMatches(password)...)
+	matches = append(matches, dateMatches(password)...)
+
+	for i := range matches {
+		matches[i].Guesses = guessesFor(matches[i])
+	}
+	return matches
+}
+
+func (m *matcher) dictionaryMatches(password []rune) []Match {
+	lower := []rune(strings.ToLower(string(password)))
+
+	var matches []Match
+	for i := range lower {
+		for j := i + 2; j < len(lower); j++ {
+			word := string(lower[i : j+1])
+			for name, dictionary := range m.dictionaries {
+				if rank, ok := dictionary[word]; ok {
+					matches = append(matches, Match{
+						Pattern:        Dictionary,
+						I:              i,
+						J:              j,
+						Token:          string(password[i : j+1]),
+						DictionaryName: name,
+						Rank:           rank,
+					})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+func (m *matcher) reversedDictionaryMatches(password []rune) []Match {
+	reversed := reverse(password)
+
+	var matches []Match
+	for _, match := range m.dictionaryMatches(reversed) {
+		// Palindromes were already matched forwards.
+		if match.Token == string(reverse([]rune(match.Token))) {
+			continue
+		}
+		match.Reversed = true
+		match.I, match.J = len(password)-1-match.J, len(password)-1-match.I
+		match.Token = string(password[match.I : match.J+1])
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// l33tMatches finds dictionary words spelled with substitutions such as p@ssw0rd.
+// Characters with several meanings are tried with each of them in turn.
+func (m *matcher) l33tMatches(password []rune) []Match {
+	var matches []Match
+	for _, translated := range l33tTranslations(password) {
+		for _, match := range m.dictionaryMatches(translated) {
+			token := password[match.I : match.J+1]
+			if !containsL33t(token) {
+				continue
+			}
+			match.L33t = true
+			match.Token = string(token)
+			matches = append(matches, match)
+		}
+	}
+	return dedupe(matches)
+}
+
+// l33tTranslations returns the password with every substitution undone,
+// once for each alternative meaning of the ambiguous characters.
+func l33tTranslations(password []rune) [][]rune {
+	if !containsL33t(password) {
+		return nil
+	}
+
+	var translations [][]rune
+	for alternative := 0; alternative < 2; alternative++ {
+		translated := make([]rune, len(password))
+		for i, r := range password {
+			letters, ok := l33tTable[r]
+			switch {
+			case !ok:
+				translated[i] = r
+			case alternative < len(letters):
+				translated[i] = letters[alternative]
+			default:
+				translated[i] = letters[0]
+			}
+		}
+		translations = append(translations, translated)
+	}
+	return translations
+}
+
+func containsL33t(token []rune) bool {
+	for _, r := range token {
+		if _, ok := l33tTable[r]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sequenceMatches finds runs like abc, 6543 or acegi, where each character is a fixed step from the previous one.
+func sequenceMatches(password []rune) []Match {
+	const maxStep = 5
+
+	var matches []Match
+	for i := 0; i < len(password)-2; {
+		step := password[i+1] - password[i]
+		if step == 0 || step > maxStep || step < -maxStep || !sameClass(password[i], password[i+1]) {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j+1 < len(password) && password[j+1]-password[j] == step && sameClass(password[j], password[j+1]) {
+			j++
+		}
+
+		if j-i >= 2 {
+			matches = append(matches, Match{Pattern: Sequence, I: i, J: j, Token: string(password[i : j+1])})
+		}
+		i = j
+	}
+	return matches
+}
+
+func sameClass(a, b rune) bool {
+	return unicode.IsDigit(a) && unicode.IsDigit(b) ||
+		unicode.IsLower(a) && unicode.IsLower(b) ||
+		unicode.IsUpper(a) && unicode.IsUpper(b)
+}
+
+// repeatMatches finds repeated characters or groups of characters like aaa or abcabc.
+func (m *matcher) repeatMatches(password []rune) []Match {
+	var matches []Match
+	for i := 0; i < len(password)-1; {
+		bestEnd, bestBase := -1, 0
+		for base := 1; i+2*base <= len(password); base++ {
+			end := i + base
+			for end+base <= len(password) && string(password[end:end+base]) == string(password[i:i+base]) {
+				end += base
+			}
+			if end-i >= 2*base && end > bestEnd {
+				bestEnd, bestBase = end, base
+			}
+		}
+
+		if bestEnd < 0 {
+			i++
+			continue
+		}
+
+		matches = append(matches, Match{
+			Pattern:   Repeat,
+			I:         i,
+			J:         bestEnd - 1,
+			Token:     string(password[i:bestEnd]),
+			BaseToken: string(password[i : i+bestBase]),
+		})
+		i = bestEnd
+	}
+
+	// The base of a repeat is itself estimated, so abcabc costs about twice abc.
+	for k := range matches {
+		base := []rune(matches[k].BaseToken)
+		_, matches[k].BaseGuesses = mostGuessableSequence(base, m.matchesWithoutRepeats(base))
+	}
+	return matches
+}
+
+func (m *matcher) matchesWithoutRepeats(password []rune) []Match {
+	var matches []Match
+	matches = append(matches, m.dictionaryMatches(password)...)
+	matches = append(matches, m.reversedDictionaryMatches(password)...)
+	matches = append(matches, m.l33tMatches(password)...)
+	matches = append(matches, spatialMatches(password)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, dateMatches(password)...)
+
+	for i := range matches {
+		matches[i].Guesses = guessesFor(matches[i])
+	}
+	return matches
+}
+
+func reverse(runes []rune) []rune {
+	reversed := make([]rune, len(runes))
+	for i, r := range runes {
+		reversed[len(runes)-1-i] = r
+	}
+	return reversed
+}
+
+func dedupe(matches []Match) []Match {
+	type key struct {
+		i, j int
+		name string
+	}
+
+	seen := make(map[key]bool)
+	unique := matches[:0]
+	for _, match := range matches {
+		k := key{match.I, match.J, match.DictionaryName}
+		if !seen[k] {
+			seen[k] = true
+			unique = append(unique, match)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,230 @@
+package strength
+
+import (
+	"math"
+	"unicode"
+)
+
+const (
+	// bruteforceCardinality is the assumed number of possibilities per character not covered by a pattern.
+	bruteforceCardinality = 10
+	minGuessesSingleChar  = 10
+	minGuessesMultiChar   = 50
+	// minGuessesBeforeGrowingSequence penalizes splitting a password into many small patterns,
+	// since an attacker would have to try all those combinations.
+	minGuessesBeforeGrowingSequence = 10000
+)
+
+// guessesFor estimates the number of guesses needed to find the match on its own.
+func guessesFor(m Match) float64 {
+	length := len([]rune(m.Token))
+
+	var guesses float64
+	switch m.Pattern {
+	case Dictionary:
+		guesses = float64(m.Rank) * uppercaseVariations(m.Token)
+		if m.L33t {
+			guesses *= l33tVariations(m.Token)
+		}
+		if m.Reversed {
+			guesses *= 2
+		}
+	case Spatial:
+		guesses = spatialGuesses(m)
+	case Sequence:
+		guesses = sequenceGuesses(m.Token)
+	case Repeat:
+		guesses = m.BaseGuesses * float64(length/len([]rune(m.BaseToken)))
+	case Date:
+		guesses = yearSpace(m.Token)
+		if length > 4 {
+			guesses *= 365
+		}
+		if !datePattern.MatchString(m.Token) && !yearPattern.MatchString(m.Token) {
+			guesses *= 4 // separator
+		}
+	default:
+		guesses = math.Pow(bruteforceCardinality, float64(length))
+	}
+
+	minGuesses := float64(minGuessesMultiChar)
+	if length == 1 {
+		minGuesses = minGuessesSingleChar
+	}
+	return math.Max(guesses, minGuesses)
+}
+
+// uppercaseVariations is the number of ways the token could have been capitalized,
+// weighting the common "Capitalized", "ALL CAPS" and "endS" styles as cheap.
+func uppercaseVariations(token string) float64 {
+	var upper, lower int
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	runes := []rune(token)
+	switch {
+	case upper == 0:
+		return 1
+	case lower == 0, upper == 1 && (unicode.IsUpper(runes[0]) || unicode.IsUpper(runes[len(runes)-1])):
+		return 2
+	}
+
+	var variations float64
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += binomial(upper+lower, i)
+	}
+	return variations
+}
+
+// l33tVariations is the number of ways the substitutions in token could have been made.
+func l33tVariations(token string) float64 {
+	var substituted int
+	for _, r := range token {
+		if _, ok := l33tTable[r]; ok {
+			substituted++
+		}
+	}
+	return math.Max(2, math.Pow(2, float64(substituted))-1)
+}
+
+func spatialGuesses(m Match) float64 {
+	length := len([]rune(m.Token))
+
+	var guesses float64
+	for i := 2; i <= length; i++ {
+		for j := 1; j <= min(m.Turns, i-1); j++ {
+			guesses += binomial(i-1, j-1) * keyboardStartingPositions * math.Pow(keyboardAverageDegree, float64(j))
+		}
+	}
+
+	var shifted int
+	for _, r := range m.Token {
+		if qwertyKeys[r].shifted {
+			shifted++
+		}
+	}
+	if shifted > 0 {
+		unshifted := length - shifted
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			var variations float64
+			for i := 1; i <= min(shifted, unshifted); i++ {
+				variations += binomial(length, i)
+			}
+			guesses *= variations
+		}
+	}
+	return guesses
+}
+
+func sequenceGuesses(token string) float64 {
+	runes := []rune(token)
+	first := runes[0]
+
+	var base float64
+	switch {
+	case first == 'a' || first == 'A' || first == 'z' || first == 'Z' || first == '0' || first == '1' || first == '9':
+		base = 4 // obvious starting points
+	case unicode.IsDigit(first):
+		base = 10
+	default:
+		base = 26
+	}
+	if len(runes) > 1 && runes[1] < runes[0] {
+		base *= 2 // descending
+	}
+	return base * float64(len(runes))
+}
+
+// mostGuessableSequence finds the combination of non-overlapping matches covering the password
+// that needs the fewest guesses, filling any gaps with bruteforce matches.
+// It mirrors zxcvbn's search, where a sequence of l matches costs l! * product(guesses) plus a penalty for its length.
+func mostGuessableSequence(password []rune, matches []Match) ([]Match, float64) {
+	n := len(password)
+	if n == 0 {
+		return nil, 1
+	}
+
+	byEnd := make([][]Match, n)
+	for _, m := range matches {
+		byEnd[m.J] = append(byEnd[m.J], m)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			bruteforce := Match{Pattern: Bruteforce, I: i, J: j, Token: string(password[i : j+1])}
+			bruteforce.Guesses = guessesFor(bruteforce)
+			byEnd[j] = append(byEnd[j], bruteforce)
+		}
+	}
+
+	// product[k][l] is the lowest product of guesses of l matches covering password[:k+1],
+	// and last[k][l] the final match of that sequence.
+	product := make([]map[int]float64, n)
+	last := make([]map[int]Match, n)
+	for k := range product {
+		product[k] = make(map[int]float64)
+		last[k] = make(map[int]Match)
+	}
+
+	update := func(m Match, l int, p float64) {
+		if current, ok := product[m.J][l]; !ok || p < current {
+			product[m.J][l] = p
+			last[m.J][l] = m
+		}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range byEnd[k] {
+			if m.I == 0 {
+				update(m, 1, m.Guesses)
+				continue
+			}
+			for l, p := range product[m.I-1] {
+				update(m, l+1, p*m.Guesses)
+			}
+		}
+	}
+
+	bestLength, bestGuesses := 0, math.Inf(1)
+	for l, p := range product[n-1] {
+		guesses := factorial(l)*p + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		if guesses < bestGuesses || guesses == bestGuesses && l < bestLength {
+			bestLength, bestGuesses = l, guesses
+		}
+	}
+
+	sequence := make([]Match, bestLength)
+	for k, l := n-1, bestLength; l > 0; l-- {
+		m := last[k][l]
+		sequence[l-1] = m
+		k = m.I - 1
+	}
+
+	return sequence, bestGuesses
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+func factorial(n int) float64 {
+	result := 1.0
+	for i := 2; i <= n; i++ {
+		result *= float64(i)
+	}
+	return result
+}
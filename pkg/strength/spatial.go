@@ -0,0 +1,89 @@
+package strength
+
+// qwertyRows and their shifted counterparts describe the keyboard used to find walks like qwerty or zxcvbn.
+var (
+	qwertyRows = []string{
+		"`1234567890-=",
+		"qwertyuiop[]\\",
+		"asdfghjkl;'",
+		"zxcvbnm,./",
+	}
+	qwertyShiftedRows = []string{
+		"~!@#$%^&*()_+",
+		"QWERTYUIOP{}|",
+		"ASDFGHJKL:\"",
+		"ZXCVBNM<>?",
+	}
+)
+
+type keyPosition struct {
+	row, col int
+	shifted  bool
+}
+
+// keyboardStartingPositions and keyboardAverageDegree are used to estimate how many walks of a given length exist.
+const (
+	keyboardStartingPositions = 94
+	keyboardAverageDegree     = 4.6
+)
+
+var qwertyKeys = buildKeyPositions()
+
+func buildKeyPositions() map[rune]keyPosition {
+	keys := make(map[rune]keyPosition)
+	for row, keysInRow := range qwertyRows {
+		for col, r := range keysInRow {
+			keys[r] = keyPosition{row: row, col: col}
+		}
+	}
+	for row, keysInRow := range qwertyShiftedRows {
+		for col, r := range keysInRow {
+			keys[r] = keyPosition{row: row, col: col, shifted: true}
+		}
+	}
+	return keys
+}
+
+// adjacentDirection returns which of the six neighbors of a is b, or -1 if they aren't adjacent.
+// Rows are staggered, so each key touches two keys in the rows above and below it.
+func adjacentDirection(a, b keyPosition) int {
+	offsets := [][2]int{{0, -1}, {0, 1}, {-1, 0}, {-1, 1}, {1, -1}, {1, 0}}
+	for direction, offset := range offsets {
+		if b.row == a.row+offset[0] && b.col == a.col+offset[1] {
+			return direction
+		}
+	}
+	return -1
+}
+
+// spatialMatches finds walks of three or more adjacent keys.
+func spatialMatches(password []rune) []Match {
+	var matches []Match
+	for i := 0; i < len(password)-2; {
+		j, turns, lastDirection := i, 0, -1
+		for j+1 < len(password) {
+			a, okA := qwertyKeys[password[j]]
+			b, okB := qwertyKeys[password[j+1]]
+			if !okA || !okB {
+				break
+			}
+			direction := adjacentDirection(a, b)
+			if direction < 0 {
+				break
+			}
+			if direction != lastDirection {
+				turns++
+				lastDirection = direction
+			}
+			j++
+		}
+
+		if j-i >= 2 {
+			matches = append(matches, Match{Pattern: Spatial, I: i, J: j, Token: string(password[i : j+1]), Turns: turns})
+			i = j + 1
+		} else {
+			i++
+		}
+	}
+	return matches
+}
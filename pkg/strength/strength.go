@@ -0,0 +1,84 @@
+// Package strength estimates password strength in the style of Dropbox's zxcvbn.
+//
+// Instead of counting character classes, the password is split into the patterns an attacker
+// would try first: common passwords and words (optionally capitalized, reversed or l33t spelled),
+// keyboard walks, sequences, repeats and dates. The number of guesses needed to find the cheapest
+// combination of those patterns determines the score.
+package strength
+
+import (
+	"math"
+	"strings"
+)
+
+// maxLength bounds the work done for very long inputs. Anything beyond is counted as random characters.
+const maxLength = 100
+
+// Result is the strength estimate of a password.
+type Result struct {
+	// Guesses is the estimated number of guesses needed to find the password.
+	Guesses float64
+	// Score goes from 0 (too guessable) to 4 (very unguessable).
+	Score int
+	// Warning explains what makes the password weak. It may be empty.
+	Warning string
+	// Suggestions help the user pick a stronger password.
+	Suggestions []string
+	// Sequence is the cheapest way found to guess the password.
+	Sequence []Match
+}
+
+// Estimate returns the strength of password. userInputs such as the user's name or email are
+// treated as a dictionary, since attackers who target a user try them first.
+func Estimate(password string, userInputs ...string) Result {
+	runes := []rune(password)
+	if len(runes) > maxLength {
+		runes = runes[:maxLength]
+	}
+
+	m := newMatcher(userInputs)
+	sequence, guesses := mostGuessableSequence(runes, m.matches(runes))
+
+	if len(runes) < len([]rune(password)) {
+		guesses *= math.Pow(bruteforceCardinality, float64(len([]rune(password))-maxLength))
+	}
+
+	result := Result{
+		Guesses:  guesses,
+		Score:    score(guesses),
+		Sequence: sequence,
+	}
+	result.Warning, result.Suggestions = feedback(result.Score, sequence)
+
+	return result
+}
+
+// score converts a number of guesses to a 0-4 score using zxcvbn's thresholds,
+// which assume an online attack is throttled and an offline attack uses a slow hash.
+func score(guesses float64) int {
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// userInputWords splits inputs like emails into the words an attacker would try.
+func userInputWords(inputs []string) []string {
+	var words []string
+	for _, input := range inputs {
+		input = strings.ToLower(input)
+		words = append(words, input)
+		words = append(words, strings.FieldsFunc(input, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+		})...)
+	}
+	return words
+}
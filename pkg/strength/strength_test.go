@@ -0,0 +1,88 @@
+package strength
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimate_Scores(t *testing.T) {
+	tests := []struct {
+		password string
+		maxScore int
+		minScore int
+	}{
+		{"password", 0, 0},
+		{"p@ssw0rd", 0, 0},
+		{"drowssap", 0, 0},
+		{"qwertyuiop", 0, 0},
+		{"abcdefgh", 0, 0},
+		{"aaaaaaaaaa", 0, 0},
+		{"13/05/1987", 1, 0},
+		{"correcthorsebatterystaple", 4, 4},
+		{"xK9#mP2$vLq", 4, 4},
+	}
+
+	for _, test := range tests {
+		result := Estimate(test.password)
+		if result.Score < test.minScore || result.Score > test.maxScore {
+			t.Errorf("Estimate(%q).Score = %d; want between %d and %d", test.password, result.Score, test.minScore, test.maxScore)
+		}
+	}
+}
+
+func TestEstimate_Patterns(t *testing.T) {
+	tests := []struct {
+		password string
+		pattern  string
+		token    string
+	}{
+		{"Monkey", Dictionary, "Monkey"},
+		{"p@ssw0rd", Dictionary, "p@ssw0rd"},
+		{"zxcvbnm,./", Spatial, "zxcvbnm,./"},
+		{"13579", Sequence, "13579"},
+		{"abcabcabc", Repeat, "abcabcabc"},
+		{"19870513", Date, "19870513"},
+	}
+
+	for _, test := range tests {
+		sequence := Estimate(test.password).Sequence
+		if len(sequence) != 1 || sequence[0].Pattern != test.pattern || sequence[0].Token != test.token {
+			t.Errorf("Estimate(%q) matched %+v; want a single %s match", test.password, sequence, test.pattern)
+		}
+	}
+}
+
+func TestEstimate_UserInputs(t *testing.T) {
+	without := Estimate("kowalski1985")
+	with := Estimate("kowalski1985", "jan.kowalski@example.com")
+
+	if with.Guesses >= without.Guesses {
+		t.Errorf("expected the user's email to make the password easier to guess")
+	}
+	if with.Warning != "Avoid using your name or email address in your password" {
+		t.Errorf("unexpected warning %q", with.Warning)
+	}
+}
+
+func TestEstimate_Feedback(t *testing.T) {
+	result := Estimate("P@ssword")
+	if result.Warning != "This is similar to a commonly used password" {
+		t.Errorf("unexpected warning %q", result.Warning)
+	}
+	for _, want := range []string{"Capitalization doesn't help very much", "Predictable substitutions like '@' instead of 'a' don't help very much"} {
+		if !strings.Contains(strings.Join(result.Suggestions, "\n"), want) {
+			t.Errorf("expected suggestions %q to contain %q", result.Suggestions, want)
+		}
+	}
+
+	if result := Estimate("correcthorsebatterystaple"); result.Warning != "" || len(result.Suggestions) != 0 {
+		t.Errorf("expected no feedback for a strong password, got %q %q", result.Warning, result.Suggestions)
+	}
+}
+
+func TestEstimate_LongPassword(t *testing.T) {
+	result := Estimate(strings.Repeat("xK9#mP2$vL", 20))
+	if result.Score != 4 {
+		t.Errorf("expected a score of 4, got %d", result.Score)
+	}
+}
@@ -0,0 +1,126 @@
+// Package throttle tracks failed attempts per key in memory and delays further attempts
+// with exponential backoff, locking the key out entirely after too many failures.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how many failures are recorded between sweeps of expired entries.
+const sweepInterval = 1000
+
+// Policy configures how a Throttle reacts to failures.
+type Policy struct {
+	// FreeAttempts is the number of failures allowed before any delay is imposed.
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure beyond FreeAttempts. It doubles with each further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay.
+	MaxDelay time.Duration
+	// LockoutAfter is the number of failures that locks the key out for LockoutDuration.
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+type entry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// Throttle is safe for concurrent use.
+type Throttle struct {
+	policy Policy
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	writes  int
+}
+
+func New(policy Policy) *Throttle {
+	return &Throttle{
+		policy:  policy,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Check returns how long the caller must wait before attempting again for key, or zero if it may proceed.
+func (t *Throttle) Check(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return 0
+	}
+
+	if wait := e.blockedUntil.Sub(t.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Fail records a failed attempt for key and returns how long further attempts are blocked.
+func (t *Throttle) Fail(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	e, ok := t.entries[key]
+	if !ok || now.Sub(e.lastFailure) > t.policy.Window {
+		e = &entry{}
+		t.entries[key] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	switch {
+	case t.policy.LockoutAfter > 0 && e.failures >= t.policy.LockoutAfter:
+		e.blockedUntil = now.Add(t.policy.LockoutDuration)
+	case e.failures > t.policy.FreeAttempts:
+		e.blockedUntil = now.Add(t.backoff(e.failures - t.policy.FreeAttempts))
+	}
+
+	t.writes++
+	if t.writes%sweepInterval == 0 {
+		t.sweep(now)
+	}
+
+	return max(e.blockedUntil.Sub(now), 0)
+}
+
+// Reset forgets the failures recorded for key, typically after a successful attempt.
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// backoff returns the delay after the nth failure beyond the free attempts.
+func (t *Throttle) backoff(n int) time.Duration {
+	delay := t.policy.BaseDelay
+	for i := 1; i < n; i++ {
+		delay *= 2
+		if delay >= t.policy.MaxDelay {
+			return t.policy.MaxDelay
+		}
+	}
+	return min(delay, t.policy.MaxDelay)
+}
+
+// sweep removes entries that are neither blocked nor within the failure window, so memory stays bounded.
+func (t *Throttle) sweep(now time.Time) {
+	for key, e := range t.entries {
+		if now.After(e.blockedUntil) && now.Sub(e.lastFailure) > t.policy.Window {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    2,
+	BaseDelay:       time.Second,
+	MaxDelay:        8 * time.Second,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+// newTestThrottle returns a throttle whose clock only moves when the returned function is called.
+func newTestThrottle() (*Throttle, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t := New(testPolicy)
+	t.now = func() time.Time { return now }
+	return t, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestThrottle_Backoff(t *testing.T) {
+	th, advance := newTestThrottle()
+
+	for i := 0; i < testPolicy.FreeAttempts; i++ {
+		if wait := th.Fail("key"); wait != 0 {
+			t.Fatalf("expected no delay within the free attempts, got %v", wait)
+		}
+	}
+
+	for _, want := range []time.Duration{1, 2, 4, 8, 8} {
+		want *= time.Second
+		if wait := th.Fail("key"); wait != want {
+			t.Errorf("expected delay of %v, got %v", want, wait)
+		}
+		if wait := th.Check("key"); wait != want {
+			t.Errorf("expected Check to report %v, got %v", want, wait)
+		}
+		advance(want)
+		if wait := th.Check("key"); wait != 0 {
+			t.Errorf("expected the delay to be over, got %v", wait)
+		}
+	}
+
+	if wait := th.Check("other"); wait != 0 {
+		t.Errorf("expected other keys not to be affected, got %v", wait)
+	}
+}
+
+func TestThrottle_Lockout(t *testing.T) {
+	th, advance := newTestThrottle()
+
+	var wait time.Duration
+	for i := 0; i < testPolicy.LockoutAfter; i++ {
+		wait = th.Fail("key")
+	}
+
+	if wait != testPolicy.LockoutDuration {
+		t.Fatalf("expected lockout of %v, got %v", testPolicy.LockoutDuration, wait)
+	}
+
+	advance(testPolicy.LockoutDuration - time.Second)
+	if wait := th.Check("key"); wait != time.Second {
+		t.Errorf("expected key to still be locked out for 1s, got %v", wait)
+	}
+}
+
+func TestThrottle_ResetAndWindow(t *testing.T) {
+	th, advance := newTestThrottle()
+
+	for i := 0; i < 5; i++ {
+		th.Fail("key")
+	}
+	th.Reset("key")
+	if wait := th.Check("key"); wait != 0 {
+		t.Errorf("expected reset key to be allowed, got %v", wait)
+	}
+
+	for i := 0; i < 5; i++ {
+		th.Fail("key")
+	}
+	advance(testPolicy.Window + time.Second)
+	if wait := th.Fail("key"); wait != 0 {
+		t.Errorf("expected failures outside the window to be forgotten, got %v", wait)
+	}
+}
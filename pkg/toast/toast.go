@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 )
 
 const (
@@ -13,9 +15,17 @@ const (
 	ERROR   = "error"
 )
 
+// EventName is the event toasts are triggered with, which toast.js listens for.
+const EventName = "triggerToast"
+
 type Toast struct {
 	Variant string `json:"variant"`
 	Message string `json:"message"`
+	Title   string `json:"title,omitempty"`
+	// Duration is how long the toast is shown for, in milliseconds. Zero uses the default of the page.
+	Duration int64 `json:"duration,omitempty"`
+	// Dismissible toasts have a button to close them before their time is up.
+	Dismissible bool `json:"dismissible,omitempty"`
 }
 
 type TriggerToastEvent struct {
@@ -24,7 +34,7 @@ type TriggerToastEvent struct {
 
 // Creates a new Toast with the given variant and message.
 func new(variant, message string) Toast {
-	return Toast{variant, message}
+	return Toast{Variant: variant, Message: message}
 }
 
 func Info(message string) Toast {
@@ -43,6 +53,24 @@ func Error(message string) Toast {
 	return new(ERROR, message)
 }
 
+// WithTitle returns a copy of the toast with a title shown above its message.
+func (t Toast) WithTitle(title string) Toast {
+	t.Title = title
+	return t
+}
+
+// WithDuration returns a copy of the toast that is shown for d.
+func (t Toast) WithDuration(d time.Duration) Toast {
+	t.Duration = d.Milliseconds()
+	return t
+}
+
+// AsDismissible returns a copy of the toast that can be closed before its time is up.
+func (t Toast) AsDismissible() Toast {
+	t.Dismissible = true
+	return t
+}
+
 // ToJsonEvent constructs the "triggerToast" event in JSON format.
 func (t Toast) ToJsonEvent() ([]byte, error) {
 	event := TriggerToastEvent{t}
@@ -55,14 +83,155 @@ func (t Toast) ToJsonEvent() ([]byte, error) {
 	return jsonData, nil
 }
 
-// WriteToHeader writes the toast event to the HX-Trigger response header.
+// WriteToHeader adds the toast to the HX-Trigger response header, along with the toasts and events already there.
 func (t Toast) WriteToHeader(w http.ResponseWriter) error {
-	event, err := t.ToJsonEvent()
-	if err != nil {
+	if err := Collect(w).Toast(t); err != nil {
 		return fmt.Errorf("error writing toast event: %w", err)
 	}
 
-	w.Header().Set("HX-Trigger", string(event))
+	return nil
+}
+
+// Timing is the response header that triggers events at a given point of the swap.
+type Timing string
+
+const (
+	// Immediately triggers events as soon as the response is received.
+	Immediately Timing = "HX-Trigger"
+	// AfterSwap triggers events once the response has been swapped into the page.
+	AfterSwap Timing = "HX-Trigger-After-Swap"
+	// AfterSettle triggers events once the swapped content has settled.
+	AfterSettle Timing = "HX-Trigger-After-Settle"
+)
+
+// Collector merges the toasts and custom events of a response into one JSON object per timing header.
+// It keeps them in the headers themselves, so collectors made for the same response, including the ones
+// WriteToHeader uses, add to each other's events rather than overwrite them. Events must be added
+// before the response is written.
+type Collector struct {
+	w http.ResponseWriter
+}
+
+// Collect returns the collector of the response's events.
+func Collect(w http.ResponseWriter) *Collector {
+	return &Collector{w: w}
+}
+
+// Toast shows the toast as soon as the response is received.
+func (c *Collector) Toast(t Toast) error {
+	return c.ToastAt(Immediately, t)
+}
+
+// ToastAt shows the toast at the given timing. A single toast is sent as the detail of the "triggerToast"
+// event, and further ones turn it into an array of toasts, shown in the order they were added.
+func (c *Collector) ToastAt(timing Timing, t Toast) error {
+	return c.update(timing, func(events map[string]json.RawMessage) error {
+		toasts, err := decodeToasts(events)
+		if err != nil {
+			return err
+		}
+		toasts = append(toasts, t)
+
+		var detail any = toasts
+		if len(toasts) == 1 {
+			detail = t
+		}
+		return setEvent(events, EventName, detail)
+	})
+}
+
+// Trigger triggers a custom event with the detail, which may be nil, as soon as the response is received.
+func (c *Collector) Trigger(name string, detail any) error {
+	return c.TriggerAt(Immediately, name, detail)
+}
+
+// TriggerAt triggers a custom event at the given timing, replacing an event of the same name.
+func (c *Collector) TriggerAt(timing Timing, name string, detail any) error {
+	return c.update(timing, func(events map[string]json.RawMessage) error {
+		return setEvent(events, name, detail)
+	})
+}
 
+// Toasts returns the toasts added to the response at every timing, in the order the timings trigger them.
+func (c *Collector) Toasts() ([]Toast, error) {
+	var toasts []Toast
+	for _, timing := range []Timing{Immediately, AfterSwap, AfterSettle} {
+		events, err := c.read(timing)
+		if err != nil {
+			return nil, err
+		}
+		list, err := decodeToasts(events)
+		if err != nil {
+			return nil, err
+		}
+		toasts = append(toasts, list...)
+	}
+	return toasts, nil
+}
+
+// read returns the events of the timing header.
+// Headers set without a collector, as a comma separated list of event names, are read as events without detail.
+func (c *Collector) read(timing Timing) (map[string]json.RawMessage, error) {
+	events := make(map[string]json.RawMessage)
+
+	if header := strings.TrimSpace(c.w.Header().Get(string(timing))); strings.HasPrefix(header, "{") {
+		if err := json.Unmarshal([]byte(header), &events); err != nil {
+			return nil, fmt.Errorf("error reading %s header: %w", timing, err)
+		}
+	} else if header != "" {
+		for _, name := range strings.Split(header, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				events[name] = json.RawMessage("null")
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// update reads the events of the timing header, lets fn change them and writes them back.
+func (c *Collector) update(timing Timing, fn func(map[string]json.RawMessage) error) error {
+	events, err := c.read(timing)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(events); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("error marshalling %s header: %w", timing, err)
+	}
+	c.w.Header().Set(string(timing), string(data))
+
+	return nil
+}
+
+// decodeToasts returns the toasts of the "triggerToast" event, which is a single toast or an array of them.
+func decodeToasts(events map[string]json.RawMessage) ([]Toast, error) {
+	existing, ok := events[EventName]
+	if !ok {
+		return nil, nil
+	}
+
+	var toasts []Toast
+	if err := json.Unmarshal(existing, &toasts); err != nil {
+		var single Toast
+		if err := json.Unmarshal(existing, &single); err != nil {
+			return nil, fmt.Errorf("error reading toasts: %w", err)
+		}
+		toasts = []Toast{single}
+	}
+	return toasts, nil
+}
+
+func setEvent(events map[string]json.RawMessage, name string, detail any) error {
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return fmt.Errorf("error marshalling event %s: %w", name, err)
+	}
+	events[name] = data
 	return nil
 }
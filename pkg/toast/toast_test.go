@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestToast(t *testing.T) {
@@ -90,4 +91,76 @@ func TestToast(t *testing.T) {
 			t.Errorf("expected %s, got %s", toast.Message, event.TriggerToast.Message)
 		}
 	})
+	t.Run("Options are sent along with the toast", func(t *testing.T) {
+		toast := Warning("Test message").WithTitle("Heads up").WithDuration(5 * time.Second).AsDismissible()
+		jsonData, err := toast.ToJsonEvent()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		want := `{"triggerToast":{"variant":"warning","message":"Test message","title":"Heads up","duration":5000,"dismissible":true}}`
+		if string(jsonData) != want {
+			t.Errorf("expected %s, got %s", want, jsonData)
+		}
+	})
+}
+
+func TestCollector(t *testing.T) {
+	t.Run("Several toasts are merged into an array", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		if err := Success("First").WriteToHeader(recorder); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := Collect(recorder).Toast(Error("Second")); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := Info("Third").WriteToHeader(recorder); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var event struct {
+			TriggerToast []Toast `json:"triggerToast"`
+		}
+		if err := json.Unmarshal([]byte(recorder.Header().Get("HX-Trigger")), &event); err != nil {
+			t.Fatalf("expected no error unmarshalling, got %v", err)
+		}
+
+		if len(event.TriggerToast) != 3 || event.TriggerToast[0].Message != "First" || event.TriggerToast[2].Message != "Third" {
+			t.Errorf("expected the three toasts in order, got %+v", event.TriggerToast)
+		}
+	})
+
+	t.Run("Custom events are kept along with toasts, at their timing", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		recorder.Header().Set("HX-Trigger", "listChanged, formReset")
+
+		c := Collect(recorder)
+		if err := c.Toast(Success("Saved")); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := c.Trigger("contactSaved", map[string]int{"id": 7}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := c.TriggerAt(AfterSettle, "auditTrailChanged", nil); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := c.ToastAt(AfterSwap, Info("Swapped")); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		tests := []struct {
+			header string
+			want   string
+		}{
+			{"HX-Trigger", `{"contactSaved":{"id":7},"formReset":null,"listChanged":null,"triggerToast":{"variant":"success","message":"Saved"}}`},
+			{"HX-Trigger-After-Swap", `{"triggerToast":{"variant":"info","message":"Swapped"}}`},
+			{"HX-Trigger-After-Settle", `{"auditTrailChanged":null}`},
+		}
+
+		for _, tc := range tests {
+			if got := recorder.Header().Get(tc.header); got != tc.want {
+				t.Errorf("%s: expected %s, got %s", tc.header, tc.want, got)
+			}
+		}
+	})
 }
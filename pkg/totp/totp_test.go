@@ -0,0 +1,85 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAt(t *testing.T) {
+	// RFC 6238 Appendix B, truncated to 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, test := range tests {
+		code, err := CodeAt(rfcSecret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if code != test.code {
+			t.Errorf("CodeAt(%d) = %s; want %s", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	previous, _ := CodeAt(rfcSecret, now.Add(-Period))
+	old, _ := CodeAt(rfcSecret, now.Add(-3*Period))
+
+	if counter, ok := Validate(rfcSecret, "081804", now, 1); !ok || counter != Counter(now) {
+		t.Errorf("expected current code to be valid at counter %d, got %d, %v", Counter(now), counter, ok)
+	}
+
+	if counter, ok := Validate(rfcSecret, previous, now, 1); !ok || counter != Counter(now)-1 {
+		t.Errorf("expected previous code to be accepted within the drift window")
+	}
+
+	if _, ok := Validate(rfcSecret, old, now, 1); ok {
+		t.Errorf("expected code outside the drift window to be rejected")
+	}
+
+	if _, ok := Validate(rfcSecret, "12345", now, 1); ok {
+		t.Errorf("expected code with the wrong length to be rejected")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := CodeAt(secret, time.Now()); err != nil {
+		t.Errorf("expected generated secret to be usable, got %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Contacts App", "user@example.com", "JBSWY3DPEHPK3PXP")
+
+	for _, want := range []string{
+		"otpauth://totp/Contacts%20App:user@example.com?",
+		"secret=JBSWY3DPEHPK3PXP",
+		"issuer=Contacts%20App",
+		"digits=6",
+		"period=30",
+	} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("expected %q to contain %q", uri, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package useragent extracts the browser and operating system from User-Agent headers,
+// well enough to tell a user's devices apart. Unrecognized parts are left empty.
+package useragent
+
+import "strings"
+
+// Agent describes the software that sent a request.
+type Agent struct {
+	Browser string
+	OS      string
+}
+
+// token maps a substring of the header to a name. The first matching token wins,
+// so more specific ones come first: Edge and Opera also claim to be Chrome, which claims to be Safari.
+type token struct {
+	match string
+	name  string
+}
+
+var browsers = []token{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+	{"Wget/", "Wget"},
+	{"python-requests/", "Python Requests"},
+	{"Go-http-client/", "Go"},
+}
+
+var systems = []token{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// Parse returns the browser and operating system named by the User-Agent header.
+func Parse(header string) Agent {
+	return Agent{
+		Browser: find(browsers, header),
+		OS:      find(systems, header),
+	}
+}
+
+// String describes the agent for people, like "Firefox on Windows".
+func (a Agent) String() string {
+	switch {
+	case a.Browser != "" && a.OS != "":
+		return a.Browser + " on " + a.OS
+	case a.Browser != "":
+		return a.Browser
+	case a.OS != "":
+		return "Unknown browser on " + a.OS
+	default:
+		return "Unknown device"
+	}
+}
+
+func find(tokens []token, header string) string {
+	for _, t := range tokens {
+		if strings.Contains(header, t.match) {
+			return t.name
+		}
+	}
+	return ""
+}
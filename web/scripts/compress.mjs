@@ -0,0 +1,33 @@
+// Writes brotli and gzip variants next to the static assets, for the server to embed and send to browsers
+// that accept them. Variants are named after the hash of the asset they were compressed from, like
+// toast.js.3b1f0c9a2e4d.br, so the server ignores the ones left behind by a change. Run it before building.
+import { createHash } from "node:crypto";
+import { readdirSync, readFileSync, rmSync, writeFileSync } from "node:fs";
+import { basename, dirname, join } from "node:path";
+import { brotliCompressSync, constants, gzipSync } from "node:zlib";
+
+const root = new URL("../static", import.meta.url).pathname;
+const isVariant = (file) => /\.[0-9a-f]{12}\.(br|gz)$/.test(file);
+
+const files = readdirSync(root, { recursive: true, withFileTypes: true })
+  .filter((entry) => entry.isFile())
+  .map((entry) => join(entry.parentPath ?? entry.path, entry.name));
+
+for (const file of files.filter((file) => !isVariant(file))) {
+  const data = readFileSync(file);
+  const hash = createHash("sha256").update(data).digest("hex").slice(0, 12);
+
+  for (const stale of files.filter((other) => isVariant(other) && other.startsWith(file + ".") && !other.includes(hash))) {
+    rmSync(stale);
+  }
+
+  const variants = {
+    br: brotliCompressSync(data, { params: { [constants.BROTLI_PARAM_QUALITY]: constants.BROTLI_MAX_QUALITY } }),
+    gz: gzipSync(data, { level: 9 }),
+  };
+  for (const [ext, compressed] of Object.entries(variants)) {
+    if (compressed.length < data.length) {
+      writeFileSync(join(dirname(file), `${basename(file)}.${hash}.${ext}`), compressed);
+    }
+  }
+}
@@ -5,20 +5,46 @@ class Toast {
    * A class representing a Toast notification.
    * @param variant {("info"|"success"|"warning"|"error")}
    * @param message { string }
+   * @param options {{ title?: string, duration?: number, dismissible?: boolean }}
    */
-  constructor(variant, message) {
+  constructor(variant, message, { title, duration, dismissible } = {}) {
     this.variant = variant
     this.message = message
+    this.title = title
+    this.duration = duration
+    this.dismissible = dismissible
   }
 
   /**
-   * Makes the toast element. A span element containing the entire notification.
+   * Makes the toast element. A span element containing the entire notification, with the same markup as the
+   * toasts pushed over the live event stream (see commons/toast.html).
    * @returns {HTMLSpanElement}
    */
   #makeToastElement() {
     const span = document.createElement('span')
     span.className = `toast toast-${this.variant}`
-    span.textContent = this.message
+    if (this.duration) {
+      span.dataset.duration = this.duration
+    }
+
+    if (this.title) {
+      const title = document.createElement('strong')
+      title.className = 'toast-title'
+      title.textContent = this.title
+      span.appendChild(title)
+    }
+
+    span.appendChild(document.createTextNode(this.message))
+
+    if (this.dismissible) {
+      const button = document.createElement('button')
+      button.type = 'button'
+      button.className = 'toast-close'
+      button.setAttribute('aria-label', 'Dismiss')
+      button.textContent = '×'
+      span.appendChild(button)
+    }
+
     return span
   }
 
@@ -34,47 +60,84 @@ class Toast {
 /**
  * Listen for the custom 'triggerToast' event.
  *
- * The 'triggerToast' event is triggered by an htmx response header, specifically the "HX-Trigger" response header.
- * When this event is detected, a new Toast object is created using the event details (variant and message).
- * The toast notification is then displayed using the toast.show() method.
+ * The 'triggerToast' event is triggered by an htmx response header: "HX-Trigger", or "HX-Trigger-After-Swap" and
+ * "HX-Trigger-After-Settle" for toasts that wait for the swap. Its detail is a single toast, or an array of toasts
+ * when a response has several, which htmx passes along as the detail's value.
+ * A Toast object is created and shown for each of them, in order.
  */
 document.addEventListener('triggerToast', (e) => {
-  const toast = new Toast(e.detail.variant, e.detail.message)
-  toast.show()
+  const toasts = Array.isArray(e.detail.value) ? e.detail.value : [e.detail]
+
+  for (const { variant, message, ...options } of toasts) {
+    const toast = new Toast(variant, message, options)
+    toast.show()
+  }
 })
 
 /**
  * Handle toast notifications with animations and automatic removal.
  *
  * Constants:
- * - TOAST_DISPLAY_TIME: Total display time (in milliseconds).
+ * - TOAST_DISPLAY_TIME: Default total display time (in milliseconds), which toasts can override with data-duration.
  * - TOAST_TRANSITION_TIME: Transition time (in milliseconds).
  *
- * The MutationObserver observes changes in the toast container:
- * - Triggers 'slide-in' animation shortly after a toast is added.
- * - Triggers 'fade-out' animation before the total display time elapses.
- * - Removes the toast from the DOM after the total display time.
+ * The MutationObserver observes changes in the toast container, and every toast added to it, as well as the flashes
+ * the page was rendered with, is handled by handleToast:
+ * - Triggers 'slide-in' animation shortly after the toast is added.
+ * - Triggers 'fade-out' animation before the display time elapses, or when its dismiss button is clicked.
+ * - Removes the toast from the DOM once it has faded out.
  */
 
 const TOAST_DISPLAY_TIME = 2500
 const TOAST_TRANSITION_TIME = 500
 
-const observer = new MutationObserver((mutationList) => {
-  const addedToast = mutationList[0].addedNodes.item(0)
+/**
+ * Fades the toast out and removes it, unless that's already underway.
+ * @param toastEl {HTMLElement}
+ */
+function dismissToast(toastEl) {
+  if (toastEl.classList.contains('fade-out')) {
+    return
+  }
 
-  if (addedToast) {
-    setTimeout(() => {
-      addedToast.classList.add('slide-in')
-    }, 50)
+  toastEl.classList.add('fade-out')
+  setTimeout(() => {
+    toastEl.remove()
+  }, TOAST_TRANSITION_TIME)
+}
+
+/**
+ * Animates the toast in, and out again once its display time elapses.
+ * @param toastEl {HTMLElement}
+ */
+function handleToast(toastEl) {
+  const displayTime = Number(toastEl.dataset.duration) || TOAST_DISPLAY_TIME
+
+  setTimeout(() => {
+    toastEl.classList.add('slide-in')
+  }, 50)
 
-    setTimeout(() => {
-      addedToast.classList.add('fade-out')
-    }, TOAST_DISPLAY_TIME - TOAST_TRANSITION_TIME)
+  setTimeout(() => {
+    dismissToast(toastEl)
+  }, displayTime - TOAST_TRANSITION_TIME)
 
-    setTimeout(() => {
-      addedToast.remove()
-    }, TOAST_DISPLAY_TIME)
+  toastEl.querySelector('.toast-close')?.addEventListener('click', () => {
+    dismissToast(toastEl)
+  })
+}
+
+const observer = new MutationObserver((mutationList) => {
+  for (const mutation of mutationList) {
+    for (const addedToast of mutation.addedNodes) {
+      if (addedToast instanceof HTMLElement) {
+        handleToast(addedToast)
+      }
+    }
   }
 })
 
 observer.observe(toastContainer, { childList: true })
+
+for (const flashedToast of toastContainer.children) {
+  handleToast(flashedToast)
+}
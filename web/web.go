@@ -0,0 +1,19 @@
+// Package web embeds the files the app serves, so the binary doesn't depend on the directory it runs from.
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed templates
+var templates embed.FS
+
+//go:embed static
+var static embed.FS
+
+// Templates holds the HTML templates, named by their path under web/templates.
+var Templates, _ = fs.Sub(templates, "templates")
+
+// Static holds the assets served under /static/, named by their path under web/static.
+var Static, _ = fs.Sub(static, "static")